@@ -0,0 +1,54 @@
+// avdl converts an Avro IDL protocol to JSON without the Java toolchain, e.g.
+//
+//	avdl chat.avdl > chat.avpr
+//	avdl --schemata schemas/ chat.avdl
+//
+// With --schemata, each named type is written to <dir>/<full name>.avsc instead
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"test/avro/pkg/protocol"
+)
+
+const usage = `usage: avdl [--schemata dir] <file.avdl>
+`
+
+func main() {
+	flags := flag.NewFlagSet("avdl", flag.ExitOnError)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	schemata := flags.String("schemata", "", "write one .avsc per named type to this directory")
+	flags.Parse(os.Args[1:])
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	p, err := protocol.ParseIDLFile(flags.Arg(0))
+	exitOnError(err)
+	if *schemata == "" {
+		data, err := json.MarshalIndent(p, "", "  ")
+		exitOnError(err)
+		fmt.Println(string(data))
+		return
+	}
+
+	exitOnError(os.MkdirAll(*schemata, 0o755))
+	for _, t := range p.Types {
+		data, err := json.MarshalIndent(t, "", "  ")
+		exitOnError(err)
+		exitOnError(os.WriteFile(filepath.Join(*schemata, t.Name+".avsc"), append(data, '\n'), 0o644))
+	}
+}
+
+func exitOnError(err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "avdl: %s\n", err)
+		os.Exit(1)
+	}
+}
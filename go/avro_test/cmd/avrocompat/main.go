@@ -0,0 +1,59 @@
+// avrocompat checks a new schema against the previous versions, oldest first, and exits
+// with status 1 on incompatibilities, e.g. in CI
+//
+//	avrocompat --mode FULL_TRANSITIVE order.v1.avsc order.v2.avsc order.avsc
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"test/avro/pkg/compat"
+	"test/avro/pkg/schema"
+)
+
+const usage = `usage: avrocompat [--mode mode] <previous.avsc>... <new.avsc>
+
+modes: NONE, BACKWARD, BACKWARD_TRANSITIVE, FORWARD, FORWARD_TRANSITIVE, FULL, FULL_TRANSITIVE
+`
+
+func main() {
+	flags := flag.NewFlagSet("avrocompat", flag.ExitOnError)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	modeName := flags.String("mode", string(compat.Backward), "compatibility mode")
+	flags.Parse(os.Args[1:])
+	if flags.NArg() < 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	mode, err := compat.ParseMode(*modeName)
+	exitOnError(err)
+
+	var schemas []*schema.Schema
+	for _, path := range flags.Args() {
+		data, err := os.ReadFile(path)
+		exitOnError(err)
+		s, err := schema.Parse(data)
+		if err != nil {
+			exitOnError(fmt.Errorf("%s: %w", path, err))
+		}
+		schemas = append(schemas, s)
+	}
+
+	previous, candidate := schemas[:len(schemas)-1], schemas[len(schemas)-1]
+	res := compat.Check(mode, candidate, previous)
+	for _, i := range res {
+		fmt.Printf("%s: %s\n", flags.Arg(i.Version), i)
+	}
+	if len(res) > 0 {
+		os.Exit(1)
+	}
+}
+
+func exitOnError(err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "avrocompat: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,53 @@
+// avrogen generates Go types with MarshalAvro and UnmarshalAvro methods from Avro
+// schemas, e.g.
+//
+//	avrogen --package events -o events.gen.go events.avdl longlist.avsc
+//
+// See the gen package for the mapping of Avro types
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"test/avro/pkg/gen"
+	"test/avro/pkg/schema"
+)
+
+const usage = `usage: avrogen [--package name] [-o file.go] <file.avsc|file.avpr|file.avdl>...
+`
+
+func main() {
+	flags := flag.NewFlagSet("avrogen", flag.ExitOnError)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	pkg := flags.String("package", "avro", "package of the generated file")
+	output := flags.String("o", "", "generated file, stdout by default")
+	flags.Parse(os.Args[1:])
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	var roots []*schema.Schema
+	for _, path := range flags.Args() {
+		schemas, err := gen.Load(path)
+		exitOnError(err)
+		roots = append(roots, schemas...)
+	}
+	source, err := gen.Generate(*pkg, roots)
+	exitOnError(err)
+	if *output == "" {
+		_, err = os.Stdout.Write(source)
+	} else {
+		err = os.WriteFile(*output, source, 0o644)
+	}
+	exitOnError(err)
+}
+
+func exitOnError(err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "avrogen: %s\n", err)
+		os.Exit(1)
+	}
+}
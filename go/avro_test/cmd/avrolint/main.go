@@ -0,0 +1,74 @@
+// avrolint validates .avsc, .avpr and .avdl files and flags questionable patterns, with
+// the position of each problem. It exits with status 1 on problems, e.g. in CI
+//
+//	avrolint schemas/*.avsc
+//	avrolint --disable non-portable-name,namespace-collision orders.avdl
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"test/avro/pkg/lint"
+)
+
+const usage = `usage: avrolint [--disable rule,...] <file>...
+
+rules: invalid, default-mismatch, unbounded-recursion, logical-type, namespace-collision,
+non-portable-name
+`
+
+func main() {
+	flags := flag.NewFlagSet("avrolint", flag.ExitOnError)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	disable := flags.String("disable", "", "comma-separated rules to skip")
+	flags.Parse(os.Args[1:])
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	disabled := make(map[lint.Rule]bool)
+	for _, name := range strings.Split(*disable, ",") {
+		if name == "" {
+			continue
+		}
+		if !isRule(lint.Rule(name)) {
+			exitOnError(fmt.Errorf("unknown rule %s", name))
+		}
+		disabled[lint.Rule(name)] = true
+	}
+
+	failed := false
+	for _, path := range flags.Args() {
+		problems, err := lint.File(path)
+		exitOnError(err)
+		for _, p := range problems {
+			if !disabled[p.Rule] {
+				fmt.Println(p)
+				failed = true
+			}
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func isRule(rule lint.Rule) bool {
+	for _, r := range lint.Rules {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+func exitOnError(err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "avrolint: %s\n", err)
+		os.Exit(1)
+	}
+}
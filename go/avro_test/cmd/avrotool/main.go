@@ -0,0 +1,238 @@
+// avrotool inspects and rewrites Avro object container files without the Java
+// avro-tools, e.g.
+//
+//	avrotool cat --limit 10 orders.avro
+//	avrotool count orders-*.avro
+//	avrotool recodec --codec snappy orders.avro orders.snappy.avro
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"test/avro/pkg/ocf"
+	"test/avro/pkg/textual"
+)
+
+const usage = `usage: avrotool <command> [arguments]
+
+commands:
+  cat [--offset n] [--limit n] [--plain] [--omit-defaults] <file.avro>...
+                                                print the items as JSON, one per line
+  schema <file.avro>                            print the schema
+  count <file.avro>...                          print the number of items
+  concat [--codec codec] -o <out.avro> <file.avro>...
+                                                concatenate files with the same schema
+  recodec --codec codec <in.avro> <out.avro>    rewrite a file with another codec
+
+codecs: null, deflate, snappy
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	commands := map[string]func(args []string){
+		"cat":     cat,
+		"schema":  printSchema,
+		"count":   count,
+		"concat":  concat,
+		"recodec": recodec,
+	}
+	command, found := commands[os.Args[1]]
+	if !found {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	command(os.Args[2:])
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet("avrotool "+name, flag.ExitOnError)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	return flags
+}
+
+// parse parses args and exits if there are fewer than min positional arguments, or more
+// than max unless max is -1
+func parse(flags *flag.FlagSet, args []string, min int, max int) {
+	flags.Parse(args)
+	if flags.NArg() < min || (max >= 0 && flags.NArg() > max) {
+		flags.Usage()
+		os.Exit(2)
+	}
+}
+
+func open(path string) (*os.File, *ocf.Reader) {
+	f, err := os.Open(path)
+	exitOnError(err)
+	r, err := ocf.NewReader(f)
+	if err != nil {
+		exitOnError(fmt.Errorf("%s: %w", path, err))
+	}
+	return f, r
+}
+
+func cat(args []string) {
+	flags := newFlagSet("cat")
+	offset := flags.Int64("offset", 0, "start at the first block at or after this byte offset")
+	limit := flags.Int("limit", -1, "maximum number of items to print")
+	plain := flags.Bool("plain", false, "print unions without their type wrapper")
+	omitDefaults := flags.Bool("omit-defaults", false, "leave out the fields with their default value")
+	parse(flags, args, 1, -1)
+	options := textual.Options{Plain: *plain, OmitDefaults: *omitDefaults}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	printed := 0
+	for _, path := range flags.Args() {
+		f, r := open(path)
+		codec, err := textual.NewCodec(r.Header().Schema, options)
+		exitOnError(err)
+		if *offset > 0 {
+			exitOnError(r.SeekSync(*offset))
+		}
+		for (*limit < 0 || printed < *limit) && r.Scan() {
+			data, err := codec.TextualFromNative(nil, r.Native())
+			exitOnError(err)
+			out.Write(append(data, '\n'))
+			printed++
+		}
+		if err := r.Err(); err != nil {
+			exitOnError(fmt.Errorf("%s: %w", path, err))
+		}
+		f.Close()
+	}
+}
+
+func printSchema(args []string) {
+	flags := newFlagSet("schema")
+	parse(flags, args, 1, 1)
+	f, r := open(flags.Arg(0))
+	defer f.Close()
+	data, err := json.MarshalIndent(r.Header().Schema, "", "  ")
+	exitOnError(err)
+	fmt.Println(string(data))
+}
+
+func count(args []string) {
+	flags := newFlagSet("count")
+	parse(flags, args, 1, -1)
+	for _, path := range flags.Args() {
+		f, r := open(path)
+		n, err := countItems(r)
+		if err != nil {
+			exitOnError(fmt.Errorf("%s: %w", path, err))
+		}
+		f.Close()
+		if flags.NArg() == 1 {
+			fmt.Println(n)
+		} else {
+			fmt.Printf("%d %s\n", n, path)
+		}
+	}
+}
+
+// countItems sums the counts of the blocks, without decoding the items
+func countItems(r *ocf.Reader) (int64, error) {
+	var n int64
+	for {
+		block, err := r.NextBlock()
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		n += block.Count
+	}
+}
+
+func concat(args []string) {
+	flags := newFlagSet("concat")
+	codec := flags.String("codec", "", "codec of the output, the one of the first file by default")
+	output := flags.String("o", "", "output file")
+	parse(flags, args, 1, -1)
+	if *output == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	f, first := open(flags.Arg(0))
+	f.Close()
+	options := ocf.Options{Codec: first.Header().Codec, Metadata: userMetadata(first.Header())}
+	if *codec != "" {
+		options.Codec = ocf.Codec(*codec)
+	}
+	copyBlocks(*output, first.Header(), options, flags.Args())
+}
+
+func recodec(args []string) {
+	flags := newFlagSet("recodec")
+	codec := flags.String("codec", "", "codec of the output")
+	parse(flags, args, 2, 2)
+	if *codec == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	f, in := open(flags.Arg(0))
+	f.Close()
+	options := ocf.Options{Codec: ocf.Codec(*codec), Metadata: userMetadata(in.Header())}
+	copyBlocks(flags.Arg(1), in.Header(), options, flags.Args()[:1])
+}
+
+// userMetadata is the metadata of header without the reserved keys
+func userMetadata(header *ocf.Header) map[string][]byte {
+	metadata := make(map[string][]byte)
+	for key, value := range header.Metadata {
+		if key != ocf.SchemaKey && key != ocf.CodecKey {
+			metadata[key] = value
+		}
+	}
+	return metadata
+}
+
+// copyBlocks writes the blocks of inputs, which must have the schema of header, to output
+func copyBlocks(output string, header *ocf.Header, options ocf.Options, inputs []string) {
+	_, err := ocf.ParseCodec(string(options.Codec))
+	exitOnError(err)
+	out, err := os.Create(output)
+	exitOnError(err)
+	buffered := bufio.NewWriter(out)
+	w, err := ocf.NewWriter(buffered, header.Schema, options)
+	exitOnError(err)
+
+	expected := header.Schema.String()
+	for _, path := range inputs {
+		f, r := open(path)
+		if r.Header().Schema.String() != expected {
+			exitOnError(fmt.Errorf("%s: the schema differs from the one of %s", path, inputs[0]))
+		}
+		for {
+			block, err := r.NextBlock()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				exitOnError(fmt.Errorf("%s: %w", path, err))
+			}
+			exitOnError(w.WriteBlock(block))
+		}
+		f.Close()
+	}
+	exitOnError(buffered.Flush())
+	exitOnError(out.Close())
+}
+
+func exitOnError(err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "avrotool: %s\n", err)
+		os.Exit(1)
+	}
+}
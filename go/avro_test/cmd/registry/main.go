@@ -0,0 +1,59 @@
+// registry serves a local schema registry with the REST API of the Confluent Schema
+// Registry, saved to a directory, e.g.
+//
+//	registry --addr localhost:8081 --dir .registry
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"test/avro/pkg/compat"
+	"test/avro/pkg/registry"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:8081", "listen address")
+	dir := flag.String("dir", ".registry", "directory of the registry data")
+	mode := flag.String("mode", "", "global compatibility mode, kept from the data by default")
+	flag.Parse()
+
+	r, err := registry.Open(*dir)
+	exitOnError(err)
+	if *mode != "" {
+		parsed, err := compat.ParseMode(*mode)
+		exitOnError(err)
+		exitOnError(r.SetMode("", parsed))
+	}
+
+	server := &http.Server{Addr: *addr, Handler: registry.NewHandler(r)}
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		<-signals
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+
+	log.Printf("schema registry listening on %s, data in %s", *addr, *dir)
+	err = server.ListenAndServe()
+	if !errors.Is(err, http.ErrServerClosed) {
+		exitOnError(err)
+	}
+}
+
+func exitOnError(err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "registry: %s\n", err)
+		os.Exit(1)
+	}
+}
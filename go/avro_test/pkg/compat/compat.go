@@ -0,0 +1,306 @@
+// Package compat checks that schema changes keep the data readable, per the schema
+// resolution rules of https://avro.apache.org/docs/1.11.1/specification/#schema-resolution
+package compat
+
+import (
+	"fmt"
+	"strings"
+
+	"test/avro/pkg/schema"
+)
+
+// Mode is a compatibility level, named as in the Confluent Schema Registry
+type Mode string
+
+const (
+	None Mode = "NONE"
+	// Backward checks that the new schema reads the data of the latest one
+	Backward           Mode = "BACKWARD"
+	BackwardTransitive Mode = "BACKWARD_TRANSITIVE"
+	// Forward checks that the latest schema reads the data of the new one
+	Forward           Mode = "FORWARD"
+	ForwardTransitive Mode = "FORWARD_TRANSITIVE"
+	// Full is both Backward and Forward
+	Full           Mode = "FULL"
+	FullTransitive Mode = "FULL_TRANSITIVE"
+)
+
+var modes = []Mode{None, Backward, BackwardTransitive, Forward, ForwardTransitive, Full, FullTransitive}
+
+// ParseMode parses a mode, case insensitive
+func ParseMode(s string) (Mode, error) {
+	for _, mode := range modes {
+		if strings.EqualFold(s, string(mode)) {
+			return mode, nil
+		}
+	}
+	return "", fmt.Errorf("unknown compatibility mode %q", s)
+}
+
+func (m Mode) backward() bool {
+	return m == Backward || m == BackwardTransitive || m == Full || m == FullTransitive
+}
+
+func (m Mode) forward() bool {
+	return m == Forward || m == ForwardTransitive || m == Full || m == FullTransitive
+}
+
+func (m Mode) transitive() bool {
+	return m == BackwardTransitive || m == ForwardTransitive || m == FullTransitive
+}
+
+// Kind is the rule an incompatibility breaks
+type Kind string
+
+const (
+	TypeMismatch       Kind = "TYPE_MISMATCH"
+	NameMismatch       Kind = "NAME_MISMATCH"
+	FixedSizeMismatch  Kind = "FIXED_SIZE_MISMATCH"
+	MissingEnumSymbols Kind = "MISSING_ENUM_SYMBOLS"
+	MissingDefault     Kind = "READER_FIELD_MISSING_DEFAULT_VALUE"
+	MissingUnionBranch Kind = "MISSING_UNION_BRANCH"
+)
+
+// Direction tells which schema reads the data of the other one in Check
+type Direction string
+
+const (
+	// NewReadsOld is a backward check
+	NewReadsOld Direction = "backward"
+	// OldReadsNew is a forward check
+	OldReadsNew Direction = "forward"
+)
+
+// Incompatibility is a reason why the reader can't read the data of the writer
+type Incompatibility struct {
+	Kind Kind
+	// Path is where the problem is in the reader, e.g. $.items[].price; [] are the items
+	// of an array and {} the values of a map
+	Path    string
+	Message string
+	// Version and Direction are set by Check: Version is the index of the other schema in
+	// the previous schemas
+	Version   int
+	Direction Direction
+}
+
+func (i Incompatibility) String() string {
+	if i.Direction != "" {
+		return fmt.Sprintf("%s with version %d: %s: %s", i.Direction, i.Version, i.Path, i.Message)
+	}
+	return fmt.Sprintf("%s: %s", i.Path, i.Message)
+}
+
+// Incompatibilities are all the problems found
+type Incompatibilities []Incompatibility
+
+func (l Incompatibilities) Error() string {
+	messages := make([]string, 0, len(l))
+	for _, i := range l {
+		messages = append(messages, i.String())
+	}
+	return strings.Join(messages, "\n")
+}
+
+// Err returns l as an error, nil if there is no incompatibility
+func (l Incompatibilities) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
+
+// Check checks a new schema against the previous ones, oldest first, in mode
+func Check(mode Mode, candidate *schema.Schema, previous []*schema.Schema) Incompatibilities {
+	var res Incompatibilities
+	if mode == None || len(previous) == 0 {
+		return res
+	}
+	first := len(previous) - 1
+	if mode.transitive() {
+		first = 0
+	}
+	for version := first; version < len(previous); version++ {
+		if mode.backward() {
+			for _, i := range CanRead(candidate, previous[version]) {
+				i.Version, i.Direction = version, NewReadsOld
+				res = append(res, i)
+			}
+		}
+		if mode.forward() {
+			for _, i := range CanRead(previous[version], candidate) {
+				i.Version, i.Direction = version, OldReadsNew
+				res = append(res, i)
+			}
+		}
+	}
+	return res
+}
+
+// CanRead checks that the data written with writer can be read with reader
+func CanRead(reader *schema.Schema, writer *schema.Schema) Incompatibilities {
+	c := &checker{checked: make(map[[2]*schema.Schema]bool)}
+	c.check(reader, writer, "$")
+	return c.res
+}
+
+type checker struct {
+	res Incompatibilities
+	// checked are the pairs of named types already checked, or being checked for
+	// recursive types
+	checked map[[2]*schema.Schema]bool
+}
+
+func (c *checker) add(kind Kind, path string, format string, args ...interface{}) {
+	c.res = append(c.res, Incompatibility{Kind: kind, Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// promotions are the writer types each reader type reads besides itself
+var promotions = map[schema.Type][]schema.Type{
+	schema.Long:   {schema.Int},
+	schema.Float:  {schema.Int, schema.Long},
+	schema.Double: {schema.Int, schema.Long, schema.Float},
+	schema.String: {schema.Bytes},
+	schema.Bytes:  {schema.String},
+}
+
+func promotes(reader schema.Type, writer schema.Type) bool {
+	for _, t := range promotions[reader] {
+		if t == writer {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *checker) check(reader *schema.Schema, writer *schema.Schema, path string) {
+	if writer.Type == schema.Union {
+		// Every branch may have been written
+		for _, branch := range writer.Branches {
+			c.check(reader, branch, path)
+		}
+		return
+	}
+	if reader.Type == schema.Union {
+		for _, branch := range reader.Branches {
+			if c.reads(branch, writer) {
+				return
+			}
+		}
+		c.add(MissingUnionBranch, path, "no branch of the union reads %s", describe(writer))
+		return
+	}
+
+	if reader.Type != writer.Type && !(isRecord(reader) && isRecord(writer)) {
+		if !promotes(reader.Type, writer.Type) {
+			c.add(TypeMismatch, path, "%s can't read %s", describe(reader), describe(writer))
+		}
+		return
+	}
+	if reader.Type.IsNamed() {
+		key := [2]*schema.Schema{reader, writer}
+		if c.checked[key] {
+			return
+		}
+		c.checked[key] = true
+		if !namesMatch(reader, writer) {
+			c.add(NameMismatch, path, "%s can't read %s, add an alias to read it", describe(reader), describe(writer))
+			return
+		}
+	}
+
+	switch reader.Type {
+	case schema.Record, schema.ErrorRecord:
+		c.checkRecord(reader, writer, path)
+	case schema.Enum:
+		var missing []string
+		for _, symbol := range writer.Symbols {
+			if !contains(reader.Symbols, symbol) {
+				missing = append(missing, symbol)
+			}
+		}
+		if len(missing) > 0 && reader.EnumDefault == "" {
+			c.add(MissingEnumSymbols, path, "%s has no symbol %s and no default", describe(reader), strings.Join(missing, ", "))
+		}
+	case schema.Fixed:
+		if reader.Size != writer.Size {
+			c.add(FixedSizeMismatch, path, "%s has size %d, the writer has size %d", describe(reader), reader.Size, writer.Size)
+		}
+	case schema.Array:
+		c.check(reader.Items, writer.Items, path+"[]")
+	case schema.Map:
+		c.check(reader.Values, writer.Values, path+"{}")
+	}
+}
+
+// reads tries a union branch. The pairs of c aren't checked again, and the pairs of the
+// try aren't remembered since its incompatibilities are dropped
+func (c *checker) reads(reader *schema.Schema, writer *schema.Schema) bool {
+	try := &checker{checked: make(map[[2]*schema.Schema]bool, len(c.checked))}
+	for key := range c.checked {
+		try.checked[key] = true
+	}
+	try.check(reader, writer, "")
+	return len(try.res) == 0
+}
+
+func (c *checker) checkRecord(reader *schema.Schema, writer *schema.Schema, path string) {
+	for _, field := range reader.Fields {
+		fieldPath := path + "." + field.Name
+		written := writerField(field, writer)
+		if written == nil {
+			if !field.HasDefault {
+				c.add(MissingDefault, fieldPath, "field %s isn't in the writer and has no default", field.Name)
+			}
+			continue
+		}
+		c.check(field.Type, written.Type, fieldPath)
+	}
+}
+
+// writerField is the writer field read by field, by name or alias
+func writerField(field *schema.Field, writer *schema.Schema) *schema.Field {
+	if written := writer.Field(field.Name); written != nil {
+		return written
+	}
+	for _, alias := range field.Aliases {
+		if written := writer.Field(alias); written != nil {
+			return written
+		}
+	}
+	return nil
+}
+
+func isRecord(s *schema.Schema) bool {
+	return s.Type == schema.Record || s.Type == schema.ErrorRecord
+}
+
+// namesMatch compares the unqualified names, the reader reads its aliases
+func namesMatch(reader *schema.Schema, writer *schema.Schema) bool {
+	name := writer.ShortName()
+	if reader.ShortName() == name {
+		return true
+	}
+	for _, alias := range reader.Aliases {
+		if _, short := schema.SplitName(alias); short == name {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(symbols []string, symbol string) bool {
+	for _, s := range symbols {
+		if s == symbol {
+			return true
+		}
+	}
+	return false
+}
+
+func describe(s *schema.Schema) string {
+	if s.Type.IsNamed() {
+		return fmt.Sprintf("%s %s", s.Type, s.Name)
+	}
+	return string(s.Type)
+}
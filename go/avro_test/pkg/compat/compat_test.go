@@ -0,0 +1,137 @@
+package compat
+
+import (
+	"testing"
+
+	"test/avro/pkg/schema"
+
+	"github.com/stretchr/testify/require"
+)
+
+func parse(t *testing.T, s string) *schema.Schema {
+	parsed, err := schema.Parse([]byte(s))
+	require.NoError(t, err)
+	return parsed
+}
+
+const order = `{"type": "record", "name": "Order", "fields": [
+	{"name": "id", "type": "string"},
+	{"name": "quantity", "type": "int"}
+]}`
+
+func TestCanRead(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		reader string
+		writer string
+		kind   Kind
+		path   string
+	}{
+		{name: "same schema", reader: order, writer: order},
+		{
+			name:   "added field with default",
+			reader: `{"type": "record", "name": "Order", "fields": [{"name": "id", "type": "string"}, {"name": "quantity", "type": "int"}, {"name": "note", "type": ["null", "string"], "default": null}]}`,
+			writer: order,
+		},
+		{
+			name:   "added field without default",
+			reader: `{"type": "record", "name": "Order", "fields": [{"name": "id", "type": "string"}, {"name": "quantity", "type": "int"}, {"name": "note", "type": "string"}]}`,
+			writer: order,
+			kind:   MissingDefault,
+			path:   "$.note",
+		},
+		{
+			name:   "removed field",
+			reader: `{"type": "record", "name": "Order", "fields": [{"name": "id", "type": "string"}]}`,
+			writer: order,
+		},
+		{
+			name:   "promotion",
+			reader: `{"type": "record", "name": "Order", "fields": [{"name": "id", "type": "bytes"}, {"name": "quantity", "type": "double"}]}`,
+			writer: order,
+		},
+		{
+			name:   "narrowing",
+			reader: order,
+			writer: `{"type": "record", "name": "Order", "fields": [{"name": "id", "type": "string"}, {"name": "quantity", "type": "long"}]}`,
+			kind:   TypeMismatch,
+			path:   "$.quantity",
+		},
+		{
+			name:   "renamed field with alias",
+			reader: `{"type": "record", "name": "Order", "fields": [{"name": "id", "type": "string"}, {"name": "count", "type": "int", "aliases": ["quantity"]}]}`,
+			writer: order,
+		},
+		{
+			name:   "renamed record",
+			reader: `{"type": "record", "name": "Purchase", "fields": [{"name": "id", "type": "string"}]}`,
+			writer: order,
+			kind:   NameMismatch,
+			path:   "$",
+		},
+		{
+			name:   "renamed record with alias",
+			reader: `{"type": "record", "name": "shop.Purchase", "aliases": ["old.Order"], "fields": [{"name": "id", "type": "string"}]}`,
+			writer: order,
+		},
+		{name: "union widening", reader: `["null", "string", "long"]`, writer: `["null", "string"]`},
+		{name: "writer branch promoted", reader: `["null", "double"]`, writer: `"int"`},
+		{name: "union narrowing", reader: `["null", "string"]`, writer: `["null", "string", "long"]`, kind: MissingUnionBranch, path: "$"},
+		{name: "reader union", reader: `["null", "string"]`, writer: `"long"`, kind: MissingUnionBranch, path: "$"},
+		{name: "writer union", reader: `"string"`, writer: `["null", "string"]`, kind: TypeMismatch, path: "$"},
+		{name: "added symbol", reader: `{"type": "enum", "name": "E", "symbols": ["A", "B", "C"]}`, writer: `{"type": "enum", "name": "E", "symbols": ["A", "B"]}`},
+		{name: "removed symbol", reader: `{"type": "enum", "name": "E", "symbols": ["A"]}`, writer: `{"type": "enum", "name": "E", "symbols": ["A", "B"]}`, kind: MissingEnumSymbols, path: "$"},
+		{name: "removed symbol with default", reader: `{"type": "enum", "name": "E", "symbols": ["A"], "default": "A"}`, writer: `{"type": "enum", "name": "E", "symbols": ["A", "B"]}`},
+		{name: "fixed size", reader: `{"type": "fixed", "name": "F", "size": 8}`, writer: `{"type": "fixed", "name": "F", "size": 4}`, kind: FixedSizeMismatch, path: "$"},
+		{name: "array items", reader: `{"type": "array", "items": "int"}`, writer: `{"type": "array", "items": "long"}`, kind: TypeMismatch, path: "$[]"},
+		{name: "map values", reader: `{"type": "map", "values": {"type": "array", "items": "long"}}`, writer: `{"type": "map", "values": {"type": "array", "items": "int"}}`},
+		{
+			name:   "recursive",
+			reader: `{"type": "record", "name": "LongList", "fields": [{"name": "next", "type": ["null", "LongList"], "default": null}, {"name": "value", "type": "long", "default": 0}]}`,
+			writer: `{"type": "record", "name": "LongList", "fields": [{"name": "next", "type": ["null", "LongList"], "default": null}]}`,
+		},
+	} {
+		res := CanRead(parse(t, c.reader), parse(t, c.writer))
+		if c.kind == "" {
+			require.Empty(t, res, c.name)
+			continue
+		}
+		require.Equal(t, 1, len(res), c.name)
+		require.Equal(t, c.kind, res[0].Kind, c.name)
+		require.Equal(t, c.path, res[0].Path, c.name)
+	}
+}
+
+func TestCheckModes(t *testing.T) {
+	v1 := parse(t, `{"type": "record", "name": "R", "fields": [{"name": "a", "type": "string"}]}`)
+	v2 := parse(t, `{"type": "record", "name": "R", "fields": [{"name": "a", "type": "string"}, {"name": "b", "type": "int", "default": 0}]}`)
+	// Drops a, which has no default in v1 and v2
+	v3 := parse(t, `{"type": "record", "name": "R", "fields": [{"name": "b", "type": "int", "default": 0}]}`)
+
+	require.Empty(t, Check(Backward, v2, []*schema.Schema{v1}))
+	require.Empty(t, Check(Forward, v2, []*schema.Schema{v1}))
+	require.Empty(t, Check(Full, v2, []*schema.Schema{v1}))
+
+	require.Empty(t, Check(Backward, v3, []*schema.Schema{v1, v2}))
+	res := Check(Forward, v3, []*schema.Schema{v1, v2})
+	require.Equal(t, 1, len(res))
+	require.Equal(t, Incompatibility{Kind: MissingDefault, Path: "$.a", Message: "field a isn't in the writer and has no default", Version: 1, Direction: OldReadsNew}, res[0])
+	require.EqualError(t, res.Err(), "forward with version 1: $.a: field a isn't in the writer and has no default")
+
+	// Only transitive modes check v1
+	res = Check(FullTransitive, v3, []*schema.Schema{v1, v2})
+	require.Equal(t, 2, len(res))
+	require.Equal(t, 0, res[0].Version)
+	require.Equal(t, 1, res[1].Version)
+
+	require.Empty(t, Check(None, v3, []*schema.Schema{v1, v2}))
+	require.Empty(t, Check(FullTransitive, v3, nil))
+}
+
+func TestParseMode(t *testing.T) {
+	mode, err := ParseMode("backward_transitive")
+	require.NoError(t, err)
+	require.Equal(t, BackwardTransitive, mode)
+	_, err = ParseMode("SIDEWAYS")
+	require.EqualError(t, err, `unknown compatibility mode "SIDEWAYS"`)
+}
@@ -0,0 +1,418 @@
+// Code generated by avrogen. DO NOT EDIT.
+
+package example
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+// LongList is the Avro record LongList
+type LongList struct {
+	Next LongListNext `avro:"next"`
+}
+
+const longListSchema = `{"type":"record","name":"LongList","fields":[{"name":"next","type":["null","LongList",{"type":"long","logicalType":"timestamp-millis"}],"default":null}]}`
+
+var longListCodec = mustCodec(longListSchema)
+
+// MarshalAvro returns the Avro binary encoding of r
+func (r *LongList) MarshalAvro() ([]byte, error) {
+	return longListCodec.BinaryFromNative(nil, r.toNative())
+}
+
+// UnmarshalAvro sets r from its Avro binary encoding
+func (r *LongList) UnmarshalAvro(data []byte) error {
+	native, _, err := longListCodec.NativeFromBinary(data)
+	if err != nil {
+		return err
+	}
+	*r = longListFromNative(native)
+	return nil
+}
+
+func (r LongList) toNative() map[string]interface{} {
+	return map[string]interface{}{
+		"next": r.Next.toNative(),
+	}
+}
+
+func longListFromNative(native interface{}) LongList {
+	fields := native.(map[string]interface{})
+	return LongList{
+		Next: longListNextFromNative(fields["next"]),
+	}
+}
+
+// Status is the Avro enum example.orders.Status
+type Status string
+
+const (
+	StatusPending         Status = "PENDING"
+	StatusShipped         Status = "SHIPPED"
+	StatusCancelledByUser Status = "CANCELLED_BY_USER"
+)
+
+// Hash is the Avro fixed example.orders.Hash
+type Hash [8]byte
+
+func (f Hash) toNative() []byte {
+	return f[:]
+}
+
+func hashFromNative(native interface{}) Hash {
+	var f Hash
+	copy(f[:], native.([]byte))
+	return f
+}
+
+// A line of an order
+type Item struct {
+	Sku      string  `avro:"sku"`
+	Quantity int32   `avro:"quantity"`
+	Price    big.Rat `avro:"price"`
+}
+
+const itemSchema = `{"type":"record","name":"Item","namespace":"example.orders","doc":"A line of an order","fields":[{"name":"sku","type":"string"},{"name":"quantity","type":"int"},{"name":"price","type":{"type":"bytes","logicalType":"decimal","precision":9,"scale":2}}]}`
+
+var itemCodec = mustCodec(itemSchema)
+
+// MarshalAvro returns the Avro binary encoding of r
+func (r *Item) MarshalAvro() ([]byte, error) {
+	return itemCodec.BinaryFromNative(nil, r.toNative())
+}
+
+// UnmarshalAvro sets r from its Avro binary encoding
+func (r *Item) UnmarshalAvro(data []byte) error {
+	native, _, err := itemCodec.NativeFromBinary(data)
+	if err != nil {
+		return err
+	}
+	*r = itemFromNative(native)
+	return nil
+}
+
+func (r Item) toNative() map[string]interface{} {
+	return map[string]interface{}{
+		"sku":      r.Sku,
+		"quantity": r.Quantity,
+		"price":    new(big.Rat).Set(&r.Price),
+	}
+}
+
+func itemFromNative(native interface{}) Item {
+	fields := native.(map[string]interface{})
+	return Item{
+		Sku:      fields["sku"].(string),
+		Quantity: fields["quantity"].(int32),
+		Price:    *fields["price"].(*big.Rat),
+	}
+}
+
+// Order is the Avro record example.orders.Order
+type Order struct {
+	Id      string            `avro:"id"`
+	Status  Status            `avro:"status"`
+	Created time.Time         `avro:"created"`
+	Shipped *time.Time        `avro:"shipped"`
+	Due     time.Time         `avro:"due"`
+	Cutoff  time.Duration     `avro:"cutoff"`
+	Items   []Item            `avro:"items"`
+	Labels  map[string]string `avro:"labels"`
+	Note    *string           `avro:"note"`
+	Gift    OrderGift         `avro:"gift"`
+	Hashes  []OrderHashesItem `avro:"hashes"`
+	Payload []byte            `avro:"payload"`
+	Express bool              `avro:"express"`
+	Weight  float32           `avro:"weight"`
+	Total   float64           `avro:"total"`
+}
+
+const orderSchema = `{"type":"record","name":"Order","namespace":"example.orders","fields":[{"name":"id","type":"string"},{"name":"status","type":{"type":"enum","name":"Status","symbols":["PENDING","SHIPPED","CANCELLED_BY_USER"],"default":"PENDING"}},{"name":"created","type":{"type":"long","logicalType":"timestamp-millis"}},{"name":"shipped","type":["null",{"type":"long","logicalType":"timestamp-millis"}],"default":null},{"name":"due","type":{"type":"int","logicalType":"date"}},{"name":"cutoff","type":{"type":"int","logicalType":"time-millis"}},{"name":"items","type":{"type":"array","items":{"type":"record","name":"Item","doc":"A line of an order","fields":[{"name":"sku","type":"string"},{"name":"quantity","type":"int"},{"name":"price","type":{"type":"bytes","logicalType":"decimal","precision":9,"scale":2}}]}},"default":[]},{"name":"labels","type":{"type":"map","values":"string"},"default":{}},{"name":"note","type":["null","string"],"default":null},{"name":"gift","type":["null","Item","string",{"type":"array","items":"long"}],"default":null},{"name":"hashes","type":{"type":"array","items":[{"type":"fixed","name":"Hash","size":8},"long"]},"default":[]},{"name":"payload","type":"bytes"},{"name":"express","type":"boolean"},{"name":"weight","type":"float"},{"name":"total","type":"double"}]}`
+
+var orderCodec = mustCodec(orderSchema)
+
+// MarshalAvro returns the Avro binary encoding of r
+func (r *Order) MarshalAvro() ([]byte, error) {
+	return orderCodec.BinaryFromNative(nil, r.toNative())
+}
+
+// UnmarshalAvro sets r from its Avro binary encoding
+func (r *Order) UnmarshalAvro(data []byte) error {
+	native, _, err := orderCodec.NativeFromBinary(data)
+	if err != nil {
+		return err
+	}
+	*r = orderFromNative(native)
+	return nil
+}
+
+func (r Order) toNative() map[string]interface{} {
+	return map[string]interface{}{
+		"id":      r.Id,
+		"status":  string(r.Status),
+		"created": r.Created,
+		"shipped": func(v *time.Time) interface{} {
+			if v == nil {
+				return nil
+			}
+			return goavro.Union("long.timestamp-millis", *v)
+		}(r.Shipped),
+		"due":    r.Due,
+		"cutoff": r.Cutoff,
+		"items": func(items []Item) []interface{} {
+			native := make([]interface{}, 0, len(items))
+			for _, item := range items {
+				native = append(native, item.toNative())
+			}
+			return native
+		}(r.Items),
+		"labels": func(values map[string]string) map[string]interface{} {
+			native := make(map[string]interface{}, len(values))
+			for key, value := range values {
+				native[key] = value
+			}
+			return native
+		}(r.Labels),
+		"note": func(v *string) interface{} {
+			if v == nil {
+				return nil
+			}
+			return goavro.Union("string", *v)
+		}(r.Note),
+		"gift": r.Gift.toNative(),
+		"hashes": func(items []OrderHashesItem) []interface{} {
+			native := make([]interface{}, 0, len(items))
+			for _, item := range items {
+				native = append(native, item.toNative())
+			}
+			return native
+		}(r.Hashes),
+		"payload": r.Payload,
+		"express": r.Express,
+		"weight":  r.Weight,
+		"total":   r.Total,
+	}
+}
+
+func orderFromNative(native interface{}) Order {
+	fields := native.(map[string]interface{})
+	return Order{
+		Id:      fields["id"].(string),
+		Status:  Status(fields["status"].(string)),
+		Created: fields["created"].(time.Time),
+		Shipped: func(native interface{}) *time.Time {
+			if native == nil {
+				return nil
+			}
+			v := native.(map[string]interface{})["long.timestamp-millis"].(time.Time)
+			return &v
+		}(fields["shipped"]),
+		Due:    fields["due"].(time.Time),
+		Cutoff: fields["cutoff"].(time.Duration),
+		Items: func(native interface{}) []Item {
+			items := native.([]interface{})
+			res := make([]Item, 0, len(items))
+			for _, item := range items {
+				res = append(res, itemFromNative(item))
+			}
+			return res
+		}(fields["items"]),
+		Labels: func(native interface{}) map[string]string {
+			values := native.(map[string]interface{})
+			res := make(map[string]string, len(values))
+			for key, value := range values {
+				res[key] = value.(string)
+			}
+			return res
+		}(fields["labels"]),
+		Note: func(native interface{}) *string {
+			if native == nil {
+				return nil
+			}
+			v := native.(map[string]interface{})["string"].(string)
+			return &v
+		}(fields["note"]),
+		Gift: orderGiftFromNative(fields["gift"]),
+		Hashes: func(native interface{}) []OrderHashesItem {
+			items := native.([]interface{})
+			res := make([]OrderHashesItem, 0, len(items))
+			for _, item := range items {
+				res = append(res, orderHashesItemFromNative(item))
+			}
+			return res
+		}(fields["hashes"]),
+		Payload: fields["payload"].([]byte),
+		Express: fields["express"].(bool),
+		Weight:  fields["weight"].(float32),
+		Total:   fields["total"].(float64),
+	}
+}
+
+// Rejected is the Avro record example.orders.Rejected
+type Rejected struct {
+	Reason string `avro:"reason"`
+}
+
+const rejectedSchema = `{"type":"record","name":"Rejected","namespace":"example.orders","fields":[{"name":"reason","type":"string"}]}`
+
+var rejectedCodec = mustCodec(rejectedSchema)
+
+// MarshalAvro returns the Avro binary encoding of r
+func (r *Rejected) MarshalAvro() ([]byte, error) {
+	return rejectedCodec.BinaryFromNative(nil, r.toNative())
+}
+
+// UnmarshalAvro sets r from its Avro binary encoding
+func (r *Rejected) UnmarshalAvro(data []byte) error {
+	native, _, err := rejectedCodec.NativeFromBinary(data)
+	if err != nil {
+		return err
+	}
+	*r = rejectedFromNative(native)
+	return nil
+}
+
+func (r Rejected) toNative() map[string]interface{} {
+	return map[string]interface{}{
+		"reason": r.Reason,
+	}
+}
+
+func rejectedFromNative(native interface{}) Rejected {
+	fields := native.(map[string]interface{})
+	return Rejected{
+		Reason: fields["reason"].(string),
+	}
+}
+
+// LongListNext is a union, nil fields but one, or all nil for null
+type LongListNext struct {
+	LongList        *LongList
+	TimestampMillis *time.Time
+}
+
+func (u LongListNext) toNative() interface{} {
+	switch {
+	case u.LongList != nil:
+		return goavro.Union("LongList", u.LongList.toNative())
+	case u.TimestampMillis != nil:
+		return goavro.Union("long.timestamp-millis", *u.TimestampMillis)
+	}
+	return nil
+}
+
+func longListNextFromNative(native interface{}) LongListNext {
+	var u LongListNext
+	if native == nil {
+		return u
+	}
+	for name, value := range native.(map[string]interface{}) {
+		switch name {
+		case "LongList":
+			v := longListFromNative(value)
+			u.LongList = &v
+		case "long.timestamp-millis":
+			v := value.(time.Time)
+			u.TimestampMillis = &v
+		}
+	}
+	return u
+}
+
+// OrderGift is a union, nil fields but one, or all nil for null
+type OrderGift struct {
+	Item   *Item
+	String *string
+	Array  *[]int64
+}
+
+func (u OrderGift) toNative() interface{} {
+	switch {
+	case u.Item != nil:
+		return goavro.Union("example.orders.Item", u.Item.toNative())
+	case u.String != nil:
+		return goavro.Union("string", *u.String)
+	case u.Array != nil:
+		return goavro.Union("array", func(items []int64) []interface{} {
+			native := make([]interface{}, 0, len(items))
+			for _, item := range items {
+				native = append(native, item)
+			}
+			return native
+		}(*u.Array))
+	}
+	return nil
+}
+
+func orderGiftFromNative(native interface{}) OrderGift {
+	var u OrderGift
+	if native == nil {
+		return u
+	}
+	for name, value := range native.(map[string]interface{}) {
+		switch name {
+		case "example.orders.Item":
+			v := itemFromNative(value)
+			u.Item = &v
+		case "string":
+			v := value.(string)
+			u.String = &v
+		case "array":
+			v := func(native interface{}) []int64 {
+				items := native.([]interface{})
+				res := make([]int64, 0, len(items))
+				for _, item := range items {
+					res = append(res, item.(int64))
+				}
+				return res
+			}(value)
+			u.Array = &v
+		}
+	}
+	return u
+}
+
+// OrderHashesItem is a union, nil fields but one
+type OrderHashesItem struct {
+	Hash *Hash
+	Long *int64
+}
+
+func (u OrderHashesItem) toNative() interface{} {
+	switch {
+	case u.Hash != nil:
+		return goavro.Union("example.orders.Hash", u.Hash.toNative())
+	case u.Long != nil:
+		return goavro.Union("long", *u.Long)
+	}
+	return nil
+}
+
+func orderHashesItemFromNative(native interface{}) OrderHashesItem {
+	var u OrderHashesItem
+	if native == nil {
+		return u
+	}
+	for name, value := range native.(map[string]interface{}) {
+		switch name {
+		case "example.orders.Hash":
+			v := hashFromNative(value)
+			u.Hash = &v
+		case "long":
+			v := value.(int64)
+			u.Long = &v
+		}
+	}
+	return u
+}
+
+func mustCodec(schema string) *goavro.Codec {
+	codec, err := goavro.NewCodec(schema)
+	if err != nil {
+		panic(err)
+	}
+	return codec
+}
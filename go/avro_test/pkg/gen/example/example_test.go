@@ -0,0 +1,94 @@
+package example
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLongListRoundTrip(t *testing.T) {
+	at := time.UnixMilli(1700000000123).UTC()
+	list := LongList{Next: LongListNext{LongList: &LongList{Next: LongListNext{TimestampMillis: &at}}}}
+	data, err := list.MarshalAvro()
+	require.NoError(t, err)
+
+	var decoded LongList
+	require.NoError(t, decoded.UnmarshalAvro(data))
+	require.Equal(t, list, decoded)
+
+	// The encoding is the one of the schema in test.go
+	native, _, err := longListCodec.NativeFromBinary(data)
+	require.NoError(t, err)
+	textual, err := longListCodec.TextualFromNative(nil, native)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"next":{"LongList":{"next":{"long.timestamp-millis":1700000000123}}}}`, string(textual))
+
+	var empty LongList
+	data, err = empty.MarshalAvro()
+	require.NoError(t, err)
+	require.Equal(t, []byte{0}, data)
+}
+
+func TestOrderRoundTrip(t *testing.T) {
+	shipped := time.Date(2024, 3, 2, 10, 0, 0, 0, time.UTC)
+	note := "leave at the door"
+	order := Order{
+		Id:      "o-1",
+		Status:  StatusCancelledByUser,
+		Created: time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC),
+		Shipped: &shipped,
+		Due:     time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC),
+		Cutoff:  17 * time.Hour,
+		Items:   []Item{{Sku: "a", Quantity: 2, Price: *big.NewRat(1999, 100)}, {Sku: "b", Quantity: 1, Price: *big.NewRat(5, 1)}},
+		Labels:  map[string]string{"channel": "web"},
+		Note:    &note,
+		Gift:    OrderGift{Array: &[]int64{1, 2}},
+		Hashes:  []OrderHashesItem{{Hash: &Hash{1, 2, 3, 4, 5, 6, 7, 8}}, {Long: new(int64)}},
+		Payload: []byte{0xca, 0xfe},
+		Express: true,
+		Weight:  1.5,
+		Total:   39.98,
+	}
+	data, err := order.MarshalAvro()
+	require.NoError(t, err)
+
+	var decoded Order
+	require.NoError(t, decoded.UnmarshalAvro(data))
+	require.Equal(t, order, decoded)
+
+	// Nullable fields are nil for null
+	order.Shipped, order.Note, order.Gift = nil, nil, OrderGift{}
+	data, err = order.MarshalAvro()
+	require.NoError(t, err)
+	decoded = Order{}
+	require.NoError(t, decoded.UnmarshalAvro(data))
+	require.Nil(t, decoded.Shipped)
+	require.Nil(t, decoded.Note)
+	require.Equal(t, OrderGift{}, decoded.Gift)
+}
+
+func TestZeroDecimalEncodesAsZero(t *testing.T) {
+	order := Order{Status: StatusPending, Items: []Item{{Sku: "a"}}}
+	data, err := order.MarshalAvro()
+	require.NoError(t, err)
+
+	var decoded Order
+	require.NoError(t, decoded.UnmarshalAvro(data))
+	require.Equal(t, 1, len(decoded.Items))
+	require.Equal(t, 0, decoded.Items[0].Price.Sign())
+}
+
+func TestErrorRecordsEncodeAsRecords(t *testing.T) {
+	rejected := Rejected{Reason: "out of stock"}
+	data, err := rejected.MarshalAvro()
+	require.NoError(t, err)
+
+	codec, err := goavro.NewCodec(`{"type": "record", "name": "Rejected", "fields": [{"name": "reason", "type": "string"}]}`)
+	require.NoError(t, err)
+	native, _, err := codec.NativeFromBinary(data)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"reason": "out of stock"}, native)
+}
@@ -0,0 +1,4 @@
+// Package example has types generated from longlist.avsc and orders.avdl
+package example
+
+//go:generate go run ../../../cmd/avrogen --package example -o example.gen.go longlist.avsc orders.avdl
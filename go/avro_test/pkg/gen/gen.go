@@ -0,0 +1,465 @@
+// Package gen generates Go types for Avro schemas. Records become structs with
+// MarshalAvro and UnmarshalAvro methods backed by goavro codecs:
+//
+//   - ["null", T] unions are *T, nil for null
+//   - other unions are structs with a pointer per branch, all nil for null
+//   - enums are string types with a constant per symbol, fixed are byte arrays
+//   - timestamp-millis, timestamp-micros and date are time.Time, time-millis and
+//     time-micros are time.Duration, as in goavro natives, decimal is big.Rat, so that
+//     the zero value encodes as 0
+package gen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"test/avro/pkg/schema"
+)
+
+// Generate returns the Go source of package pkg with the types of the named types
+// reachable from roots. The generated file has helpers, so all the schemas of a package
+// are generated at once
+func Generate(pkg string, roots []*schema.Schema) ([]byte, error) {
+	g := &generator{goNames: make(map[string]string), imports: make(map[string]bool)}
+	for _, root := range roots {
+		g.collect(root, make(map[*schema.Schema]bool))
+	}
+	if err := g.assignNames(); err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	g.out = &body
+	for _, s := range g.named {
+		if err := g.namedType(s); err != nil {
+			return nil, err
+		}
+	}
+	// Unions register the unions of their branches while they are written
+	for i := 0; i < len(g.unions); i++ {
+		if err := g.unionType(g.unions[i]); err != nil {
+			return nil, err
+		}
+	}
+	if len(g.named) > 0 {
+		g.printf("func mustCodec(schema string) *goavro.Codec {\n")
+		g.printf("codec, err := goavro.NewCodec(schema)\nif err != nil {\npanic(err)\n}\nreturn codec\n}\n")
+	}
+
+	var file bytes.Buffer
+	fmt.Fprintf(&file, "// Code generated by avrogen. DO NOT EDIT.\n\npackage %s\n\n", pkg)
+	imports := []string{}
+	for path := range g.imports {
+		imports = append(imports, path)
+	}
+	sort.Strings(imports)
+	if len(g.named) > 0 {
+		imports = append(imports, "", "github.com/linkedin/goavro/v2")
+	}
+	if len(imports) > 0 {
+		file.WriteString("import (\n")
+		for _, path := range imports {
+			if path == "" {
+				file.WriteString("\n")
+				continue
+			}
+			fmt.Fprintf(&file, "%q\n", path)
+		}
+		file.WriteString(")\n\n")
+	}
+	file.Write(body.Bytes())
+
+	source, err := format.Source(file.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generated invalid code: %w", err)
+	}
+	return source, nil
+}
+
+type generator struct {
+	out *bytes.Buffer
+	// named are the named types in definition order
+	named   []*schema.Schema
+	goNames map[string]string
+	unions  []union
+	imports map[string]bool
+}
+
+// union is a union type, named after where it's used
+type union struct {
+	name   string
+	schema *schema.Schema
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(g.out, format, args...)
+}
+
+func (g *generator) collect(s *schema.Schema, seen map[*schema.Schema]bool) {
+	if seen[s] {
+		return
+	}
+	seen[s] = true
+	if s.Type.IsNamed() {
+		if _, found := g.goNames[s.Name]; found {
+			return
+		}
+		g.goNames[s.Name] = ""
+		g.named = append(g.named, s)
+	}
+	for _, field := range s.Fields {
+		g.collect(field.Type, seen)
+	}
+	for _, branch := range s.Branches {
+		g.collect(branch, seen)
+	}
+	if s.Items != nil {
+		g.collect(s.Items, seen)
+	}
+	if s.Values != nil {
+		g.collect(s.Values, seen)
+	}
+}
+
+func (g *generator) assignNames() error {
+	byGoName := make(map[string]string)
+	for _, s := range g.named {
+		name := exported(s.ShortName())
+		if other, found := byGoName[name]; found {
+			return fmt.Errorf("%s and %s are both generated as %s", other, s.Name, name)
+		}
+		byGoName[name] = s.Name
+		g.goNames[s.Name] = name
+	}
+	return nil
+}
+
+// exported converts an Avro name to an exported Go name, e.g. created_at to CreatedAt
+func exported(name string) string {
+	var b strings.Builder
+	for _, part := range strings.Split(name, "_") {
+		if part == "" {
+			continue
+		}
+		if strings.ToUpper(part) == part {
+			// SYMBOL_NAME
+			part = strings.ToLower(part)
+		}
+		runes := []rune(part)
+		runes[0] = unicode.ToUpper(runes[0])
+		b.WriteString(string(runes))
+	}
+	if b.Len() == 0 {
+		return "X"
+	}
+	return b.String()
+}
+
+func unexported(name string) string {
+	runes := []rune(name)
+	runes[0] = unicode.ToLower(runes[0])
+	return string(runes)
+}
+
+func isDecimal(s *schema.Schema) bool {
+	return s.LogicalType == "decimal" && (s.Type == schema.Bytes || s.Type == schema.Fixed)
+}
+
+// logicalGoType is the Go type of the logical types goavro converts, the one of their
+// native but for decimal, whose native is a *big.Rat
+func (g *generator) logicalGoType(s *schema.Schema) string {
+	switch {
+	case isDecimal(s):
+		g.imports["math/big"] = true
+		return "big.Rat"
+	case s.Type == schema.Long && (s.LogicalType == "timestamp-millis" || s.LogicalType == "timestamp-micros"),
+		s.Type == schema.Int && s.LogicalType == "date":
+		g.imports["time"] = true
+		return "time.Time"
+	case s.Type == schema.Int && s.LogicalType == "time-millis",
+		s.Type == schema.Long && s.LogicalType == "time-micros":
+		g.imports["time"] = true
+		return "time.Duration"
+	}
+	return ""
+}
+
+var primitiveGoTypes = map[schema.Type]string{
+	schema.Null:    "struct{}",
+	schema.Boolean: "bool",
+	schema.Int:     "int32",
+	schema.Long:    "int64",
+	schema.Float:   "float32",
+	schema.Double:  "float64",
+	schema.Bytes:   "[]byte",
+	schema.String:  "string",
+}
+
+// goType is the Go type of s; context names the unions
+func (g *generator) goType(s *schema.Schema, context string) string {
+	if t := g.logicalGoType(s); t != "" {
+		return t
+	}
+	if s.Type.IsPrimitive() {
+		return primitiveGoTypes[s.Type]
+	}
+	switch s.Type {
+	case schema.Array:
+		return "[]" + g.goType(s.Items, context+"Item")
+	case schema.Map:
+		return "map[string]" + g.goType(s.Values, context+"Value")
+	case schema.Union:
+		if branch, ok := s.Nullable(); ok {
+			return "*" + g.goType(branch, context)
+		}
+		g.registerUnion(context, s)
+		return context
+	}
+	return g.goNames[s.Name]
+}
+
+func (g *generator) registerUnion(name string, s *schema.Schema) {
+	for _, u := range g.unions {
+		if u.schema == s {
+			return
+		}
+	}
+	g.unions = append(g.unions, union{name: name, schema: s})
+}
+
+// branchFieldName is the field of a branch in a union struct
+func (g *generator) branchFieldName(s *schema.Schema) string {
+	switch {
+	case s.Type.IsNamed():
+		return g.goNames[s.Name]
+	case s.LogicalType != "":
+		return exported(strings.ReplaceAll(s.LogicalType, "-", "_"))
+	}
+	return exported(string(s.Type))
+}
+
+// toNative is the expression of the goavro native of expr, of type s
+func (g *generator) toNative(s *schema.Schema, expr string, context string) string {
+	if isDecimal(s) {
+		// A copy, array items are a single variable
+		return "new(big.Rat).Set(" + address(expr) + ")"
+	}
+	if g.logicalGoType(s) != "" {
+		return expr
+	}
+	switch s.Type {
+	case schema.Null:
+		return "nil"
+	case schema.Record, schema.ErrorRecord, schema.Fixed:
+		return receiver(expr) + ".toNative()"
+	case schema.Enum:
+		return "string(" + expr + ")"
+	case schema.Array:
+		return fmt.Sprintf("func(items %s) []interface{} {\nnative := make([]interface{}, 0, len(items))\nfor _, item := range items {\nnative = append(native, %s)\n}\nreturn native\n}(%s)",
+			g.goType(s, context), g.toNative(s.Items, "item", context+"Item"), expr)
+	case schema.Map:
+		return fmt.Sprintf("func(values %s) map[string]interface{} {\nnative := make(map[string]interface{}, len(values))\nfor key, value := range values {\nnative[key] = %s\n}\nreturn native\n}(%s)",
+			g.goType(s, context), g.toNative(s.Values, "value", context+"Value"), expr)
+	case schema.Union:
+		if branch, ok := s.Nullable(); ok {
+			return fmt.Sprintf("func(v %s) interface{} {\nif v == nil {\nreturn nil\n}\nreturn goavro.Union(%q, %s)\n}(%s)",
+				g.goType(s, context), branch.NativeName(), g.toNative(branch, "*v", context), expr)
+		}
+		return receiver(expr) + ".toNative()"
+	}
+	return expr
+}
+
+// receiver is the receiver of a toNative call on expr, methods of values can be called
+// on pointers
+func receiver(expr string) string {
+	return strings.TrimPrefix(expr, "*")
+}
+
+// address is the address of expr, a variable or a dereferenced pointer
+func address(expr string) string {
+	if strings.HasPrefix(expr, "*") {
+		return strings.TrimPrefix(expr, "*")
+	}
+	return "&" + expr
+}
+
+// fromNative is the expression of the Go value of the goavro native expr, of type s
+func (g *generator) fromNative(s *schema.Schema, expr string, context string) string {
+	if isDecimal(s) {
+		return "*" + expr + ".(*big.Rat)"
+	}
+	if t := g.logicalGoType(s); t != "" {
+		return expr + ".(" + t + ")"
+	}
+	switch s.Type {
+	case schema.Null:
+		return "struct{}{}"
+	case schema.Record, schema.ErrorRecord, schema.Fixed:
+		return unexported(g.goNames[s.Name]) + "FromNative(" + expr + ")"
+	case schema.Enum:
+		return g.goNames[s.Name] + "(" + expr + ".(string))"
+	case schema.Array:
+		t := g.goType(s, context)
+		return fmt.Sprintf("func(native interface{}) %s {\nitems := native.([]interface{})\nres := make(%s, 0, len(items))\nfor _, item := range items {\nres = append(res, %s)\n}\nreturn res\n}(%s)",
+			t, t, g.fromNative(s.Items, "item", context+"Item"), expr)
+	case schema.Map:
+		t := g.goType(s, context)
+		return fmt.Sprintf("func(native interface{}) %s {\nvalues := native.(map[string]interface{})\nres := make(%s, len(values))\nfor key, value := range values {\nres[key] = %s\n}\nreturn res\n}(%s)",
+			t, t, g.fromNative(s.Values, "value", context+"Value"), expr)
+	case schema.Union:
+		if branch, ok := s.Nullable(); ok {
+			return fmt.Sprintf("func(native interface{}) %s {\nif native == nil {\nreturn nil\n}\nv := %s\nreturn &v\n}(%s)",
+				g.goType(s, context), g.fromNative(branch, fmt.Sprintf("native.(map[string]interface{})[%q]", branch.NativeName()), context), expr)
+		}
+		return unexported(context) + "FromNative(" + expr + ")"
+	}
+	return expr + ".(" + primitiveGoTypes[s.Type] + ")"
+}
+
+func (g *generator) comment(doc string, fallback string) {
+	if doc == "" {
+		doc = fallback
+	}
+	for _, line := range strings.Split(doc, "\n") {
+		g.printf("// %s\n", strings.TrimSpace(line))
+	}
+}
+
+func (g *generator) namedType(s *schema.Schema) error {
+	name := g.goNames[s.Name]
+	switch {
+	case isDecimal(s):
+		// A fixed decimal is a big.Rat
+		return nil
+	case s.Type == schema.Enum:
+		g.comment(s.Doc, fmt.Sprintf("%s is the Avro enum %s", name, s.Name))
+		g.printf("type %s string\n\nconst (\n", name)
+		for _, symbol := range s.Symbols {
+			g.printf("%s%s %s = %q\n", name, exported(symbol), name, symbol)
+		}
+		g.printf(")\n\n")
+	case s.Type == schema.Fixed:
+		g.comment(s.Doc, fmt.Sprintf("%s is the Avro fixed %s", name, s.Name))
+		g.printf("type %s [%d]byte\n\n", name, s.Size)
+		g.printf("func (f %s) toNative() []byte {\nreturn f[:]\n}\n\n", name)
+		g.printf("func %sFromNative(native interface{}) %s {\nvar f %s\ncopy(f[:], native.([]byte))\nreturn f\n}\n\n", unexported(name), name, name)
+	default:
+		return g.record(s, name)
+	}
+	return nil
+}
+
+func (g *generator) record(s *schema.Schema, name string) error {
+	fieldNames := make(map[string]string)
+	g.comment(s.Doc, fmt.Sprintf("%s is the Avro record %s", name, s.Name))
+	g.printf("type %s struct {\n", name)
+	for _, field := range s.Fields {
+		fieldName := exported(field.Name)
+		if other, found := fieldNames[fieldName]; found {
+			return fmt.Errorf("%s: fields %s and %s are both generated as %s", s.Name, other, field.Name, fieldName)
+		}
+		fieldNames[fieldName] = field.Name
+		if field.Doc != "" {
+			g.comment(field.Doc, "")
+		}
+		g.printf("%s %s `avro:%q`\n", fieldName, g.goType(field.Type, name+fieldName), field.Name)
+	}
+	g.printf("}\n\n")
+
+	// goavro has no error type, the encoding is the one of a record
+	codecSchema := s
+	if s.Type == schema.ErrorRecord {
+		copied := *s
+		copied.Type = schema.Record
+		codecSchema = &copied
+	}
+	json := codecSchema.String()
+	if strings.Contains(json, "`") {
+		g.printf("const %sSchema = %s\n\n", unexported(name), strconv.Quote(json))
+	} else {
+		g.printf("const %sSchema = `%s`\n\n", unexported(name), json)
+	}
+	g.printf("var %sCodec = mustCodec(%sSchema)\n\n", unexported(name), unexported(name))
+
+	g.printf("// MarshalAvro returns the Avro binary encoding of r\n")
+	g.printf("func (r *%s) MarshalAvro() ([]byte, error) {\nreturn %sCodec.BinaryFromNative(nil, r.toNative())\n}\n\n", name, unexported(name))
+	g.printf("// UnmarshalAvro sets r from its Avro binary encoding\n")
+	g.printf("func (r *%s) UnmarshalAvro(data []byte) error {\nnative, _, err := %sCodec.NativeFromBinary(data)\nif err != nil {\nreturn err\n}\n*r = %sFromNative(native)\nreturn nil\n}\n\n",
+		name, unexported(name), unexported(name))
+
+	g.printf("func (r %s) toNative() map[string]interface{} {\nreturn map[string]interface{}{\n", name)
+	for _, field := range s.Fields {
+		fieldName := exported(field.Name)
+		g.printf("%q: %s,\n", field.Name, g.toNative(field.Type, "r."+fieldName, name+fieldName))
+	}
+	g.printf("}\n}\n\n")
+
+	g.printf("func %sFromNative(native interface{}) %s {\n", unexported(name), name)
+	if len(s.Fields) == 0 {
+		g.printf("return %s{}\n}\n\n", name)
+		return nil
+	}
+	g.printf("fields := native.(map[string]interface{})\nreturn %s{\n", name)
+	for _, field := range s.Fields {
+		fieldName := exported(field.Name)
+		g.printf("%s: %s,\n", fieldName, g.fromNative(field.Type, fmt.Sprintf("fields[%q]", field.Name), name+fieldName))
+	}
+	g.printf("}\n}\n\n")
+	return nil
+}
+
+func (g *generator) unionType(u union) error {
+	names := make(map[string]bool)
+	var nullable bool
+	g.printf("// %s is a union, nil fields but one", u.name)
+	for _, branch := range u.schema.Branches {
+		if branch.Type == schema.Null {
+			nullable = true
+		}
+	}
+	if nullable {
+		g.printf(", or all nil for null")
+	}
+	g.printf("\ntype %s struct {\n", u.name)
+	for _, branch := range u.schema.Branches {
+		if branch.Type == schema.Null {
+			continue
+		}
+		fieldName := g.branchFieldName(branch)
+		if names[fieldName] {
+			return fmt.Errorf("%s: two branches are generated as %s", u.name, fieldName)
+		}
+		names[fieldName] = true
+		g.printf("%s *%s\n", fieldName, g.goType(branch, u.name+fieldName))
+	}
+	g.printf("}\n\n")
+
+	g.printf("func (u %s) toNative() interface{} {\nswitch {\n", u.name)
+	for _, branch := range u.schema.Branches {
+		if branch.Type == schema.Null {
+			continue
+		}
+		fieldName := g.branchFieldName(branch)
+		g.printf("case u.%s != nil:\nreturn goavro.Union(%q, %s)\n", fieldName, branch.NativeName(),
+			g.toNative(branch, "*u."+fieldName, u.name+fieldName))
+	}
+	g.printf("}\nreturn nil\n}\n\n")
+
+	g.printf("func %sFromNative(native interface{}) %s {\nvar u %s\nif native == nil {\nreturn u\n}\n", unexported(u.name), u.name, u.name)
+	g.printf("for name, value := range native.(map[string]interface{}) {\nswitch name {\n")
+	for _, branch := range u.schema.Branches {
+		if branch.Type == schema.Null {
+			continue
+		}
+		fieldName := g.branchFieldName(branch)
+		g.printf("case %q:\nv := %s\nu.%s = &v\n", branch.NativeName(), g.fromNative(branch, "value", u.name+fieldName), fieldName)
+	}
+	g.printf("}\n}\nreturn u\n}\n\n")
+	return nil
+}
@@ -0,0 +1,41 @@
+package gen
+
+import (
+	"os"
+	"testing"
+
+	"test/avro/pkg/schema"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeneratedExampleIsUpToDate(t *testing.T) {
+	var roots []*schema.Schema
+	for _, path := range []string{"example/longlist.avsc", "example/orders.avdl"} {
+		schemas, err := Load(path)
+		require.NoError(t, err)
+		roots = append(roots, schemas...)
+	}
+	source, err := Generate("example", roots)
+	require.NoError(t, err)
+
+	generated, err := os.ReadFile("example/example.gen.go")
+	require.NoError(t, err)
+	require.Equal(t, string(generated), string(source), "run go generate ./pkg/gen/example")
+}
+
+func TestGenerateNameCollisions(t *testing.T) {
+	s, err := schema.Parse([]byte(`{"type": "record", "name": "a.R", "fields": [
+		{"name": "other", "type": {"type": "record", "name": "b.R", "fields": []}}
+	]}`))
+	require.NoError(t, err)
+	_, err = Generate("p", []*schema.Schema{s})
+	require.EqualError(t, err, "a.R and b.R are both generated as R")
+
+	s, err = schema.Parse([]byte(`{"type": "record", "name": "R", "fields": [
+		{"name": "created_at", "type": "long"}, {"name": "CreatedAt", "type": "long"}
+	]}`))
+	require.NoError(t, err)
+	_, err = Generate("p", []*schema.Schema{s})
+	require.EqualError(t, err, "R: fields created_at and CreatedAt are both generated as CreatedAt")
+}
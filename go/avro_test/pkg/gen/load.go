@@ -0,0 +1,37 @@
+package gen
+
+import (
+	"os"
+	"path/filepath"
+
+	"test/avro/pkg/protocol"
+	"test/avro/pkg/schema"
+)
+
+// Load reads the schemas of an .avsc, .avpr or .avdl file
+func Load(path string) ([]*schema.Schema, error) {
+	if filepath.Ext(path) == ".avdl" {
+		p, err := protocol.ParseIDLFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return p.Types, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if filepath.Ext(path) == ".avpr" {
+		p, err := protocol.Parse(data)
+		if err != nil {
+			return nil, err
+		}
+		return p.Types, nil
+	}
+	s, err := schema.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	return []*schema.Schema{s}, nil
+}
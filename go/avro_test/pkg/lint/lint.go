@@ -0,0 +1,442 @@
+// Package lint validates Avro schemas and flags the patterns that parse but are likely
+// mistakes, with the line and column of each problem
+package lint
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"test/avro/pkg/protocol"
+	"test/avro/pkg/resolve"
+	"test/avro/pkg/schema"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+// Rule is the kind of a problem
+type Rule string
+
+const (
+	// Invalid schemas don't parse, or goavro rejects them
+	Invalid Rule = "invalid"
+	// DefaultMismatch defaults aren't values of the field type, e.g. of the first branch
+	// of a union
+	DefaultMismatch Rule = "default-mismatch"
+	// UnboundedRecursion records contain themselves without a way to stop, e.g. without a
+	// null branch, so they have no finite value
+	UnboundedRecursion Rule = "unbounded-recursion"
+	// LogicalType logical types are unknown or don't apply to their type, so they're ignored
+	LogicalType Rule = "logical-type"
+	// NamespaceCollision named types have the same short name in different namespaces, names
+	// that differ only by case, or a full name that is also a namespace
+	NamespaceCollision Rule = "namespace-collision"
+	// NonPortableName names are keywords of Java or Python, or differ only by case from
+	// another name of the same scope
+	NonPortableName Rule = "non-portable-name"
+)
+
+// Rules are all the rules
+var Rules = []Rule{Invalid, DefaultMismatch, UnboundedRecursion, LogicalType, NamespaceCollision, NonPortableName}
+
+// Problem is a problem found at Path, a JSON path of the document. Line is 0 when the
+// position isn't known
+type Problem struct {
+	File    string
+	Line    int
+	Column  int
+	Path    string
+	Rule    Rule
+	Message string
+}
+
+func (p *Problem) String() string {
+	if p.Line == 0 {
+		return fmt.Sprintf("%s: %s: %s [%s]", p.File, p.Path, p.Message, p.Rule)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s [%s]", p.File, p.Line, p.Column, p.Message, p.Rule)
+}
+
+// Problems are all the problems of a document, sorted by position
+type Problems []*Problem
+
+func (l Problems) Error() string {
+	messages := make([]string, 0, len(l))
+	for _, p := range l {
+		messages = append(messages, p.String())
+	}
+	return strings.Join(messages, "\n")
+}
+
+// Err returns nil for an empty list
+func (l Problems) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
+
+// File lints an .avsc, .avpr or .avdl file. The error is only for files that can't be read
+func File(path string) (Problems, error) {
+	if filepath.Ext(path) == ".avdl" {
+		p, err := protocol.ParseIDLFile(path)
+		var idlErrors protocol.IDLErrorList
+		if errors.As(err, &idlErrors) {
+			var problems Problems
+			for _, e := range idlErrors {
+				problems = append(problems, &Problem{File: e.File, Line: e.Line, Column: e.Column, Path: "$", Rule: Invalid, Message: e.Message})
+			}
+			return problems, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		// The paths are the ones of the protocol JSON, the IDL has no positions for them
+		return lintProtocol(p, path, nil), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Lint(data, path), nil
+}
+
+// Lint lints an .avpr document if filename has the .avpr extension, and an .avsc one
+// otherwise
+func Lint(data []byte, filename string) Problems {
+	positions, err := schema.Positions(data)
+	if err != nil {
+		return parseProblems(err, filename, nil)
+	}
+	if filepath.Ext(filename) == ".avpr" {
+		p, err := protocol.Parse(data)
+		if err != nil {
+			return parseProblems(err, filename, positions)
+		}
+		return lintProtocol(p, filename, positions)
+	}
+
+	s, err := schema.Parse(data)
+	if err != nil {
+		return parseProblems(err, filename, positions)
+	}
+	l := newLinter(filename, positions)
+	l.lint([]*schema.Schema{s})
+	if len(l.problems) == 0 {
+		// goavro is what reads the data, it has its own limits
+		if _, err := goavro.NewCodec(string(data)); err != nil {
+			l.add("$", Invalid, "goavro rejects the schema: %s", err)
+		}
+	}
+	return l.sorted()
+}
+
+// Schemas lints parsed schemas, the problems have paths but no file and positions
+func Schemas(roots []*schema.Schema) Problems {
+	l := newLinter("", nil)
+	l.lint(roots)
+	return l.sorted()
+}
+
+func lintProtocol(p *protocol.Protocol, filename string, positions map[string]schema.Position) Problems {
+	l := newLinter(filename, positions)
+	roots := append([]*schema.Schema(nil), p.Types...)
+	for _, m := range p.Messages {
+		for _, param := range m.Request {
+			l.field(param)
+			roots = append(roots, param.Type)
+		}
+		roots = append(roots, m.Response)
+		roots = append(roots, m.Errors...)
+	}
+	l.lint(roots)
+	if len(l.problems) == 0 {
+		for _, t := range p.Types {
+			// goavro has no error type
+			if t.Type == schema.ErrorRecord {
+				continue
+			}
+			if _, err := goavro.NewCodec(t.String()); err != nil {
+				l.add(t.Path, Invalid, "goavro rejects %s: %s", t.Name, err)
+			}
+		}
+	}
+	return l.sorted()
+}
+
+func parseProblems(err error, filename string, positions map[string]schema.Position) Problems {
+	l := newLinter(filename, positions)
+	var list schema.ErrorList
+	var single *schema.Error
+	switch {
+	case errors.As(err, &list):
+		for _, e := range list {
+			l.add(e.Path, Invalid, "%s", e.Message)
+		}
+	case errors.As(err, &single):
+		l.add(single.Path, Invalid, "%s", single.Message)
+	default:
+		l.add("$", Invalid, "%s", err)
+	}
+	return l.sorted()
+}
+
+type linter struct {
+	file      string
+	positions map[string]schema.Position
+	problems  Problems
+	// visited are the schemas already linted, named types are shared
+	visited map[*schema.Schema]bool
+	named   []*schema.Schema
+}
+
+func newLinter(file string, positions map[string]schema.Position) *linter {
+	return &linter{file: file, positions: positions, visited: make(map[*schema.Schema]bool)}
+}
+
+// add records a problem at path, located at the closest enclosing value with a position
+func (l *linter) add(path string, rule Rule, format string, args ...interface{}) {
+	p := &Problem{File: l.file, Path: path, Rule: rule, Message: fmt.Sprintf(format, args...)}
+	for located := path; l.positions != nil; located = parentPath(located) {
+		if position, found := l.positions[located]; found {
+			p.Line, p.Column = position.Line, position.Column
+			break
+		}
+		if located == "$" || located == "" {
+			break
+		}
+	}
+	l.problems = append(l.problems, p)
+}
+
+// parentPath removes the last step of a JSON path
+func parentPath(path string) string {
+	i := strings.LastIndexAny(path, ".[")
+	if i <= 0 {
+		return "$"
+	}
+	return path[:i]
+}
+
+func (l *linter) sorted() Problems {
+	sort.SliceStable(l.problems, func(i, j int) bool {
+		a, b := l.problems[i], l.problems[j]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return l.problems
+}
+
+func (l *linter) lint(roots []*schema.Schema) {
+	for _, root := range roots {
+		l.walk(root)
+	}
+	l.recursion()
+	l.collisions()
+}
+
+func (l *linter) walk(s *schema.Schema) {
+	if s == nil || l.visited[s] {
+		return
+	}
+	l.visited[s] = true
+	if s.Type.IsNamed() {
+		l.named = append(l.named, s)
+		l.names(s)
+	}
+	if err := schema.ValidateLogicalType(s); err != nil {
+		l.add(s.Path+".logicalType", LogicalType, "%s, it's read as %s", err, s.Type)
+	}
+	for _, field := range s.Fields {
+		l.field(field)
+		l.walk(field.Type)
+	}
+	l.walk(s.Items)
+	l.walk(s.Values)
+	for _, branch := range s.Branches {
+		l.walk(branch)
+	}
+}
+
+func (l *linter) field(field *schema.Field) {
+	l.name(field.Path+".name", field.Name, "field")
+	if !field.HasDefault {
+		return
+	}
+	_, err := resolve.DefaultNative(field.Type, field.Default)
+	if err == nil {
+		return
+	}
+	path := field.Path + ".default"
+	if field.Type.Type == schema.Union {
+		for i, branch := range field.Type.Branches[1:] {
+			if _, branchErr := resolve.DefaultNative(branch, field.Default); branchErr == nil {
+				l.add(path, DefaultMismatch, "the default of %s is a %s, the branch %d of the union, but the default of a union is a value of its first branch %s",
+					field.Name, branch.TypeName(), i+1, field.Type.Branches[0].TypeName())
+				return
+			}
+		}
+	}
+	l.add(path, DefaultMismatch, "the default of %s isn't a value of %s: %s", field.Name, typeDescription(field.Type), err)
+}
+
+func typeDescription(s *schema.Schema) string {
+	if s.Type == schema.Union {
+		return "the first branch " + s.Branches[0].TypeName()
+	}
+	return s.TypeName()
+}
+
+// recursion flags the records that have no finite value, i.e. each value of the record
+// contains another one
+func (l *linter) recursion() {
+	finite := make(map[*schema.Schema]bool)
+	var isFinite func(s *schema.Schema) bool
+	isFinite = func(s *schema.Schema) bool {
+		switch s.Type {
+		case schema.Record, schema.ErrorRecord:
+			return finite[s]
+		case schema.Union:
+			for _, branch := range s.Branches {
+				if isFinite(branch) {
+					return true
+				}
+			}
+			return false
+		}
+		// arrays and maps can be empty
+		return true
+	}
+	// the records with finite fields are finite, until a fixed point
+	for changed := true; changed; {
+		changed = false
+		for _, s := range l.named {
+			if finite[s] || (s.Type != schema.Record && s.Type != schema.ErrorRecord) {
+				continue
+			}
+			all := true
+			for _, field := range s.Fields {
+				all = all && isFinite(field.Type)
+			}
+			if all {
+				finite[s] = true
+				changed = true
+			}
+		}
+	}
+
+	for _, s := range l.named {
+		if finite[s] || (s.Type != schema.Record && s.Type != schema.ErrorRecord) {
+			continue
+		}
+		for _, field := range s.Fields {
+			if !isFinite(field.Type) {
+				l.add(field.Path+".type", UnboundedRecursion, "every %s contains another %s through %s, so it has no finite value: add \"null\" to the type of %s",
+					s.Name, fieldTypeName(field.Type), field.Name, field.Name)
+				break
+			}
+		}
+	}
+}
+
+func fieldTypeName(s *schema.Schema) string {
+	if s.Type == schema.Union {
+		names := make([]string, 0, len(s.Branches))
+		for _, branch := range s.Branches {
+			names = append(names, branch.TypeName())
+		}
+		return strings.Join(names, " or ")
+	}
+	return s.TypeName()
+}
+
+func (l *linter) collisions() {
+	byShortName := make(map[string]*schema.Schema)
+	byFoldedName := make(map[string]*schema.Schema)
+	namespaces := make(map[string]*schema.Schema)
+	for _, s := range l.named {
+		if ns := s.Namespace(); ns != "" {
+			for parts := strings.Split(ns, "."); len(parts) > 0; parts = parts[:len(parts)-1] {
+				prefix := strings.Join(parts, ".")
+				if _, found := namespaces[prefix]; !found {
+					namespaces[prefix] = s
+				}
+			}
+		}
+	}
+	for _, s := range l.named {
+		path := s.Path + ".name"
+		if previous, found := byFoldedName[strings.ToLower(s.Name)]; found {
+			l.add(path, NamespaceCollision, "%s and %s differ only by case", s.Name, previous.Name)
+		} else {
+			byFoldedName[strings.ToLower(s.Name)] = s
+			if previous, found := byShortName[s.ShortName()]; found {
+				l.add(path, NamespaceCollision, "%s has the short name of %s, generated code without namespaces has both as %s",
+					s.Name, previous.Name, s.ShortName())
+			} else {
+				byShortName[s.ShortName()] = s
+			}
+		}
+		if inner, found := namespaces[s.Name]; found {
+			l.add(path, NamespaceCollision, "%s is also the namespace of %s, so it's both a type and a package", s.Name, inner.Name)
+		}
+	}
+}
+
+// names checks the names defined by a named type
+func (l *linter) names(s *schema.Schema) {
+	for _, part := range strings.Split(s.Name, ".") {
+		if keywords[part] {
+			l.add(s.Path+".name", NonPortableName, "%s of %s is a keyword of %s", part, s.Name, languages)
+			break
+		}
+	}
+
+	folded := make(map[string]string)
+	for _, field := range s.Fields {
+		key := strings.ToLower(field.Name)
+		if previous, found := folded[key]; found {
+			l.add(field.Path+".name", NonPortableName, "the fields %s and %s of %s differ only by case", previous, field.Name, s.Name)
+		}
+		folded[key] = field.Name
+	}
+	for i, symbol := range s.Symbols {
+		path := fmt.Sprintf("%s.symbols[%d]", s.Path, i)
+		l.name(path, symbol, "symbol")
+		key := strings.ToLower(symbol)
+		if previous, found := folded[key]; found {
+			l.add(path, NonPortableName, "the symbols %s and %s of %s differ only by case", previous, symbol, s.Name)
+		}
+		folded[key] = symbol
+	}
+}
+
+func (l *linter) name(path string, name string, kind string) {
+	if keywords[name] {
+		l.add(path, NonPortableName, "the %s %s is a keyword of %s", kind, name, languages)
+	}
+}
+
+// languages are the ones whose generated code keeps the Avro names as they are
+const languages = "Java or Python"
+
+// keywords are the reserved words of languages that are valid Avro names
+var keywords = make(map[string]bool)
+
+func init() {
+	for _, keyword := range strings.Fields(`
+		abstract assert boolean break byte case catch char class const continue default do double
+		else enum extends final finally float for goto if implements import instanceof int interface
+		long native new package private protected public return short static strictfp super switch
+		synchronized this throw throws transient try void volatile while true false null
+		and as async await def del elif except from global in is lambda nonlocal not or pass raise
+		with yield None True False`) {
+		keywords[keyword] = true
+	}
+}
@@ -0,0 +1,96 @@
+package lint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func lines(problems Problems) []string {
+	res := make([]string, 0, len(problems))
+	for _, p := range problems {
+		res = append(res, p.String())
+	}
+	return res
+}
+
+func TestLongListIsClean(t *testing.T) {
+	problems := Lint([]byte(`{"type": "record", "name": "LongList", "fields": [
+		{"name": "next", "type": ["null", "LongList", {"type": "long", "logicalType": "timestamp-millis"}], "default": null}
+	]}`), "longlist.avsc")
+	require.Empty(t, problems)
+	require.NoError(t, problems.Err())
+}
+
+func TestLint(t *testing.T) {
+	problems := Lint([]byte(`{
+  "type": "record",
+  "name": "Order",
+  "namespace": "shop",
+  "fields": [
+    {"name": "id", "type": "string"},
+    {"name": "note", "type": ["null", "string"], "default": "none"},
+    {"name": "count", "type": ["null", "int"], "default": "x"},
+    {"name": "parent", "type": "Order"},
+    {"name": "day", "type": {"type": "long", "logicalType": "date"}},
+    {"name": "rank", "type": {"type": "int", "logicalType": "rank"}},
+    {"name": "price", "type": {"type": "fixed", "name": "Price", "size": 2, "logicalType": "decimal", "precision": 6}},
+    {"name": "class", "type": {"type": "enum", "name": "Class", "symbols": ["A", "a"]}},
+    {"name": "Id", "type": {"type": "record", "name": "Order", "namespace": "billing", "fields": []}},
+    {"name": "sub", "type": {"type": "record", "name": "Item", "namespace": "shop.Order", "fields": []}}
+  ]
+}`), "order.avsc")
+	require.Equal(t, []string{
+		`order.avsc:3:11: shop.Order is also the namespace of shop.Order.Item, so it's both a type and a package [namespace-collision]`,
+		`order.avsc:7:61: the default of note is a string, the branch 1 of the union, but the default of a union is a value of its first branch null [default-mismatch]`,
+		`order.avsc:8:59: the default of count isn't a value of the first branch null: invalid default "x" for null [default-mismatch]`,
+		`order.avsc:9:32: every shop.Order contains another shop.Order through parent, so it has no finite value: add "null" to the type of parent [unbounded-recursion]`,
+		`order.avsc:10:61: date doesn't apply to long, it's read as long [logical-type]`,
+		`order.avsc:11:61: unknown logical type "rank", it's read as int [logical-type]`,
+		`order.avsc:12:92: decimal precision 6 doesn't fit in 2 bytes, the maximum is 4, it's read as fixed [logical-type]`,
+		`order.avsc:13:14: the field class is a keyword of Java or Python [non-portable-name]`,
+		`order.avsc:13:82: the symbols A and a of shop.Class differ only by case [non-portable-name]`,
+		`order.avsc:14:14: the fields id and Id of shop.Order differ only by case [non-portable-name]`,
+		`order.avsc:14:55: billing.Order has the short name of shop.Order, generated code without namespaces has both as Order [namespace-collision]`,
+	}, lines(problems))
+}
+
+func TestInvalid(t *testing.T) {
+	problems := Lint([]byte(`{"type": "record", "name": "R", "fields": [
+	{"name": "a", "type": "Missing"},
+	{"name": "b"}
+]}`), "r.avsc")
+	require.Equal(t, []string{
+		`r.avsc:2:24: unknown type "Missing" [invalid]`,
+		`r.avsc:3:2: missing "type" [invalid]`,
+	}, lines(problems))
+
+	problems = Lint([]byte("{\"type\": \"record\",\n\"name\": }"), "r.avsc")
+	require.Len(t, problems, 1)
+	require.Equal(t, Invalid, problems[0].Rule)
+
+	// goavro rejects string defaults of decimals
+	problems = Lint([]byte(`{"type": "record", "name": "R", "fields": [
+	{"name": "a", "type": {"type": "bytes", "logicalType": "decimal", "precision": 4}, "default": "\u0000"}
+]}`), "r.avsc")
+	require.Len(t, problems, 1)
+	require.Contains(t, problems[0].String(), "r.avsc:1:1: goavro rejects the schema: ")
+}
+
+func TestProtocol(t *testing.T) {
+	problems := Lint([]byte(`{
+  "protocol": "Mail",
+  "namespace": "mail",
+  "types": [
+    {"type": "record", "name": "Message", "fields": [{"name": "from", "type": "string"}]},
+    {"type": "error", "name": "Bounce", "fields": [{"name": "reason", "type": "string"}]}
+  ],
+  "messages": {
+    "send": {"request": [{"name": "message", "type": "Message"}, {"name": "retries", "type": "int", "default": "3"}], "response": "null", "errors": ["Bounce"]}
+  }
+}`), "mail.avpr")
+	require.Equal(t, []string{
+		`mail.avpr:5:63: the field from is a keyword of Java or Python [non-portable-name]`,
+		`mail.avpr:9:112: the default of retries isn't a value of int: invalid default "3" for int [default-mismatch]`,
+	}, lines(problems))
+}
@@ -0,0 +1,123 @@
+// Package ocf reads and writes Avro Object Container Files, see
+// https://avro.apache.org/docs/1.11.1/specification/#object-container-files. Blocks are
+// exposed as well as items, so that files can be counted, concatenated and recompressed
+// without decoding the items
+package ocf
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	"test/avro/pkg/schema"
+
+	"github.com/golang/snappy"
+)
+
+// Magic starts the files
+var Magic = []byte("Obj\x01")
+
+// SyncSize is the size of the sync markers
+const SyncSize = 16
+
+// SyncMarker ends the header and each block
+type SyncMarker [SyncSize]byte
+
+// Metadata keys of the header reserved by the spec
+const (
+	SchemaKey = "avro.schema"
+	CodecKey  = "avro.codec"
+)
+
+// Codec compresses the blocks
+type Codec string
+
+const (
+	Null    Codec = "null"
+	Deflate Codec = "deflate"
+	Snappy  Codec = "snappy"
+)
+
+// ParseCodec parses the name of a codec, where "" is Null as in files without codec
+func ParseCodec(name string) (Codec, error) {
+	switch c := Codec(name); c {
+	case "":
+		return Null, nil
+	case Null, Deflate, Snappy:
+		return c, nil
+	}
+	return "", fmt.Errorf("unsupported codec %q", name)
+}
+
+func (c Codec) compress(data []byte) ([]byte, error) {
+	switch c {
+	case Deflate:
+		var buf bytes.Buffer
+		w, err := flate.NewWriter(&buf, flate.DefaultCompression)
+		if err != nil {
+			return nil, err
+		}
+		_, err = w.Write(data)
+		if err != nil {
+			return nil, err
+		}
+		err = w.Close()
+		return buf.Bytes(), err
+	case Snappy:
+		// the compressed data is followed by the big-endian CRC32 of the uncompressed data
+		compressed := snappy.Encode(nil, data)
+		var checksum [4]byte
+		binary.BigEndian.PutUint32(checksum[:], crc32.ChecksumIEEE(data))
+		return append(compressed, checksum[:]...), nil
+	}
+	return data, nil
+}
+
+func (c Codec) decompress(data []byte) ([]byte, error) {
+	switch c {
+	case Deflate:
+		return io.ReadAll(flate.NewReader(bytes.NewReader(data)))
+	case Snappy:
+		if len(data) < 4 {
+			return nil, errors.New("snappy block without checksum")
+		}
+		compressed, checksum := data[:len(data)-4], binary.BigEndian.Uint32(data[len(data)-4:])
+		decompressed, err := snappy.Decode(nil, compressed)
+		if err != nil {
+			return nil, err
+		}
+		if crc32.ChecksumIEEE(decompressed) != checksum {
+			return nil, errors.New("snappy block checksum mismatch")
+		}
+		return decompressed, nil
+	}
+	return data, nil
+}
+
+// Header is the header of a file
+type Header struct {
+	Schema *schema.Schema
+	Codec  Codec
+	// Metadata has all the metadata of the header, including SchemaKey and CodecKey
+	Metadata map[string][]byte
+	Sync     SyncMarker
+}
+
+// Block is a block of Count items in the binary encoding
+type Block struct {
+	Count int64
+	// Data is uncompressed
+	Data []byte
+	// Offset is where the block starts in the file, see Reader.SeekSync
+	Offset int64
+}
+
+// appendLong appends the zig-zag varint encoding of Avro longs
+func appendLong(buf []byte, n int64) []byte {
+	var varint [binary.MaxVarintLen64]byte
+	return append(buf, varint[:binary.PutVarint(varint[:], n)]...)
+}
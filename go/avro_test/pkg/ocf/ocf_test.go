@@ -0,0 +1,159 @@
+package ocf
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"test/avro/pkg/schema"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/stretchr/testify/require"
+)
+
+const order = `{"type": "record", "name": "Order", "fields": [{"name": "id", "type": "long"}, {"name": "note", "type": ["null", "string"]}]}`
+
+func parse(t *testing.T, s string) *schema.Schema {
+	parsed, err := schema.Parse([]byte(s))
+	require.NoError(t, err)
+	return parsed
+}
+
+func orders(n int) []interface{} {
+	var natives []interface{}
+	for i := 0; i < n; i++ {
+		natives = append(natives, map[string]interface{}{"id": int64(i), "note": goavro.Union("string", "note")})
+	}
+	return natives
+}
+
+func write(t *testing.T, options Options, natives []interface{}) []byte {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, parse(t, order), options)
+	require.NoError(t, err)
+	require.NoError(t, w.Append(natives...))
+	require.NoError(t, w.Flush())
+	return buf.Bytes()
+}
+
+func readAll(t *testing.T, r *Reader) []interface{} {
+	var natives []interface{}
+	for r.Scan() {
+		natives = append(natives, r.Native())
+	}
+	require.NoError(t, r.Err())
+	return natives
+}
+
+func blocks(t *testing.T, r *Reader) []*Block {
+	var res []*Block
+	for {
+		block, err := r.NextBlock()
+		if err == io.EOF {
+			return res
+		}
+		require.NoError(t, err)
+		res = append(res, block)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, codec := range []Codec{Null, Deflate, Snappy} {
+		data := write(t, Options{Codec: codec, BlockCount: 3, Metadata: map[string][]byte{"origin": []byte("test")}}, orders(10))
+
+		r, err := NewReader(bytes.NewReader(data))
+		require.NoError(t, err)
+		require.Equal(t, codec, r.Header().Codec)
+		require.Equal(t, "test", string(r.Header().Metadata["origin"]))
+		require.Equal(t, "Order", r.Header().Schema.Name)
+		require.Equal(t, orders(10), readAll(t, r), codec)
+
+		// goavro reads the files too
+		goavroReader, err := goavro.NewOCFReader(bytes.NewReader(data))
+		require.NoError(t, err)
+		count := 0
+		for goavroReader.Scan() {
+			_, err := goavroReader.Read()
+			require.NoError(t, err)
+			count++
+		}
+		require.Equal(t, 10, count, codec)
+	}
+}
+
+func TestReadGoavroFile(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := goavro.NewOCFWriter(goavro.OCFConfig{W: &buf, Schema: order, CompressionName: "snappy"})
+	require.NoError(t, err)
+	require.NoError(t, w.Append(orders(4)))
+
+	r, err := NewReader(&buf)
+	require.NoError(t, err)
+	require.Equal(t, orders(4), readAll(t, r))
+}
+
+func TestBlockSizes(t *testing.T) {
+	r, err := NewReader(bytes.NewReader(write(t, Options{SyncInterval: 30}, orders(10))))
+	require.NoError(t, err)
+	var counts []int64
+	for _, block := range blocks(t, r) {
+		counts = append(counts, block.Count)
+	}
+	// the items are 7 bytes
+	require.Equal(t, []int64{5, 5}, counts)
+}
+
+func TestSeekSync(t *testing.T) {
+	data := write(t, Options{Codec: Deflate, BlockCount: 4}, orders(10))
+	r, err := NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	all := blocks(t, r)
+	require.Len(t, all, 3)
+
+	require.NoError(t, r.SeekSync(all[1].Offset))
+	require.Equal(t, orders(10)[4:], readAll(t, r))
+	require.NoError(t, r.SeekSync(all[1].Offset+1))
+	require.Equal(t, orders(10)[8:], readAll(t, r))
+	require.NoError(t, r.SeekSync(0))
+	require.Equal(t, orders(10), readAll(t, r))
+	require.NoError(t, r.SeekSync(int64(len(data))))
+	require.Empty(t, readAll(t, r))
+
+	r, err = NewReader(bytes.NewBuffer(data))
+	require.NoError(t, err)
+	require.Equal(t, ErrNotSeeker, r.SeekSync(0))
+}
+
+func TestRecodecBlocks(t *testing.T) {
+	r, err := NewReader(bytes.NewReader(write(t, Options{BlockCount: 4}, orders(10))))
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, r.Header().Schema, Options{Codec: Snappy})
+	require.NoError(t, err)
+	require.NoError(t, w.Append(orders(1)...))
+	for _, block := range blocks(t, r) {
+		require.NoError(t, w.WriteBlock(block))
+	}
+
+	r, err = NewReader(&buf)
+	require.NoError(t, err)
+	require.Equal(t, Snappy, r.Header().Codec)
+	require.Equal(t, append(orders(1), orders(10)...), readAll(t, r))
+}
+
+func TestErrors(t *testing.T) {
+	_, err := NewReader(bytes.NewReader([]byte("{}")))
+	require.EqualError(t, err, "header: not an Avro object container file")
+
+	_, err = NewWriter(io.Discard, parse(t, order), Options{Codec: "bzip2"})
+	require.EqualError(t, err, `unsupported codec "bzip2"`)
+	_, err = NewWriter(io.Discard, parse(t, order), Options{Metadata: map[string][]byte{"avro.schema": nil}})
+	require.EqualError(t, err, "reserved metadata key avro.schema")
+
+	data := write(t, Options{}, orders(2))
+	data[len(data)-1]++
+	r, err := NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.False(t, r.Scan())
+	require.EqualError(t, r.Err(), "block at offset 164: sync marker mismatch")
+}
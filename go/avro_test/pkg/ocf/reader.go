@@ -0,0 +1,268 @@
+package ocf
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"test/avro/pkg/schema"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+// ErrNotSeeker is returned by SeekSync for readers that aren't an io.Seeker
+var ErrNotSeeker = errors.New("the reader can't seek")
+
+// offsetReader tracks the offset in the file
+type offsetReader struct {
+	r      *bufio.Reader
+	offset int64
+}
+
+func (r *offsetReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.offset += int64(n)
+	return n, err
+}
+
+func (r *offsetReader) ReadByte() (byte, error) {
+	b, err := r.r.ReadByte()
+	if err == nil {
+		r.offset++
+	}
+	return b, err
+}
+
+// Reader streams the items or the blocks of a file
+type Reader struct {
+	r      offsetReader
+	seeker io.ReadSeeker
+	header Header
+	codec  *goavro.Codec
+	// headerSize is the offset of the first block
+	headerSize int64
+
+	// rest is the data of the current block after the scanned items
+	rest      []byte
+	remaining int64
+	native    interface{}
+	err       error
+}
+
+// NewReader reads the header of the file at the start of r. SeekSync needs r to be an io.Seeker
+func NewReader(r io.Reader) (*Reader, error) {
+	reader := &Reader{r: offsetReader{r: bufio.NewReader(r)}}
+	reader.seeker, _ = r.(io.ReadSeeker)
+	err := reader.readHeader()
+	if err != nil {
+		return nil, fmt.Errorf("header: %w", err)
+	}
+	reader.headerSize = reader.r.offset
+	return reader, nil
+}
+
+func (r *Reader) readHeader() error {
+	magic := make([]byte, len(Magic))
+	_, err := io.ReadFull(&r.r, magic)
+	if err != nil || !bytes.Equal(magic, Magic) {
+		return errors.New("not an Avro object container file")
+	}
+
+	metadata := make(map[string][]byte)
+	for {
+		count, err := binary.ReadVarint(&r.r)
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		if count == 0 {
+			break
+		}
+		if count < 0 {
+			// a negative count is followed by the size of the map block
+			count = -count
+			_, err = binary.ReadVarint(&r.r)
+			if err != nil {
+				return unexpectedEOF(err)
+			}
+		}
+		for i := int64(0); i < count; i++ {
+			key, err := r.readBytes()
+			if err != nil {
+				return err
+			}
+			value, err := r.readBytes()
+			if err != nil {
+				return err
+			}
+			metadata[string(key)] = value
+		}
+	}
+	_, err = io.ReadFull(&r.r, r.header.Sync[:])
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+
+	schemaJSON, found := metadata[SchemaKey]
+	if !found {
+		return errors.New("no schema")
+	}
+	r.header.Schema, err = schema.Parse(schemaJSON)
+	if err != nil {
+		return err
+	}
+	r.codec, err = goavro.NewCodec(string(schemaJSON))
+	if err != nil {
+		return err
+	}
+	r.header.Codec, err = ParseCodec(string(metadata[CodecKey]))
+	if err != nil {
+		return err
+	}
+	r.header.Metadata = metadata
+	return nil
+}
+
+// readBytes reads Avro bytes, whose size is checked against the data that is actually read
+func (r *Reader) readBytes() ([]byte, error) {
+	size, err := binary.ReadVarint(&r.r)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if size < 0 {
+		return nil, fmt.Errorf("negative size %d at offset %d", size, r.r.offset)
+	}
+	data, err := io.ReadAll(io.LimitReader(&r.r, size))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) < size {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return data, nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// Header is the header of the file
+func (r *Reader) Header() *Header {
+	return &r.header
+}
+
+// Codec decodes the items of the file
+func (r *Reader) Codec() *goavro.Codec {
+	return r.codec
+}
+
+// NextBlock reads the next block, skipping the items of the current block that Scan
+// hasn't returned. It returns io.EOF at the end of the file
+func (r *Reader) NextBlock() (*Block, error) {
+	offset := r.r.offset
+	count, err := binary.ReadVarint(&r.r)
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, err
+	}
+	if count < 0 {
+		return nil, fmt.Errorf("block at offset %d: negative count %d", offset, count)
+	}
+	compressed, err := r.readBytes()
+	if err != nil {
+		return nil, fmt.Errorf("block at offset %d: %w", offset, err)
+	}
+	var sync SyncMarker
+	_, err = io.ReadFull(&r.r, sync[:])
+	if err != nil {
+		return nil, fmt.Errorf("block at offset %d: %w", offset, unexpectedEOF(err))
+	}
+	if sync != r.header.Sync {
+		return nil, fmt.Errorf("block at offset %d: sync marker mismatch", offset)
+	}
+	data, err := r.header.Codec.decompress(compressed)
+	if err != nil {
+		return nil, fmt.Errorf("block at offset %d: %w", offset, err)
+	}
+	r.rest = data
+	r.remaining = count
+	return &Block{Count: count, Data: data, Offset: offset}, nil
+}
+
+// Scan reads the next item, which is then returned by Native. It returns false at the
+// end of the file or on errors, see Err
+func (r *Reader) Scan() bool {
+	for r.remaining == 0 {
+		if r.err != nil {
+			return false
+		}
+		_, err := r.NextBlock()
+		if err != nil {
+			if err != io.EOF {
+				r.err = err
+			}
+			return false
+		}
+	}
+	native, rest, err := r.codec.NativeFromBinary(r.rest)
+	if err != nil {
+		r.err = err
+		r.remaining = 0
+		return false
+	}
+	r.native = native
+	r.rest = rest
+	r.remaining--
+	return true
+}
+
+// Native is the goavro native of the item read by Scan
+func (r *Reader) Native() interface{} {
+	return r.native
+}
+
+// Err is the error that stopped Scan, nil at the end of the file
+func (r *Reader) Err() error {
+	return r.err
+}
+
+// SeekSync moves to the first block starting at or after offset, e.g. the Offset of a block
+// read earlier or a split of the file for parallel readers. The reader is at the end of
+// the file if there is no such block
+func (r *Reader) SeekSync(offset int64) error {
+	if r.seeker == nil {
+		return ErrNotSeeker
+	}
+	// the sync marker before the block is searched, the first one ends the header
+	start := offset - SyncSize
+	if start < r.headerSize-SyncSize {
+		start = r.headerSize - SyncSize
+	}
+	_, err := r.seeker.Seek(start, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	r.r.r.Reset(r.seeker)
+	r.r.offset = start
+	r.rest, r.remaining, r.native, r.err = nil, 0, nil, nil
+
+	var window SyncMarker
+	_, err = io.ReadFull(&r.r, window[:])
+	for err == nil && window != r.header.Sync {
+		var b byte
+		b, err = r.r.ReadByte()
+		copy(window[:], window[1:])
+		window[SyncSize-1] = b
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,160 @@
+package ocf
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"test/avro/pkg/schema"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+// DefaultSyncInterval is the sync interval of the Java implementation
+const DefaultSyncInterval = 64000
+
+// Options configure a Writer, the zero value writes uncompressed blocks of
+// DefaultSyncInterval bytes
+type Options struct {
+	// Codec is Null if empty
+	Codec Codec
+	// SyncInterval is the size of the encoded items, before compression, that ends a block.
+	// It's DefaultSyncInterval if 0
+	SyncInterval int
+	// BlockCount is the maximum number of items of a block, unlimited if 0
+	BlockCount int
+	// Metadata is added to the header, the keys starting with "avro." are reserved
+	Metadata map[string][]byte
+	// Sync is the sync marker, random if zero
+	Sync SyncMarker
+}
+
+// Writer writes a file. The items are buffered in a block until Flush
+type Writer struct {
+	w       io.Writer
+	header  Header
+	codec   *goavro.Codec
+	options Options
+
+	block []byte
+	count int64
+}
+
+// NewWriter writes the header of a file of s items to w
+func NewWriter(w io.Writer, s *schema.Schema, options Options) (*Writer, error) {
+	codecName, err := ParseCodec(string(options.Codec))
+	if err != nil {
+		return nil, err
+	}
+	if options.SyncInterval <= 0 {
+		options.SyncInterval = DefaultSyncInterval
+	}
+	if options.Sync == (SyncMarker{}) {
+		_, err = rand.Read(options.Sync[:])
+		if err != nil {
+			return nil, err
+		}
+	}
+	schemaJSON := s.String()
+	codec, err := goavro.NewCodec(schemaJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := map[string][]byte{SchemaKey: []byte(schemaJSON), CodecKey: []byte(codecName)}
+	for key, value := range options.Metadata {
+		if strings.HasPrefix(key, "avro.") {
+			return nil, fmt.Errorf("reserved metadata key %s", key)
+		}
+		metadata[key] = value
+	}
+	writer := &Writer{
+		w:       w,
+		header:  Header{Schema: s, Codec: codecName, Metadata: metadata, Sync: options.Sync},
+		codec:   codec,
+		options: options,
+	}
+	return writer, writer.writeHeader()
+}
+
+func (w *Writer) writeHeader() error {
+	keys := make([]string, 0, len(w.header.Metadata))
+	for key := range w.header.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	buf := append([]byte(nil), Magic...)
+	buf = appendLong(buf, int64(len(keys)))
+	for _, key := range keys {
+		buf = appendLong(buf, int64(len(key)))
+		buf = append(buf, key...)
+		buf = appendLong(buf, int64(len(w.header.Metadata[key])))
+		buf = append(buf, w.header.Metadata[key]...)
+	}
+	buf = appendLong(buf, 0)
+	buf = append(buf, w.header.Sync[:]...)
+	_, err := w.w.Write(buf)
+	return err
+}
+
+// Header is the header written to the file
+func (w *Writer) Header() *Header {
+	return &w.header
+}
+
+// Append adds goavro natives to the current block, and writes the block when it reaches
+// the sync interval or the block count
+func (w *Writer) Append(natives ...interface{}) error {
+	for _, native := range natives {
+		block, err := w.codec.BinaryFromNative(w.block, native)
+		if err != nil {
+			return err
+		}
+		w.block = block
+		w.count++
+		if len(w.block) >= w.options.SyncInterval || w.count == int64(w.options.BlockCount) {
+			err = w.Flush()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// WriteBlock writes the current block then b, whose items must be of the schema of the
+// file, e.g. a block of Reader.NextBlock
+func (w *Writer) WriteBlock(b *Block) error {
+	err := w.Flush()
+	if err != nil {
+		return err
+	}
+	return w.writeBlock(b.Count, b.Data)
+}
+
+// Flush writes the current block if it has items. It doesn't flush w
+func (w *Writer) Flush() error {
+	if w.count == 0 {
+		return nil
+	}
+	err := w.writeBlock(w.count, w.block)
+	w.block = w.block[:0]
+	w.count = 0
+	return err
+}
+
+func (w *Writer) writeBlock(count int64, data []byte) error {
+	compressed, err := w.header.Codec.compress(data)
+	if err != nil {
+		return err
+	}
+	buf := appendLong(nil, count)
+	buf = appendLong(buf, int64(len(compressed)))
+	buf = append(buf, compressed...)
+	buf = append(buf, w.header.Sync[:]...)
+	_, err = w.w.Write(buf)
+	return err
+}
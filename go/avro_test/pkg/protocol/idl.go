@@ -0,0 +1,926 @@
+package protocol
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"test/avro/pkg/schema"
+)
+
+// ParseIDLFile parses an Avro IDL protocol, see ParseIDL
+func ParseIDLFile(path string) (*Protocol, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseIDL(data, path)
+}
+
+// ParseIDL parses an Avro IDL (.avdl) protocol, see
+// https://avro.apache.org/docs/1.11.1/idl-language/. filename is used in the errors and
+// imports are read relative to it. The error is an IDLErrorList
+func ParseIDL(data []byte, filename string) (*Protocol, error) {
+	b := &idlBuilder{positions: make(map[*schema.Object]position), imported: make(map[string]bool)}
+	if abs, err := filepath.Abs(filename); err == nil {
+		b.imported[abs] = true
+	}
+	tree, err := b.parseFile(string(data), filename)
+	if err != nil {
+		return nil, err
+	}
+	p, err := parseValue(tree)
+	var errs schema.ErrorList
+	if errors.As(err, &errs) {
+		res := make(IDLErrorList, 0, len(errs))
+		for _, e := range errs {
+			res = append(res, errorAt(b.locate(tree, e.Path, filename), "%s", e.Message))
+		}
+		return nil, res
+	}
+	return p, err
+}
+
+// idlBuilder converts IDL to the JSON tree of the equivalent .avpr, so that the checks of
+// Parse apply. The position of each object is kept to report the errors in the IDL
+type idlBuilder struct {
+	positions map[*schema.Object]position
+	imported  map[string]bool
+}
+
+type idlParser struct {
+	b      *idlBuilder
+	tokens []token
+	next   int
+	dir    string
+}
+
+func (b *idlBuilder) parseFile(src string, filename string) (*schema.Object, error) {
+	tokens, err := tokenize(src, filename)
+	if err != nil {
+		return nil, IDLErrorList{err.(*IDLError)}
+	}
+	p := &idlParser{b: b, tokens: tokens, dir: filepath.Dir(filename)}
+	protocol, err := p.protocol()
+	if err != nil {
+		var list IDLErrorList
+		if errors.As(err, &list) {
+			return nil, list
+		}
+		return nil, IDLErrorList{err.(*IDLError)}
+	}
+	return protocol, nil
+}
+
+// locate returns the position of the deepest object on the JSON path
+func (b *idlBuilder) locate(tree interface{}, path string, filename string) position {
+	res := position{File: filename, Line: 1, Column: 1}
+	if object, ok := tree.(*schema.Object); ok {
+		if pos, found := b.positions[object]; found {
+			res = pos
+		}
+	}
+	node := tree
+	for _, step := range splitPath(path) {
+		switch v := node.(type) {
+		case *schema.Object:
+			node, _ = v.Get(step)
+		case []interface{}:
+			i, err := strconv.Atoi(step)
+			if err != nil || i >= len(v) {
+				return res
+			}
+			node = v[i]
+		default:
+			return res
+		}
+		if object, ok := node.(*schema.Object); ok {
+			if pos, found := b.positions[object]; found {
+				res = pos
+			}
+		}
+	}
+	return res
+}
+
+var pathStep = regexp.MustCompile(`\.([A-Za-z_][A-Za-z0-9_]*)|\[(\d+)\]|\[("(?:[^"\\]|\\.)*")\]`)
+
+func splitPath(path string) []string {
+	var steps []string
+	for _, match := range pathStep.FindAllStringSubmatch(path, -1) {
+		switch {
+		case match[1] != "":
+			steps = append(steps, match[1])
+		case match[2] != "":
+			steps = append(steps, match[2])
+		default:
+			key, _ := strconv.Unquote(match[3])
+			steps = append(steps, key)
+		}
+	}
+	return steps
+}
+
+func (p *idlParser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *idlParser) advance() token {
+	t := p.tokens[p.next]
+	if t.kind != tokenEOF {
+		p.next++
+	}
+	return t
+}
+
+func (p *idlParser) isPunct(text string) bool {
+	return p.peek().is(tokenPunct, text)
+}
+
+func (p *idlParser) isKeyword(text string) bool {
+	return p.peek().is(tokenIdent, text)
+}
+
+func (p *idlParser) expect(text string) (token, error) {
+	t := p.advance()
+	if (t.kind == tokenPunct || t.kind == tokenIdent) && t.text == text {
+		return t, nil
+	}
+	return t, errorAt(t.pos, "expected %q, got %s", text, t)
+}
+
+func (p *idlParser) identifier() (token, error) {
+	t := p.advance()
+	if t.kind != tokenIdent && t.kind != tokenQuotedIdent {
+		return t, errorAt(t.pos, "expected an identifier, got %s", t)
+	}
+	return t, nil
+}
+
+func (p *idlParser) object(pos position, members ...schema.Member) *schema.Object {
+	object := &schema.Object{Members: members}
+	p.b.positions[object] = pos
+	return object
+}
+
+type annotation struct {
+	name  string
+	value interface{}
+	pos   position
+}
+
+// annotations parses @name(json) annotations; doc is the comment before the first token
+func (p *idlParser) annotations() (annotations []annotation, doc string, err error) {
+	doc = p.peek().doc
+	for p.isPunct("@") {
+		at := p.advance()
+		name, err := p.annotationName()
+		if err != nil {
+			return nil, "", err
+		}
+		_, err = p.expect("(")
+		if err != nil {
+			return nil, "", err
+		}
+		value, err := p.jsonValue()
+		if err != nil {
+			return nil, "", err
+		}
+		_, err = p.expect(")")
+		if err != nil {
+			return nil, "", err
+		}
+		annotations = append(annotations, annotation{name: name, value: value, pos: at.pos})
+	}
+	return annotations, doc, nil
+}
+
+// annotationName allows dashes, e.g. @java-class
+func (p *idlParser) annotationName() (string, error) {
+	t, err := p.identifier()
+	if err != nil {
+		return "", err
+	}
+	name := t.text
+	for p.isPunct("-") {
+		p.advance()
+		part, err := p.identifier()
+		if err != nil {
+			return "", err
+		}
+		name += "-" + part.text
+	}
+	return name, nil
+}
+
+func (p *idlParser) protocol() (*schema.Object, error) {
+	annotations, doc, err := p.annotations()
+	if err != nil {
+		return nil, err
+	}
+	start, err := p.expect("protocol")
+	if err != nil {
+		return nil, err
+	}
+	if doc == "" {
+		doc = start.doc
+	}
+	name, err := p.identifier()
+	if err != nil {
+		return nil, err
+	}
+	protocol := p.object(start.pos, schema.Member{Key: "protocol", Value: name.text})
+	props := applyNamedAnnotations(protocol, annotations)
+	if doc != "" {
+		protocol.Set("doc", doc)
+	}
+	types := []interface{}{}
+	messages := p.object(start.pos)
+
+	_, err = p.expect("{")
+	if err != nil {
+		return nil, err
+	}
+	for !p.isPunct("}") {
+		if p.peek().kind == tokenEOF {
+			return nil, errorAt(p.peek().pos, "expected \"}\" at the end of protocol %s", name.text)
+		}
+		err = p.declaration(&types, messages)
+		if err != nil {
+			return nil, err
+		}
+	}
+	p.advance()
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, errorAt(t.pos, "unexpected %s after the protocol", t)
+	}
+
+	protocol.Set("types", types)
+	protocol.Set("messages", messages)
+	protocol.Members = append(protocol.Members, props...)
+	return protocol, nil
+}
+
+// applyNamedAnnotations sets @namespace and @aliases and returns the other annotations
+func applyNamedAnnotations(object *schema.Object, annotations []annotation) []schema.Member {
+	var props []schema.Member
+	for _, a := range annotations {
+		switch a.name {
+		case "namespace", "aliases":
+			object.Set(a.name, a.value)
+		default:
+			props = append(props, schema.Member{Key: a.name, Value: a.value})
+		}
+	}
+	return props
+}
+
+func (p *idlParser) declaration(types *[]interface{}, messages *schema.Object) error {
+	annotations, doc, err := p.annotations()
+	if err != nil {
+		return err
+	}
+	t := p.peek()
+	if t.kind == tokenIdent {
+		switch t.text {
+		case "import":
+			if len(annotations) > 0 {
+				return errorAt(t.pos, "an import can't have annotations")
+			}
+			return p.importDeclaration(types, messages)
+		case "record", "error", "enum", "fixed":
+			definition, err := p.namedType(annotations, doc)
+			if err != nil {
+				return err
+			}
+			*types = append(*types, definition)
+			return nil
+		}
+	}
+	return p.message(annotations, doc, messages)
+}
+
+func (p *idlParser) namedType(annotations []annotation, doc string) (*schema.Object, error) {
+	keyword := p.advance()
+	if doc == "" {
+		doc = keyword.doc
+	}
+	name, err := p.identifier()
+	if err != nil {
+		return nil, err
+	}
+	definition := p.object(keyword.pos,
+		schema.Member{Key: "type", Value: keyword.text},
+		schema.Member{Key: "name", Value: name.text})
+	props := applyNamedAnnotations(definition, annotations)
+	if doc != "" {
+		definition.Set("doc", doc)
+	}
+
+	switch keyword.text {
+	case "record", "error":
+		_, err = p.expect("{")
+		if err != nil {
+			return nil, err
+		}
+		fields := []interface{}{}
+		for !p.isPunct("}") {
+			if p.peek().kind == tokenEOF {
+				return nil, errorAt(p.peek().pos, "expected \"}\" at the end of %s %s", keyword.text, name.text)
+			}
+			declared, err := p.fieldDeclaration(";")
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, declared...)
+		}
+		p.advance()
+		definition.Set("fields", fields)
+	case "enum":
+		_, err = p.expect("{")
+		if err != nil {
+			return nil, err
+		}
+		symbols := []interface{}{}
+		for !p.isPunct("}") {
+			symbol, err := p.identifier()
+			if err != nil {
+				return nil, err
+			}
+			symbols = append(symbols, symbol.text)
+			if !p.isPunct(",") {
+				break
+			}
+			p.advance()
+		}
+		_, err = p.expect("}")
+		if err != nil {
+			return nil, err
+		}
+		definition.Set("symbols", symbols)
+		if p.isPunct("=") {
+			p.advance()
+			symbol, err := p.identifier()
+			if err != nil {
+				return nil, err
+			}
+			definition.Set("default", symbol.text)
+			_, err = p.expect(";")
+			if err != nil {
+				return nil, err
+			}
+		} else if p.isPunct(";") {
+			p.advance()
+		}
+	case "fixed":
+		_, err = p.expect("(")
+		if err != nil {
+			return nil, err
+		}
+		size := p.advance()
+		if size.kind != tokenNumber {
+			return nil, errorAt(size.pos, "expected the size of fixed %s, got %s", name.text, size)
+		}
+		definition.Set("size", json.Number(size.text))
+		_, err = p.expect(")")
+		if err != nil {
+			return nil, err
+		}
+		_, err = p.expect(";")
+		if err != nil {
+			return nil, err
+		}
+	}
+	definition.Members = append(definition.Members, props...)
+	return definition, nil
+}
+
+// fieldAttributes are the annotations of a field declaration that belong to the field
+// rather than to its type
+var fieldAttributes = map[string]bool{"order": true, "aliases": true}
+
+// fieldDeclaration parses `[annotations] type [annotations] name [= default], ...` up to
+// end, i.e. ";" for record fields or ")" for message parameters
+func (p *idlParser) fieldDeclaration(end string) ([]interface{}, error) {
+	annotations, doc, err := p.annotations()
+	if err != nil {
+		return nil, err
+	}
+	typeStart := p.peek()
+	if doc == "" {
+		doc = typeStart.doc
+	}
+	var fieldProps []annotation
+	var typeProps []annotation
+	for _, a := range annotations {
+		if fieldAttributes[a.name] {
+			fieldProps = append(fieldProps, a)
+		} else {
+			typeProps = append(typeProps, a)
+		}
+	}
+	fieldType, nullable, err := p.fieldType(typeProps)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields []interface{}
+	for {
+		variableAnnotations, variableDoc, err := p.annotations()
+		if err != nil {
+			return nil, err
+		}
+		name, err := p.identifier()
+		if err != nil {
+			return nil, err
+		}
+		field := p.object(name.pos, schema.Member{Key: "name", Value: name.text})
+		if variableDoc == "" {
+			variableDoc = name.doc
+		}
+		if variableDoc != "" {
+			field.Set("doc", variableDoc)
+		} else if doc != "" {
+			field.Set("doc", doc)
+		}
+		fieldTypeValue := fieldType
+		var defaultValue interface{}
+		hasDefault := false
+		if p.isPunct("=") {
+			p.advance()
+			defaultValue, err = p.jsonValue()
+			if err != nil {
+				return nil, err
+			}
+			hasDefault = true
+		}
+		if nullable {
+			// The branch of the default comes first
+			if hasDefault && defaultValue != nil {
+				fieldTypeValue = []interface{}{fieldType, "null"}
+			} else {
+				fieldTypeValue = []interface{}{"null", fieldType}
+			}
+		}
+		field.Set("type", fieldTypeValue)
+		if hasDefault {
+			field.Set("default", defaultValue)
+		}
+		for _, a := range append(append([]annotation{}, fieldProps...), variableAnnotations...) {
+			field.Set(a.name, a.value)
+		}
+		fields = append(fields, field)
+
+		if !p.isPunct(",") {
+			break
+		}
+		p.advance()
+		if end == ")" {
+			// Each parameter has its own type
+			break
+		}
+	}
+	if end == ";" {
+		_, err = p.expect(";")
+		if err != nil {
+			return nil, err
+		}
+	}
+	return fields, nil
+}
+
+// fieldType parses a type and its `?` suffix
+func (p *idlParser) fieldType(annotations []annotation) (value interface{}, nullable bool, err error) {
+	value, err = p.typeReference(annotations)
+	if err != nil {
+		return nil, false, err
+	}
+	if p.isPunct("?") {
+		p.advance()
+		return value, true, nil
+	}
+	return value, false, nil
+}
+
+var primitiveTypes = map[string]bool{"null": true, "boolean": true, "int": true, "long": true, "float": true, "double": true, "bytes": true, "string": true}
+
+// logicalAliases are the IDL shortcuts for logical types
+var logicalAliases = map[string][2]string{
+	"date":               {"int", "date"},
+	"time_ms":            {"int", "time-millis"},
+	"timestamp_ms":       {"long", "timestamp-millis"},
+	"local_timestamp_ms": {"long", "local-timestamp-millis"},
+	"uuid":               {"string", "uuid"},
+}
+
+// typeReference parses a type; annotations become attributes of the type
+func (p *idlParser) typeReference(annotations []annotation) (interface{}, error) {
+	t := p.advance()
+	withAnnotations := func(object *schema.Object) *schema.Object {
+		for _, a := range annotations {
+			object.Set(a.name, a.value)
+		}
+		return object
+	}
+	if t.kind == tokenIdent {
+		switch {
+		case t.text == "array" || t.text == "map":
+			_, err := p.expect("<")
+			if err != nil {
+				return nil, err
+			}
+			inner, err := p.typeReference(nil)
+			if err != nil {
+				return nil, err
+			}
+			_, err = p.expect(">")
+			if err != nil {
+				return nil, err
+			}
+			key := "items"
+			if t.text == "map" {
+				key = "values"
+			}
+			return withAnnotations(p.object(t.pos, schema.Member{Key: "type", Value: t.text}, schema.Member{Key: key, Value: inner})), nil
+		case t.text == "union":
+			if len(annotations) > 0 {
+				return nil, errorAt(annotations[0].pos, "a union can't have annotations")
+			}
+			_, err := p.expect("{")
+			if err != nil {
+				return nil, err
+			}
+			branches := []interface{}{}
+			for {
+				branch, err := p.typeReference(nil)
+				if err != nil {
+					return nil, err
+				}
+				branches = append(branches, branch)
+				if !p.isPunct(",") {
+					break
+				}
+				p.advance()
+			}
+			_, err = p.expect("}")
+			if err != nil {
+				return nil, err
+			}
+			return branches, nil
+		case t.text == "decimal":
+			_, err := p.expect("(")
+			if err != nil {
+				return nil, err
+			}
+			precision := p.advance()
+			if precision.kind != tokenNumber {
+				return nil, errorAt(precision.pos, "expected the precision of the decimal, got %s", precision)
+			}
+			object := p.object(t.pos,
+				schema.Member{Key: "type", Value: "bytes"},
+				schema.Member{Key: "logicalType", Value: "decimal"},
+				schema.Member{Key: "precision", Value: json.Number(precision.text)})
+			if p.isPunct(",") {
+				p.advance()
+				scale := p.advance()
+				if scale.kind != tokenNumber {
+					return nil, errorAt(scale.pos, "expected the scale of the decimal, got %s", scale)
+				}
+				object.Set("scale", json.Number(scale.text))
+			}
+			_, err = p.expect(")")
+			if err != nil {
+				return nil, err
+			}
+			return withAnnotations(object), nil
+		case t.text == "void":
+			if len(annotations) > 0 {
+				return withAnnotations(p.object(t.pos, schema.Member{Key: "type", Value: "null"})), nil
+			}
+			return "null", nil
+		case primitiveTypes[t.text]:
+			if len(annotations) > 0 {
+				return withAnnotations(p.object(t.pos, schema.Member{Key: "type", Value: t.text})), nil
+			}
+			return t.text, nil
+		}
+		if logical, found := logicalAliases[t.text]; found {
+			return withAnnotations(p.object(t.pos,
+				schema.Member{Key: "type", Value: logical[0]},
+				schema.Member{Key: "logicalType", Value: logical[1]})), nil
+		}
+	}
+	if t.kind != tokenIdent && t.kind != tokenQuotedIdent {
+		return nil, errorAt(t.pos, "expected a type, got %s", t)
+	}
+	if len(annotations) > 0 {
+		return nil, errorAt(annotations[0].pos, "@%s can't annotate a reference to %s", annotations[0].name, t.text)
+	}
+	return t.text, nil
+}
+
+func (p *idlParser) message(annotations []annotation, doc string, messages *schema.Object) error {
+	start := p.peek()
+	if doc == "" {
+		doc = start.doc
+	}
+	response, err := p.typeReference(nil)
+	if err != nil {
+		return err
+	}
+	name, err := p.identifier()
+	if err != nil {
+		return err
+	}
+	message := p.object(start.pos)
+	if doc != "" {
+		message.Set("doc", doc)
+	}
+	_, err = p.expect("(")
+	if err != nil {
+		return err
+	}
+	request := []interface{}{}
+	for !p.isPunct(")") {
+		params, err := p.fieldDeclaration(")")
+		if err != nil {
+			return err
+		}
+		request = append(request, params...)
+	}
+	p.advance()
+	message.Set("request", request)
+	message.Set("response", response)
+
+	switch {
+	case p.isKeyword("throws"):
+		p.advance()
+		errorTypes := []interface{}{}
+		for {
+			errorType, err := p.identifier()
+			if err != nil {
+				return err
+			}
+			errorTypes = append(errorTypes, errorType.text)
+			if !p.isPunct(",") {
+				break
+			}
+			p.advance()
+		}
+		message.Set("errors", errorTypes)
+	case p.isKeyword("oneway"):
+		p.advance()
+		message.Set("one-way", true)
+	}
+	_, err = p.expect(";")
+	if err != nil {
+		return err
+	}
+	for _, a := range annotations {
+		message.Set(a.name, a.value)
+	}
+	if _, found := messages.Get(name.text); found {
+		return errorAt(name.pos, "message %s is already defined", name.text)
+	}
+	messages.Set(name.text, message)
+	return nil
+}
+
+func (p *idlParser) importDeclaration(types *[]interface{}, messages *schema.Object) error {
+	start := p.advance()
+	kind, err := p.identifier()
+	if err != nil {
+		return err
+	}
+	file := p.advance()
+	if file.kind != tokenString {
+		return errorAt(file.pos, "expected the path of the imported file, got %s", file)
+	}
+	_, err = p.expect(";")
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(p.dir, file.text)
+	if abs, err := filepath.Abs(path); err == nil {
+		if p.b.imported[abs] {
+			return nil
+		}
+		p.b.imported[abs] = true
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return errorAt(start.pos, "import: %s", err)
+	}
+
+	var imported interface{}
+	switch kind.text {
+	case "idl":
+		imported, err = p.b.parseFile(string(data), path)
+		if err != nil {
+			return err
+		}
+	case "protocol", "schema":
+		imported, err = schema.DecodeJSON(data)
+		if err != nil {
+			return errorAt(start.pos, "import %s: %s", file.text, err)
+		}
+		p.b.markPositions(imported, start.pos)
+	default:
+		return errorAt(kind.pos, "expected idl, protocol or schema, got %s", kind)
+	}
+
+	if kind.text == "schema" {
+		*types = append(*types, imported)
+		return nil
+	}
+	object, ok := imported.(*schema.Object)
+	if !ok {
+		return errorAt(start.pos, "import %s: not a protocol", file.text)
+	}
+	namespace := importedNamespace(object)
+	if importedTypes, found := object.Get("types"); found {
+		if list, ok := importedTypes.([]interface{}); ok {
+			for _, t := range list {
+				// The types keep the namespace of the imported protocol
+				if definition, ok := t.(*schema.Object); ok && namespace != "" {
+					if _, found := definition.Get("namespace"); !found {
+						definition.Set("namespace", namespace)
+					}
+				}
+				*types = append(*types, t)
+			}
+		}
+	}
+	if importedMessages, found := object.Get("messages"); found {
+		if messagesObject, ok := importedMessages.(*schema.Object); ok {
+			for _, member := range messagesObject.Members {
+				if message, ok := member.Value.(*schema.Object); ok && namespace != "" {
+					qualifyMessage(message, namespace)
+				}
+				messages.Set(member.Key, member.Value)
+			}
+		}
+	}
+	return nil
+}
+
+func importedNamespace(protocol *schema.Object) string {
+	if namespace, found := protocol.Get("namespace"); found {
+		if s, ok := namespace.(string); ok {
+			return s
+		}
+	}
+	if name, found := protocol.Get("protocol"); found {
+		if s, ok := name.(string); ok {
+			namespace, _ := schema.SplitName(s)
+			return namespace
+		}
+	}
+	return ""
+}
+
+// qualifyMessage qualifies the type references of an imported message, which would
+// otherwise resolve in the namespace of the importing protocol
+func qualifyMessage(message *schema.Object, namespace string) {
+	if request, found := message.Get("request"); found {
+		if params, ok := request.([]interface{}); ok {
+			for _, param := range params {
+				if field, ok := param.(*schema.Object); ok {
+					if t, found := field.Get("type"); found {
+						field.Set("type", qualify(t, namespace))
+					}
+				}
+			}
+		}
+	}
+	for _, key := range []string{"response", "errors"} {
+		if t, found := message.Get(key); found {
+			message.Set(key, qualify(t, namespace))
+		}
+	}
+}
+
+func qualify(t interface{}, namespace string) interface{} {
+	switch v := t.(type) {
+	case string:
+		if primitiveTypes[v] || strings.Contains(v, ".") {
+			return v
+		}
+		return namespace + "." + v
+	case []interface{}:
+		branches := make([]interface{}, 0, len(v))
+		for _, branch := range v {
+			branches = append(branches, qualify(branch, namespace))
+		}
+		return branches
+	case *schema.Object:
+		for _, key := range []string{"items", "values"} {
+			if inner, found := v.Get(key); found {
+				v.Set(key, qualify(inner, namespace))
+			}
+		}
+		if _, found := v.Get("name"); found {
+			if _, found := v.Get("namespace"); !found {
+				v.Set("namespace", namespace)
+			}
+		}
+	}
+	return t
+}
+
+// markPositions reports the errors of an imported JSON file at the import
+func (b *idlBuilder) markPositions(value interface{}, pos position) {
+	switch v := value.(type) {
+	case *schema.Object:
+		b.positions[v] = pos
+		for _, member := range v.Members {
+			b.markPositions(member.Value, pos)
+		}
+	case []interface{}:
+		for _, item := range v {
+			b.markPositions(item, pos)
+		}
+	}
+}
+
+// jsonValue parses a JSON value made of IDL tokens, i.e. a default or an annotation value
+func (p *idlParser) jsonValue() (interface{}, error) {
+	t := p.advance()
+	switch t.kind {
+	case tokenString:
+		return t.text, nil
+	case tokenNumber:
+		var number json.Number
+		err := json.Unmarshal([]byte(t.text), &number)
+		if err != nil {
+			return nil, errorAt(t.pos, "invalid number %s", t.text)
+		}
+		return number, nil
+	case tokenIdent:
+		switch t.text {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+	case tokenPunct:
+		switch t.text {
+		case "[":
+			array := []interface{}{}
+			for !p.isPunct("]") {
+				item, err := p.jsonValue()
+				if err != nil {
+					return nil, err
+				}
+				array = append(array, item)
+				if !p.isPunct(",") {
+					break
+				}
+				p.advance()
+			}
+			_, err := p.expect("]")
+			return array, err
+		case "{":
+			object := p.object(t.pos)
+			for !p.isPunct("}") {
+				key := p.advance()
+				if key.kind != tokenString {
+					return nil, errorAt(key.pos, "expected a string key, got %s", key)
+				}
+				_, err := p.expect(":")
+				if err != nil {
+					return nil, err
+				}
+				value, err := p.jsonValue()
+				if err != nil {
+					return nil, err
+				}
+				object.Set(key.text, value)
+				if !p.isPunct(",") {
+					break
+				}
+				p.advance()
+			}
+			_, err := p.expect("}")
+			return object, err
+		}
+	}
+	return nil, errorAt(t.pos, "expected a JSON value, got %s", t)
+}
+
+func unquote(raw string) (string, error) {
+	var s string
+	err := json.Unmarshal([]byte(raw), &s)
+	if err != nil {
+		return "", fmt.Errorf("not a JSON string")
+	}
+	return s, nil
+}
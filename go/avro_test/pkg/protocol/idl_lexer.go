@@ -0,0 +1,266 @@
+package protocol
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	// tokenQuotedIdent is an identifier in backquotes, never a keyword
+	tokenQuotedIdent
+	tokenString
+	tokenNumber
+	tokenPunct
+)
+
+type position struct {
+	File   string
+	Line   int
+	Column int
+}
+
+func (p position) String() string {
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+}
+
+type token struct {
+	kind tokenKind
+	// text is the identifier, the punctuation, the number or the unquoted string
+	text string
+	// raw is the string literal with its quotes
+	raw string
+	pos position
+	// doc is the /** */ comment right before the token
+	doc string
+}
+
+func (t token) is(kind tokenKind, text string) bool {
+	return t.kind == kind && t.text == text
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of file"
+	case tokenString:
+		return t.raw
+	case tokenQuotedIdent:
+		return "`" + t.text + "`"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// IDLError is a problem in an .avdl file
+type IDLError struct {
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *IDLError) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Message)
+}
+
+func errorAt(pos position, format string, args ...interface{}) *IDLError {
+	return &IDLError{File: pos.File, Line: pos.Line, Column: pos.Column, Message: fmt.Sprintf(format, args...)}
+}
+
+// IDLErrorList has all the problems of an .avdl file and its imports
+type IDLErrorList []*IDLError
+
+func (l IDLErrorList) Error() string {
+	messages := make([]string, 0, len(l))
+	for _, err := range l {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "\n")
+}
+
+type lexer struct {
+	src    string
+	offset int
+	pos    position
+}
+
+func tokenize(src string, file string) ([]token, error) {
+	l := &lexer{src: src, pos: position{File: file, Line: 1, Column: 1}}
+	var tokens []token
+	for {
+		doc, err := l.skipSpace()
+		if err != nil {
+			return nil, err
+		}
+		t, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		t.doc = doc
+		tokens = append(tokens, t)
+		if t.kind == tokenEOF {
+			return tokens, nil
+		}
+	}
+}
+
+func (l *lexer) peek() rune {
+	r, _ := utf8.DecodeRuneInString(l.src[l.offset:])
+	return r
+}
+
+func (l *lexer) advance() rune {
+	r, size := utf8.DecodeRuneInString(l.src[l.offset:])
+	l.offset += size
+	if r == '\n' {
+		l.pos.Line++
+		l.pos.Column = 1
+	} else {
+		l.pos.Column++
+	}
+	return r
+}
+
+// skipSpace skips spaces and comments and returns the last doc comment
+func (l *lexer) skipSpace() (doc string, err error) {
+	for l.offset < len(l.src) {
+		rest := l.src[l.offset:]
+		switch {
+		case unicode.IsSpace(l.peek()):
+			l.advance()
+		case strings.HasPrefix(rest, "//"):
+			for l.offset < len(l.src) && l.peek() != '\n' {
+				l.advance()
+			}
+		case strings.HasPrefix(rest, "/*"):
+			start := l.pos
+			end := strings.Index(rest[2:], "*/")
+			if end < 0 {
+				return "", errorAt(start, "unterminated comment")
+			}
+			comment := rest[2 : 2+end]
+			for i := 0; i < end+4; {
+				_, size := utf8.DecodeRuneInString(l.src[l.offset:])
+				l.advance()
+				i += size
+			}
+			if strings.HasPrefix(comment, "*") && comment != "*" {
+				doc = cleanDoc(comment[1:])
+			} else {
+				doc = ""
+			}
+		default:
+			return doc, nil
+		}
+	}
+	return doc, nil
+}
+
+// cleanDoc removes the leading stars and the indentation of a doc comment
+func cleanDoc(comment string) string {
+	lines := strings.Split(comment, "\n")
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if i > 0 {
+			line = strings.TrimSpace(strings.TrimPrefix(line, "*"))
+		}
+		lines[i] = line
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
+func isIdentStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func isIdentPart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func (l *lexer) next() (token, error) {
+	start := l.pos
+	if l.offset >= len(l.src) {
+		return token{kind: tokenEOF, pos: start}, nil
+	}
+	r := l.peek()
+	switch {
+	case isIdentStart(r):
+		begin := l.offset
+		for l.offset < len(l.src) {
+			r = l.peek()
+			if isIdentPart(r) {
+				l.advance()
+				continue
+			}
+			// Dotted names, e.g. org.example.Record
+			if r == '.' {
+				following, _ := utf8.DecodeRuneInString(l.src[l.offset+1:])
+				if isIdentStart(following) {
+					l.advance()
+					continue
+				}
+			}
+			break
+		}
+		return token{kind: tokenIdent, text: l.src[begin:l.offset], pos: start}, nil
+	case r == '`':
+		l.advance()
+		begin := l.offset
+		for l.offset < len(l.src) && l.peek() != '`' && l.peek() != '\n' {
+			l.advance()
+		}
+		if l.offset >= len(l.src) || l.peek() != '`' {
+			return token{}, errorAt(start, "unterminated quoted identifier")
+		}
+		text := l.src[begin:l.offset]
+		l.advance()
+		return token{kind: tokenQuotedIdent, text: text, pos: start}, nil
+	case r == '"':
+		begin := l.offset
+		l.advance()
+		for {
+			if l.offset >= len(l.src) || l.peek() == '\n' {
+				return token{}, errorAt(start, "unterminated string")
+			}
+			c := l.advance()
+			if c == '\\' && l.offset < len(l.src) {
+				l.advance()
+				continue
+			}
+			if c == '"' {
+				break
+			}
+		}
+		raw := l.src[begin:l.offset]
+		text, err := unquote(raw)
+		if err != nil {
+			return token{}, errorAt(start, "invalid string %s: %s", raw, err)
+		}
+		return token{kind: tokenString, text: text, raw: raw, pos: start}, nil
+	case unicode.IsDigit(r) || r == '-' && l.offset+1 < len(l.src) && isDigit(l.src[l.offset+1]):
+		begin := l.offset
+		l.advance()
+		for l.offset < len(l.src) {
+			r = l.peek()
+			if unicode.IsDigit(r) || r == '.' || r == 'e' || r == 'E' || r == '+' || r == '-' {
+				l.advance()
+				continue
+			}
+			break
+		}
+		return token{kind: tokenNumber, text: l.src[begin:l.offset], pos: start}, nil
+	case strings.ContainsRune("{}()<>[],;=@?:-", r):
+		l.advance()
+		return token{kind: tokenPunct, text: string(r), pos: start}, nil
+	}
+	return token{}, errorAt(start, "unexpected character %q", r)
+}
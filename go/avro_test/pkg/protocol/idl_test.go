@@ -0,0 +1,124 @@
+package protocol
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"test/avro/pkg/schema"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIDL(t *testing.T) {
+	p, err := ParseIDLFile("testdata/idl/chat.avdl")
+	require.NoError(t, err)
+	require.Equal(t, "example.chat.Chat", p.Name)
+	require.Equal(t, "Chat rooms and their messages", p.Doc)
+	require.Equal(t, []schema.Member{{Key: "java-package", Value: "example"}}, p.Props)
+
+	var names []string
+	for _, t := range p.Types {
+		names = append(names, t.Name)
+	}
+	require.Equal(t, []string{
+		"example.common.Audit", "example.hash.Digest", "example.chat.Visibility", "example.chat.Token",
+		"example.chat.LongList", "example.chat.Room", "example.chat.RoomFull",
+	}, names)
+	require.Equal(t, "Kinds of rooms", p.Type("Visibility").Doc)
+	require.Equal(t, "PUBLIC", p.Type("Visibility").EnumDefault)
+	require.Equal(t, 16, p.Type("Token").Size)
+
+	room := p.Type("Room")
+	require.Equal(t, "descending", room.Field("created").Order)
+	require.Equal(t, schema.Array, room.Field("members").Type.Type)
+	require.Equal(t, schema.Map, room.Field("counters").Type.Type)
+	topic := room.Field("topic").Type
+	require.Equal(t, schema.Null, topic.Branches[0].Type)
+	// The branch of a non-null default comes first
+	limit := room.Field("limit").Type
+	require.Equal(t, schema.Int, limit.Branches[0].Type)
+	require.Equal(t, json.Number("10"), room.Field("limit").Default)
+	fee := room.Field("fee").Type
+	require.Equal(t, "decimal", fee.LogicalType)
+	require.Equal(t, 9, fee.Precision)
+	require.Equal(t, 2, fee.Scale)
+	require.Equal(t, "uuid", room.Field("id").Type.LogicalType)
+	require.Equal(t, "date", room.Field("opened").Type.LogicalType)
+	require.Equal(t, "time-micros", room.Field("at").Type.LogicalType)
+	require.Same(t, p.Type("example.common.Audit"), room.Field("audit").Type)
+	require.NotNil(t, room.Field("record"))
+	require.Equal(t, []string{"old_count"}, room.Field("count").Aliases)
+
+	open := p.Message("open")
+	require.Equal(t, "Opens a room", open.Doc)
+	require.Same(t, room, open.Response)
+	require.Equal(t, 2, len(open.Request))
+	require.Equal(t, "PUBLIC", open.Request[1].Default)
+	require.Equal(t, []*schema.Schema{p.Type("RoomFull")}, open.Errors)
+	require.True(t, p.Message("close").OneWay)
+	require.Equal(t, schema.Null, p.Message("ping").Response.Type)
+	// Messages are imported with the types
+	require.True(t, p.Message("audit").OneWay)
+}
+
+func TestIDLRoundTripsThroughGoavro(t *testing.T) {
+	p, err := ParseIDLFile("testdata/idl/chat.avdl")
+	require.NoError(t, err)
+
+	// The .avpr is the same protocol
+	data, err := json.Marshal(p)
+	require.NoError(t, err)
+	again, err := Parse(data)
+	require.NoError(t, err)
+	data2, err := json.Marshal(again)
+	require.NoError(t, err)
+	require.JSONEq(t, string(data), string(data2))
+
+	// Each type is a valid .avsc; goavro has no error type
+	for _, s := range p.Types {
+		if s.Type == schema.ErrorRecord {
+			continue
+		}
+		_, err = goavro.NewCodec(s.String())
+		require.NoError(t, err, s.Name)
+	}
+
+	codec, err := goavro.NewCodec(p.Type("LongList").String())
+	require.NoError(t, err)
+	native, _, err := codec.NativeFromTextual([]byte(`{"next":{"example.chat.LongList":{"next":null}}}`))
+	require.NoError(t, err)
+	binary, err := codec.BinaryFromNative(nil, native)
+	require.NoError(t, err)
+	native, _, err = codec.NativeFromBinary(binary)
+	require.NoError(t, err)
+	textual, err := codec.TextualFromNative(nil, native)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"next":{"example.chat.LongList":{"next":null}}}`, string(textual))
+}
+
+func TestParseIDLReportsPositions(t *testing.T) {
+	_, err := ParseIDLFile("testdata/idl/broken.avdl")
+	var errs IDLErrorList
+	require.True(t, errors.As(err, &errs))
+	require.Equal(t, 3, len(errs))
+	require.Equal(t, "testdata/idl/broken.avdl", errs[0].File)
+	require.Equal(t, []int{3, 5, 8}, []int{errs[0].Line, errs[1].Line, errs[2].Line})
+	require.Contains(t, errs[0].Message, `unknown type "Missing"`)
+	require.Equal(t, 13, errs[0].Column)
+}
+
+func TestParseIDLSyntaxErrors(t *testing.T) {
+	for idl, message := range map[string]string{
+		"protocol P {\n  record R {\n    int x\n  }\n}":               `test.avdl:4:3: expected ";", got "}"`,
+		"protocol P {\n  record R { string s = \"x }\n}":              "test.avdl:2:25: unterminated string",
+		"protocol P {\n  fixed F(x);\n}":                              `test.avdl:2:11: expected the size of fixed F, got "x"`,
+		"protocol P {\n  import idl \"missing.avdl\";\n}":             "test.avdl:2:3: import:",
+		"protocol P {\n  record S { @logicalType(\"date\") R r; }\n}": "test.avdl:2:14: @logicalType can't annotate a reference to R",
+		"protocol P {}\nprotocol Q {}":                                `test.avdl:2:1: unexpected "protocol" after the protocol`,
+	} {
+		_, err := ParseIDL([]byte(idl), "test.avdl")
+		require.ErrorContains(t, err, message, idl)
+	}
+}
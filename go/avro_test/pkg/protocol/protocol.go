@@ -1,9 +1,277 @@
+// Package protocol parses Avro protocols, see
+// https://avro.apache.org/docs/1.11.1/specification/#protocol-declaration
 package protocol
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+
+	"test/avro/pkg/schema"
 )
 
-func Parse() error {
-	return errors.New("Test")
+// Protocol is a parsed .avpr document
+type Protocol struct {
+	// Name is the full name of the protocol
+	Name string
+	Doc  string
+	// Types are all the named types of the protocol in definition order, including the
+	// ones defined inline in other types or in messages
+	Types    []*schema.Schema
+	Messages []*Message
+	Props    []schema.Member
+}
+
+// Message is a remote procedure of a protocol
+type Message struct {
+	Name    string
+	Doc     string
+	Request []*schema.Field
+	// Response is the null schema for messages without response
+	Response *schema.Schema
+	// Errors are the declared error types; the errors union of the spec also has "string"
+	// for system errors, see ErrorUnion
+	Errors []*schema.Schema
+	OneWay bool
+	Props  []schema.Member
+	// Path is where the message is in the parsed document
+	Path string
+}
+
+// Namespace of the protocol, the default namespace of its types
+func (p *Protocol) Namespace() string {
+	namespace, _ := schema.SplitName(p.Name)
+	return namespace
+}
+
+// Type returns the named type name, relative to the protocol namespace if not qualified
+func (p *Protocol) Type(name string) *schema.Schema {
+	fullName := schema.FullName(name, p.Namespace())
+	for _, t := range p.Types {
+		if t.Name == fullName || t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// Message returns the message named name, nil if missing
+func (p *Protocol) Message(name string) *Message {
+	for _, m := range p.Messages {
+		if m.Name == name {
+			return m
+		}
+	}
+	return nil
+}
+
+// ErrorUnion is the union a message response can fail with: "string" then the declared errors
+func (m *Message) ErrorUnion() *schema.Schema {
+	branches := append([]*schema.Schema{{Type: schema.String}}, m.Errors...)
+	return &schema.Schema{Type: schema.Union, Branches: branches}
+}
+
+// Parse parses an .avpr document. The error is a schema.ErrorList with the JSON path of
+// each problem
+func Parse(data []byte) (*Protocol, error) {
+	value, err := schema.DecodeJSON(data)
+	if err != nil {
+		var parseErr *schema.Error
+		if errors.As(err, &parseErr) {
+			return nil, schema.ErrorList{parseErr}
+		}
+		return nil, err
+	}
+	return parseValue(value)
+}
+
+// parseValue parses a protocol decoded by schema.DecodeJSON
+func parseValue(value interface{}) (*Protocol, error) {
+	object, ok := value.(*schema.Object)
+	if !ok {
+		return nil, schema.ErrorList{{Path: "$", Message: "a protocol is a JSON object"}}
+	}
+
+	p := schema.NewParser()
+	protocol := &Protocol{}
+	name := stringMember(p, object, "protocol", "$", true)
+	namespace := stringMember(p, object, "namespace", "$", false)
+	protocol.Name = schema.FullName(name, namespace)
+	protocol.Doc = stringMember(p, object, "doc", "$", false)
+	namespace = protocol.Namespace()
+
+	if types, found := object.Get("types"); found {
+		list, ok := types.([]interface{})
+		if !ok {
+			p.Errorf("$.types", "expected an array of named types")
+		}
+		for i, item := range list {
+			path := fmt.Sprintf("$.types[%d]", i)
+			t := p.Parse(item, path, namespace)
+			if t != nil && !t.Type.IsNamed() {
+				p.Errorf(path, "expected a named type, got %s", t.Type)
+			}
+		}
+	}
+
+	if messages, found := object.Get("messages"); found {
+		messagesObject, ok := messages.(*schema.Object)
+		if !ok {
+			p.Errorf("$.messages", "expected an object of messages")
+		} else {
+			for _, member := range messagesObject.Members {
+				message := parseMessage(p, member.Key, member.Value, "$.messages."+member.Key, namespace)
+				if message != nil {
+					protocol.Messages = append(protocol.Messages, message)
+				}
+			}
+		}
+	}
+
+	for _, member := range object.Members {
+		switch member.Key {
+		case "protocol", "namespace", "doc", "types", "messages":
+		default:
+			protocol.Props = append(protocol.Props, schema.Member{Key: member.Key, Value: schema.PlainJSON(member.Value)})
+		}
+	}
+	protocol.Types = p.Named()
+	err := p.Err()
+	if err != nil {
+		return nil, err
+	}
+	return protocol, nil
+}
+
+func parseMessage(p *schema.Parser, name string, value interface{}, path string, namespace string) *Message {
+	object, ok := value.(*schema.Object)
+	if !ok {
+		p.Errorf(path, "expected a message object")
+		return nil
+	}
+	message := &Message{Name: name, Path: path}
+	message.Doc = stringMember(p, object, "doc", path, false)
+
+	if request, found := object.Get("request"); found {
+		message.Request = p.ParseFields(request, path+".request", namespace)
+	} else {
+		p.Errorf(path, "missing \"request\"")
+	}
+
+	if response, found := object.Get("response"); found {
+		message.Response = p.Parse(response, path+".response", namespace)
+	} else {
+		p.Errorf(path, "missing \"response\"")
+	}
+
+	if errorTypes, found := object.Get("errors"); found {
+		list, ok := errorTypes.([]interface{})
+		if !ok {
+			p.Errorf(path+".errors", "expected an array of error types")
+		}
+		for i, item := range list {
+			errorPath := fmt.Sprintf("%s.errors[%d]", path, i)
+			t := p.Parse(item, errorPath, namespace)
+			if t == nil {
+				continue
+			}
+			if t.Type != schema.ErrorRecord {
+				p.Errorf(errorPath, "%s isn't declared as an error", t.TypeName())
+				continue
+			}
+			message.Errors = append(message.Errors, t)
+		}
+	}
+
+	if oneWay, found := object.Get("one-way"); found {
+		message.OneWay, ok = oneWay.(bool)
+		if !ok {
+			p.Errorf(path+".one-way", "expected a boolean")
+		}
+	}
+	if message.OneWay {
+		if message.Response != nil && message.Response.Type != schema.Null {
+			p.Errorf(path+".response", "a one-way message must have a null response")
+		}
+		if len(message.Errors) > 0 {
+			p.Errorf(path+".errors", "a one-way message can't have errors")
+		}
+	}
+
+	for _, member := range object.Members {
+		switch member.Key {
+		case "doc", "request", "response", "errors", "one-way":
+		default:
+			message.Props = append(message.Props, schema.Member{Key: member.Key, Value: schema.PlainJSON(member.Value)})
+		}
+	}
+	return message
+}
+
+func stringMember(p *schema.Parser, object *schema.Object, key string, path string, required bool) string {
+	value, found := object.Get(key)
+	if !found {
+		if required {
+			p.Errorf(path, "missing %q", key)
+		}
+		return ""
+	}
+	s, ok := value.(string)
+	if !ok {
+		p.Errorf(path+"."+key, "expected a string")
+	}
+	return s
+}
+
+// MarshalJSON writes the protocol as an .avpr document
+func (p *Protocol) MarshalJSON() ([]byte, error) {
+	namespace := p.Namespace()
+	w := schema.NewWriter()
+	object := &schema.Object{}
+	_, name := schema.SplitName(p.Name)
+	object.Set("protocol", name)
+	if namespace != "" {
+		object.Set("namespace", namespace)
+	}
+	if p.Doc != "" {
+		object.Set("doc", p.Doc)
+	}
+	types := make([]interface{}, 0, len(p.Types))
+	for _, t := range p.Types {
+		value := w.Value(t, namespace)
+		// Types defined inline in another type are already written
+		if _, isReference := value.(string); !isReference {
+			types = append(types, value)
+		}
+	}
+	object.Set("types", types)
+
+	messages := &schema.Object{}
+	for _, m := range p.Messages {
+		message := &schema.Object{}
+		if m.Doc != "" {
+			message.Set("doc", m.Doc)
+		}
+		request := make([]interface{}, 0, len(m.Request))
+		for _, field := range m.Request {
+			request = append(request, w.FieldValue(field, namespace))
+		}
+		message.Set("request", request)
+		message.Set("response", w.Value(m.Response, namespace))
+		if len(m.Errors) > 0 {
+			errorTypes := make([]interface{}, 0, len(m.Errors))
+			for _, e := range m.Errors {
+				errorTypes = append(errorTypes, w.Value(e, namespace))
+			}
+			message.Set("errors", errorTypes)
+		}
+		if m.OneWay {
+			message.Set("one-way", true)
+		}
+		message.Members = append(message.Members, m.Props...)
+		messages.Set(m.Name, message)
+	}
+	object.Set("messages", messages)
+	object.Members = append(object.Members, p.Props...)
+	return json.Marshal(object)
 }
@@ -0,0 +1,118 @@
+package protocol
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+
+	"test/avro/pkg/schema"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func parseFile(t *testing.T, path string) *Protocol {
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	p, err := Parse(data)
+	require.NoError(t, err)
+	return p
+}
+
+func TestParseProtocol(t *testing.T) {
+	p := parseFile(t, "testdata/mail.avpr")
+	require.Equal(t, "example.proto.Mail", p.Name)
+	require.Equal(t, "example.proto", p.Namespace())
+	require.Equal(t, "Sends and tracks messages", p.Doc)
+
+	var names []string
+	for _, t := range p.Types {
+		names = append(names, t.Name)
+	}
+	require.Equal(t, []string{"example.proto.Priority", "example.proto.Message", "example.hash.Digest", "example.proto.Rejected"}, names)
+
+	message := p.Type("Message")
+	require.Equal(t, schema.Record, message.Type)
+	require.Same(t, p.Type("Priority"), message.Field("priority").Type)
+	sentAt, ok := message.Field("sentAt").Type.Nullable()
+	require.True(t, ok)
+	require.Equal(t, "timestamp-millis", sentAt.LogicalType)
+	require.True(t, message.Field("sentAt").HasDefault)
+	require.Nil(t, message.Field("sentAt").Default)
+	require.Equal(t, "NORMAL", p.Type("Priority").EnumDefault)
+
+	send := p.Message("send")
+	require.Equal(t, "Returns the message id", send.Doc)
+	require.Equal(t, 1, len(send.Request))
+	require.Same(t, message, send.Request[0].Type)
+	require.Equal(t, schema.String, send.Response.Type)
+	require.Equal(t, []*schema.Schema{p.Type("Rejected")}, send.Errors)
+	require.False(t, send.OneWay)
+	require.Equal(t, 2, len(send.ErrorUnion().Branches))
+
+	ping := p.Message("ping")
+	require.True(t, ping.OneWay)
+	require.Empty(t, ping.Request)
+	require.Equal(t, []schema.Member{{Key: "java-package", Value: "example"}}, p.Props)
+}
+
+func TestProtocolJSONRoundTrip(t *testing.T) {
+	p := parseFile(t, "testdata/mail.avpr")
+	data, err := json.Marshal(p)
+	require.NoError(t, err)
+
+	again, err := Parse(data)
+	require.NoError(t, err)
+	require.Equal(t, len(p.Types), len(again.Types))
+	require.Equal(t, len(p.Messages), len(again.Messages))
+	data2, err := json.Marshal(again)
+	require.NoError(t, err)
+	require.JSONEq(t, string(data), string(data2))
+
+	// The types of the protocol are valid schemas on their own
+	_, err = goavro.NewCodec(p.Type("Message").String())
+	require.NoError(t, err)
+}
+
+func TestParseReportsJSONPaths(t *testing.T) {
+	_, err := Parse([]byte(`{
+		"protocol": "Broken",
+		"types": [
+			{"type": "record", "name": "A", "fields": [{"name": "x", "type": "Missing"}, {"name": "x", "type": "int"}]},
+			"int",
+			{"type": "enum", "name": "E", "symbols": ["A", "A"]}
+		],
+		"messages": {
+			"fire": {"request": [], "response": "int", "one-way": true},
+			"call": {"request": [{"name": "a", "type": "A"}], "response": "null", "errors": ["A"]},
+			"bad": {"response": "null"}
+		}
+	}`))
+	var errs schema.ErrorList
+	require.True(t, errors.As(err, &errs))
+
+	var paths []string
+	for _, e := range errs {
+		paths = append(paths, e.Path)
+	}
+	require.Equal(t, []string{
+		"$.types[0].fields[0].type",
+		"$.types[0].fields[1].name",
+		"$.types[1]",
+		"$.types[2].symbols[1]",
+		"$.messages.fire.response",
+		"$.messages.call.errors[0]",
+		"$.messages.bad",
+	}, paths)
+	require.Contains(t, errs[0].Message, `unknown type "Missing"`)
+	require.Contains(t, errs[5].Message, "A isn't declared as an error")
+}
+
+func TestParseReportsInvalidJSON(t *testing.T) {
+	_, err := Parse([]byte("{\n  \"protocol\": \"P\",\n  \"types\": [,]\n}"))
+	require.ErrorContains(t, err, "invalid JSON at line 3")
+
+	_, err = Parse([]byte(`{"protocol": "P", "protocol": "Q"}`))
+	require.ErrorContains(t, err, `$: duplicate key "protocol"`)
+}
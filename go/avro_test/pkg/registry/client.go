@@ -0,0 +1,158 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"test/avro/pkg/compat"
+)
+
+// Client calls a registry with the Confluent API, this one or a Confluent one. The
+// errors of the API are *Error
+type Client struct {
+	url  string
+	http *http.Client
+}
+
+// NewClient returns a client of the registry at baseURL, e.g. http://localhost:8081
+func NewClient(baseURL string) *Client {
+	return &Client{url: strings.TrimSuffix(baseURL, "/"), http: http.DefaultClient}
+}
+
+// WithHTTPClient sets the client of the requests, e.g. for timeouts or authentication
+func (c *Client) WithHTTPClient(client *http.Client) *Client {
+	c.http = client
+	return c
+}
+
+func (c *Client) do(ctx context.Context, method string, path string, body interface{}, res interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.url+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", ContentType)
+	if body != nil {
+		req.Header.Set("Content-Type", ContentType)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		registryErr := &Error{StatusCode: resp.StatusCode}
+		if json.Unmarshal(data, registryErr) != nil || registryErr.Code == 0 {
+			registryErr.Code = resp.StatusCode
+			registryErr.Message = strings.TrimSpace(string(data))
+		}
+		return registryErr
+	}
+	err = json.Unmarshal(data, res)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	return nil
+}
+
+func subjectPath(subject string) string {
+	return "/subjects/" + url.PathEscape(subject)
+}
+
+func versionPath(version int) string {
+	if version == Latest {
+		return "latest"
+	}
+	return strconv.Itoa(version)
+}
+
+// Register adds a schema to subject and returns its ID
+func (c *Client) Register(ctx context.Context, subject string, schema string) (int, error) {
+	var res struct {
+		ID int `json:"id"`
+	}
+	err := c.do(ctx, http.MethodPost, subjectPath(subject)+"/versions", schemaRequest{Schema: schema}, &res)
+	return res.ID, err
+}
+
+// Lookup returns the version of subject with the schema
+func (c *Client) Lookup(ctx context.Context, subject string, schema string) (SubjectVersion, error) {
+	var res SubjectVersion
+	err := c.do(ctx, http.MethodPost, subjectPath(subject), schemaRequest{Schema: schema}, &res)
+	return res, err
+}
+
+// Schema returns the schema with the ID id
+func (c *Client) Schema(ctx context.Context, id int) (string, error) {
+	var res schemaRequest
+	err := c.do(ctx, http.MethodGet, "/schemas/ids/"+strconv.Itoa(id), nil, &res)
+	return res.Schema, err
+}
+
+// Subjects returns the subjects
+func (c *Client) Subjects(ctx context.Context) ([]string, error) {
+	var res []string
+	err := c.do(ctx, http.MethodGet, "/subjects", nil, &res)
+	return res, err
+}
+
+// Versions returns the versions of subject
+func (c *Client) Versions(ctx context.Context, subject string) ([]int, error) {
+	var res []int
+	err := c.do(ctx, http.MethodGet, subjectPath(subject)+"/versions", nil, &res)
+	return res, err
+}
+
+// Version returns a version of subject, or its latest version for Latest
+func (c *Client) Version(ctx context.Context, subject string, version int) (SubjectVersion, error) {
+	var res SubjectVersion
+	err := c.do(ctx, http.MethodGet, subjectPath(subject)+"/versions/"+versionPath(version), nil, &res)
+	return res, err
+}
+
+// CheckCompatibility checks a schema against a version of subject, see
+// Registry.CheckCompatibility. The messages explain the incompatibilities
+func (c *Client) CheckCompatibility(ctx context.Context, subject string, version int, schema string) (bool, []string, error) {
+	var res compatibilityResponse
+	path := "/compatibility" + subjectPath(subject) + "/versions/" + versionPath(version) + "?verbose=true"
+	err := c.do(ctx, http.MethodPost, path, schemaRequest{Schema: schema}, &res)
+	return res.IsCompatible, res.Messages, err
+}
+
+func configPath(subject string) string {
+	if subject == "" {
+		return "/config"
+	}
+	return "/config/" + url.PathEscape(subject)
+}
+
+// Mode returns the compatibility mode of subject, the global one for ""
+func (c *Client) Mode(ctx context.Context, subject string) (compat.Mode, error) {
+	var res configResponse
+	err := c.do(ctx, http.MethodGet, configPath(subject), nil, &res)
+	return res.CompatibilityLevel, err
+}
+
+// SetMode sets the compatibility mode of subject, the global one for ""
+func (c *Client) SetMode(ctx context.Context, subject string, mode compat.Mode) error {
+	var res configRequest
+	return c.do(ctx, http.MethodPut, configPath(subject), configRequest{Compatibility: mode}, &res)
+}
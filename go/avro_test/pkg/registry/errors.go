@@ -0,0 +1,39 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error codes of the Confluent API
+const (
+	CodeSubjectNotFound = 40401
+	CodeVersionNotFound = 40402
+	CodeSchemaNotFound  = 40403
+	CodeIncompatible    = 409
+	CodeInvalidSchema   = 42201
+	CodeInvalidVersion  = 42202
+	CodeInvalidMode     = 42203
+	CodeInternal        = 50001
+)
+
+// Error is an error of the API, as returned by the server and the client
+type Error struct {
+	StatusCode int    `json:"-"`
+	Code       int    `json:"error_code"`
+	Message    string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("schema registry error %d: %s", e.Code, e.Message)
+}
+
+// IsCode tells whether err is an Error with code
+func IsCode(err error, code int) bool {
+	var registryErr *Error
+	return errors.As(err, &registryErr) && registryErr.Code == code
+}
+
+func subjectNotFound(subject string) error {
+	return &Error{StatusCode: 404, Code: CodeSubjectNotFound, Message: fmt.Sprintf("Subject '%s' not found.", subject)}
+}
@@ -0,0 +1,323 @@
+// Package registry is a local schema registry with the REST API of the Confluent Schema
+// Registry, see https://docs.confluent.io/platform/current/schema-registry/develop/api.html.
+// Subjects have versions of Avro schemas, registering checks the compatibility mode of
+// the subject, and the state is saved to a directory
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"test/avro/pkg/compat"
+	"test/avro/pkg/schema"
+)
+
+// DefaultMode is the compatibility mode of subjects without config, as in Confluent
+const DefaultMode = compat.Backward
+
+// SubjectVersion is a version of a subject
+type SubjectVersion struct {
+	Subject string `json:"subject"`
+	ID      int    `json:"id"`
+	Version int    `json:"version"`
+	Schema  string `json:"schema"`
+}
+
+// Registry is safe for concurrent use
+type Registry struct {
+	mu sync.Mutex
+	// path is the file of the state, empty to keep it in memory
+	path  string
+	state state
+}
+
+type state struct {
+	Schemas  []storedSchema         `json:"schemas"`
+	Subjects map[string][]version   `json:"subjects"`
+	Mode     compat.Mode            `json:"compatibilityLevel"`
+	Modes    map[string]compat.Mode `json:"subjectCompatibilityLevels"`
+}
+
+type storedSchema struct {
+	ID     int    `json:"id"`
+	Schema string `json:"schema"`
+	parsed *schema.Schema
+}
+
+type version struct {
+	Version int `json:"version"`
+	ID      int `json:"id"`
+}
+
+// NewMemory returns a registry that isn't saved
+func NewMemory() *Registry {
+	return &Registry{state: state{Subjects: make(map[string][]version), Mode: DefaultMode, Modes: make(map[string]compat.Mode)}}
+}
+
+// Open loads the registry saved in dir, or creates it
+func Open(dir string) (*Registry, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+	r := NewMemory()
+	r.path = filepath.Join(dir, "registry.json")
+	data, err := os.ReadFile(r.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(data, &r.state)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", r.path, err)
+	}
+	if r.state.Subjects == nil {
+		r.state.Subjects = make(map[string][]version)
+	}
+	if r.state.Modes == nil {
+		r.state.Modes = make(map[string]compat.Mode)
+	}
+	for i := range r.state.Schemas {
+		stored := &r.state.Schemas[i]
+		stored.parsed, err = schema.Parse([]byte(stored.Schema))
+		if err != nil {
+			return nil, fmt.Errorf("%s: schema %d: %w", r.path, stored.ID, err)
+		}
+	}
+	return r, nil
+}
+
+// save writes the state to a temporary file renamed over the previous one, so that a
+// crash keeps either state
+func (r *Registry) save() error {
+	if r.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(r.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := r.path + ".tmp"
+	err = os.WriteFile(tmp, data, 0o644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, r.path)
+}
+
+func parseSchema(s string) (*schema.Schema, error) {
+	parsed, err := schema.Parse([]byte(s))
+	if err != nil {
+		return nil, &Error{StatusCode: 422, Code: CodeInvalidSchema, Message: "Invalid schema: " + err.Error()}
+	}
+	return parsed, nil
+}
+
+// schemaID returns the ID of a schema with the same JSON, 0 if new
+func (r *Registry) schemaID(normalized string) int {
+	for _, stored := range r.state.Schemas {
+		if stored.Schema == normalized {
+			return stored.ID
+		}
+	}
+	return 0
+}
+
+func (r *Registry) schemaByID(id int) *storedSchema {
+	for i := range r.state.Schemas {
+		if r.state.Schemas[i].ID == id {
+			return &r.state.Schemas[i]
+		}
+	}
+	return nil
+}
+
+// Register adds a schema to subject and returns its ID. Registering a schema again returns
+// the same ID, a schema incompatible with the versions of the subject is an error with
+// CodeIncompatible
+func (r *Registry) Register(subject string, s string) (int, error) {
+	parsed, err := parseSchema(s)
+	if err != nil {
+		return 0, err
+	}
+	normalized := parsed.String()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	versions := r.state.Subjects[subject]
+	id := r.schemaID(normalized)
+	for _, v := range versions {
+		if v.ID == id {
+			return id, nil
+		}
+	}
+
+	var previous []*schema.Schema
+	for _, v := range versions {
+		previous = append(previous, r.schemaByID(v.ID).parsed)
+	}
+	if incompatibilities := compat.Check(r.mode(subject), parsed, previous); len(incompatibilities) > 0 {
+		return 0, &Error{StatusCode: 409, Code: CodeIncompatible, Message: "Schema being registered is incompatible with an earlier schema: " + incompatibilities.Error()}
+	}
+
+	if id == 0 {
+		id = len(r.state.Schemas) + 1
+		r.state.Schemas = append(r.state.Schemas, storedSchema{ID: id, Schema: normalized, parsed: parsed})
+	}
+	next := 1
+	if len(versions) > 0 {
+		next = versions[len(versions)-1].Version + 1
+	}
+	r.state.Subjects[subject] = append(versions, version{Version: next, ID: id})
+	return id, r.save()
+}
+
+// Lookup returns the version of subject with the schema s
+func (r *Registry) Lookup(subject string, s string) (SubjectVersion, error) {
+	parsed, err := parseSchema(s)
+	if err != nil {
+		return SubjectVersion{}, err
+	}
+	normalized := parsed.String()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	versions, found := r.state.Subjects[subject]
+	if !found {
+		return SubjectVersion{}, subjectNotFound(subject)
+	}
+	id := r.schemaID(normalized)
+	for _, v := range versions {
+		if v.ID == id {
+			return SubjectVersion{Subject: subject, ID: id, Version: v.Version, Schema: normalized}, nil
+		}
+	}
+	return SubjectVersion{}, &Error{StatusCode: 404, Code: CodeSchemaNotFound, Message: "Schema not found"}
+}
+
+// Schema returns the schema with the ID id
+func (r *Registry) Schema(id int) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := r.schemaByID(id)
+	if stored == nil {
+		return "", &Error{StatusCode: 404, Code: CodeSchemaNotFound, Message: fmt.Sprintf("Schema %d not found", id)}
+	}
+	return stored.Schema, nil
+}
+
+// Subjects returns the subjects in alphabetical order
+func (r *Registry) Subjects() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	subjects := make([]string, 0, len(r.state.Subjects))
+	for subject := range r.state.Subjects {
+		subjects = append(subjects, subject)
+	}
+	sort.Strings(subjects)
+	return subjects
+}
+
+// Versions returns the versions of subject
+func (r *Registry) Versions(subject string) ([]int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	versions, found := r.state.Subjects[subject]
+	if !found {
+		return nil, subjectNotFound(subject)
+	}
+	res := make([]int, 0, len(versions))
+	for _, v := range versions {
+		res = append(res, v.Version)
+	}
+	return res, nil
+}
+
+// Latest is the version -1 of Version
+const Latest = -1
+
+// Version returns a version of subject, or its latest version for Latest
+func (r *Registry) Version(subject string, number int) (SubjectVersion, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	v, err := r.version(subject, number)
+	if err != nil {
+		return SubjectVersion{}, err
+	}
+	return SubjectVersion{Subject: subject, ID: v.ID, Version: v.Version, Schema: r.schemaByID(v.ID).Schema}, nil
+}
+
+func (r *Registry) version(subject string, number int) (version, error) {
+	versions, found := r.state.Subjects[subject]
+	if !found {
+		return version{}, subjectNotFound(subject)
+	}
+	if number == Latest {
+		return versions[len(versions)-1], nil
+	}
+	for _, v := range versions {
+		if v.Version == number {
+			return v, nil
+		}
+	}
+	return version{}, &Error{StatusCode: 404, Code: CodeVersionNotFound, Message: fmt.Sprintf("Version %d not found", number)}
+}
+
+// CheckCompatibility checks s against a version of subject. For Latest, s is checked as
+// if it was registered, i.e. against the versions the mode of the subject checks
+func (r *Registry) CheckCompatibility(subject string, number int, s string) (compat.Incompatibilities, error) {
+	parsed, err := parseSchema(s)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	mode := r.mode(subject)
+	var previous []*schema.Schema
+	if number == Latest {
+		for _, v := range r.state.Subjects[subject] {
+			previous = append(previous, r.schemaByID(v.ID).parsed)
+		}
+	} else {
+		v, err := r.version(subject, number)
+		if err != nil {
+			return nil, err
+		}
+		previous = []*schema.Schema{r.schemaByID(v.ID).parsed}
+	}
+	return compat.Check(mode, parsed, previous), nil
+}
+
+// Mode returns the compatibility mode of subject, the global one for ""
+func (r *Registry) Mode(subject string) compat.Mode {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.mode(subject)
+}
+
+func (r *Registry) mode(subject string) compat.Mode {
+	if mode, found := r.state.Modes[subject]; found {
+		return mode
+	}
+	return r.state.Mode
+}
+
+// SetMode sets the compatibility mode of subject, the global one for ""
+func (r *Registry) SetMode(subject string, mode compat.Mode) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if subject == "" {
+		r.state.Mode = mode
+	} else {
+		r.state.Modes[subject] = mode
+	}
+	return r.save()
+}
@@ -0,0 +1,140 @@
+package registry
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"test/avro/pkg/compat"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	v1 = `{"type": "record", "name": "Order", "fields": [{"name": "id", "type": "string"}]}`
+	v2 = `{"type": "record", "name": "Order", "fields": [{"name": "id", "type": "string"}, {"name": "note", "type": ["null", "string"], "default": null}]}`
+	// v3 adds a field without default, which can't read v1 and v2
+	v3 = `{"type": "record", "name": "Order", "fields": [{"name": "id", "type": "string"}, {"name": "total", "type": "double"}]}`
+)
+
+func startServer(t *testing.T, r *Registry) *Client {
+	server := httptest.NewServer(NewHandler(r))
+	t.Cleanup(server.Close)
+	return NewClient(server.URL)
+}
+
+func TestRegisterAndFetch(t *testing.T) {
+	ctx := context.Background()
+	client := startServer(t, NewMemory())
+
+	id1, err := client.Register(ctx, "orders-value", v1)
+	require.NoError(t, err)
+	require.Equal(t, 1, id1)
+	id2, err := client.Register(ctx, "orders-value", v2)
+	require.NoError(t, err)
+	require.Equal(t, 2, id2)
+
+	// Same schema, same ID, also in another subject
+	again, err := client.Register(ctx, "orders-value", v1)
+	require.NoError(t, err)
+	require.Equal(t, id1, again)
+	other, err := client.Register(ctx, "archive-value", v1)
+	require.NoError(t, err)
+	require.Equal(t, id1, other)
+
+	s, err := client.Schema(ctx, id2)
+	require.NoError(t, err)
+	require.JSONEq(t, v2, s)
+
+	latest, err := client.Version(ctx, "orders-value", Latest)
+	require.NoError(t, err)
+	require.Equal(t, 2, latest.Version)
+	require.Equal(t, id2, latest.ID)
+
+	found, err := client.Lookup(ctx, "orders-value", v1)
+	require.NoError(t, err)
+	require.Equal(t, SubjectVersion{Subject: "orders-value", ID: id1, Version: 1, Schema: found.Schema}, found)
+
+	versions, err := client.Versions(ctx, "orders-value")
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 2}, versions)
+	subjects, err := client.Subjects(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"archive-value", "orders-value"}, subjects)
+}
+
+func TestRegisterEnforcesCompatibility(t *testing.T) {
+	ctx := context.Background()
+	client := startServer(t, NewMemory())
+	_, err := client.Register(ctx, "orders-value", v1)
+	require.NoError(t, err)
+
+	ok, messages, err := client.CheckCompatibility(ctx, "orders-value", Latest, v3)
+	require.NoError(t, err)
+	require.False(t, ok)
+	require.Equal(t, []string{"backward with version 0: $.total: field total isn't in the writer and has no default"}, messages)
+
+	_, err = client.Register(ctx, "orders-value", v3)
+	require.True(t, IsCode(err, CodeIncompatible), err)
+
+	require.NoError(t, client.SetMode(ctx, "orders-value", compat.None))
+	mode, err := client.Mode(ctx, "orders-value")
+	require.NoError(t, err)
+	require.Equal(t, compat.None, mode)
+	mode, err = client.Mode(ctx, "")
+	require.NoError(t, err)
+	require.Equal(t, compat.Backward, mode)
+	_, err = client.Register(ctx, "orders-value", v3)
+	require.NoError(t, err)
+
+	err = client.SetMode(ctx, "", compat.Mode("SIDEWAYS"))
+	require.True(t, IsCode(err, CodeInvalidMode), err)
+}
+
+func TestErrors(t *testing.T) {
+	ctx := context.Background()
+	client := startServer(t, NewMemory())
+
+	_, err := client.Schema(ctx, 7)
+	require.True(t, IsCode(err, CodeSchemaNotFound), err)
+	_, err = client.Version(ctx, "missing", Latest)
+	require.True(t, IsCode(err, CodeSubjectNotFound), err)
+	_, err = client.Register(ctx, "orders-value", `{"type": "record"}`)
+	require.True(t, IsCode(err, CodeInvalidSchema), err)
+	require.ErrorContains(t, err, `missing "name"`)
+
+	_, err = client.Register(ctx, "orders-value", v1)
+	require.NoError(t, err)
+	_, err = client.Version(ctx, "orders-value", 3)
+	require.True(t, IsCode(err, CodeVersionNotFound), err)
+	_, err = client.Lookup(ctx, "orders-value", v2)
+	require.True(t, IsCode(err, CodeSchemaNotFound), err)
+
+	resp, err := http.Post(client.url+"/subjects/orders-value/versions/0", ContentType, strings.NewReader("{}"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestOpenKeepsTheState(t *testing.T) {
+	dir := t.TempDir()
+	r, err := Open(dir)
+	require.NoError(t, err)
+	id, err := r.Register("orders-value", v1)
+	require.NoError(t, err)
+	require.NoError(t, r.SetMode("orders-value", compat.Full))
+
+	r, err = Open(dir)
+	require.NoError(t, err)
+	s, err := r.Schema(id)
+	require.NoError(t, err)
+	require.JSONEq(t, v1, s)
+	require.Equal(t, compat.Full, r.Mode("orders-value"))
+	require.Equal(t, compat.Backward, r.Mode(""))
+
+	// The parsed schemas are loaded for the checks
+	_, err = r.Register("orders-value", v3)
+	require.True(t, IsCode(err, CodeIncompatible), err)
+}
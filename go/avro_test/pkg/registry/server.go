@@ -0,0 +1,169 @@
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"test/avro/pkg/compat"
+)
+
+// ContentType is the media type of the API
+const ContentType = "application/vnd.schemaregistry.v1+json"
+
+type schemaRequest struct {
+	Schema     string `json:"schema"`
+	SchemaType string `json:"schemaType,omitempty"`
+}
+
+type configRequest struct {
+	Compatibility compat.Mode `json:"compatibility"`
+}
+
+type configResponse struct {
+	CompatibilityLevel compat.Mode `json:"compatibilityLevel"`
+}
+
+type compatibilityResponse struct {
+	IsCompatible bool     `json:"is_compatible"`
+	Messages     []string `json:"messages,omitempty"`
+}
+
+// NewHandler serves the API of r:
+//
+//	GET  /schemas/ids/{id}
+//	GET  /subjects
+//	GET  /subjects/{subject}/versions
+//	POST /subjects/{subject}/versions
+//	GET  /subjects/{subject}/versions/{version|latest}
+//	POST /subjects/{subject}
+//	POST /compatibility/subjects/{subject}/versions/{version|latest}
+//	GET  /config[/{subject}]
+//	PUT  /config[/{subject}]
+func NewHandler(r *Registry) http.Handler {
+	return &handler{registry: r}
+}
+
+type handler struct {
+	registry *Registry
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	res, err := h.route(req)
+	w.Header().Set("Content-Type", ContentType)
+	if err != nil {
+		var registryErr *Error
+		if !errors.As(err, &registryErr) {
+			registryErr = &Error{StatusCode: http.StatusInternalServerError, Code: CodeInternal, Message: err.Error()}
+		}
+		w.WriteHeader(registryErr.StatusCode)
+		res = registryErr
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+func notFound(req *http.Request) error {
+	return &Error{StatusCode: http.StatusNotFound, Code: http.StatusNotFound, Message: fmt.Sprintf("%s %s not found", req.Method, req.URL.Path)}
+}
+
+func (h *handler) route(req *http.Request) (interface{}, error) {
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	method := req.Method
+	switch {
+	case len(parts) == 3 && parts[0] == "schemas" && parts[1] == "ids" && method == http.MethodGet:
+		id, err := strconv.Atoi(parts[2])
+		if err != nil {
+			return nil, notFound(req)
+		}
+		s, err := h.registry.Schema(id)
+		return schemaRequest{Schema: s}, err
+	case len(parts) == 1 && parts[0] == "subjects" && method == http.MethodGet:
+		return h.registry.Subjects(), nil
+	case len(parts) == 2 && parts[0] == "subjects" && method == http.MethodPost:
+		s, err := decodeSchema(req)
+		if err != nil {
+			return nil, err
+		}
+		return h.registry.Lookup(parts[1], s)
+	case len(parts) == 3 && parts[0] == "subjects" && parts[2] == "versions" && method == http.MethodGet:
+		return h.registry.Versions(parts[1])
+	case len(parts) == 3 && parts[0] == "subjects" && parts[2] == "versions" && method == http.MethodPost:
+		s, err := decodeSchema(req)
+		if err != nil {
+			return nil, err
+		}
+		id, err := h.registry.Register(parts[1], s)
+		return map[string]int{"id": id}, err
+	case len(parts) == 4 && parts[0] == "subjects" && parts[2] == "versions" && method == http.MethodGet:
+		number, err := parseVersion(parts[3])
+		if err != nil {
+			return nil, err
+		}
+		return h.registry.Version(parts[1], number)
+	case len(parts) == 5 && parts[0] == "compatibility" && parts[1] == "subjects" && parts[3] == "versions" && method == http.MethodPost:
+		number, err := parseVersion(parts[4])
+		if err != nil {
+			return nil, err
+		}
+		s, err := decodeSchema(req)
+		if err != nil {
+			return nil, err
+		}
+		incompatibilities, err := h.registry.CheckCompatibility(parts[2], number, s)
+		if err != nil {
+			return nil, err
+		}
+		res := compatibilityResponse{IsCompatible: len(incompatibilities) == 0}
+		for _, i := range incompatibilities {
+			res.Messages = append(res.Messages, i.String())
+		}
+		return res, nil
+	case (len(parts) == 1 || len(parts) == 2) && parts[0] == "config":
+		subject := ""
+		if len(parts) == 2 {
+			subject = parts[1]
+		}
+		switch method {
+		case http.MethodGet:
+			return configResponse{CompatibilityLevel: h.registry.Mode(subject)}, nil
+		case http.MethodPut:
+			var config configRequest
+			err := json.NewDecoder(req.Body).Decode(&config)
+			if err != nil {
+				return nil, &Error{StatusCode: http.StatusUnprocessableEntity, Code: CodeInvalidMode, Message: "Invalid config: " + err.Error()}
+			}
+			mode, err := compat.ParseMode(string(config.Compatibility))
+			if err != nil {
+				return nil, &Error{StatusCode: http.StatusUnprocessableEntity, Code: CodeInvalidMode, Message: err.Error()}
+			}
+			return configRequest{Compatibility: mode}, h.registry.SetMode(subject, mode)
+		}
+	}
+	return nil, notFound(req)
+}
+
+func decodeSchema(req *http.Request) (string, error) {
+	var body schemaRequest
+	err := json.NewDecoder(req.Body).Decode(&body)
+	if err != nil {
+		return "", &Error{StatusCode: http.StatusUnprocessableEntity, Code: CodeInvalidSchema, Message: "Invalid request: " + err.Error()}
+	}
+	if body.SchemaType != "" && body.SchemaType != "AVRO" {
+		return "", &Error{StatusCode: http.StatusUnprocessableEntity, Code: CodeInvalidSchema, Message: fmt.Sprintf("Unsupported schema type %s", body.SchemaType)}
+	}
+	return body.Schema, nil
+}
+
+func parseVersion(s string) (int, error) {
+	if s == "latest" {
+		return Latest, nil
+	}
+	number, err := strconv.Atoi(s)
+	if err != nil || number < 1 {
+		return 0, &Error{StatusCode: http.StatusUnprocessableEntity, Code: CodeInvalidVersion, Message: fmt.Sprintf("The specified version '%s' is not a valid version id.", s)}
+	}
+	return number, nil
+}
@@ -0,0 +1,140 @@
+package resolve
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"test/avro/pkg/schema"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+// DefaultNative converts the JSON default of a field of type s, as parsed in
+// schema.Field.Default, to a goavro native. The default of a union is for its first branch
+func DefaultNative(s *schema.Schema, value interface{}) (interface{}, error) {
+	switch s.Type {
+	case schema.Union:
+		branch := s.Branches[0]
+		native, err := DefaultNative(branch, value)
+		if err != nil || branch.Type == schema.Null {
+			return nil, err
+		}
+		return goavro.Union(branch.NativeName(), native), nil
+	case schema.Null:
+		if value != nil {
+			return nil, invalidDefault(s, value)
+		}
+		return nil, nil
+	case schema.Boolean:
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+	case schema.Int, schema.Long, schema.Float, schema.Double:
+		number, ok := value.(json.Number)
+		if !ok {
+			return nil, invalidDefault(s, value)
+		}
+		raw, err := numberNative(s.Type, number)
+		if err != nil {
+			return nil, invalidDefault(s, value)
+		}
+		return fromRaw(raw, s)
+	case schema.String, schema.Enum:
+		if str, ok := value.(string); ok {
+			return str, nil
+		}
+	case schema.Bytes, schema.Fixed:
+		// The code points of the string are the bytes
+		if str, ok := value.(string); ok {
+			b := make([]byte, 0, len(str))
+			for _, r := range str {
+				if r > 0xff {
+					return nil, invalidDefault(s, value)
+				}
+				b = append(b, byte(r))
+			}
+			return fromRaw(b, s)
+		}
+	case schema.Array:
+		if items, ok := value.([]interface{}); ok {
+			res := make([]interface{}, 0, len(items))
+			for _, item := range items {
+				native, err := DefaultNative(s.Items, item)
+				if err != nil {
+					return nil, err
+				}
+				res = append(res, native)
+			}
+			return res, nil
+		}
+	case schema.Map:
+		if object, ok := defaultObject(value); ok {
+			res := make(map[string]interface{}, len(object.Members))
+			for _, member := range object.Members {
+				native, err := DefaultNative(s.Values, member.Value)
+				if err != nil {
+					return nil, err
+				}
+				res[member.Key] = native
+			}
+			return res, nil
+		}
+	case schema.Record, schema.ErrorRecord:
+		if object, ok := defaultObject(value); ok {
+			res := make(map[string]interface{}, len(s.Fields))
+			for _, field := range s.Fields {
+				fieldValue, found := object.Get(field.Name)
+				if !found {
+					if !field.HasDefault {
+						return nil, fmt.Errorf("default of %s has no field %s", s.Name, field.Name)
+					}
+					fieldValue = field.Default
+				}
+				native, err := DefaultNative(field.Type, fieldValue)
+				if err != nil {
+					return nil, err
+				}
+				res[field.Name] = native
+			}
+			return res, nil
+		}
+	}
+	return nil, invalidDefault(s, value)
+}
+
+// defaultObject returns the object of a default, decoded or plain JSON as in schema.Field
+func defaultObject(value interface{}) (*schema.Object, bool) {
+	switch v := value.(type) {
+	case *schema.Object:
+		return v, true
+	case map[string]interface{}:
+		object := &schema.Object{}
+		for key, member := range v {
+			object.Set(key, member)
+		}
+		return object, true
+	}
+	return nil, false
+}
+
+func numberNative(t schema.Type, number json.Number) (interface{}, error) {
+	switch t {
+	case schema.Int:
+		i, err := number.Int64()
+		if err != nil || int64(int32(i)) != i {
+			return nil, fmt.Errorf("invalid int %s", number)
+		}
+		return int32(i), nil
+	case schema.Long:
+		return number.Int64()
+	case schema.Float:
+		f, err := number.Float64()
+		return float32(f), err
+	}
+	return number.Float64()
+}
+
+func invalidDefault(s *schema.Schema, value interface{}) error {
+	data, _ := json.Marshal(value)
+	return fmt.Errorf("invalid default %s for %s", data, s.Type)
+}
@@ -0,0 +1,346 @@
+// Package resolve decodes data written with a schema into the natives of another schema,
+// per https://avro.apache.org/docs/1.11.1/specification/#schema-resolution, so that
+// consumers on a newer schema read the old events
+package resolve
+
+import (
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"test/avro/pkg/compat"
+	"test/avro/pkg/schema"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+// Decoder decodes the data of the writer schema. The natives have the shape of the reader
+// schema, as if goavro had decoded them with a reader codec:
+//   - reader fields missing in the writer get their default, writer fields missing in the
+//     reader are dropped, fields are matched by name or reader alias
+//   - numbers are promoted, e.g. int to long, and strings and bytes are interchangeable
+//   - enum symbols unknown to the reader are the reader default
+//   - the reader union branch is the first one that matches the written value
+//
+// A Decoder is safe for concurrent use
+type Decoder struct {
+	reader *schema.Schema
+	writer *schema.Schema
+	codec  *goavro.Codec
+
+	mu sync.Mutex
+	// branches are the reader union branches chosen for each writer schema
+	branches map[[2]*schema.Schema]*schema.Schema
+}
+
+// NewDecoder fails when the reader can't read all the data of the writer, the error is
+// then compat.Incompatibilities
+func NewDecoder(reader *schema.Schema, writer *schema.Schema) (*Decoder, error) {
+	if err := compat.CanRead(reader, writer).Err(); err != nil {
+		return nil, err
+	}
+	codec, err := goavro.NewCodec(writer.String())
+	if err != nil {
+		return nil, fmt.Errorf("writer schema: %w", err)
+	}
+	return &Decoder{reader: reader, writer: writer, codec: codec, branches: make(map[[2]*schema.Schema]*schema.Schema)}, nil
+}
+
+// Reader is the schema of the decoded natives
+func (d *Decoder) Reader() *schema.Schema {
+	return d.reader
+}
+
+// Writer is the schema of the data
+func (d *Decoder) Writer() *schema.Schema {
+	return d.writer
+}
+
+// NativeFromBinary decodes a datum and returns the rest of buf, as goavro.Codec does
+func (d *Decoder) NativeFromBinary(buf []byte) (interface{}, []byte, error) {
+	native, rest, err := d.codec.NativeFromBinary(buf)
+	if err != nil {
+		return nil, buf, err
+	}
+	resolved, err := d.Resolve(native)
+	if err != nil {
+		return nil, buf, err
+	}
+	return resolved, rest, nil
+}
+
+// Resolve converts a native of the writer schema to the reader schema
+func (d *Decoder) Resolve(native interface{}) (interface{}, error) {
+	return d.resolve(native, d.reader, d.writer)
+}
+
+func (d *Decoder) resolve(native interface{}, reader *schema.Schema, writer *schema.Schema) (interface{}, error) {
+	if writer.Type == schema.Union {
+		if native == nil {
+			return d.resolve(nil, reader, null)
+		}
+		name, value, err := unwrap(native)
+		if err != nil {
+			return nil, err
+		}
+		for _, branch := range writer.Branches {
+			if branch.NativeName() == name {
+				return d.resolve(value, reader, branch)
+			}
+		}
+		return nil, fmt.Errorf("no branch %s in the writer union", name)
+	}
+	if reader.Type == schema.Union {
+		branch := d.branch(reader, writer)
+		value, err := d.resolve(native, branch, writer)
+		if err != nil || branch.Type == schema.Null {
+			return nil, err
+		}
+		return goavro.Union(branch.NativeName(), value), nil
+	}
+
+	switch reader.Type {
+	case schema.Record, schema.ErrorRecord:
+		return d.resolveRecord(native, reader, writer)
+	case schema.Enum:
+		symbol, ok := native.(string)
+		if !ok {
+			return nil, unexpected(native, writer)
+		}
+		for _, s := range reader.Symbols {
+			if s == symbol {
+				return symbol, nil
+			}
+		}
+		return reader.EnumDefault, nil
+	case schema.Array:
+		items, ok := native.([]interface{})
+		if !ok {
+			return nil, unexpected(native, writer)
+		}
+		res := make([]interface{}, 0, len(items))
+		for _, item := range items {
+			value, err := d.resolve(item, reader.Items, writer.Items)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, value)
+		}
+		return res, nil
+	case schema.Map:
+		values, ok := native.(map[string]interface{})
+		if !ok {
+			return nil, unexpected(native, writer)
+		}
+		res := make(map[string]interface{}, len(values))
+		for key, value := range values {
+			resolved, err := d.resolve(value, reader.Values, writer.Values)
+			if err != nil {
+				return nil, err
+			}
+			res[key] = resolved
+		}
+		return res, nil
+	}
+	return promote(native, reader, writer)
+}
+
+func (d *Decoder) resolveRecord(native interface{}, reader *schema.Schema, writer *schema.Schema) (interface{}, error) {
+	fields, ok := native.(map[string]interface{})
+	if !ok {
+		return nil, unexpected(native, writer)
+	}
+	res := make(map[string]interface{}, len(reader.Fields))
+	for _, field := range reader.Fields {
+		written := writerField(field, writer)
+		if written == nil {
+			value, err := DefaultNative(field.Type, field.Default)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", reader.Name, field.Name, err)
+			}
+			res[field.Name] = value
+			continue
+		}
+		value, err := d.resolve(fields[written.Name], field.Type, written.Type)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", reader.Name, field.Name, err)
+		}
+		res[field.Name] = value
+	}
+	return res, nil
+}
+
+func writerField(field *schema.Field, writer *schema.Schema) *schema.Field {
+	if written := writer.Field(field.Name); written != nil {
+		return written
+	}
+	for _, alias := range field.Aliases {
+		if written := writer.Field(alias); written != nil {
+			return written
+		}
+	}
+	return nil
+}
+
+// branch is the first reader branch of the type of the writer, or else the first one
+// that reads it
+func (d *Decoder) branch(reader *schema.Schema, writer *schema.Schema) *schema.Schema {
+	key := [2]*schema.Schema{reader, writer}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if branch, found := d.branches[key]; found {
+		return branch
+	}
+	var res *schema.Schema
+	for _, branch := range reader.Branches {
+		if branch.Type == writer.Type && len(compat.CanRead(branch, writer)) == 0 {
+			res = branch
+			break
+		}
+	}
+	if res == nil {
+		for _, branch := range reader.Branches {
+			if len(compat.CanRead(branch, writer)) == 0 {
+				res = branch
+				break
+			}
+		}
+	}
+	// NewDecoder checked that a branch reads the writer
+	d.branches[key] = res
+	return res
+}
+
+func unwrap(native interface{}) (string, interface{}, error) {
+	wrapped, ok := native.(map[string]interface{})
+	if !ok || len(wrapped) != 1 {
+		return "", nil, fmt.Errorf("expected a union value, got %T", native)
+	}
+	for name, value := range wrapped {
+		return name, value, nil
+	}
+	return "", nil, nil
+}
+
+func unexpected(native interface{}, writer *schema.Schema) error {
+	return fmt.Errorf("unexpected %T for %s", native, writer.Type)
+}
+
+// promote converts a primitive, fixed or logical value
+func promote(native interface{}, reader *schema.Schema, writer *schema.Schema) (interface{}, error) {
+	raw, err := toRaw(native, writer)
+	if err != nil {
+		return nil, err
+	}
+	switch v := raw.(type) {
+	case int32:
+		switch reader.Type {
+		case schema.Long:
+			raw = int64(v)
+		case schema.Float:
+			raw = float32(v)
+		case schema.Double:
+			raw = float64(v)
+		}
+	case int64:
+		switch reader.Type {
+		case schema.Float:
+			raw = float32(v)
+		case schema.Double:
+			raw = float64(v)
+		}
+	case float32:
+		if reader.Type == schema.Double {
+			raw = float64(v)
+		}
+	case string:
+		if reader.Type == schema.Bytes {
+			raw = []byte(v)
+		}
+	case []byte:
+		if reader.Type == schema.String {
+			raw = string(v)
+		}
+	}
+	return fromRaw(raw, reader)
+}
+
+var (
+	null  = &schema.Schema{Type: schema.Null}
+	epoch = time.Unix(0, 0).UTC()
+)
+
+// toRaw converts the goavro native of a logical type to the native of its type
+func toRaw(native interface{}, s *schema.Schema) (interface{}, error) {
+	switch v := native.(type) {
+	case time.Time:
+		switch s.LogicalType {
+		case "timestamp-millis":
+			return v.UnixNano() / int64(time.Millisecond), nil
+		case "timestamp-micros":
+			return v.UnixNano() / int64(time.Microsecond), nil
+		case "date":
+			return int32(v.Sub(epoch) / (24 * time.Hour)), nil
+		}
+	case time.Duration:
+		switch s.LogicalType {
+		case "time-millis":
+			return int32(v / time.Millisecond), nil
+		case "time-micros":
+			return int64(v / time.Microsecond), nil
+		}
+	case *big.Rat:
+		return decimalBytes(v, s.Scale), nil
+	default:
+		return native, nil
+	}
+	return nil, unexpected(native, s)
+}
+
+// fromRaw converts the native of a type to the goavro native of its logical type
+func fromRaw(raw interface{}, s *schema.Schema) (interface{}, error) {
+	switch s.NativeName() {
+	case "long.timestamp-millis":
+		return time.Unix(0, raw.(int64)*int64(time.Millisecond)).UTC(), nil
+	case "long.timestamp-micros":
+		return time.Unix(0, raw.(int64)*int64(time.Microsecond)).UTC(), nil
+	case "int.date":
+		return epoch.AddDate(0, 0, int(raw.(int32))), nil
+	case "int.time-millis":
+		return time.Duration(raw.(int32)) * time.Millisecond, nil
+	case "long.time-micros":
+		return time.Duration(raw.(int64)) * time.Microsecond, nil
+	case "bytes.decimal":
+		return decimalRat(raw.([]byte), s.Scale), nil
+	}
+	if s.Type == schema.Fixed && s.LogicalType == "decimal" {
+		return decimalRat(raw.([]byte), s.Scale), nil
+	}
+	return raw, nil
+}
+
+// decimalBytes is the two's complement of the unscaled value
+func decimalBytes(r *big.Rat, scale int) []byte {
+	unscaled := new(big.Rat).Mul(r, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)))
+	i := new(big.Int).Quo(unscaled.Num(), unscaled.Denom())
+	if i.Sign() >= 0 {
+		b := i.Bytes()
+		if len(b) == 0 || b[0]&0x80 != 0 {
+			b = append([]byte{0}, b...)
+		}
+		return b
+	}
+	// -i = 2^n - |i| with n a multiple of 8 bits large enough for the sign bit
+	n := uint(i.BitLen()/8+1) * 8
+	b := new(big.Int).Add(new(big.Int).Lsh(big.NewInt(1), n), i).Bytes()
+	return b
+}
+
+func decimalRat(b []byte, scale int) *big.Rat {
+	i := new(big.Int).SetBytes(b)
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		i.Sub(i, new(big.Int).Lsh(big.NewInt(1), uint(len(b))*8))
+	}
+	return new(big.Rat).SetFrac(i, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil))
+}
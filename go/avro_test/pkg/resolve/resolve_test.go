@@ -0,0 +1,144 @@
+package resolve
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"test/avro/pkg/compat"
+	"test/avro/pkg/schema"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func parse(t *testing.T, s string) *schema.Schema {
+	parsed, err := schema.Parse([]byte(s))
+	require.NoError(t, err)
+	return parsed
+}
+
+// decode writes textual with the writer schema and reads it with the reader schema. The
+// result is returned in the textual encoding of the reader, which checks its shape
+func decode(t *testing.T, reader string, writer string, textual string) string {
+	writerCodec, err := goavro.NewCodec(writer)
+	require.NoError(t, err)
+	native, _, err := writerCodec.NativeFromTextual([]byte(textual))
+	require.NoError(t, err)
+	binary, err := writerCodec.BinaryFromNative(nil, native)
+	require.NoError(t, err)
+
+	d, err := NewDecoder(parse(t, reader), parse(t, writer))
+	require.NoError(t, err)
+	resolved, rest, err := d.NativeFromBinary(binary)
+	require.NoError(t, err)
+	require.Empty(t, rest)
+
+	readerCodec, err := goavro.NewCodec(reader)
+	require.NoError(t, err)
+	res, err := readerCodec.TextualFromNative(nil, resolved)
+	require.NoError(t, err)
+	return string(res)
+}
+
+// longList is the schema of test.go
+const longList = `{"type": "record", "name": "LongList", "fields": [
+	{"name": "next", "type": ["null", "LongList", {"type": "long", "logicalType": "timestamp-millis"}], "default": null}
+]}`
+
+func TestNewReaderReadsOldLongList(t *testing.T) {
+	reader := `{"type": "record", "name": "LongList", "fields": [
+		{"name": "next", "type": ["null", "LongList", {"type": "long", "logicalType": "timestamp-millis"}, "string"], "default": null},
+		{"name": "label", "type": "string", "default": "none"},
+		{"name": "created", "type": {"type": "long", "logicalType": "timestamp-millis"}, "default": 1000}
+	]}`
+	res := decode(t, reader, longList, `{"next": {"LongList": {"next": {"long.timestamp-millis": 1700000000123}}}}`)
+	require.JSONEq(t, `{
+		"next": {"LongList": {"next": {"long.timestamp-millis": 1700000000123}, "label": "none", "created": 1000}},
+		"label": "none",
+		"created": 1000
+	}`, res)
+}
+
+func TestResolutionRules(t *testing.T) {
+	writer := `{"type": "record", "name": "Order", "fields": [
+		{"name": "id", "type": "string"},
+		{"name": "quantity", "type": "int"},
+		{"name": "weight", "type": "float"},
+		{"name": "status", "type": {"type": "enum", "name": "Status", "symbols": ["OPEN", "LOST"]}},
+		{"name": "payload", "type": "string"},
+		{"name": "tags", "type": {"type": "map", "values": {"type": "array", "items": "int"}}},
+		{"name": "obsolete", "type": "string"},
+		{"name": "day", "type": {"type": "int", "logicalType": "date"}}
+	]}`
+	reader := `{"type": "record", "name": "Purchase", "aliases": ["Order"], "fields": [
+		{"name": "id", "type": "string"},
+		{"name": "count", "aliases": ["quantity"], "type": ["null", "long"]},
+		{"name": "weight", "type": "double"},
+		{"name": "status", "type": {"type": "enum", "name": "Status", "symbols": ["OPEN", "UNKNOWN"], "default": "UNKNOWN"}},
+		{"name": "payload", "type": "bytes"},
+		{"name": "tags", "type": {"type": "map", "values": {"type": "array", "items": "double"}}},
+		{"name": "day", "type": "int"},
+		{"name": "rate", "type": ["double", "null"], "default": 0.5}
+	]}`
+	res := decode(t, reader, writer, `{
+		"id": "o-1", "quantity": 3, "weight": 1.5, "status": "LOST", "payload": "ÿ",
+		"tags": {"a": [1, 2]}, "obsolete": "x", "day": 19000
+	}`)
+	require.JSONEq(t, `{
+		"id": "o-1", "count": {"long": 3}, "weight": 1.5, "status": "UNKNOWN", "payload": "Ã¿",
+		"tags": {"a": [1, 2]}, "day": 19000, "rate": {"double": 0.5}
+	}`, res)
+}
+
+func TestNewDecoderRejectsIncompatibleSchemas(t *testing.T) {
+	_, err := NewDecoder(parse(t, `{"type": "record", "name": "R", "fields": [{"name": "a", "type": "int"}]}`),
+		parse(t, `{"type": "record", "name": "R", "fields": [{"name": "b", "type": "int"}]}`))
+	var incompatibilities compat.Incompatibilities
+	require.True(t, errors.As(err, &incompatibilities))
+	require.Equal(t, compat.MissingDefault, incompatibilities[0].Kind)
+}
+
+func TestDefaultNative(t *testing.T) {
+	for _, c := range []struct {
+		schema   string
+		value    interface{}
+		expected interface{}
+	}{
+		{`"int"`, json.Number("3"), int32(3)},
+		{`["null", "string"]`, nil, nil},
+		{`["string", "null"]`, "x", goavro.Union("string", "x")},
+		{`{"type": "fixed", "name": "F", "size": 2}`, "ÿ\u0001", []byte{0xff, 1}},
+		{`{"type": "long", "logicalType": "timestamp-millis"}`, json.Number("1500"), time.Unix(1, 5e8).UTC()},
+		{`{"type": "bytes", "logicalType": "decimal", "precision": 4, "scale": 2}`, "ÿ8", big.NewRat(-2, 1)},
+		{`{"type": "array", "items": "double"}`, []interface{}{json.Number("1.5")}, []interface{}{1.5}},
+		{
+			`{"type": "record", "name": "R", "fields": [{"name": "a", "type": "int"}, {"name": "b", "type": "string", "default": "x"}]}`,
+			&schema.Object{Members: []schema.Member{{Key: "a", Value: json.Number("1")}}},
+			map[string]interface{}{"a": int32(1), "b": "x"},
+		},
+		{
+			`{"type": "map", "values": {"type": "record", "name": "R", "fields": [{"name": "a", "type": "int", "default": 2}]}}`,
+			map[string]interface{}{"k": map[string]interface{}{}},
+			map[string]interface{}{"k": map[string]interface{}{"a": int32(2)}},
+		},
+	} {
+		native, err := DefaultNative(parse(t, c.schema), c.value)
+		require.NoError(t, err, c.schema)
+		require.Equal(t, c.expected, native, c.schema)
+	}
+
+	_, err := DefaultNative(parse(t, `"int"`), json.Number("3000000000"))
+	require.EqualError(t, err, "invalid default 3000000000 for int")
+	_, err = DefaultNative(parse(t, `"string"`), nil)
+	require.EqualError(t, err, "invalid default null for string")
+}
+
+func TestDecimalBytes(t *testing.T) {
+	for _, r := range []*big.Rat{big.NewRat(0, 1), big.NewRat(1234, 100), big.NewRat(-2, 1), big.NewRat(-128, 100), big.NewRat(128, 100)} {
+		require.Equal(t, r, decimalRat(decimalBytes(r, 2), 2), r.String())
+	}
+	require.Equal(t, []byte{0xff, 0x38}, decimalBytes(big.NewRat(-2, 1), 2))
+}
@@ -0,0 +1,127 @@
+package schema
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// Canonical returns the Parsing Canonical Form of s, see
+// https://avro.apache.org/docs/1.11.1/specification/#parsing-canonical-form-for-schemas.
+// It has full names, only the attributes that change the binary encoding, in a fixed
+// order and without whitespace. Error records are written as records
+func (s *Schema) Canonical() string {
+	var b strings.Builder
+	writeCanonical(&b, s, make(map[string]bool))
+	return b.String()
+}
+
+func writeCanonical(b *strings.Builder, s *Schema, defined map[string]bool) {
+	if s.Type.IsNamed() {
+		if defined[s.Name] {
+			writeString(b, s.Name)
+			return
+		}
+		defined[s.Name] = true
+	}
+
+	switch s.Type {
+	case Union:
+		b.WriteByte('[')
+		for i, branch := range s.Branches {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeCanonical(b, branch, defined)
+		}
+		b.WriteByte(']')
+	case Record, ErrorRecord:
+		b.WriteString(`{"name":`)
+		writeString(b, s.Name)
+		b.WriteString(`,"type":"record","fields":[`)
+		for i, field := range s.Fields {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(`{"name":`)
+			writeString(b, field.Name)
+			b.WriteString(`,"type":`)
+			writeCanonical(b, field.Type, defined)
+			b.WriteByte('}')
+		}
+		b.WriteString("]}")
+	case Enum:
+		b.WriteString(`{"name":`)
+		writeString(b, s.Name)
+		b.WriteString(`,"type":"enum","symbols":[`)
+		for i, symbol := range s.Symbols {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeString(b, symbol)
+		}
+		b.WriteString("]}")
+	case Fixed:
+		b.WriteString(`{"name":`)
+		writeString(b, s.Name)
+		b.WriteString(`,"type":"fixed","size":`)
+		b.WriteString(strconv.Itoa(s.Size))
+		b.WriteByte('}')
+	case Array:
+		b.WriteString(`{"type":"array","items":`)
+		writeCanonical(b, s.Items, defined)
+		b.WriteByte('}')
+	case Map:
+		b.WriteString(`{"type":"map","values":`)
+		writeCanonical(b, s.Values, defined)
+		b.WriteByte('}')
+	default:
+		writeString(b, string(s.Type))
+	}
+}
+
+func writeString(b *strings.Builder, s string) {
+	data, _ := json.Marshal(s)
+	b.Write(data)
+}
+
+// Fingerprint64 is the CRC-64-AVRO (Rabin) fingerprint of the canonical form of s, the
+// one of single object encoding
+func (s *Schema) Fingerprint64() uint64 {
+	return crc64Avro([]byte(s.Canonical()))
+}
+
+// FingerprintMD5 is the MD5 of the canonical form of s
+func (s *Schema) FingerprintMD5() [md5.Size]byte {
+	return md5.Sum([]byte(s.Canonical()))
+}
+
+// FingerprintSHA256 is the SHA-256 of the canonical form of s
+func (s *Schema) FingerprintSHA256() [sha256.Size]byte {
+	return sha256.Sum256([]byte(s.Canonical()))
+}
+
+// crc64Empty is the fingerprint of empty data
+const crc64Empty = 0xc15d213aa4d7a795
+
+var crc64Table = func() [256]uint64 {
+	var table [256]uint64
+	for i := range table {
+		fp := uint64(i)
+		for j := 0; j < 8; j++ {
+			fp = (fp >> 1) ^ (crc64Empty & -(fp & 1))
+		}
+		table[i] = fp
+	}
+	return table
+}()
+
+func crc64Avro(data []byte) uint64 {
+	fp := uint64(crc64Empty)
+	for _, b := range data {
+		fp = (fp >> 8) ^ crc64Table[byte(fp)^b]
+	}
+	return fp
+}
@@ -0,0 +1,40 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Error is a problem found at Path, a JSON path in the parsed document such as
+// $.types[0].fields[2].type
+type Error struct {
+	Path    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// ErrorList has all the problems found in a document, in document order
+type ErrorList []*Error
+
+func (l ErrorList) Error() string {
+	messages := make([]string, 0, len(l))
+	for _, err := range l {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "\n")
+}
+
+// Err returns nil for an empty list
+func (l ErrorList) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
+
+func (l *ErrorList) add(path string, format string, args ...interface{}) {
+	*l = append(*l, &Error{Path: path, Message: fmt.Sprintf(format, args...)})
+}
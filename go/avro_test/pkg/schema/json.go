@@ -0,0 +1,252 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Member is a member of an Object
+type Member struct {
+	Key   string
+	Value interface{}
+}
+
+// Object is a JSON object keeping the order of its members. DecodeJSON returns objects
+// as *Object, numbers as json.Number
+type Object struct {
+	Members []Member
+}
+
+// Get returns the value of key
+func (o *Object) Get(key string) (interface{}, bool) {
+	for _, member := range o.Members {
+		if member.Key == key {
+			return member.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Set replaces the value of key or appends it
+func (o *Object) Set(key string, value interface{}) {
+	for i := range o.Members {
+		if o.Members[i].Key == key {
+			o.Members[i].Value = value
+			return
+		}
+	}
+	o.Members = append(o.Members, Member{Key: key, Value: value})
+}
+
+func (o *Object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, member := range o.Members {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(member.Key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		value, err := json.Marshal(member.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// DecodeJSON decodes a JSON document keeping the order of the object members
+func DecodeJSON(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	value, err := decodeValue(decoder, "$")
+	if err != nil {
+		return nil, jsonError(err, data)
+	}
+	_, err = decoder.Token()
+	if err != io.EOF {
+		return nil, &Error{Path: "$", Message: fmt.Sprintf("unexpected data after the JSON value at offset %d", decoder.InputOffset())}
+	}
+	return value, nil
+}
+
+func jsonError(err error, data []byte) error {
+	var parseErr *Error
+	if errors.As(err, &parseErr) {
+		return parseErr
+	}
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		line, column := position(data, syntaxErr.Offset)
+		return &Error{Path: "$", Message: fmt.Sprintf("invalid JSON at line %d, column %d: %s", line, column, syntaxErr)}
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return &Error{Path: "$", Message: "unexpected end of JSON"}
+	}
+	return &Error{Path: "$", Message: err.Error()}
+}
+
+func position(data []byte, offset int64) (line int, column int) {
+	line, column = 1, 1
+	for i := int64(0); i < offset && i < int64(len(data)); i++ {
+		if data[i] == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+	}
+	return line, column
+}
+
+func decodeValue(decoder *json.Decoder, path string) (interface{}, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	delim, ok := token.(json.Delim)
+	if !ok {
+		return token, nil
+	}
+	switch delim {
+	case '{':
+		object := &Object{}
+		for decoder.More() {
+			token, err = decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			key := token.(string)
+			if _, found := object.Get(key); found {
+				return nil, &Error{Path: path, Message: fmt.Sprintf("duplicate key %q", key)}
+			}
+			value, err := decodeValue(decoder, memberPath(path, key))
+			if err != nil {
+				return nil, err
+			}
+			object.Members = append(object.Members, Member{Key: key, Value: value})
+		}
+		_, err = decoder.Token()
+		return object, err
+	case '[':
+		array := []interface{}{}
+		for decoder.More() {
+			value, err := decodeValue(decoder, indexPath(path, len(array)))
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, value)
+		}
+		_, err = decoder.Token()
+		return array, err
+	}
+	return nil, fmt.Errorf("unexpected %s", delim)
+}
+
+// PlainJSON converts the objects of a decoded value to map[string]interface{}
+func PlainJSON(value interface{}) interface{} {
+	switch v := value.(type) {
+	case *Object:
+		res := make(map[string]interface{}, len(v.Members))
+		for _, member := range v.Members {
+			res[member.Key] = PlainJSON(member.Value)
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			res = append(res, PlainJSON(item))
+		}
+		return res
+	}
+	return value
+}
+
+func memberPath(path string, key string) string {
+	if isValidName(key) {
+		return path + "." + key
+	}
+	return path + "[" + strconv.Quote(key) + "]"
+}
+
+func indexPath(path string, index int) string {
+	return path + "[" + strconv.Itoa(index) + "]"
+}
+
+// Position is a line and a column of a document, from 1
+type Position struct {
+	Line   int
+	Column int
+}
+
+// Positions returns where the values of a JSON document start by JSON path, e.g. to
+// locate the Path of a Schema or an Error
+func Positions(data []byte) (map[string]Position, error) {
+	var lineStarts []int
+	lineStarts = append(lineStarts, 0)
+	for i, b := range data {
+		if b == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	locate := func(offset int64) Position {
+		line := sort.Search(len(lineStarts), func(i int) bool { return int64(lineStarts[i]) > offset })
+		return Position{Line: line, Column: int(offset) - lineStarts[line-1] + 1}
+	}
+
+	positions := make(map[string]Position)
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	var position func(path string) error
+	position = func(path string) error {
+		// the decoder is after the previous token, before separators and spaces
+		offset := decoder.InputOffset()
+		for offset < int64(len(data)) && strings.IndexByte(" \t\r\n,:", data[offset]) >= 0 {
+			offset++
+		}
+		positions[path] = locate(offset)
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		switch token {
+		case json.Delim('{'):
+			for decoder.More() {
+				key, err := decoder.Token()
+				if err != nil {
+					return err
+				}
+				err = position(memberPath(path, key.(string)))
+				if err != nil {
+					return err
+				}
+			}
+			_, err = decoder.Token()
+		case json.Delim('['):
+			for i := 0; decoder.More(); i++ {
+				err = position(indexPath(path, i))
+				if err != nil {
+					return err
+				}
+			}
+			_, err = decoder.Token()
+		}
+		return err
+	}
+	err := position("$")
+	if err != nil {
+		return nil, jsonError(err, data)
+	}
+	return positions, nil
+}
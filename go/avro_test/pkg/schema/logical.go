@@ -0,0 +1,67 @@
+package schema
+
+import (
+	"fmt"
+	"math"
+)
+
+// logicalTypes are the logical types of the spec and the types they annotate
+var logicalTypes = map[string][]Type{
+	"decimal":                {Bytes, Fixed},
+	"uuid":                   {String, Fixed},
+	"date":                   {Int},
+	"time-millis":            {Int},
+	"time-micros":            {Long},
+	"timestamp-millis":       {Long},
+	"timestamp-micros":       {Long},
+	"local-timestamp-millis": {Long},
+	"local-timestamp-micros": {Long},
+	"duration":               {Fixed},
+}
+
+// IsKnownLogicalType is true for the logical types of the spec
+func IsKnownLogicalType(name string) bool {
+	_, found := logicalTypes[name]
+	return found
+}
+
+// ValidateLogicalType returns why the logical type of s is invalid, nil if it's valid or
+// s has none. The spec has invalid logical types ignored, i.e. s is its underlying type
+func ValidateLogicalType(s *Schema) error {
+	if s.LogicalType == "" {
+		return nil
+	}
+	types, found := logicalTypes[s.LogicalType]
+	if !found {
+		return fmt.Errorf("unknown logical type %q", s.LogicalType)
+	}
+	valid := false
+	for _, t := range types {
+		valid = valid || s.Type == t
+	}
+	if !valid {
+		return fmt.Errorf("%s doesn't apply to %s", s.LogicalType, s.Type)
+	}
+
+	switch {
+	case s.LogicalType == "uuid" && s.Type == Fixed && s.Size != 16:
+		return fmt.Errorf("uuid needs a fixed of size 16, got %d", s.Size)
+	case s.LogicalType == "duration" && s.Size != 12:
+		return fmt.Errorf("duration needs a fixed of size 12, got %d", s.Size)
+	case s.LogicalType == "decimal":
+		if s.Precision <= 0 {
+			return fmt.Errorf("decimal needs a positive precision, got %d", s.Precision)
+		}
+		if s.Scale < 0 || s.Scale > s.Precision {
+			return fmt.Errorf("decimal scale %d isn't between 0 and the precision %d", s.Scale, s.Precision)
+		}
+		if s.Type == Fixed {
+			// the digits of the largest signed number of Size bytes
+			maxPrecision := int(math.Floor(math.Log10(2) * float64(8*s.Size-1)))
+			if s.Precision > maxPrecision {
+				return fmt.Errorf("decimal precision %d doesn't fit in %d bytes, the maximum is %d", s.Precision, s.Size, maxPrecision)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,468 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Parse parses a single schema, i.e. an .avsc document
+func Parse(data []byte) (*Schema, error) {
+	value, err := DecodeJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	p := NewParser()
+	s := p.Parse(value, "$", "")
+	err = p.Err()
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Parser parses schemas that share named types, e.g. the types and messages of a protocol.
+// It records the errors and keeps going, so that all of them are reported at once
+type Parser struct {
+	names  map[string]*Schema
+	named  []*Schema
+	errors ErrorList
+}
+
+func NewParser() *Parser {
+	return &Parser{names: make(map[string]*Schema)}
+}
+
+// Err returns the errors found so far as an ErrorList, nil if none
+func (p *Parser) Err() error {
+	return p.errors.Err()
+}
+
+// Errorf records an error at path
+func (p *Parser) Errorf(path string, format string, args ...interface{}) {
+	p.errors.add(path, format, args...)
+}
+
+// Named returns the named types in definition order
+func (p *Parser) Named() []*Schema {
+	return p.named
+}
+
+// Lookup resolves name, relative to namespace if not qualified
+func (p *Parser) Lookup(name string, namespace string) (*Schema, bool) {
+	s, found := p.names[FullName(name, namespace)]
+	if !found {
+		// Types in the null namespace can be referenced from any namespace
+		s, found = p.names[name]
+	}
+	return s, found
+}
+
+// Define registers a named type defined elsewhere, e.g. by an imported file
+func (p *Parser) Define(s *Schema) error {
+	if previous, found := p.names[s.Name]; found {
+		if previous == s {
+			return nil
+		}
+		return fmt.Errorf("%s is already defined at %s", s.Name, previous.Path)
+	}
+	p.names[s.Name] = s
+	p.named = append(p.named, s)
+	return nil
+}
+
+// Parse parses the schema value decoded by DecodeJSON, found at path in the document. Named
+// types without namespace get namespace. It returns nil if value isn't a schema
+func (p *Parser) Parse(value interface{}, path string, namespace string) *Schema {
+	switch v := value.(type) {
+	case string:
+		return p.parseReference(v, path, namespace)
+	case []interface{}:
+		return p.parseUnion(v, path, namespace)
+	case *Object:
+		return p.parseObject(v, path, namespace)
+	}
+	p.Errorf(path, "a schema is a string, an object or an array, got %s", describe(value))
+	return nil
+}
+
+func (p *Parser) parseReference(name string, path string, namespace string) *Schema {
+	t := Type(name)
+	if t.IsPrimitive() {
+		return &Schema{Type: t, Path: path}
+	}
+	if s, found := p.Lookup(name, namespace); found {
+		return s
+	}
+	switch t {
+	case Record, ErrorRecord, Enum, Fixed, Array, Map:
+		p.Errorf(path, "%q needs the object form, e.g. {\"type\": %q, ...}", name, name)
+	default:
+		p.Errorf(path, "unknown type %q", FullName(name, namespace))
+	}
+	return nil
+}
+
+func (p *Parser) parseUnion(branches []interface{}, path string, namespace string) *Schema {
+	s := &Schema{Type: Union, Path: path}
+	if len(branches) == 0 {
+		p.Errorf(path, "a union needs at least one branch")
+		return nil
+	}
+	seen := make(map[string]int)
+	for i, value := range branches {
+		branchPath := indexPath(path, i)
+		branch := p.Parse(value, branchPath, namespace)
+		if branch == nil {
+			continue
+		}
+		if branch.Type == Union {
+			p.Errorf(branchPath, "a union can't directly contain a union")
+			continue
+		}
+		name := branch.TypeName()
+		if previous, found := seen[name]; found {
+			p.Errorf(branchPath, "duplicate %s in union, already at index %d", name, previous)
+			continue
+		}
+		seen[name] = i
+		s.Branches = append(s.Branches, branch)
+	}
+	return s
+}
+
+// attributes are the keys of the spec per type, the others are kept as Props
+var attributes = map[Type][]string{
+	Record:      {"type", "name", "namespace", "doc", "aliases", "fields"},
+	ErrorRecord: {"type", "name", "namespace", "doc", "aliases", "fields"},
+	Enum:        {"type", "name", "namespace", "doc", "aliases", "symbols", "default"},
+	Fixed:       {"type", "name", "namespace", "doc", "aliases", "size"},
+	Array:       {"type", "items"},
+	Map:         {"type", "values"},
+	Null:        {"type"},
+	Boolean:     {"type"},
+	Int:         {"type"},
+	Long:        {"type"},
+	Float:       {"type"},
+	Double:      {"type"},
+	Bytes:       {"type"},
+	String:      {"type"},
+}
+
+func (p *Parser) parseObject(object *Object, path string, namespace string) *Schema {
+	typeValue, found := object.Get("type")
+	if !found {
+		p.Errorf(path, "missing \"type\"")
+		return nil
+	}
+	typeName, ok := typeValue.(string)
+	if !ok {
+		// e.g. {"type": {"type": "array", "items": "int"}}
+		return p.Parse(typeValue, memberPath(path, "type"), namespace)
+	}
+
+	t := Type(typeName)
+	var s *Schema
+	switch {
+	case t.IsNamed():
+		s = p.parseNamed(t, object, path, namespace)
+	case t == Array:
+		s = &Schema{Type: Array, Path: path}
+		s.Items = p.requiredSchema(object, "items", path, namespace)
+	case t == Map:
+		s = &Schema{Type: Map, Path: path}
+		s.Values = p.requiredSchema(object, "values", path, namespace)
+	case t.IsPrimitive():
+		s = &Schema{Type: t, Path: path}
+	default:
+		// {"type": "LongList"} references a named type
+		s = p.parseReference(typeName, memberPath(path, "type"), namespace)
+		if s != nil && len(object.Members) > 1 {
+			p.Errorf(path, "a reference to %s can't have other attributes", s.Name)
+		}
+		return s
+	}
+	if s == nil {
+		return nil
+	}
+
+	known := append([]string{}, attributes[t]...)
+	if s.Type.IsPrimitive() || s.Type == Fixed {
+		known = append(known, "logicalType", "precision", "scale")
+		p.parseLogicalType(s, object, path)
+	}
+	s.Props = extraMembers(object, known)
+	return s
+}
+
+func (p *Parser) requiredSchema(object *Object, key string, path string, namespace string) *Schema {
+	value, found := object.Get(key)
+	if !found {
+		p.Errorf(path, "missing %q", key)
+		return nil
+	}
+	return p.Parse(value, memberPath(path, key), namespace)
+}
+
+func (p *Parser) parseNamed(t Type, object *Object, path string, namespace string) *Schema {
+	name, ok := p.requiredString(object, "name", path)
+	if !ok {
+		return nil
+	}
+	if ns, found := object.Get("namespace"); found {
+		nsString, ok := ns.(string)
+		if !ok && ns != nil {
+			p.Errorf(memberPath(path, "namespace"), "expected a string, got %s", describe(ns))
+		}
+		namespace = nsString
+	}
+	if !isValidNamespace(namespace) {
+		p.Errorf(memberPath(path, "namespace"), "invalid namespace %q", namespace)
+	}
+	fullName := FullName(name, namespace)
+	ns, shortName := SplitName(fullName)
+	if !isValidName(shortName) || !isValidNamespace(ns) {
+		p.Errorf(memberPath(path, "name"), "invalid name %q", name)
+	}
+	if Type(shortName).IsPrimitive() && ns == "" {
+		p.Errorf(memberPath(path, "name"), "%q is a primitive type", name)
+	}
+
+	s := &Schema{Type: t, Name: fullName, Path: path}
+	s.Doc = p.optionalString(object, "doc", path)
+	s.Aliases = p.parseAliases(object, path, ns)
+	if previous, found := p.names[fullName]; found {
+		p.Errorf(memberPath(path, "name"), "%s is already defined at %s", fullName, previous.Path)
+	} else {
+		// Defined before the fields, so that they can refer to it
+		p.names[fullName] = s
+		p.named = append(p.named, s)
+	}
+
+	switch t {
+	case Record, ErrorRecord:
+		fields, found := object.Get("fields")
+		if !found {
+			p.Errorf(path, "missing \"fields\"")
+			break
+		}
+		s.Fields = p.ParseFields(fields, memberPath(path, "fields"), ns)
+	case Enum:
+		p.parseEnum(s, object, path)
+	case Fixed:
+		size, found := object.Get("size")
+		if !found {
+			p.Errorf(path, "missing \"size\"")
+			break
+		}
+		s.Size, ok = p.integer(size, memberPath(path, "size"))
+		if ok && s.Size < 0 {
+			p.Errorf(memberPath(path, "size"), "negative size %d", s.Size)
+		}
+	}
+	return s
+}
+
+func (p *Parser) parseEnum(s *Schema, object *Object, path string) {
+	symbols, found := object.Get("symbols")
+	if !found {
+		p.Errorf(path, "missing \"symbols\"")
+		return
+	}
+	symbolsPath := memberPath(path, "symbols")
+	list, ok := symbols.([]interface{})
+	if !ok {
+		p.Errorf(symbolsPath, "expected an array of strings, got %s", describe(symbols))
+		return
+	}
+	seen := make(map[string]bool)
+	for i, value := range list {
+		symbol, ok := value.(string)
+		if !ok || !isValidName(symbol) {
+			p.Errorf(indexPath(symbolsPath, i), "invalid symbol %s", describe(value))
+			continue
+		}
+		if seen[symbol] {
+			p.Errorf(indexPath(symbolsPath, i), "duplicate symbol %q", symbol)
+			continue
+		}
+		seen[symbol] = true
+		s.Symbols = append(s.Symbols, symbol)
+	}
+	if _, found := object.Get("default"); found {
+		s.EnumDefault = p.optionalString(object, "default", path)
+		if !seen[s.EnumDefault] {
+			p.Errorf(memberPath(path, "default"), "default %q isn't one of the symbols", s.EnumDefault)
+		}
+	}
+}
+
+// ParseFields parses the fields of a record or the request of a protocol message
+func (p *Parser) ParseFields(value interface{}, path string, namespace string) []*Field {
+	list, ok := value.([]interface{})
+	if !ok {
+		p.Errorf(path, "expected an array of fields, got %s", describe(value))
+		return nil
+	}
+	fields := make([]*Field, 0, len(list))
+	seen := make(map[string]bool)
+	for i, item := range list {
+		fieldPath := indexPath(path, i)
+		object, ok := item.(*Object)
+		if !ok {
+			p.Errorf(fieldPath, "expected a field object, got %s", describe(item))
+			continue
+		}
+		name, ok := p.requiredString(object, "name", fieldPath)
+		if !ok {
+			continue
+		}
+		if !isValidName(name) {
+			p.Errorf(memberPath(fieldPath, "name"), "invalid field name %q", name)
+		}
+		if seen[name] {
+			p.Errorf(memberPath(fieldPath, "name"), "duplicate field %q", name)
+		}
+		seen[name] = true
+
+		field := &Field{Name: name, Path: fieldPath}
+		field.Doc = p.optionalString(object, "doc", fieldPath)
+		field.Type = p.requiredSchema(object, "type", fieldPath, namespace)
+		field.Default, field.HasDefault = object.Get("default")
+		field.Default = PlainJSON(field.Default)
+		field.Order = p.optionalString(object, "order", fieldPath)
+		switch field.Order {
+		case "", "ascending", "descending", "ignore":
+		default:
+			p.Errorf(memberPath(fieldPath, "order"), "invalid order %q", field.Order)
+		}
+		field.Aliases = p.parseAliases(object, fieldPath, "")
+		field.Props = extraMembers(object, []string{"name", "doc", "type", "default", "order", "aliases"})
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+func (p *Parser) parseLogicalType(s *Schema, object *Object, path string) {
+	value, found := object.Get("logicalType")
+	if !found {
+		return
+	}
+	logicalType, ok := value.(string)
+	if !ok {
+		p.Errorf(memberPath(path, "logicalType"), "expected a string, got %s", describe(value))
+		return
+	}
+	s.LogicalType = logicalType
+	if logicalType != "decimal" {
+		return
+	}
+	// Invalid decimals fall back to the underlying type, see ValidateLogicalType
+	if precision, found := object.Get("precision"); found {
+		s.Precision, _ = p.integer(precision, memberPath(path, "precision"))
+	}
+	if scale, found := object.Get("scale"); found {
+		s.Scale, _ = p.integer(scale, memberPath(path, "scale"))
+	}
+}
+
+func (p *Parser) parseAliases(object *Object, path string, namespace string) []string {
+	value, found := object.Get("aliases")
+	if !found {
+		return nil
+	}
+	aliasesPath := memberPath(path, "aliases")
+	list, ok := value.([]interface{})
+	if !ok {
+		p.Errorf(aliasesPath, "expected an array of names, got %s", describe(value))
+		return nil
+	}
+	var aliases []string
+	for i, item := range list {
+		alias, ok := item.(string)
+		if !ok {
+			p.Errorf(indexPath(aliasesPath, i), "expected a name, got %s", describe(item))
+			continue
+		}
+		alias = FullName(alias, namespace)
+		ns, name := SplitName(alias)
+		if !isValidName(name) || !isValidNamespace(ns) {
+			p.Errorf(indexPath(aliasesPath, i), "invalid alias %q", alias)
+			continue
+		}
+		aliases = append(aliases, alias)
+	}
+	return aliases
+}
+
+func (p *Parser) requiredString(object *Object, key string, path string) (string, bool) {
+	value, found := object.Get(key)
+	if !found {
+		p.Errorf(path, "missing %q", key)
+		return "", false
+	}
+	s, ok := value.(string)
+	if !ok {
+		p.Errorf(memberPath(path, key), "expected a string, got %s", describe(value))
+	}
+	return s, ok
+}
+
+func (p *Parser) optionalString(object *Object, key string, path string) string {
+	value, found := object.Get(key)
+	if !found {
+		return ""
+	}
+	s, ok := value.(string)
+	if !ok {
+		p.Errorf(memberPath(path, key), "expected a string, got %s", describe(value))
+	}
+	return s
+}
+
+func (p *Parser) integer(value interface{}, path string) (int, bool) {
+	number, ok := value.(json.Number)
+	if ok {
+		n, err := strconv.Atoi(number.String())
+		if err == nil {
+			return n, true
+		}
+	}
+	p.Errorf(path, "expected an integer, got %s", describe(value))
+	return 0, false
+}
+
+func extraMembers(object *Object, known []string) []Member {
+	var res []Member
+	for _, member := range object.Members {
+		isKnown := false
+		for _, key := range known {
+			if member.Key == key {
+				isKnown = true
+				break
+			}
+		}
+		if !isKnown {
+			res = append(res, Member{Key: member.Key, Value: PlainJSON(member.Value)})
+		}
+	}
+	return res
+}
+
+func describe(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return strconv.Quote(v)
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	case []interface{}:
+		return "an array"
+	case *Object:
+		return "an object"
+	}
+	return fmt.Sprintf("%v", value)
+}
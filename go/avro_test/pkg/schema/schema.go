@@ -0,0 +1,191 @@
+// Package schema is a typed model of Avro schemas, see
+// https://avro.apache.org/docs/1.11.1/specification/
+package schema
+
+import (
+	"regexp"
+	"strings"
+)
+
+type Type string
+
+const (
+	Null    Type = "null"
+	Boolean Type = "boolean"
+	Int     Type = "int"
+	Long    Type = "long"
+	Float   Type = "float"
+	Double  Type = "double"
+	Bytes   Type = "bytes"
+	String  Type = "string"
+
+	Record Type = "record"
+	// ErrorRecord is a record declared with "error", only valid in protocols
+	ErrorRecord Type = "error"
+	Enum        Type = "enum"
+	Array       Type = "array"
+	Map         Type = "map"
+	Fixed       Type = "fixed"
+	Union       Type = "union"
+)
+
+var primitiveTypes = map[Type]bool{Null: true, Boolean: true, Int: true, Long: true, Float: true, Double: true, Bytes: true, String: true}
+
+// IsPrimitive is true for the types that don't have attributes
+func (t Type) IsPrimitive() bool {
+	return primitiveTypes[t]
+}
+
+// IsNamed is true for record, error, enum and fixed
+func (t Type) IsNamed() bool {
+	return t == Record || t == ErrorRecord || t == Enum || t == Fixed
+}
+
+// Schema is a node of a schema. Named types are shared by pointer between their definition
+// and their references, so recursive types are cyclic
+type Schema struct {
+	Type Type
+	// Name is the full name of named types
+	Name string
+	// Aliases are full names
+	Aliases []string
+	Doc     string
+
+	// Fields of a record or error
+	Fields []*Field
+	// Symbols of an enum, and the symbol read in place of unknown ones if any
+	Symbols     []string
+	EnumDefault string
+	// Size of a fixed
+	Size int
+	// Items of an array
+	Items *Schema
+	// Values of a map
+	Values *Schema
+	// Branches of a union
+	Branches []*Schema
+
+	// LogicalType is kept as written, see ValidateLogicalType
+	LogicalType string
+	Precision   int
+	Scale       int
+
+	// Props are the attributes that aren't part of the spec, in document order
+	Props []Member
+	// Path is where the schema is in the parsed document
+	Path string
+}
+
+// Field is a field of a record or a parameter of a protocol message
+type Field struct {
+	Name string
+	Doc  string
+	Type *Schema
+	// Default is the JSON default value, see PlainJSON; HasDefault tells a null default from none
+	Default    interface{}
+	HasDefault bool
+	// Order is ascending when empty
+	Order   string
+	Aliases []string
+	Props   []Member
+	Path    string
+}
+
+// Namespace of a named type
+func (s *Schema) Namespace() string {
+	namespace, _ := SplitName(s.Name)
+	return namespace
+}
+
+// ShortName is the name of a named type without its namespace
+func (s *Schema) ShortName() string {
+	_, name := SplitName(s.Name)
+	return name
+}
+
+// TypeName is the full name of named types and the type otherwise, i.e. the name of
+// a union branch in the JSON encoding
+func (s *Schema) TypeName() string {
+	if s.Type.IsNamed() {
+		return s.Name
+	}
+	return string(s.Type)
+}
+
+// NativeName is the name of a union branch in goavro natives, which qualifies the
+// logical types goavro converts, e.g. long.timestamp-millis
+func (s *Schema) NativeName() string {
+	switch {
+	case s.Type.IsNamed():
+		return s.Name
+	case s.Type == Bytes && s.LogicalType == "decimal",
+		s.Type == Long && (s.LogicalType == "timestamp-millis" || s.LogicalType == "timestamp-micros" || s.LogicalType == "time-micros"),
+		s.Type == Int && (s.LogicalType == "date" || s.LogicalType == "time-millis"):
+		return string(s.Type) + "." + s.LogicalType
+	}
+	return string(s.Type)
+}
+
+// Field returns the field named name, nil if missing
+func (s *Schema) Field(name string) *Field {
+	for _, field := range s.Fields {
+		if field.Name == name {
+			return field
+		}
+	}
+	return nil
+}
+
+// Nullable returns T for a ["null", T] or [T, "null"] union
+func (s *Schema) Nullable() (*Schema, bool) {
+	if s.Type != Union || len(s.Branches) != 2 {
+		return nil, false
+	}
+	if s.Branches[0].Type == Null {
+		return s.Branches[1], true
+	}
+	if s.Branches[1].Type == Null {
+		return s.Branches[0], true
+	}
+	return nil, false
+}
+
+// Prop returns a non-standard attribute
+func (s *Schema) Prop(key string) (interface{}, bool) {
+	return (&Object{Members: s.Props}).Get(key)
+}
+
+var namePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func isValidName(name string) bool {
+	return namePattern.MatchString(name)
+}
+
+func isValidNamespace(namespace string) bool {
+	if namespace == "" {
+		return true
+	}
+	for _, part := range strings.Split(namespace, ".") {
+		if !isValidName(part) {
+			return false
+		}
+	}
+	return true
+}
+
+// SplitName splits a full name in namespace and name
+func SplitName(fullName string) (namespace string, name string) {
+	i := strings.LastIndexByte(fullName, '.')
+	if i < 0 {
+		return "", fullName
+	}
+	return fullName[:i], fullName[i+1:]
+}
+
+// FullName qualifies name with namespace unless it already has a namespace
+func FullName(name string, namespace string) string {
+	if strings.ContainsRune(name, '.') || namespace == "" {
+		return name
+	}
+	return namespace + "." + name
+}
@@ -0,0 +1,138 @@
+package schema
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/stretchr/testify/require"
+)
+
+// longList is the schema of test.go
+const longList = `{
+	"type": "record",
+	"name": "LongList",
+	"fields": [
+		{"name": "next", "type": ["null", "LongList", {"type": "long", "logicalType": "timestamp-millis"}], "default": null}
+	]
+}`
+
+func TestParseRecursiveSchema(t *testing.T) {
+	s, err := Parse([]byte(longList))
+	require.NoError(t, err)
+	require.Equal(t, Record, s.Type)
+	next := s.Field("next")
+	require.Equal(t, Union, next.Type.Type)
+	require.Same(t, s, next.Type.Branches[1])
+	require.Equal(t, "timestamp-millis", next.Type.Branches[2].LogicalType)
+	require.Equal(t, "$.fields[0].type[2]", next.Type.Branches[2].Path)
+
+	// Written back, the recursive reference is a name
+	require.JSONEq(t, `{"type":"record","name":"LongList","fields":[{"name":"next","type":["null","LongList",{"type":"long","logicalType":"timestamp-millis"}],"default":null}]}`, s.String())
+	_, err = goavro.NewCodec(s.String())
+	require.NoError(t, err)
+}
+
+func TestParseNamespaces(t *testing.T) {
+	s, err := Parse([]byte(`{
+		"type": "record", "name": "Outer", "namespace": "a.b", "aliases": ["Old", "x.Older"],
+		"fields": [
+			{"name": "inner", "type": {"type": "fixed", "name": "Inner", "size": 4}},
+			{"name": "other", "type": {"type": "enum", "name": "c.Other", "symbols": ["X"]}},
+			{"name": "again", "type": "Inner"},
+			{"name": "qualified", "type": "c.Other"}
+		]
+	}`))
+	require.NoError(t, err)
+	require.Equal(t, "a.b.Outer", s.Name)
+	require.Equal(t, []string{"a.b.Old", "x.Older"}, s.Aliases)
+	require.Equal(t, "a.b.Inner", s.Field("inner").Type.Name)
+	require.Equal(t, "c.Other", s.Field("other").Type.Name)
+	require.Same(t, s.Field("inner").Type, s.Field("again").Type)
+	require.Same(t, s.Field("other").Type, s.Field("qualified").Type)
+
+	again, err := Parse([]byte(s.String()))
+	require.NoError(t, err)
+	require.Equal(t, s.String(), again.String())
+}
+
+func TestParseKeepsProps(t *testing.T) {
+	s, err := Parse([]byte(`{"type": "string", "avro.java.string": "String"}`))
+	require.NoError(t, err)
+	value, found := s.Prop("avro.java.string")
+	require.True(t, found)
+	require.Equal(t, "String", value)
+	require.JSONEq(t, `{"type": "string", "avro.java.string": "String"}`, s.String())
+}
+
+func TestParseErrors(t *testing.T) {
+	for schema, message := range map[string]string{
+		`"Unknown"`:                                      `$: unknown type "Unknown"`,
+		`{"type": "record", "name": "R"}`:                `$: missing "fields"`,
+		`{"type": "record", "name": "1R", "fields": []}`: `$.name: invalid name "1R"`,
+		`{"type": "fixed", "name": "F", "size": "4"}`:    `$.size: expected an integer, got "4"`,
+		`["int", "int"]`:                                 `$[1]: duplicate int in union`,
+		`["int", ["null"]]`:                              `$[1]: a union can't directly contain a union`,
+		`{"type": "enum", "name": "E", "symbols": ["A"], "default": "B"}`: `$.default: default "B" isn't one of the symbols`,
+		`{"type": "array"}`: `$: missing "items"`,
+		`{"type": "map", "values": {"type": "record", "name": "int", "fields": []}}`: `$.values.name: "int" is a primitive type`,
+	} {
+		_, err := Parse([]byte(schema))
+		require.ErrorContains(t, err, message, schema)
+	}
+}
+
+func TestCanonical(t *testing.T) {
+	s, err := Parse([]byte(`{
+		"type": "record", "name": "Order", "namespace": "shop", "doc": "an order", "aliases": ["Purchase"],
+		"fields": [
+			{"name": "id", "type": {"type": "fixed", "name": "Id", "size": 16}, "doc": "the ID"},
+			{"name": "status", "type": {"type": "enum", "name": "Status", "namespace": "shop.status", "symbols": ["OPEN", "CLOSED"], "default": "OPEN"}},
+			{"name": "lines", "type": {"type": "array", "items": {"type": "record", "name": "Line", "fields": [{"name": "id", "type": "Id"}]}}},
+			{"name": "at", "type": ["null", {"type": "long", "logicalType": "timestamp-millis"}], "default": null},
+			{"name": "tags", "type": {"type": "map", "values": "string", "custom": true}}
+		]
+	}`))
+	require.NoError(t, err)
+	canonical := `{"name":"shop.Order","type":"record","fields":[` +
+		`{"name":"id","type":{"name":"shop.Id","type":"fixed","size":16}},` +
+		`{"name":"status","type":{"name":"shop.status.Status","type":"enum","symbols":["OPEN","CLOSED"]}},` +
+		`{"name":"lines","type":{"type":"array","items":{"name":"shop.Line","type":"record","fields":[{"name":"id","type":"shop.Id"}]}}},` +
+		`{"name":"at","type":["null","long"]},` +
+		`{"name":"tags","type":{"type":"map","values":"string"}}]}`
+	require.Equal(t, canonical, s.Canonical())
+
+	// goavro only agrees without nested namespaces and logical types
+	s, err = Parse([]byte(`{"type": "record", "name": "R", "namespace": "n", "fields": [{"name": "a", "type": ["null", "int"], "doc": "a"}]}`))
+	require.NoError(t, err)
+	codec, err := goavro.NewCodec(s.String())
+	require.NoError(t, err)
+	require.Equal(t, codec.CanonicalSchema(), s.Canonical())
+	require.Equal(t, codec.Rabin, s.Fingerprint64())
+
+	// the fingerprints of the spec test vectors
+	for schema, fingerprint := range map[string]uint64{`"int"`: 0x7275d51a3f395c8f, `"string"`: 0x8f014872634503c7} {
+		s, err := Parse([]byte(schema))
+		require.NoError(t, err)
+		require.Equal(t, fingerprint, s.Fingerprint64(), schema)
+	}
+	s, err = Parse([]byte(`"int"`))
+	require.NoError(t, err)
+	md5 := s.FingerprintMD5()
+	require.Equal(t, "ef524ea1b91e73173d938ade36c1db32", hex.EncodeToString(md5[:]))
+	sha := s.FingerprintSHA256()
+	require.Equal(t, "3f2b87a9fe7cc9b13835598c3981cd45e3e355309e5090aa0933d7becb6fba45", hex.EncodeToString(sha[:]))
+}
+
+func TestPositions(t *testing.T) {
+	positions, err := Positions([]byte("{\n  \"type\": \"map\",\n  \"values\": [\"null\",\n    {\"type\": \"fixed\", \"name\": \"F\", \"size\": 4, \"my-prop\": 1}]\n}"))
+	require.NoError(t, err)
+	require.Equal(t, Position{Line: 1, Column: 1}, positions["$"])
+	require.Equal(t, Position{Line: 2, Column: 11}, positions["$.type"])
+	require.Equal(t, Position{Line: 3, Column: 14}, positions["$.values[0]"])
+	require.Equal(t, Position{Line: 4, Column: 5}, positions["$.values[1]"])
+	require.Equal(t, Position{Line: 4, Column: 58}, positions[`$.values[1]["my-prop"]`])
+
+	_, err = Positions([]byte(`{"type": }`))
+	require.EqualError(t, err, "$: invalid JSON at line 1, column 11: missing value after object key")
+}
@@ -0,0 +1,132 @@
+package schema
+
+import (
+	"encoding/json"
+)
+
+// Writer converts schemas back to JSON. A named type is defined the first time it's
+// written and referenced by name afterwards, so a Writer is shared by all the schemas
+// of a document
+type Writer struct {
+	defined map[string]bool
+}
+
+func NewWriter() *Writer {
+	return &Writer{defined: make(map[string]bool)}
+}
+
+// Define marks a named type as already defined, e.g. by an imported document
+func (w *Writer) Define(name string) {
+	w.defined[name] = true
+}
+
+// Value returns the JSON value of s, to marshal with encoding/json. namespace is the
+// enclosing namespace, which qualifies relative names
+func (w *Writer) Value(s *Schema, namespace string) interface{} {
+	if s.Type.IsNamed() {
+		if w.defined[s.Name] {
+			return relativeName(s.Name, namespace)
+		}
+		w.defined[s.Name] = true
+	}
+
+	switch s.Type {
+	case Union:
+		branches := make([]interface{}, 0, len(s.Branches))
+		for _, branch := range s.Branches {
+			branches = append(branches, w.Value(branch, namespace))
+		}
+		return branches
+	case Array:
+		return &Object{Members: append([]Member{{"type", "array"}, {"items", w.Value(s.Items, namespace)}}, s.Props...)}
+	case Map:
+		return &Object{Members: append([]Member{{"type", "map"}, {"values", w.Value(s.Values, namespace)}}, s.Props...)}
+	}
+
+	if s.Type.IsPrimitive() && s.LogicalType == "" && len(s.Props) == 0 {
+		return string(s.Type)
+	}
+	object := &Object{Members: []Member{{"type", string(s.Type)}}}
+	if s.Type.IsNamed() {
+		ns, name := SplitName(s.Name)
+		object.Set("name", name)
+		if ns != namespace {
+			object.Set("namespace", ns)
+		}
+		if s.Doc != "" {
+			object.Set("doc", s.Doc)
+		}
+		if len(s.Aliases) > 0 {
+			object.Set("aliases", s.Aliases)
+		}
+		namespace = ns
+	}
+	switch s.Type {
+	case Record, ErrorRecord:
+		fields := make([]interface{}, 0, len(s.Fields))
+		for _, field := range s.Fields {
+			fields = append(fields, w.FieldValue(field, namespace))
+		}
+		object.Set("fields", fields)
+	case Enum:
+		object.Set("symbols", s.Symbols)
+		if s.EnumDefault != "" {
+			object.Set("default", s.EnumDefault)
+		}
+	case Fixed:
+		object.Set("size", s.Size)
+	}
+	if s.LogicalType != "" {
+		object.Set("logicalType", s.LogicalType)
+		if s.LogicalType == "decimal" {
+			object.Set("precision", s.Precision)
+			if s.Scale != 0 {
+				object.Set("scale", s.Scale)
+			}
+		}
+	}
+	object.Members = append(object.Members, s.Props...)
+	return object
+}
+
+// FieldValue returns the JSON value of a record field or a message parameter
+func (w *Writer) FieldValue(field *Field, namespace string) interface{} {
+	object := &Object{Members: []Member{{"name", field.Name}}}
+	if field.Doc != "" {
+		object.Set("doc", field.Doc)
+	}
+	object.Set("type", w.Value(field.Type, namespace))
+	if field.HasDefault {
+		object.Set("default", field.Default)
+	}
+	if field.Order != "" {
+		object.Set("order", field.Order)
+	}
+	if len(field.Aliases) > 0 {
+		object.Set("aliases", field.Aliases)
+	}
+	object.Members = append(object.Members, field.Props...)
+	return object
+}
+
+func relativeName(fullName string, namespace string) string {
+	ns, name := SplitName(fullName)
+	if ns == namespace {
+		return name
+	}
+	return fullName
+}
+
+// MarshalJSON writes s as a standalone schema, i.e. an .avsc document
+func (s *Schema) MarshalJSON() ([]byte, error) {
+	return json.Marshal(NewWriter().Value(s, ""))
+}
+
+// String is the JSON of s
+func (s *Schema) String() string {
+	data, err := s.MarshalJSON()
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
@@ -0,0 +1,238 @@
+// Package serde encodes Avro data in the Confluent wire format of Kafka messages: a zero
+// magic byte, the big-endian 4 bytes ID of the writer schema in the registry, then the
+// Avro binary encoding
+package serde
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+
+	"test/avro/pkg/registry"
+	"test/avro/pkg/resolve"
+	"test/avro/pkg/schema"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+// MagicByte starts the messages
+const MagicByte = 0
+
+// HeaderSize is the size of the magic byte and the schema ID
+const HeaderSize = 5
+
+// Registry is the part of a registry client serde uses, e.g. *registry.Client
+type Registry interface {
+	Register(ctx context.Context, subject string, schema string) (int, error)
+	Schema(ctx context.Context, id int) (string, error)
+}
+
+// ErrShortMessage is returned for messages without the header
+var ErrShortMessage = errors.New("message shorter than the wire format header")
+
+// BadMagicByteError is returned for messages not in the wire format
+type BadMagicByteError struct {
+	Got byte
+}
+
+func (e *BadMagicByteError) Error() string {
+	return fmt.Sprintf("unknown magic byte %d", e.Got)
+}
+
+// UnknownSchemaError is returned when the registry has no schema with the ID of a message
+type UnknownSchemaError struct {
+	ID  int
+	Err error
+}
+
+func (e *UnknownSchemaError) Error() string {
+	return fmt.Sprintf("unknown schema %d: %s", e.ID, e.Err)
+}
+
+func (e *UnknownSchemaError) Unwrap() error {
+	return e.Err
+}
+
+// AppendHeader appends the header of the schema id to buf
+func AppendHeader(buf []byte, id int) []byte {
+	var header [HeaderSize]byte
+	header[0] = MagicByte
+	binary.BigEndian.PutUint32(header[1:], uint32(id))
+	return append(buf, header[:]...)
+}
+
+// SplitHeader returns the schema ID and the Avro data of a message
+func SplitHeader(message []byte) (int, []byte, error) {
+	if len(message) < HeaderSize {
+		return 0, nil, ErrShortMessage
+	}
+	if message[0] != MagicByte {
+		return 0, nil, &BadMagicByteError{Got: message[0]}
+	}
+	return int(binary.BigEndian.Uint32(message[1:HeaderSize])), message[HeaderSize:], nil
+}
+
+// Serializer registers the schemas in subjects named by its strategy and encodes the
+// natives. It caches the IDs and the codecs and is safe for concurrent use
+type Serializer struct {
+	registry Registry
+	strategy SubjectNameStrategy
+	isKey    bool
+
+	mu sync.Mutex
+	// ids are the IDs by subject and schema JSON
+	ids    map[[2]string]int
+	codecs map[int]*goavro.Codec
+}
+
+// NewSerializer serializes the keys of messages if isKey and their values otherwise
+func NewSerializer(r Registry, strategy SubjectNameStrategy, isKey bool) *Serializer {
+	return &Serializer{registry: r, strategy: strategy, isKey: isKey, ids: make(map[[2]string]int), codecs: make(map[int]*goavro.Codec)}
+}
+
+// Serialize encodes native, a goavro native of writer, for a message of topic
+func (s *Serializer) Serialize(ctx context.Context, topic string, writer *schema.Schema, native interface{}) ([]byte, error) {
+	id, codec, err := s.codec(ctx, topic, writer)
+	if err != nil {
+		return nil, err
+	}
+	buf := AppendHeader(make([]byte, 0, 64), id)
+	return codec.BinaryFromNative(buf, native)
+}
+
+func (s *Serializer) codec(ctx context.Context, topic string, writer *schema.Schema) (int, *goavro.Codec, error) {
+	subject, err := s.strategy(topic, writer, s.isKey)
+	if err != nil {
+		return 0, nil, err
+	}
+	writerJSON := writer.String()
+	key := [2]string{subject, writerJSON}
+
+	s.mu.Lock()
+	id, found := s.ids[key]
+	codec := s.codecs[id]
+	s.mu.Unlock()
+	if found {
+		return id, codec, nil
+	}
+
+	id, err = s.registry.Register(ctx, subject, writerJSON)
+	if err != nil {
+		return 0, nil, fmt.Errorf("register %s: %w", subject, err)
+	}
+	codec, err = goavro.NewCodec(writerJSON)
+	if err != nil {
+		return 0, nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ids[key] = id
+	s.codecs[id] = codec
+	return id, codec, nil
+}
+
+// Deserializer decodes messages with the writer schemas fetched by ID, which are cached.
+// It's safe for concurrent use
+type Deserializer struct {
+	registry Registry
+	reader   *schema.Schema
+
+	mu      sync.Mutex
+	codecs  map[int]*goavro.Codec
+	readers map[int]*resolve.Decoder
+}
+
+// NewDeserializer returns natives of the writer schemas
+func NewDeserializer(r Registry) *Deserializer {
+	return &Deserializer{registry: r, codecs: make(map[int]*goavro.Codec), readers: make(map[int]*resolve.Decoder)}
+}
+
+// NewReaderDeserializer returns natives of the reader schema, resolved from the writer
+// schemas of the messages
+func NewReaderDeserializer(r Registry, reader *schema.Schema) *Deserializer {
+	d := NewDeserializer(r)
+	d.reader = reader
+	return d
+}
+
+// Deserialize decodes a message. The errors are ErrShortMessage, *BadMagicByteError,
+// *UnknownSchemaError, compat.Incompatibilities if the reader can't read the writer
+// schema, or decoding errors
+func (d *Deserializer) Deserialize(ctx context.Context, message []byte) (interface{}, error) {
+	id, data, err := SplitHeader(message)
+	if err != nil {
+		return nil, err
+	}
+	if d.reader != nil {
+		decoder, err := d.decoder(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		native, _, err := decoder.NativeFromBinary(data)
+		return native, err
+	}
+	codec, err := d.codec(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	native, _, err := codec.NativeFromBinary(data)
+	return native, err
+}
+
+func (d *Deserializer) writerSchema(ctx context.Context, id int) (string, error) {
+	writer, err := d.registry.Schema(ctx, id)
+	if registry.IsCode(err, registry.CodeSchemaNotFound) {
+		return "", &UnknownSchemaError{ID: id, Err: err}
+	}
+	return writer, err
+}
+
+func (d *Deserializer) codec(ctx context.Context, id int) (*goavro.Codec, error) {
+	d.mu.Lock()
+	codec, found := d.codecs[id]
+	d.mu.Unlock()
+	if found {
+		return codec, nil
+	}
+
+	writer, err := d.writerSchema(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	codec, err = goavro.NewCodec(writer)
+	if err != nil {
+		return nil, fmt.Errorf("schema %d: %w", id, err)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.codecs[id] = codec
+	return codec, nil
+}
+
+func (d *Deserializer) decoder(ctx context.Context, id int) (*resolve.Decoder, error) {
+	d.mu.Lock()
+	decoder, found := d.readers[id]
+	d.mu.Unlock()
+	if found {
+		return decoder, nil
+	}
+
+	writerJSON, err := d.writerSchema(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	writer, err := schema.Parse([]byte(writerJSON))
+	if err != nil {
+		return nil, fmt.Errorf("schema %d: %w", id, err)
+	}
+	decoder, err = resolve.NewDecoder(d.reader, writer)
+	if err != nil {
+		return nil, fmt.Errorf("schema %d: %w", id, err)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.readers[id] = decoder
+	return decoder, nil
+}
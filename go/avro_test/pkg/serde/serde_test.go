@@ -0,0 +1,120 @@
+package serde
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"test/avro/pkg/registry"
+	"test/avro/pkg/schema"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	orderV1 = `{"type": "record", "name": "Order", "namespace": "shop", "fields": [{"name": "id", "type": "string"}]}`
+	orderV2 = `{"type": "record", "name": "Order", "namespace": "shop", "fields": [{"name": "id", "type": "string"}, {"name": "quantity", "type": "long", "default": 1}]}`
+)
+
+func parse(t *testing.T, s string) *schema.Schema {
+	parsed, err := schema.Parse([]byte(s))
+	require.NoError(t, err)
+	return parsed
+}
+
+// countingRegistry counts the calls to check the caches
+type countingRegistry struct {
+	Registry
+	registers int
+	fetches   int
+}
+
+func (r *countingRegistry) Register(ctx context.Context, subject string, schema string) (int, error) {
+	r.registers++
+	return r.Registry.Register(ctx, subject, schema)
+}
+
+func (r *countingRegistry) Schema(ctx context.Context, id int) (string, error) {
+	r.fetches++
+	return r.Registry.Schema(ctx, id)
+}
+
+func newRegistry(t *testing.T) *countingRegistry {
+	server := httptest.NewServer(registry.NewHandler(registry.NewMemory()))
+	t.Cleanup(server.Close)
+	return &countingRegistry{Registry: registry.NewClient(server.URL)}
+}
+
+func TestRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	r := newRegistry(t)
+	serializer := NewSerializer(r, TopicNameStrategy, false)
+	deserializer := NewDeserializer(r)
+
+	for i := 0; i < 2; i++ {
+		message, err := serializer.Serialize(ctx, "orders", parse(t, orderV1), map[string]interface{}{"id": "o-1"})
+		require.NoError(t, err)
+		require.Equal(t, []byte{0, 0, 0, 0, 1}, message[:HeaderSize])
+
+		native, err := deserializer.Deserialize(ctx, message)
+		require.NoError(t, err)
+		require.Equal(t, map[string]interface{}{"id": "o-1"}, native)
+	}
+	require.Equal(t, 1, r.registers)
+	require.Equal(t, 1, r.fetches)
+
+	versions, err := r.Registry.(*registry.Client).Versions(ctx, "orders-value")
+	require.NoError(t, err)
+	require.Equal(t, []int{1}, versions)
+}
+
+func TestReaderDeserializerResolvesOldMessages(t *testing.T) {
+	ctx := context.Background()
+	r := newRegistry(t)
+	old, err := NewSerializer(r, TopicNameStrategy, false).Serialize(ctx, "orders", parse(t, orderV1), map[string]interface{}{"id": "o-1"})
+	require.NoError(t, err)
+
+	native, err := NewReaderDeserializer(r, parse(t, orderV2)).Deserialize(ctx, old)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"id": "o-1", "quantity": int64(1)}, native)
+}
+
+func TestSubjectNameStrategies(t *testing.T) {
+	order := parse(t, orderV1)
+	for _, c := range []struct {
+		strategy SubjectNameStrategy
+		isKey    bool
+		subject  string
+	}{
+		{TopicNameStrategy, false, "orders-value"},
+		{TopicNameStrategy, true, "orders-key"},
+		{RecordNameStrategy, false, "shop.Order"},
+		{TopicRecordNameStrategy, true, "orders-shop.Order"},
+	} {
+		subject, err := c.strategy("orders", order, c.isKey)
+		require.NoError(t, err)
+		require.Equal(t, c.subject, subject)
+	}
+	_, err := RecordNameStrategy("orders", parse(t, `"string"`), false)
+	require.EqualError(t, err, "the record name strategy needs a named type, got string")
+}
+
+func TestDeserializeErrors(t *testing.T) {
+	ctx := context.Background()
+	d := NewDeserializer(newRegistry(t))
+
+	_, err := d.Deserialize(ctx, []byte{0, 0, 1})
+	require.True(t, errors.Is(err, ErrShortMessage))
+
+	_, err = d.Deserialize(ctx, []byte{1, 0, 0, 0, 1, 2})
+	var magicErr *BadMagicByteError
+	require.True(t, errors.As(err, &magicErr))
+	require.Equal(t, byte(1), magicErr.Got)
+
+	_, err = d.Deserialize(ctx, AppendHeader(nil, 42))
+	var unknownErr *UnknownSchemaError
+	require.True(t, errors.As(err, &unknownErr))
+	require.Equal(t, 42, unknownErr.ID)
+	require.True(t, registry.IsCode(err, registry.CodeSchemaNotFound))
+}
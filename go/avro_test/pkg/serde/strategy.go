@@ -0,0 +1,37 @@
+package serde
+
+import (
+	"fmt"
+
+	"test/avro/pkg/schema"
+)
+
+// SubjectNameStrategy returns the subject of the schemas of a topic
+type SubjectNameStrategy func(topic string, s *schema.Schema, isKey bool) (string, error)
+
+// TopicNameStrategy is <topic>-key or <topic>-value, the default of Confluent: a topic
+// has one type of keys and one of values
+func TopicNameStrategy(topic string, _ *schema.Schema, isKey bool) (string, error) {
+	if isKey {
+		return topic + "-key", nil
+	}
+	return topic + "-value", nil
+}
+
+// RecordNameStrategy is the full name of the record, for record types shared by topics
+func RecordNameStrategy(_ string, s *schema.Schema, _ bool) (string, error) {
+	if !s.Type.IsNamed() {
+		return "", fmt.Errorf("the record name strategy needs a named type, got %s", s.Type)
+	}
+	return s.Name, nil
+}
+
+// TopicRecordNameStrategy is <topic>-<full name of the record>, for topics with several
+// record types
+func TopicRecordNameStrategy(topic string, s *schema.Schema, isKey bool) (string, error) {
+	name, err := RecordNameStrategy(topic, s, isKey)
+	if err != nil {
+		return "", err
+	}
+	return topic + "-" + name, nil
+}
@@ -0,0 +1,458 @@
+// Package textual is the JSON encoding of Avro with the options goavro doesn't have:
+// leaving out the record fields that have their default value, and plain JSON without
+// the {"type": value} wrappers of unions, for consumers that don't know Avro. The
+// decoding reverses both, so that the JSON of any Options decodes to the same natives.
+//
+// Plain JSON is decoded to the first union branch that matches the value, e.g. a number
+// is a long in ["null", "long", "double"]. It's only reversible for unions whose branches
+// have values that differ, i.e. not for ["int", "long"], ["string", "bytes"] or records
+// with the same fields
+package textual
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"test/avro/pkg/schema"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+// Options of the encoding, the zero value is the JSON encoding of the spec and goavro
+type Options struct {
+	// OmitDefaults leaves out the record fields whose value is their default
+	OmitDefaults bool
+	// Plain writes the values of unions without their wrapper
+	Plain bool
+}
+
+// Codec converts between goavro natives and JSON with options
+type Codec struct {
+	schema  *schema.Schema
+	codec   *goavro.Codec
+	options Options
+}
+
+// NewCodec returns a codec of s
+func NewCodec(s *schema.Schema, options Options) (*Codec, error) {
+	codec, err := goavro.NewCodec(s.String())
+	if err != nil {
+		return nil, err
+	}
+	return &Codec{schema: s, codec: codec, options: options}, nil
+}
+
+// TextualFromNative appends the JSON of native to buf
+func (c *Codec) TextualFromNative(buf []byte, native interface{}) ([]byte, error) {
+	standard, err := c.codec.TextualFromNative(nil, native)
+	if err != nil {
+		return nil, err
+	}
+	value, err := schema.DecodeJSON(standard)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(c.encode(c.schema, value))
+	if err != nil {
+		return nil, err
+	}
+	return append(buf, data...), nil
+}
+
+// encode converts the standard JSON value v of s
+func (c *Codec) encode(s *schema.Schema, v interface{}) interface{} {
+	switch s.Type {
+	case schema.Union:
+		wrapper, ok := v.(*schema.Object)
+		if !ok || len(wrapper.Members) != 1 {
+			return v
+		}
+		branch := unionBranch(s, wrapper.Members[0].Key)
+		if branch == nil {
+			return v
+		}
+		inner := c.encode(branch, wrapper.Members[0].Value)
+		if c.options.Plain {
+			return inner
+		}
+		return &schema.Object{Members: []schema.Member{{Key: wrapper.Members[0].Key, Value: inner}}}
+	case schema.Record, schema.ErrorRecord:
+		object, ok := v.(*schema.Object)
+		if !ok {
+			return v
+		}
+		res := &schema.Object{}
+		for _, field := range s.Fields {
+			value, _ := object.Get(field.Name)
+			if c.options.OmitDefaults && field.HasDefault && isDefault(field.Type, value, field.Default) {
+				continue
+			}
+			res.Set(field.Name, c.encode(field.Type, value))
+		}
+		return res
+	case schema.Array:
+		items, ok := v.([]interface{})
+		if !ok {
+			return v
+		}
+		res := make([]interface{}, 0, len(items))
+		for _, item := range items {
+			res = append(res, c.encode(s.Items, item))
+		}
+		return res
+	case schema.Map:
+		object, ok := v.(*schema.Object)
+		if !ok {
+			return v
+		}
+		res := &schema.Object{}
+		for _, member := range object.Members {
+			res.Set(member.Key, c.encode(s.Values, member.Value))
+		}
+		return res
+	}
+	return v
+}
+
+// unionBranch returns the branch of s named key in a wrapper, nil if none
+func unionBranch(s *schema.Schema, key string) *schema.Schema {
+	for _, branch := range s.Branches {
+		if branch.NativeName() == key || branch.TypeName() == key {
+			return branch
+		}
+	}
+	return nil
+}
+
+// isDefault is true if the standard JSON value v of s is the field default def, whose
+// unions are values of their first branch
+func isDefault(s *schema.Schema, v interface{}, def interface{}) bool {
+	switch s.Type {
+	case schema.Union:
+		first := s.Branches[0]
+		if v == nil {
+			return first.Type == schema.Null && def == nil
+		}
+		wrapper, ok := v.(*schema.Object)
+		return ok && len(wrapper.Members) == 1 && unionBranch(s, wrapper.Members[0].Key) == first &&
+			isDefault(first, wrapper.Members[0].Value, def)
+	case schema.Record, schema.ErrorRecord:
+		object, ok := v.(*schema.Object)
+		defObject, defOK := toObject(def)
+		if !ok || !defOK {
+			return false
+		}
+		for _, field := range s.Fields {
+			value, _ := object.Get(field.Name)
+			fieldDefault, found := defObject.Get(field.Name)
+			if !found {
+				if !field.HasDefault {
+					return false
+				}
+				fieldDefault = field.Default
+			}
+			if !isDefault(field.Type, value, fieldDefault) {
+				return false
+			}
+		}
+		return true
+	case schema.Array:
+		items, ok := v.([]interface{})
+		defItems, defOK := def.([]interface{})
+		if !ok || !defOK || len(items) != len(defItems) {
+			return false
+		}
+		for i := range items {
+			if !isDefault(s.Items, items[i], defItems[i]) {
+				return false
+			}
+		}
+		return true
+	case schema.Map:
+		object, ok := v.(*schema.Object)
+		defObject, defOK := toObject(def)
+		if !ok || !defOK || len(object.Members) != len(defObject.Members) {
+			return false
+		}
+		for _, member := range object.Members {
+			defValue, found := defObject.Get(member.Key)
+			if !found || !isDefault(s.Values, member.Value, defValue) {
+				return false
+			}
+		}
+		return true
+	}
+
+	number, ok := v.(json.Number)
+	defNumber, defOK := def.(json.Number)
+	if ok && defOK {
+		r, ok := new(big.Rat).SetString(string(number))
+		defRat, defOK := new(big.Rat).SetString(string(defNumber))
+		return ok && defOK && r.Cmp(defRat) == 0
+	}
+	switch v.(type) {
+	case nil, bool, string:
+		return v == def
+	}
+	return false
+}
+
+// form is the form of the JSON values to decode
+type form int
+
+const (
+	// wrapped unions are {"type": value}
+	wrapped form = iota
+	// plain unions are values of any branch
+	plain
+	// defaultValue unions are values of their first branch
+	defaultValue
+)
+
+// NativeFromTextual decodes data, a JSON value. The unions have wrappers unless Plain,
+// and the record fields with a default can be missing
+func (c *Codec) NativeFromTextual(data []byte) (interface{}, error) {
+	value, err := schema.DecodeJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	f := wrapped
+	if c.options.Plain {
+		f = plain
+	}
+	standard, err := decode(c.schema, value, f, "$")
+	if err != nil {
+		return nil, err
+	}
+	standardJSON, err := json.Marshal(standard)
+	if err != nil {
+		return nil, err
+	}
+	native, _, err := c.codec.NativeFromTextual(standardJSON)
+	return native, err
+}
+
+// decode converts the JSON value v of s at path to standard JSON
+func decode(s *schema.Schema, v interface{}, f form, path string) (interface{}, error) {
+	switch s.Type {
+	case schema.Union:
+		if f == defaultValue {
+			inner, err := decode(s.Branches[0], v, defaultValue, path)
+			return wrap(s.Branches[0], inner), err
+		}
+		if wrapper, ok := v.(*schema.Object); ok && f == wrapped && len(wrapper.Members) == 1 {
+			if branch := unionBranch(s, wrapper.Members[0].Key); branch != nil {
+				inner, err := decode(branch, wrapper.Members[0].Value, f, path)
+				return wrap(branch, inner), err
+			}
+		}
+		if v == nil && f == wrapped {
+			for _, branch := range s.Branches {
+				if branch.Type == schema.Null {
+					return nil, nil
+				}
+			}
+		}
+		if f == plain {
+			for _, branch := range s.Branches {
+				if matches(branch, v) {
+					inner, err := decode(branch, v, f, path)
+					return wrap(branch, inner), err
+				}
+			}
+		}
+		return nil, &schema.Error{Path: path, Message: fmt.Sprintf("%s isn't a value of %s", describe(v), unionDescription(s))}
+	case schema.Record, schema.ErrorRecord:
+		object, ok := toObject(v)
+		if !ok {
+			return nil, &schema.Error{Path: path, Message: fmt.Sprintf("expected a %s record, got %s", s.Name, describe(v))}
+		}
+		for _, member := range object.Members {
+			if s.Field(member.Key) == nil {
+				return nil, &schema.Error{Path: path, Message: fmt.Sprintf("unknown field %s of %s", member.Key, s.Name)}
+			}
+		}
+		res := &schema.Object{}
+		for _, field := range s.Fields {
+			value, found := object.Get(field.Name)
+			fieldForm := f
+			if !found {
+				if !field.HasDefault {
+					return nil, &schema.Error{Path: path, Message: fmt.Sprintf("missing field %s of %s", field.Name, s.Name)}
+				}
+				value, fieldForm = field.Default, defaultValue
+			}
+			decoded, err := decode(field.Type, value, fieldForm, path+"."+field.Name)
+			if err != nil {
+				return nil, err
+			}
+			res.Set(field.Name, decoded)
+		}
+		return res, nil
+	case schema.Array:
+		items, ok := v.([]interface{})
+		if !ok {
+			return nil, &schema.Error{Path: path, Message: fmt.Sprintf("expected an array, got %s", describe(v))}
+		}
+		res := make([]interface{}, 0, len(items))
+		for i, item := range items {
+			decoded, err := decode(s.Items, item, f, path+"["+strconv.Itoa(i)+"]")
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, decoded)
+		}
+		return res, nil
+	case schema.Map:
+		object, ok := toObject(v)
+		if !ok {
+			return nil, &schema.Error{Path: path, Message: fmt.Sprintf("expected a map, got %s", describe(v))}
+		}
+		res := &schema.Object{}
+		for _, member := range object.Members {
+			decoded, err := decode(s.Values, member.Value, f, path+"["+strconv.Quote(member.Key)+"]")
+			if err != nil {
+				return nil, err
+			}
+			res.Set(member.Key, decoded)
+		}
+		return res, nil
+	}
+	return v, nil
+}
+
+// toObject returns the objects of decoded JSON, or of defaults which are plain JSON
+func toObject(v interface{}) (*schema.Object, bool) {
+	switch v := v.(type) {
+	case *schema.Object:
+		return v, true
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		object := &schema.Object{}
+		for _, key := range keys {
+			object.Set(key, v[key])
+		}
+		return object, true
+	}
+	return nil, false
+}
+
+// wrap returns the standard JSON of the value v of a union branch
+func wrap(branch *schema.Schema, v interface{}) interface{} {
+	if branch.Type == schema.Null {
+		return nil
+	}
+	return &schema.Object{Members: []schema.Member{{Key: branch.NativeName(), Value: v}}}
+}
+
+// matches is true if v is a plain JSON value of s
+func matches(s *schema.Schema, v interface{}) bool {
+	switch s.Type {
+	case schema.Null:
+		return v == nil
+	case schema.Boolean:
+		_, ok := v.(bool)
+		return ok
+	case schema.Int, schema.Long:
+		number, ok := v.(json.Number)
+		if !ok {
+			return false
+		}
+		n, err := number.Int64()
+		return err == nil && (s.Type == schema.Long || (n >= math.MinInt32 && n <= math.MaxInt32))
+	case schema.Float, schema.Double:
+		_, ok := v.(json.Number)
+		return ok
+	case schema.String, schema.Bytes:
+		_, ok := v.(string)
+		return ok
+	case schema.Enum:
+		symbol, ok := v.(string)
+		for _, s := range s.Symbols {
+			if ok && s == symbol {
+				return true
+			}
+		}
+		return false
+	case schema.Fixed:
+		str, ok := v.(string)
+		return ok && utf8.RuneCountInString(str) == s.Size
+	case schema.Union:
+		for _, branch := range s.Branches {
+			if matches(branch, v) {
+				return true
+			}
+		}
+		return false
+	case schema.Record, schema.ErrorRecord:
+		object, ok := v.(*schema.Object)
+		if !ok {
+			return false
+		}
+		for _, member := range object.Members {
+			if field := s.Field(member.Key); field == nil || !matches(field.Type, member.Value) {
+				return false
+			}
+		}
+		for _, field := range s.Fields {
+			if _, found := object.Get(field.Name); !found && !field.HasDefault {
+				return false
+			}
+		}
+		return true
+	case schema.Array:
+		items, ok := v.([]interface{})
+		for _, item := range items {
+			if !matches(s.Items, item) {
+				return false
+			}
+		}
+		return ok
+	case schema.Map:
+		object, ok := v.(*schema.Object)
+		if !ok {
+			return false
+		}
+		for _, member := range object.Members {
+			if !matches(s.Values, member.Value) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func describe(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "a boolean"
+	case json.Number:
+		return "the number " + string(v)
+	case string:
+		return "a string"
+	case []interface{}:
+		return "an array"
+	}
+	return "an object"
+}
+
+func unionDescription(s *schema.Schema) string {
+	names := make([]string, 0, len(s.Branches))
+	for _, branch := range s.Branches {
+		names = append(names, branch.TypeName())
+	}
+	return "the union [" + strings.Join(names, ", ") + "]"
+}
@@ -0,0 +1,103 @@
+package textual
+
+import (
+	"testing"
+	"time"
+
+	"test/avro/pkg/schema"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/stretchr/testify/require"
+)
+
+// longList is the schema of test.go
+const longList = `{"type": "record", "name": "LongList", "fields": [
+	{"name": "next", "type": ["null", "LongList", {"type": "long", "logicalType": "timestamp-millis"}], "default": null}
+]}`
+
+const order = `{"type": "record", "name": "Order", "fields": [
+	{"name": "id", "type": "string"},
+	{"name": "quantity", "type": "int", "default": 1},
+	{"name": "weight", "type": "double", "default": 0.5},
+	{"name": "status", "type": {"type": "enum", "name": "Status", "symbols": ["OPEN", "CLOSED"]}, "default": "OPEN"},
+	{"name": "note", "type": ["string", "null"], "default": "none"},
+	{"name": "lines", "type": {"type": "array", "items": {"type": "record", "name": "Line", "fields": [
+		{"name": "sku", "type": "string"},
+		{"name": "discount", "type": ["null", "float", "string"], "default": null}
+	]}}, "default": []},
+	{"name": "labels", "type": {"type": "map", "values": "long"}, "default": {"a": 1}}
+]}`
+
+func newCodec(t *testing.T, s string, options Options) *Codec {
+	parsed, err := schema.Parse([]byte(s))
+	require.NoError(t, err)
+	c, err := NewCodec(parsed, options)
+	require.NoError(t, err)
+	return c
+}
+
+// roundTrip checks that the JSON of native with options is textual and decodes to native
+func roundTrip(t *testing.T, s string, options Options, native interface{}, textual string) {
+	c := newCodec(t, s, options)
+	data, err := c.TextualFromNative(nil, native)
+	require.NoError(t, err)
+	require.JSONEq(t, textual, string(data))
+	decoded, err := c.NativeFromTextual(data)
+	require.NoError(t, err)
+	require.Equal(t, native, decoded)
+}
+
+func TestLongList(t *testing.T) {
+	end := map[string]interface{}{"next": nil}
+	native := map[string]interface{}{"next": goavro.Union("LongList", end)}
+	roundTrip(t, longList, Options{}, native, `{"next": {"LongList": {"next": null}}}`)
+	roundTrip(t, longList, Options{OmitDefaults: true}, native, `{"next": {"LongList": {}}}`)
+	roundTrip(t, longList, Options{OmitDefaults: true, Plain: true}, native, `{"next": {}}`)
+
+	at := time.Unix(1700000000, 123e6).UTC()
+	native = map[string]interface{}{"next": goavro.Union("LongList", map[string]interface{}{"next": goavro.Union("long.timestamp-millis", at)})}
+	roundTrip(t, longList, Options{Plain: true}, native, `{"next": {"next": 1700000000123}}`)
+}
+
+func TestOrder(t *testing.T) {
+	// goavro decodes empty arrays to nil
+	defaults := map[string]interface{}{
+		"id": "o-1", "quantity": int32(1), "weight": 0.5, "status": "OPEN", "note": goavro.Union("string", "none"),
+		"lines": []interface{}(nil), "labels": map[string]interface{}{"a": int64(1)},
+	}
+	roundTrip(t, order, Options{OmitDefaults: true}, defaults, `{"id": "o-1"}`)
+
+	native := map[string]interface{}{
+		"id": "o-2", "quantity": int32(3), "weight": 0.5, "status": "CLOSED", "note": nil,
+		"lines": []interface{}{
+			map[string]interface{}{"sku": "a", "discount": goavro.Union("float", float32(0.25))},
+			map[string]interface{}{"sku": "b", "discount": goavro.Union("string", "promo")},
+			map[string]interface{}{"sku": "c", "discount": nil},
+		},
+		"labels": map[string]interface{}{},
+	}
+	roundTrip(t, order, Options{OmitDefaults: true, Plain: true}, native, `{
+		"id": "o-2", "quantity": 3, "status": "CLOSED", "note": null,
+		"lines": [{"sku": "a", "discount": 0.25}, {"sku": "b", "discount": "promo"}, {"sku": "c"}],
+		"labels": {}
+	}`)
+	roundTrip(t, order, Options{}, native, `{
+		"id": "o-2", "quantity": 3, "weight": 0.5, "status": "CLOSED", "note": null,
+		"lines": [{"sku": "a", "discount": {"float": 0.25}}, {"sku": "b", "discount": {"string": "promo"}}, {"sku": "c", "discount": null}],
+		"labels": {}
+	}`)
+}
+
+func TestDecodeErrors(t *testing.T) {
+	c := newCodec(t, order, Options{Plain: true})
+	_, err := c.NativeFromTextual([]byte(`{"quantity": 2}`))
+	require.EqualError(t, err, "$: missing field id of Order")
+	_, err = c.NativeFromTextual([]byte(`{"id": "o-1", "lines": [{"sku": "a", "discount": true}]}`))
+	require.EqualError(t, err, "$.lines[0].discount: a boolean isn't a value of the union [null, float, string]")
+	_, err = c.NativeFromTextual([]byte(`{"id": "o-1", "count": 2}`))
+	require.EqualError(t, err, "$: unknown field count of Order")
+
+	c = newCodec(t, order, Options{})
+	_, err = c.NativeFromTextual([]byte(`{"id": "o-1", "note": "x"}`))
+	require.EqualError(t, err, `$.note: a string isn't a value of the union [string, null]`)
+}
@@ -2,10 +2,17 @@ package first
 
 import (
 	"context"
+	"io"
 	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"first/blocks"
+	"first/rpctrace"
+
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/stretchr/testify/require"
 )
@@ -17,12 +24,43 @@ func setupTestingWithAnvil(anvil *Anvil) (*ethclient.Client, *Anvil, func()) {
 	}
 }
 
+// setupTesting prints the JSON-RPC transcript if the test fails.
+// Set RPC_TRACE_DIR to also keep a JSONL trace per test that can be replayed with rpctrace.ReplayFile
 func setupTesting(t *testing.T) (*ethclient.Client, *Anvil, func()) {
-	anvil := StartAndConnect()
+	var out io.Writer
+	if traceDir := os.Getenv("RPC_TRACE_DIR"); traceDir != "" {
+		f, err := os.Create(traceFilePath(traceDir, t.Name()))
+		require.NoError(t, err)
+		t.Cleanup(func() { f.Close() })
+		out = f
+	}
+	recorder := rpctrace.NewRecorder(out)
+	recorder.PrintOnFailure(t)
+
+	anvil := StartAndConnectWithRecorder(recorder)
 	return setupTestingWithAnvil(anvil)
 }
 
+// traceFilePath is the trace file of a test in traceDir; subtest names contain slashes
+func traceFilePath(traceDir string, testName string) string {
+	name := strings.NewReplacer("/", "_", "\\", "_").Replace(testName)
+	return filepath.Join(traceDir, name+".jsonl")
+}
+
+func TestTraceFilePathOfSubtests(t *testing.T) {
+	traceDir := t.TempDir()
+	t.Run("sub/case", func(t *testing.T) {
+		path := traceFilePath(traceDir, t.Name())
+		require.Equal(t, traceDir, filepath.Dir(path))
+		require.Equal(t, "TestTraceFilePathOfSubtests_sub_case.jsonl", filepath.Base(path))
+		f, err := os.Create(path)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+	})
+}
+
 func TestAnvilAPIIncreaseTimeAndBulkMineAllNewBlocksHaveSameTimestamp(t *testing.T) {
+	requireCapabilities(t, CapMine, CapIncreaseTime)
 	client, anvil, tearDown := setupTesting(t)
 	defer tearDown()
 
@@ -55,6 +93,7 @@ func TestAnvilAPIIncreaseTimeAndBulkMineAllNewBlocksHaveSameTimestamp(t *testing
 }
 
 func TestAnvilAPICanControlBlockTimeByMiningBlockByBlock(t *testing.T) {
+	requireCapabilities(t, CapMine)
 	client, anvil, tearDown := setupTesting(t)
 	defer tearDown()
 
@@ -84,3 +123,72 @@ func TestAnvilAPICanControlBlockTimeByMiningBlockByBlock(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, int(delayBlocksMiningTime.Seconds()+blockTime.Seconds()), int(secondHeader.Time-firstHeader.Time))
 }
+
+func TestAnvilAPISubscribeNewHeadsOverWebSocket(t *testing.T) {
+	requireCapabilities(t, CapMine, CapWebSocket)
+	_, anvil, tearDown := setupTesting(t)
+	defer tearDown()
+
+	heads, err := CollectNewHeads(context.Background(), anvil.WSEthClient(), 0)
+	require.NoError(t, err)
+	defer heads.Close()
+
+	blocksCount := 3
+	err = anvil.MineBlocks(blocksCount, DefaultBlockTime)
+	require.NoError(t, err)
+
+	headers, err := heads.Expect(blocksCount, 5*time.Second)
+	require.NoError(t, err)
+	for i, header := range headers {
+		require.Equal(t, big.NewInt(int64(i+1)), header.Number)
+	}
+}
+
+func TestAnvilAPIIterateMinedBlocks(t *testing.T) {
+	requireCapabilities(t, CapMine)
+	_, anvil, tearDown := setupTesting(t)
+	defer tearDown()
+
+	blocksCount := 25
+	err := anvil.MineBlocks(blocksCount, DefaultBlockTime)
+	require.NoError(t, err)
+
+	it := blocks.BlockIterator(context.Background(), anvil.Client(), 1, uint64(blocksCount))
+	var prev *blocks.Header
+	for it.Next() {
+		if prev != nil {
+			require.Equal(t, uint64(DefaultBlockTime.Seconds()), it.Header().Time-prev.Time)
+		}
+		prev = it.Header()
+	}
+	require.NoError(t, it.Err())
+	require.Equal(t, uint64(blocksCount), prev.Number.Uint64())
+}
+
+func TestAnvilAPIMinedChainMatchesTimeline(t *testing.T) {
+	requireCapabilities(t, CapMine)
+	scenario := func(blockNo int) (blockInfo *BlockInfo, stop bool) {
+		if blockNo > 20 {
+			return nil, true
+		}
+		if blockNo > 10 {
+			return &BlockInfo{2 * time.Second}, false
+		}
+		return &BlockInfo{standardBlockDuration}, false
+	}
+	client, anvil, tearDown := setupTestingWithAnvil(NewAnvilWithBlocks(scenario))
+	defer tearDown()
+
+	ctx := context.Background()
+	fetcher := blocks.NewFetcher(anvil.Client(), blocks.DefaultCacheSize)
+	timeline := NewMiningTimeline(scenario)
+	require.NoError(t, timeline.Verify(ctx, fetcher))
+
+	// The first block can be later than the model by the time it took to start mining
+	firstHeader, err := client.HeaderByNumber(ctx, big.NewInt(1))
+	require.NoError(t, err)
+	lastStandardBlockTime := time.Unix(int64(firstHeader.Time)+int64((timeline.Offset(10)-timeline.Offset(1)).Seconds()), 0)
+	header, err := fetcher.BlockAtTime(ctx, lastStandardBlockTime)
+	require.NoError(t, err)
+	require.Equal(t, uint64(10), header.Number.Uint64())
+}
@@ -1,6 +1,8 @@
 package first
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math/big"
@@ -9,7 +11,10 @@ import (
 	"strings"
 	"time"
 
+	"first/rpctrace"
+
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/rpc"
 )
@@ -25,12 +30,19 @@ const (
 type Anvil struct {
 	c                 *rpc.Client
 	eth               *ethclient.Client
+	ws                *rpc.Client
+	wsEth             *ethclient.Client
 	initialSnapshotId int
 	cmd               *exec.Cmd
+	profile           *NodeProfile
+	// attached is set by Connect for nodes started outside of the harness
+	attached bool
+	// checkpoints maps block numbers to the snapshots taken before mining on them
+	checkpoints map[uint64]int
 }
 
-func isAnvilWorking() bool {
-	client, err := rpc.Dial(fmt.Sprintf("http://localhost:%d", anvilPort))
+func isNodeWorking(profile *NodeProfile) bool {
+	client, err := rpc.Dial(profile.URL)
 	if err != nil {
 		return false
 	}
@@ -38,34 +50,41 @@ func isAnvilWorking() bool {
 
 	var accounts []string
 	err = client.Call(&accounts, "eth_accounts")
-	return err == nil && len(accounts) == 10
+	return err == nil && len(accounts) == profile.Accounts
 }
 
-func waitForAnvilToStart(client *rpc.Client, timeout time.Duration) (err error) {
+func waitForNodeToStart(profile *NodeProfile, client *rpc.Client) (err error) {
 	start := time.Now()
-	for time.Since(start) < timeout {
-		if isAnvilWorking() {
+	for time.Since(start) < profile.StartTimeout {
+		if isNodeWorking(profile) {
 			err = client.Call(nil, "eth_chainId")
 			if err == nil {
 				return nil
 			}
-			time.Sleep(5 * time.Millisecond)
 		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err == nil {
+		return fmt.Errorf("%s is not responding on %s after %s", profile.Name, profile.URL, profile.StartTimeout)
 	}
-	return fmt.Errorf("anvil did not start in %s; last error: %w", timeout, err)
+	return fmt.Errorf("%s did not start in %s; last error: %w", profile.Name, profile.StartTimeout, err)
 }
 
-func startAnvil() (*exec.Cmd, error) {
-	cmd := exec.Command("anvil", "--port", strconv.Itoa(anvilPort), "--timestamp", "1713900000", "--no-mining", "--silent")
-	err := cmd.Start()
+func startNode(profile *NodeProfile, extraArgs ...string) (*exec.Cmd, error) {
+	args := append(append([]string{}, profile.Args...), extraArgs...)
+	cmd := exec.Command(profile.Command, args...)
+	err := startInProcessGroup(cmd)
 	if err != nil {
 		return nil, err
 	}
 	return cmd, nil
 }
 
-func stopAnvil() error {
-	cmd := exec.Command("killall", "anvil")
+func stopNode(profile *NodeProfile) error {
+	if profile.ProcessName == "" {
+		return fmt.Errorf("%s is already running on %s; stop it first", profile.Name, profile.URL)
+	}
+	cmd := exec.Command("killall", profile.ProcessName)
 	err := cmd.Start()
 	if err != nil {
 		return err
@@ -77,42 +96,143 @@ func stopAnvil() error {
 	return nil
 }
 
+// StartAndConnect starts the node selected with DEV_NODE, see CurrentProfile
 func StartAndConnect() *Anvil {
-	var cmd *exec.Cmd
-	var err error
-	if isAnvilWorking() {
-		stopAnvil()
-	}
+	return startAndConnect(CurrentProfile(), rpc.Dial)
+}
 
-	cmd, err = startAnvil()
-	panicOnError(err)
+// StartAndConnectProfile starts the node described by profile
+func StartAndConnectProfile(profile *NodeProfile) *Anvil {
+	return startAndConnect(profile, rpc.Dial)
+}
 
-	client, err := rpc.Dial(fmt.Sprintf("http://localhost:%d", anvilPort))
-	panicOnError(err)
-	err = waitForAnvilToStart(client, 1*time.Second)
+// StartAndConnectWithRecorder records all the JSON-RPC traffic with the started node
+func StartAndConnectWithRecorder(recorder *rpctrace.Recorder) *Anvil {
+	return startAndConnect(CurrentProfile(), recorder.Dial)
+}
+
+// StartAndConnectWithHardfork starts the node at hardfork, e.g. "cancun" for blob
+// transactions or "prague" for set-code transactions
+func StartAndConnectWithHardfork(hardfork string) *Anvil {
+	profile := CurrentProfile()
+	panicOnError(profile.require(CapHardfork))
+	return startAndConnect(profile, rpc.Dial, profile.HardforkArgs(hardfork)...)
+}
+
+func startAndConnect(profile *NodeProfile, dial func(url string) (*rpc.Client, error), nodeArgs ...string) *Anvil {
+	anvil, err := start(profile, dial, nodeArgs...)
 	panicOnError(err)
-	anvil := &Anvil{
-		c:   client,
-		eth: ethclient.NewClient(client),
-		cmd: cmd,
+	return anvil
+}
+
+// Start is StartAndConnectProfile returning errors instead of panicking
+func Start(profile *NodeProfile, nodeArgs ...string) (*Anvil, error) {
+	return start(profile, rpc.Dial, nodeArgs...)
+}
+
+func start(profile *NodeProfile, dial func(url string) (*rpc.Client, error), nodeArgs ...string) (*Anvil, error) {
+	if isNodeWorking(profile) {
+		err := stopNode(profile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cmd, err := startNode(profile, nodeArgs...)
+	if err != nil {
+		return nil, err
+	}
+	anvil, err := connect(profile, dial, cmd)
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return nil, err
 	}
 
 	time.Sleep(100 * time.Millisecond)
-	anvil.initialSnapshotId, err = anvil.TakeSnapshot()
-	panicOnError(err)
+	if profile.Supports(CapSnapshot) {
+		anvil.initialSnapshotId, err = anvil.TakeSnapshot()
+		if err != nil {
+			anvil.Close()
+			return nil, err
+		}
+	}
+	return anvil, nil
+}
 
-	return anvil
+// Connect attaches to a node already running at profile.URL. Close leaves the node
+// running and its state as is
+func Connect(profile *NodeProfile) (*Anvil, error) {
+	anvil, err := connect(profile, rpc.Dial, nil)
+	if err != nil {
+		return nil, err
+	}
+	anvil.attached = true
+	return anvil, nil
+}
+
+func connect(profile *NodeProfile, dial func(url string) (*rpc.Client, error), cmd *exec.Cmd) (*Anvil, error) {
+	client, err := dial(profile.URL)
+	if err != nil {
+		return nil, err
+	}
+	err = waitForNodeToStart(profile, client)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	if cmd != nil {
+		for _, call := range profile.InitCalls {
+			err = client.Call(nil, call.Method, call.Args...)
+			if err != nil {
+				client.Close()
+				return nil, err
+			}
+		}
+	}
+	anvil := &Anvil{
+		c:       client,
+		eth:     ethclient.NewClient(client),
+		cmd:     cmd,
+		profile: profile,
+	}
+	if profile.Supports(CapWebSocket) {
+		wsClient, err := rpc.DialWebsocket(context.Background(), profile.WSURL, "")
+		if err != nil {
+			client.Close()
+			return nil, err
+		}
+		anvil.ws = wsClient
+		anvil.wsEth = ethclient.NewClient(wsClient)
+	}
+	return anvil, nil
 }
 
 func (g *Anvil) StopAllInstances() {
 	if g.cmd != nil {
-		err := stopAnvil()
+		var err error
+		if g.Profile().ProcessName == "" {
+			err = killProcessGroup(g.cmd)
+			panicOnError(err)
+			// The killed process exits with an error
+			_ = g.cmd.Wait()
+			return
+		}
+		err = stopNode(g.Profile())
 		panicOnError(err)
 		err = g.cmd.Wait()
 		panicOnError(err)
 	}
 }
 
+// Profile is the profile of the node; connections without one are treated as Anvil
+func (g *Anvil) Profile() *NodeProfile {
+	if g.profile == nil {
+		return AnvilProfile
+	}
+	return g.profile
+}
+
 func panicOnError(err error) {
 	if err != nil {
 		panic(err)
@@ -157,24 +277,37 @@ func MineBlocks(anvil *Anvil, getBlockInfo getBlockInfoCallback) error {
 	calls := make([]rpc.BatchElem, 0)
 	for !stop {
 		blockInfo, stop = getBlockInfo(blockNo)
-		if !stop {
-			blockCountInSlice++
-		}
 
 		// If stop then blockInfo is nil
 		if stop || prevBlockTime != blockInfo.blockDuration {
 			if blockCountInSlice > 0 {
-				calls = append(calls, prepareMineCall(blockCountInSlice, prevBlockTime))
-				if !stop {
-					prevBlockTime = blockInfo.blockDuration
-					blockCountInSlice = 0
-				}
+				calls = append(calls, prepareMineCall(anvil.Profile(), blockCountInSlice, prevBlockTime))
+			}
+			if !stop {
+				prevBlockTime = blockInfo.blockDuration
+				blockCountInSlice = 0
 			}
 		}
+
+		if !stop {
+			blockCountInSlice++
+		}
 		blockNo++
 	}
 
-	err := anvil.c.BatchCall(calls)
+	if len(calls) == 0 {
+		return nil
+	}
+	err := anvil.Profile().require(CapMine)
+	if err != nil {
+		return err
+	}
+	err = anvil.checkpoint(context.Background())
+	if err != nil {
+		return err
+	}
+
+	err = anvil.c.BatchCall(calls)
 	if err != nil {
 		return err
 	}
@@ -188,12 +321,36 @@ func MineBlocks(anvil *Anvil, getBlockInfo getBlockInfoCallback) error {
 }
 
 func (g *Anvil) Close() {
-	err := g.RevertSnapshot(g.initialSnapshotId)
-	panicOnError(err)
+	if !g.attached && g.Profile().Supports(CapSnapshot) {
+		err := g.RevertSnapshot(g.initialSnapshotId)
+		panicOnError(err)
+	}
+	if g.ws != nil {
+		g.ws.Close()
+	}
 	g.StopAllInstances()
 	g.c.Close()
 }
 
+// Stop closes the connections and stops the node started by Start without reverting its
+// state, which unlike Close works when the node already exited
+func (g *Anvil) Stop() error {
+	if g.ws != nil {
+		g.ws.Close()
+	}
+	g.c.Close()
+	if g.cmd == nil {
+		return nil
+	}
+	err := killProcessGroup(g.cmd)
+	if err != nil {
+		return err
+	}
+	// The killed process exits with an error
+	_ = g.cmd.Wait()
+	return nil
+}
+
 func (g *Anvil) Client() *rpc.Client {
 	return g.c
 }
@@ -202,6 +359,16 @@ func (g *Anvil) EthClient() *ethclient.Client {
 	return g.eth
 }
 
+// WSClient is connected over WebSocket and supports subscriptions
+func (g *Anvil) WSClient() *rpc.Client {
+	return g.ws
+}
+
+// WSEthClient is connected over WebSocket and supports SubscribeNewHead and SubscribeFilterLogs
+func (g *Anvil) WSEthClient() *ethclient.Client {
+	return g.wsEth
+}
+
 func prepareCall(method string, args []any, result interface{}) rpc.BatchElem {
 	return rpc.BatchElem{
 		Method: method,
@@ -211,6 +378,10 @@ func prepareCall(method string, args []any, result interface{}) rpc.BatchElem {
 }
 
 func (g *Anvil) TakeSnapshot() (snapshotId int, err error) {
+	err = g.Profile().require(CapSnapshot)
+	if err != nil {
+		return 0, err
+	}
 	call := prepareCall("evm_snapshot", []any{}, new(string))
 	response := new(interface{})
 	err = g.c.Call(response, call.Method, call.Args...)
@@ -225,15 +396,24 @@ func (g *Anvil) TakeSnapshot() (snapshotId int, err error) {
 }
 
 func (g *Anvil) RevertSnapshot(snapshotId int) error {
-	call := prepareCall("evm_revert", []any{snapshotId}, new(bool))
-	response := new(interface{})
-	err := g.c.Call(response, call.Method, call.Args...)
+	err := g.Profile().require(CapSnapshot)
 	if err != nil {
 		return err
 	}
-	if !(*response).(bool) {
+	var reverted bool
+	err = g.c.Call(&reverted, "evm_revert", g.Profile().RevertArgs(snapshotId)...)
+	if err != nil {
+		return err
+	}
+	if !reverted {
 		return errors.New("failed to revert snapshot")
 	}
+	// Reverting discards the snapshot and all the ones taken after it
+	for blockNumber, id := range g.checkpoints {
+		if id >= snapshotId {
+			delete(g.checkpoints, blockNumber)
+		}
+	}
 	return nil
 }
 
@@ -255,28 +435,44 @@ func (g *Anvil) RevertSnapshot(snapshotId int) error {
 // }
 
 func prepareIncreaseTimeCall(duration time.Duration) rpc.BatchElem {
-	return prepareCall("evm_increaseTime", []any{"0x" + strconv.FormatInt(int64(duration.Seconds()), 16)}, new(float64))
+	return prepareCall("evm_increaseTime", []any{"0x" + strconv.FormatInt(int64(duration.Seconds()), 16)}, new(json.RawMessage))
 }
 
 // Increase the blockchain current timestamp by the specified amount of time in seconds
 func (g *Anvil) IncreaseTime(duration time.Duration) (adjustedTime time.Duration, err error) {
+	err = g.Profile().require(CapIncreaseTime)
+	if err != nil {
+		return 0, err
+	}
 	call := prepareIncreaseTimeCall(duration)
-	response := new(interface{})
-	err = g.c.Call(response, call.Method, call.Args...)
+	var response json.RawMessage
+	err = g.c.Call(&response, call.Method, call.Args...)
 	if err != nil {
 		return 0, err
 	}
-	return time.Duration(int64((*response).(float64))) * time.Second, nil
+	offset, err := g.Profile().ParseTimeOffset(response)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected evm_increaseTime result %s: %w", response, err)
+	}
+	return time.Duration(offset) * time.Second, nil
 }
 
-func prepareMineCall(blockCount int, blockLength time.Duration) rpc.BatchElem {
-	return prepareCall("anvil_mine", []any{big.NewInt(int64(blockCount)), big.NewInt(int64(blockLength.Seconds()))}, new(string))
+func prepareMineCall(profile *NodeProfile, blockCount int, blockLength time.Duration) rpc.BatchElem {
+	return prepareCall(profile.MineMethod, profile.MineArgs(blockCount, blockLength), new(string))
 }
 
 func (g *Anvil) MineBlocks(blockCount int, blockTime time.Duration) error {
-	call := prepareMineCall(blockCount, blockTime)
+	err := g.Profile().require(CapMine)
+	if err != nil {
+		return err
+	}
+	err = g.checkpoint(context.Background())
+	if err != nil {
+		return err
+	}
+	call := prepareMineCall(g.Profile(), blockCount, blockTime)
 	response := new(interface{})
-	err := g.c.Call(response, call.Method, call.Args...)
+	err = g.c.Call(response, call.Method, call.Args...)
 	if err != nil {
 		return err
 	}
@@ -286,6 +482,15 @@ func (g *Anvil) MineBlocks(blockCount int, blockTime time.Duration) error {
 	return nil
 }
 
+// SetBalance overwrites the balance of address without a transaction
+func (g *Anvil) SetBalance(ctx context.Context, address common.Address, amount *big.Int) error {
+	err := g.Profile().require(CapSetBalance)
+	if err != nil {
+		return err
+	}
+	return g.c.CallContext(ctx, nil, g.Profile().SetBalanceMethod, address, (*hexutil.Big)(amount))
+}
+
 func (g *Anvil) AvailableAddresses() ([]common.Address, error) {
 	call := prepareCall("eth_accounts", []any{}, new(string))
 	response := new(interface{})
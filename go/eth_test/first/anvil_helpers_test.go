@@ -0,0 +1,202 @@
+package first
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"first/rpcmock"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTestingWithMock(t *testing.T) (*rpcmock.Server, *Anvil, func()) {
+	server := rpcmock.New()
+	err := server.LoadFixtures("testdata/anvil_fixtures.json")
+	require.NoError(t, err)
+
+	client, err := server.Client()
+	require.NoError(t, err)
+
+	anvil := &Anvil{
+		c:   client,
+		eth: ethclient.NewClient(client),
+	}
+	return server, anvil, func() {
+		anvil.Close()
+		server.Close()
+	}
+}
+
+func respondWithFixture(t *testing.T, server *rpcmock.Server, method string, path string) {
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	server.Respond(method, json.RawMessage(content))
+}
+
+func blocksWithDurations(durations ...time.Duration) getBlockInfoCallback {
+	return func(blockNo int) (blockInfo *BlockInfo, stop bool) {
+		if blockNo > len(durations) {
+			return nil, true
+		}
+		return &BlockInfo{durations[blockNo-1]}, false
+	}
+}
+
+func TestMineBlocksSameDurationIsASingleCall(t *testing.T) {
+	server, anvil, tearDown := setupTestingWithMock(t)
+	defer tearDown()
+
+	err := MineBlocks(anvil, func(blockNo int) (blockInfo *BlockInfo, stop bool) {
+		if blockNo > 10 {
+			return nil, true
+		}
+		return &BlockInfo{standardBlockDuration}, false
+	})
+	require.NoError(t, err)
+
+	calls := server.CallsTo("anvil_mine")
+	require.Equal(t, 1, len(calls))
+	server.ExpectCall(t, "anvil_mine", 10, 12)
+}
+
+func TestMineBlocksSplitsCallsWhenDurationChanges(t *testing.T) {
+	server, anvil, tearDown := setupTestingWithMock(t)
+	defer tearDown()
+
+	shortBlock := 2 * time.Second
+	err := MineBlocks(anvil, blocksWithDurations(standardBlockDuration, standardBlockDuration, standardBlockDuration, shortBlock, shortBlock, standardBlockDuration))
+	require.NoError(t, err)
+
+	calls := server.CallsTo("anvil_mine")
+	require.Equal(t, 3, len(calls))
+	for _, call := range calls {
+		require.Equal(t, calls[0].Batch, call.Batch, "all mine calls are sent in the same batch")
+		require.NotEqual(t, -1, call.Batch)
+	}
+	server.ExpectCall(t, "anvil_mine", 3, 12)
+	server.ExpectCall(t, "anvil_mine", 2, 2)
+	server.ExpectCall(t, "anvil_mine", 1, 12)
+}
+
+func TestMineBlocksFirstBlockWithCustomDuration(t *testing.T) {
+	server, anvil, tearDown := setupTestingWithMock(t)
+	defer tearDown()
+
+	err := MineBlocks(anvil, blocksWithDurations(time.Second, time.Second))
+	require.NoError(t, err)
+
+	require.Equal(t, 1, len(server.CallsTo("anvil_mine")))
+	server.ExpectCall(t, "anvil_mine", 2, 1)
+}
+
+func TestMineBlocksNoBlocksDoesNotCallNode(t *testing.T) {
+	server, anvil, tearDown := setupTestingWithMock(t)
+	defer tearDown()
+
+	err := MineBlocks(anvil, blocksWithDurations())
+	require.NoError(t, err)
+	server.ExpectNoCall(t, "anvil_mine")
+}
+
+func TestMineBlocksReturnsCallError(t *testing.T) {
+	server, anvil, tearDown := setupTestingWithMock(t)
+	defer tearDown()
+
+	server.Handle("anvil_mine", func(params []json.RawMessage) (interface{}, error) {
+		return nil, errors.New("mining failed")
+	})
+
+	err := MineBlocks(anvil, blocksWithDurations(standardBlockDuration))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "mining failed")
+}
+
+func TestAvailableAddressesWithoutNode(t *testing.T) {
+	server, anvil, tearDown := setupTestingWithMock(t)
+	defer tearDown()
+
+	addresses, err := anvil.AvailableAddresses()
+	require.NoError(t, err)
+	require.Equal(t, 10, len(addresses))
+	require.Equal(t, common.HexToAddress("0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266"), addresses[0])
+	server.ExpectCall(t, "eth_accounts")
+}
+
+func TestStopDoesntRevertAndToleratesExitedNode(t *testing.T) {
+	server := rpcmock.New()
+	defer server.Close()
+	client, err := server.Client()
+	require.NoError(t, err)
+
+	cmd := exec.Command("sleep", "10")
+	require.NoError(t, cmd.Start())
+	// The node exited already, e.g. it crashed
+	require.NoError(t, cmd.Process.Kill())
+	anvil := &Anvil{c: client, eth: ethclient.NewClient(client), cmd: cmd}
+
+	require.NoError(t, anvil.Stop())
+	require.Empty(t, server.Calls())
+}
+
+type fakeNodeService struct{}
+
+func (fakeNodeService) Accounts() []common.Address {
+	return []common.Address{common.HexToAddress("0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266")}
+}
+
+func (fakeNodeService) ChainId() hexutil.Uint64 {
+	return 31337
+}
+
+// TestFakeNodeProcess is the node started by TestStartStopsNodesSpawnedByTheCommand. With
+// FAKE_NODE_WRAPPER it runs the node in a child process and waits, like `npx hardhat node`
+func TestFakeNodeProcess(t *testing.T) {
+	addr := os.Getenv("FAKE_NODE_ADDR")
+	if addr == "" {
+		t.Skip("started by TestStartStopsNodesSpawnedByTheCommand")
+	}
+	if os.Getenv("FAKE_NODE_WRAPPER") != "" {
+		node := exec.Command(os.Args[0], "-test.run=^TestFakeNodeProcess$")
+		node.Env = append(os.Environ(), "FAKE_NODE_WRAPPER=")
+		_ = node.Run()
+		os.Exit(0)
+	}
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("eth", fakeNodeService{}))
+	require.NoError(t, http.ListenAndServe(addr, server))
+}
+
+func TestStartStopsNodesSpawnedByTheCommand(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	require.NoError(t, listener.Close())
+	t.Setenv("FAKE_NODE_ADDR", addr)
+	t.Setenv("FAKE_NODE_WRAPPER", "1")
+
+	// Without ProcessName, a node left running can't be stopped by the next start
+	profile := &NodeProfile{
+		Name:         "fake",
+		Command:      os.Args[0],
+		Args:         []string{"-test.run=^TestFakeNodeProcess$"},
+		URL:          "http://" + addr,
+		StartTimeout: 5 * time.Second,
+		Accounts:     1,
+	}
+	for i := 0; i < 2; i++ {
+		anvil, err := Start(profile)
+		require.NoError(t, err, "start %d", i+1)
+		anvil.Close()
+		require.False(t, isNodeWorking(profile), "the node is still running after stop %d", i+1)
+	}
+}
@@ -0,0 +1,159 @@
+package first
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto/kzg4844"
+	"github.com/holiman/uint256"
+)
+
+const (
+	// blobFieldElements is the number of 32 bytes field elements of a blob
+	blobFieldElements = len(kzg4844.Blob{}) / 32
+	// blobBytesPerElement skips the first byte of each field element, which must be smaller than the BLS modulus
+	blobBytesPerElement = 31
+	blobLengthPrefix    = 4
+	// MaxBlobData is the largest payload EncodeBlob accepts
+	MaxBlobData = blobFieldElements*blobBytesPerElement - blobLengthPrefix
+)
+
+var ErrBlobDataTooLarge = fmt.Errorf("blob data exceeds %d bytes", MaxBlobData)
+
+// EncodeBlob packs data in a blob, 31 bytes per field element, prefixed with its length
+func EncodeBlob(data []byte) (*kzg4844.Blob, error) {
+	if len(data) > MaxBlobData {
+		return nil, ErrBlobDataTooLarge
+	}
+	payload := make([]byte, blobLengthPrefix+len(data))
+	binary.BigEndian.PutUint32(payload, uint32(len(data)))
+	copy(payload[blobLengthPrefix:], data)
+
+	blob := new(kzg4844.Blob)
+	for element := 0; len(payload) > 0; element++ {
+		n := copy(blob[element*32+1:element*32+32], payload)
+		payload = payload[n:]
+	}
+	return blob, nil
+}
+
+// DecodeBlob returns the data packed by EncodeBlob
+func DecodeBlob(blob *kzg4844.Blob) ([]byte, error) {
+	payload := make([]byte, 0, blobFieldElements*blobBytesPerElement)
+	for element := 0; element < blobFieldElements; element++ {
+		payload = append(payload, blob[element*32+1:element*32+32]...)
+	}
+	length := binary.BigEndian.Uint32(payload)
+	if length > uint32(MaxBlobData) {
+		return nil, errors.New("invalid blob length prefix")
+	}
+	return payload[blobLengthPrefix : blobLengthPrefix+length], nil
+}
+
+// NewBlobSidecar encodes each data in a blob and computes the KZG commitments and proofs locally
+func NewBlobSidecar(data ...[]byte) (*types.BlobTxSidecar, error) {
+	sidecar := &types.BlobTxSidecar{}
+	for _, item := range data {
+		blob, err := EncodeBlob(item)
+		if err != nil {
+			return nil, err
+		}
+		commitment, err := kzg4844.BlobToCommitment(blob)
+		if err != nil {
+			return nil, err
+		}
+		proof, err := kzg4844.ComputeBlobProof(blob, commitment)
+		if err != nil {
+			return nil, err
+		}
+		sidecar.Blobs = append(sidecar.Blobs, *blob)
+		sidecar.Commitments = append(sidecar.Commitments, commitment)
+		sidecar.Proofs = append(sidecar.Proofs, proof)
+	}
+	return sidecar, nil
+}
+
+// VerifyBlobSidecar checks the proofs of sidecar and that its commitments match blobHashes
+func VerifyBlobSidecar(sidecar *types.BlobTxSidecar, blobHashes []common.Hash) error {
+	if len(sidecar.Blobs) != len(sidecar.Proofs) {
+		return fmt.Errorf("%d blobs for %d proofs", len(sidecar.Blobs), len(sidecar.Proofs))
+	}
+	err := sidecar.ValidateBlobCommitmentHashes(blobHashes)
+	if err != nil {
+		return err
+	}
+	for i := range sidecar.Blobs {
+		err = kzg4844.VerifyBlobProof(&sidecar.Blobs[i], sidecar.Commitments[i], sidecar.Proofs[i])
+		if err != nil {
+			return fmt.Errorf("blob %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// SendBlobs signs and broadcasts a blob transaction carrying sidecar. The blob fee cap is
+// twice the current blob base fee. The node has to run Cancun or later
+func (s *TxSender) SendBlobs(ctx context.Context, to common.Address, sidecar *types.BlobTxSidecar, data []byte) (*types.Transaction, error) {
+	blobBaseFee, err := s.client.BlobBaseFee(ctx)
+	if err != nil {
+		return nil, err
+	}
+	blobFeeCap := new(big.Int).Mul(blobBaseFee, big.NewInt(2))
+	if blobFeeCap.Sign() == 0 {
+		blobFeeCap.SetUint64(1)
+	}
+	blobHashes := sidecar.BlobHashes()
+	fees, err := s.prepare(ctx, ethereum.CallMsg{
+		To:            &to,
+		Data:          data,
+		BlobGasFeeCap: blobFeeCap,
+		BlobHashes:    blobHashes,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tx := types.NewTx(&types.BlobTx{
+		ChainID:    uint256.MustFromBig(s.chainID),
+		Nonce:      fees.nonce,
+		GasTipCap:  uint256.MustFromBig(fees.tipCap),
+		GasFeeCap:  uint256.MustFromBig(fees.feeCap),
+		Gas:        fees.gasLimit,
+		To:         to,
+		Value:      new(uint256.Int),
+		Data:       data,
+		BlobFeeCap: uint256.MustFromBig(blobFeeCap),
+		BlobHashes: blobHashes,
+		Sidecar:    sidecar,
+	})
+	return s.SendTx(ctx, tx)
+}
+
+// DecodeTransaction decodes a raw transaction of any type. Blob transactions in the network
+// encoding keep their sidecar, see Transaction.BlobTxSidecar
+func DecodeTransaction(raw []byte) (*types.Transaction, error) {
+	tx := new(types.Transaction)
+	err := tx.UnmarshalBinary(raw)
+	if err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// TransactionFee is the cost of a mined transaction: execution gas plus blob gas
+func TransactionFee(receipt *types.Receipt) *big.Int {
+	fee := new(big.Int).SetUint64(receipt.GasUsed)
+	if receipt.EffectiveGasPrice != nil {
+		fee.Mul(fee, receipt.EffectiveGasPrice)
+	}
+	if receipt.BlobGasPrice != nil {
+		fee.Add(fee, new(big.Int).Mul(new(big.Int).SetUint64(receipt.BlobGasUsed), receipt.BlobGasPrice))
+	}
+	return fee
+}
@@ -0,0 +1,209 @@
+package first
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"first/rpcmock"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto/kzg4844"
+	"github.com/stretchr/testify/require"
+)
+
+const testPrivateKey = "0xac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80"
+
+func TestBlobEncodeDecodeRoundTrip(t *testing.T) {
+	for _, data := range [][]byte{nil, []byte("hello blobs"), bytes.Repeat([]byte{0xff}, MaxBlobData)} {
+		blob, err := EncodeBlob(data)
+		require.NoError(t, err)
+		for element := 0; element < blobFieldElements; element++ {
+			require.Zero(t, blob[element*32], "field element %d must be below the modulus", element)
+		}
+		decoded, err := DecodeBlob(blob)
+		require.NoError(t, err)
+		require.Equal(t, len(data), len(decoded))
+		require.True(t, bytes.Equal(data, decoded))
+	}
+
+	_, err := EncodeBlob(make([]byte, MaxBlobData+1))
+	require.ErrorIs(t, err, ErrBlobDataTooLarge)
+}
+
+func TestNewBlobSidecarComputesCommitmentsAndProofs(t *testing.T) {
+	sidecar, err := NewBlobSidecar([]byte("first"), []byte("second"))
+	require.NoError(t, err)
+	require.Equal(t, 2, len(sidecar.Blobs))
+
+	hashes := sidecar.BlobHashes()
+	for _, hash := range hashes {
+		require.True(t, kzg4844.IsValidVersionedHash(hash[:]))
+	}
+	require.NoError(t, VerifyBlobSidecar(sidecar, hashes))
+
+	// The commitment of another blob doesn't match
+	require.Error(t, VerifyBlobSidecar(sidecar, []common.Hash{hashes[1], hashes[0]}))
+	sidecar.Proofs[0], sidecar.Proofs[1] = sidecar.Proofs[1], sidecar.Proofs[0]
+	require.Error(t, VerifyBlobSidecar(sidecar, hashes))
+}
+
+// captureRawTransactions serves what TxSender needs and decodes the sent transactions
+func captureRawTransactions(t *testing.T, server *rpcmock.Server) *[]*types.Transaction {
+	sent := &[]*types.Transaction{}
+	server.Respond("eth_getTransactionCount", "0x5")
+	server.Respond("eth_maxPriorityFeePerGas", "0x1")
+	server.Respond("eth_gasPrice", "0x3b9aca00")
+	server.Respond("eth_estimateGas", "0x5208")
+	server.Respond("eth_blobBaseFee", "0x1")
+	server.Handle("eth_sendRawTransaction", func(params []json.RawMessage) (interface{}, error) {
+		var raw hexutil.Bytes
+		require.NoError(t, json.Unmarshal(params[0], &raw))
+		tx, err := DecodeTransaction(raw)
+		require.NoError(t, err)
+		*sent = append(*sent, tx)
+		return tx.Hash(), nil
+	})
+	return sent
+}
+
+func TestSendBlobsWithoutNode(t *testing.T) {
+	server, anvil, tearDown := setupTestingWithMock(t)
+	defer tearDown()
+	sent := captureRawTransactions(t, server)
+
+	sender, err := NewTxSender(anvil.EthClient(), testPrivateKey, t.Name())
+	require.NoError(t, err)
+	sidecar, err := NewBlobSidecar([]byte("rollup batch"))
+	require.NoError(t, err)
+	to := common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8")
+
+	tx, err := sender.SendBlobs(context.Background(), to, sidecar, nil)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(*sent))
+
+	decoded := (*sent)[0]
+	require.Equal(t, tx.Hash(), decoded.Hash())
+	require.Equal(t, uint8(types.BlobTxType), decoded.Type())
+	require.Equal(t, uint64(5), decoded.Nonce())
+	require.Equal(t, big.NewInt(2), decoded.BlobGasFeeCap())
+	require.Equal(t, sidecar.BlobHashes(), decoded.BlobHashes())
+	from, err := types.Sender(types.LatestSignerForChainID(sender.ChainID()), decoded)
+	require.NoError(t, err)
+	require.Equal(t, sender.From(), from)
+
+	// The sidecar is sent in the network encoding
+	require.NotNil(t, decoded.BlobTxSidecar())
+	require.NoError(t, VerifyBlobSidecar(decoded.BlobTxSidecar(), decoded.BlobHashes()))
+	data, err := DecodeBlob(&decoded.BlobTxSidecar().Blobs[0])
+	require.NoError(t, err)
+	require.Equal(t, "rollup batch", string(data))
+
+	var estimate map[string]interface{}
+	require.NoError(t, json.Unmarshal(server.CallsTo("eth_estimateGas")[0].Params[0], &estimate))
+	require.Contains(t, estimate, "blobVersionedHashes")
+}
+
+func TestSendSetCodeWithoutNode(t *testing.T) {
+	server, anvil, tearDown := setupTestingWithMock(t)
+	defer tearDown()
+	sent := captureRawTransactions(t, server)
+
+	sender, err := NewTxSender(anvil.EthClient(), testPrivateKey, t.Name())
+	require.NoError(t, err)
+	delegate := common.HexToAddress("0x5FbDB2315678afecb367f032d93F642f64180aa3")
+
+	_, err = sender.DelegateSelf(context.Background(), delegate)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(*sent))
+
+	decoded := (*sent)[0]
+	require.Equal(t, uint8(types.SetCodeTxType), decoded.Type())
+	require.Equal(t, sender.From(), *decoded.To())
+	authorizations := decoded.SetCodeAuthorizations()
+	require.Equal(t, 1, len(authorizations))
+	require.Equal(t, delegate, authorizations[0].Address)
+	// The sender's nonce is incremented by the transaction before the authorization is applied
+	require.Equal(t, uint64(6), authorizations[0].Nonce)
+	authority, err := authorizations[0].Authority()
+	require.NoError(t, err)
+	require.Equal(t, sender.From(), authority)
+}
+
+func TestDelegationOfWithoutNode(t *testing.T) {
+	server, anvil, tearDown := setupTestingWithMock(t)
+	defer tearDown()
+	delegate := common.HexToAddress("0x5FbDB2315678afecb367f032d93F642f64180aa3")
+	server.Respond("eth_getCode", hexutil.Bytes(types.AddressToDelegation(delegate)))
+
+	address, ok, err := DelegationOf(context.Background(), anvil.EthClient(), common.Address{1}, nil)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, delegate, address)
+
+	server.Respond("eth_getCode", "0x6080")
+	_, ok, err = DelegationOf(context.Background(), anvil.EthClient(), common.Address{1}, nil)
+	require.NoError(t, err)
+	require.False(t, ok)
+}
+
+func TestTransactionFeeIncludesBlobGas(t *testing.T) {
+	receipt := &types.Receipt{GasUsed: 21000, EffectiveGasPrice: big.NewInt(10), BlobGasUsed: 131072, BlobGasPrice: big.NewInt(2)}
+	require.Equal(t, big.NewInt(21000*10+131072*2), TransactionFee(receipt))
+	receipt.BlobGasPrice = nil
+	require.Equal(t, big.NewInt(21000*10), TransactionFee(receipt))
+}
+
+func TestTransactionSendBlobs(t *testing.T) {
+	requireCapabilities(t, CapHardfork, CapMine, CapStandardAccounts)
+	anvil := StartAndConnectWithHardfork("cancun")
+	defer anvil.Close()
+	ctx := context.Background()
+
+	sender, err := NewTxSender(anvil.EthClient(), testPrivateKey, t.Name())
+	require.NoError(t, err)
+	sidecar, err := NewBlobSidecar([]byte("rollup batch"))
+	require.NoError(t, err)
+	tx, err := sender.SendBlobs(ctx, common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8"), sidecar, nil)
+	require.NoError(t, err)
+	require.NoError(t, anvil.MineBlocks(1, DefaultBlockTime))
+
+	receipt, err := sender.WaitForReceipt(ctx, tx)
+	require.NoError(t, err)
+	require.Equal(t, types.ReceiptStatusSuccessful, receipt.Status)
+	require.Equal(t, tx.BlobGas(), receipt.BlobGasUsed)
+	require.NotNil(t, receipt.BlobGasPrice)
+
+	header, err := anvil.EthClient().HeaderByNumber(ctx, receipt.BlockNumber)
+	require.NoError(t, err)
+	require.NotNil(t, header.BlobGasUsed)
+	require.Equal(t, tx.BlobGas(), *header.BlobGasUsed)
+	require.NotNil(t, header.ExcessBlobGas)
+}
+
+func TestTransactionDelegateSelf(t *testing.T) {
+	requireCapabilities(t, CapHardfork, CapMine, CapStandardAccounts)
+	anvil := StartAndConnectWithHardfork("prague")
+	defer anvil.Close()
+	ctx := context.Background()
+
+	sender, err := NewTxSender(anvil.EthClient(), testPrivateKey, t.Name())
+	require.NoError(t, err)
+	delegate := common.HexToAddress("0x5FbDB2315678afecb367f032d93F642f64180aa3")
+	tx, err := sender.DelegateSelf(ctx, delegate)
+	require.NoError(t, err)
+	require.NoError(t, anvil.MineBlocks(1, DefaultBlockTime))
+
+	receipt, err := sender.WaitForReceipt(ctx, tx)
+	require.NoError(t, err)
+	require.Equal(t, types.ReceiptStatusSuccessful, receipt.Status)
+
+	address, ok, err := DelegationOf(ctx, anvil.EthClient(), sender.From(), nil)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, delegate, address)
+}
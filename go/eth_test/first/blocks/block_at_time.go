@@ -0,0 +1,65 @@
+package blocks
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrBeforeGenesis is returned for times before the genesis block
+var ErrBeforeGenesis = errors.New("time is before the genesis block")
+
+// BlockAtTime returns the block current at t, i.e. the last block with timestamp <= t.
+// It interpolates the position of t between the bounds of the search range and falls
+// back to bisection when a guess doesn't shrink the range enough, which happens with
+// irregular block times
+func (f *Fetcher) BlockAtTime(ctx context.Context, t time.Time) (*Header, error) {
+	target := uint64(t.Unix())
+	latest, err := f.LatestBlockNumber(ctx)
+	if err != nil {
+		return nil, err
+	}
+	low, err := f.HeaderByNumber(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+	if target < low.Time {
+		return nil, ErrBeforeGenesis
+	}
+	high, err := f.HeaderByNumber(ctx, latest)
+	if err != nil {
+		return nil, err
+	}
+	if target >= high.Time {
+		return high, nil
+	}
+
+	// Invariant: low.Time <= target < high.Time
+	bisect := false
+	for high.Number.Uint64()-low.Number.Uint64() > 1 {
+		lowNo, highNo := low.Number.Uint64(), high.Number.Uint64()
+		size := highNo - lowNo
+		guess := lowNo + size/2
+		if !bisect {
+			guess = lowNo + (target-low.Time)*size/(high.Time-low.Time)
+			if guess <= lowNo {
+				guess = lowNo + 1
+			} else if guess >= highNo {
+				guess = highNo - 1
+			}
+		}
+
+		header, err := f.HeaderByNumber(ctx, guess)
+		if err != nil {
+			return nil, err
+		}
+		if header.Time <= target {
+			low = header
+		} else {
+			high = header
+		}
+		// Bisect next if interpolating didn't at least halve the range
+		bisect = !bisect && high.Number.Uint64()-low.Number.Uint64() > size/2
+	}
+	return low, nil
+}
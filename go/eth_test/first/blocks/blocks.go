@@ -0,0 +1,326 @@
+package blocks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	DefaultBatchSize   = 100
+	DefaultParallelism = 4
+	DefaultCacheSize   = 10000
+)
+
+// ErrNoBlockAfter is returned when all the blocks are older than the searched time
+var ErrNoBlockAfter = errors.New("no block at or after the given time")
+
+// GapError reports blocks missing from the node in the requested range
+type GapError struct {
+	From uint64
+	To   uint64
+}
+
+func (e *GapError) Error() string {
+	if e.From == e.To {
+		return fmt.Sprintf("block %d is missing", e.From)
+	}
+	return fmt.Sprintf("blocks %d to %d are missing", e.From, e.To)
+}
+
+// ChainError reports a header not linked to its predecessor, usually because of a reorg while fetching
+type ChainError struct {
+	Number uint64
+}
+
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("parent hash of block %d doesn't match the hash of block %d", e.Number, e.Number-1)
+}
+
+// Header is a block header with the hash reported by the node. It can differ
+// from Header.Hash() when the node has header fields newer than go-ethereum
+type Header struct {
+	*types.Header
+	BlockHash common.Hash
+}
+
+func (h *Header) UnmarshalJSON(input []byte) error {
+	var header types.Header
+	err := json.Unmarshal(input, &header)
+	if err != nil {
+		return err
+	}
+	var hash struct {
+		Hash common.Hash `json:"hash"`
+	}
+	err = json.Unmarshal(input, &hash)
+	if err != nil {
+		return err
+	}
+	h.Header = &header
+	h.BlockHash = hash.Hash
+	return nil
+}
+
+// Fetcher retrieves headers in concurrent JSON-RPC batches and keeps them in a LRU cache
+type Fetcher struct {
+	// BatchSize is the number of headers requested in a single JSON-RPC batch
+	BatchSize int
+	// Parallelism bounds the number of batches in flight
+	Parallelism int
+
+	client *rpc.Client
+	cache  *HeaderCache
+}
+
+// NewFetcher creates a fetcher with the default settings and a cache of cacheSize headers; 0 disables caching
+func NewFetcher(client *rpc.Client, cacheSize int) *Fetcher {
+	return &Fetcher{
+		BatchSize:   DefaultBatchSize,
+		Parallelism: DefaultParallelism,
+		client:      client,
+		cache:       NewHeaderCache(cacheSize),
+	}
+}
+
+func (f *Fetcher) Cache() *HeaderCache {
+	return f.cache
+}
+
+func (f *Fetcher) LatestBlockNumber(ctx context.Context) (uint64, error) {
+	var number hexutil.Uint64
+	err := f.client.CallContext(ctx, &number, "eth_blockNumber")
+	return uint64(number), err
+}
+
+func (f *Fetcher) HeaderByNumber(ctx context.Context, number uint64) (*Header, error) {
+	headers, err := f.Headers(ctx, number, number)
+	if err != nil {
+		return nil, err
+	}
+	return headers[0], nil
+}
+
+// Headers returns the headers of blocks [from, to] in order, checking that they form a chain
+func (f *Fetcher) Headers(ctx context.Context, from uint64, to uint64) ([]*Header, error) {
+	if to < from {
+		return nil, fmt.Errorf("invalid range [%d, %d]", from, to)
+	}
+	headers := make([]*Header, to-from+1)
+	missing := make([]uint64, 0)
+	for i := range headers {
+		if header, found := f.cache.Get(from + uint64(i)); found {
+			headers[i] = header
+		} else {
+			missing = append(missing, from+uint64(i))
+		}
+	}
+
+	batchSize := f.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	parallelism := f.Parallelism
+	if parallelism <= 0 {
+		parallelism = DefaultParallelism
+	}
+
+	var (
+		wg       sync.WaitGroup
+		errMu    sync.Mutex
+		firstErr error
+		slots    = make(chan struct{}, parallelism)
+	)
+	for start := 0; start < len(missing); start += batchSize {
+		end := start + batchSize
+		if end > len(missing) {
+			end = len(missing)
+		}
+		numbers := missing[start:end]
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			fetched, err := f.fetchBatch(ctx, numbers)
+			if err == nil {
+				for i, header := range fetched {
+					headers[numbers[i]-from] = header
+				}
+				return
+			}
+			errMu.Lock()
+			if firstErr == nil {
+				firstErr = err
+			}
+			errMu.Unlock()
+		}()
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	if err := checkRange(headers, from); err != nil {
+		return nil, err
+	}
+	for _, header := range headers {
+		f.cache.Add(header)
+	}
+	return headers, nil
+}
+
+func (f *Fetcher) fetchBatch(ctx context.Context, numbers []uint64) ([]*Header, error) {
+	headers := make([]*Header, len(numbers))
+	calls := make([]rpc.BatchElem, 0, len(numbers))
+	for i, number := range numbers {
+		calls = append(calls, rpc.BatchElem{
+			Method: "eth_getBlockByNumber",
+			Args:   []any{hexutil.EncodeBig(new(big.Int).SetUint64(number)), false},
+			Result: &headers[i],
+		})
+	}
+	err := f.client.BatchCallContext(ctx, calls)
+	if err != nil {
+		return nil, err
+	}
+	for i, call := range calls {
+		if call.Error != nil {
+			return nil, fmt.Errorf("error fetching block %d: %w", numbers[i], call.Error)
+		}
+	}
+	return headers, nil
+}
+
+// checkRange reports the first gap and verifies parent hashes of consecutive headers
+func checkRange(headers []*Header, from uint64) error {
+	for i, header := range headers {
+		if header != nil {
+			continue
+		}
+		gap := &GapError{From: from + uint64(i), To: from + uint64(i)}
+		for j := i + 1; j < len(headers) && headers[j] == nil; j++ {
+			gap.To = from + uint64(j)
+		}
+		return gap
+	}
+	for i := 1; i < len(headers); i++ {
+		if headers[i].ParentHash != headers[i-1].BlockHash {
+			return &ChainError{Number: headers[i].Number.Uint64()}
+		}
+	}
+	return nil
+}
+
+// FirstBlockAtOrAfter binary searches the first block with timestamp >= t
+func (f *Fetcher) FirstBlockAtOrAfter(ctx context.Context, t time.Time) (*Header, error) {
+	latest, err := f.LatestBlockNumber(ctx)
+	if err != nil {
+		return nil, err
+	}
+	target := uint64(t.Unix())
+	lastHeader, err := f.HeaderByNumber(ctx, latest)
+	if err != nil {
+		return nil, err
+	}
+	if lastHeader.Time < target {
+		return nil, ErrNoBlockAfter
+	}
+
+	low, high := uint64(0), latest
+	for low < high {
+		mid := low + (high-low)/2
+		header, err := f.HeaderByNumber(ctx, mid)
+		if err != nil {
+			return nil, err
+		}
+		if header.Time >= target {
+			high = mid
+		} else {
+			low = mid + 1
+		}
+	}
+	return f.HeaderByNumber(ctx, low)
+}
+
+// Iterator walks over a range of headers, fetching a window of
+// BatchSize * Parallelism headers ahead of the consumer
+type Iterator struct {
+	ctx     context.Context
+	fetcher *Fetcher
+	next    uint64
+	to      uint64
+
+	window []*Header
+	header *Header
+	err    error
+}
+
+// BlockIterator iterates headers of blocks [from, to] with the default settings and a private cache
+func BlockIterator(ctx context.Context, client *rpc.Client, from uint64, to uint64) *Iterator {
+	return NewFetcher(client, DefaultCacheSize).Iterate(ctx, from, to)
+}
+
+func (f *Fetcher) Iterate(ctx context.Context, from uint64, to uint64) *Iterator {
+	it := &Iterator{ctx: ctx, fetcher: f, next: from, to: to}
+	if to < from {
+		it.err = fmt.Errorf("invalid range [%d, %d]", from, to)
+	}
+	return it
+}
+
+// Next advances to the next header; false at the end of the range or on error
+func (it *Iterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	if len(it.window) == 0 {
+		if it.next > it.to {
+			it.header = nil
+			return false
+		}
+		windowSize := uint64(it.fetcher.BatchSize * it.fetcher.Parallelism)
+		if windowSize == 0 {
+			windowSize = DefaultBatchSize * DefaultParallelism
+		}
+		end := it.next + windowSize - 1
+		if end > it.to {
+			end = it.to
+		}
+		window, err := it.fetcher.Headers(it.ctx, it.next, end)
+		if err != nil {
+			it.err = err
+			it.header = nil
+			return false
+		}
+		if it.header != nil && window[0].ParentHash != it.header.BlockHash {
+			it.err = &ChainError{Number: window[0].Number.Uint64()}
+			it.header = nil
+			return false
+		}
+		it.window = window
+		it.next = end + 1
+	}
+	it.header = it.window[0]
+	it.window = it.window[1:]
+	return true
+}
+
+func (it *Iterator) Header() *Header {
+	return it.header
+}
+
+func (it *Iterator) Err() error {
+	return it.err
+}
@@ -0,0 +1,229 @@
+package blocks
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	"first/rpcmock"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+)
+
+const genesisTime = 1713900000
+
+// testChain serves eth_getBlockByNumber for headers with the given timestamps
+type testChain struct {
+	headers []*types.Header
+	missing map[uint64]bool
+}
+
+func newTestChain(timestamps []uint64) *testChain {
+	c := &testChain{missing: make(map[uint64]bool)}
+	parent := common.Hash{}
+	for i, timestamp := range timestamps {
+		header := &types.Header{
+			ParentHash: parent,
+			Number:     big.NewInt(int64(i)),
+			Difficulty: big.NewInt(0),
+			GasLimit:   30000000,
+			Time:       timestamp,
+		}
+		parent = header.Hash()
+		c.headers = append(c.headers, header)
+	}
+	return c
+}
+
+func regularTimestamps(count int, blockTime uint64) []uint64 {
+	res := make([]uint64, 0, count)
+	for i := 0; i < count; i++ {
+		res = append(res, genesisTime+uint64(i)*blockTime)
+	}
+	return res
+}
+
+func (c *testChain) serve(t *testing.T) (*rpcmock.Server, *Fetcher) {
+	server := rpcmock.New()
+	t.Cleanup(server.Close)
+	server.Handle("eth_blockNumber", func(params []json.RawMessage) (interface{}, error) {
+		return hexutil.Uint64(len(c.headers) - 1), nil
+	})
+	server.Handle("eth_getBlockByNumber", func(params []json.RawMessage) (interface{}, error) {
+		var number hexutil.Uint64
+		require.NoError(t, json.Unmarshal(params[0], &number))
+		if uint64(number) >= uint64(len(c.headers)) || c.missing[uint64(number)] {
+			return nil, nil
+		}
+		return c.headers[number], nil
+	})
+
+	client, err := server.Client()
+	require.NoError(t, err)
+	t.Cleanup(client.Close)
+	return server, NewFetcher(client, 1000)
+}
+
+func batchesOf(server *rpcmock.Server) map[int]int {
+	res := make(map[int]int)
+	for _, call := range server.CallsTo("eth_getBlockByNumber") {
+		res[call.Batch]++
+	}
+	return res
+}
+
+func TestIteratorReturnsHeadersInOrder(t *testing.T) {
+	chain := newTestChain(regularTimestamps(50, 12))
+	server, fetcher := chain.serve(t)
+	fetcher.BatchSize = 7
+	fetcher.Parallelism = 2
+
+	it := fetcher.Iterate(context.Background(), 3, 45)
+	expected := uint64(3)
+	for it.Next() {
+		require.Equal(t, expected, it.Header().Number.Uint64())
+		require.Equal(t, chain.headers[expected].Hash(), it.Header().BlockHash)
+		expected++
+	}
+	require.NoError(t, it.Err())
+	require.Equal(t, uint64(46), expected)
+
+	batches := batchesOf(server)
+	require.Equal(t, 7, len(batches), "43 headers in batches of 7")
+	for _, size := range batches {
+		require.LessOrEqual(t, size, 7)
+	}
+}
+
+func TestHeadersAreCached(t *testing.T) {
+	chain := newTestChain(regularTimestamps(20, 12))
+	server, fetcher := chain.serve(t)
+
+	_, err := fetcher.Headers(context.Background(), 0, 9)
+	require.NoError(t, err)
+	require.Equal(t, 10, fetcher.Cache().Len())
+
+	headers, err := fetcher.Headers(context.Background(), 5, 14)
+	require.NoError(t, err)
+	require.Equal(t, 10, len(headers))
+	require.Equal(t, 15, len(server.CallsTo("eth_getBlockByNumber")), "only blocks 10 to 14 are fetched again")
+}
+
+func TestHeaderCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewHeaderCache(2)
+	header := func(number int64) *Header {
+		return &Header{Header: &types.Header{Number: big.NewInt(number)}}
+	}
+	cache.Add(header(1))
+	cache.Add(header(2))
+	_, found := cache.Get(1)
+	require.True(t, found)
+	cache.Add(header(3))
+
+	_, found = cache.Get(2)
+	require.False(t, found)
+	_, found = cache.Get(1)
+	require.True(t, found)
+	require.Equal(t, 2, cache.Len())
+
+	cache.Purge()
+	require.Equal(t, 0, cache.Len())
+}
+
+func TestIteratorDetectsGaps(t *testing.T) {
+	chain := newTestChain(regularTimestamps(20, 12))
+	chain.missing[8] = true
+	chain.missing[9] = true
+	_, fetcher := chain.serve(t)
+
+	it := fetcher.Iterate(context.Background(), 0, 19)
+	count := 0
+	for it.Next() {
+		count++
+	}
+	require.Equal(t, 0, count)
+	var gap *GapError
+	require.ErrorAs(t, it.Err(), &gap)
+	require.Equal(t, &GapError{From: 8, To: 9}, gap)
+}
+
+func TestIteratorDetectsBrokenChain(t *testing.T) {
+	chain := newTestChain(regularTimestamps(20, 12))
+	chain.headers[12].ParentHash = common.Hash{1}
+	_, fetcher := chain.serve(t)
+
+	_, err := fetcher.Headers(context.Background(), 0, 19)
+	var chainErr *ChainError
+	require.ErrorAs(t, err, &chainErr)
+	require.Equal(t, uint64(12), chainErr.Number)
+}
+
+func TestFirstBlockAtOrAfter(t *testing.T) {
+	// Irregular block times: 0, 12, 24, 25, 26, 100, 112
+	timestamps := []uint64{genesisTime, genesisTime + 12, genesisTime + 24, genesisTime + 25, genesisTime + 26, genesisTime + 100, genesisTime + 112}
+	chain := newTestChain(timestamps)
+	_, fetcher := chain.serve(t)
+	ctx := context.Background()
+
+	cases := map[uint64]uint64{
+		0:   0,
+		1:   1,
+		12:  1,
+		25:  3,
+		27:  5,
+		100: 5,
+		112: 6,
+	}
+	for offset, expected := range cases {
+		header, err := fetcher.FirstBlockAtOrAfter(ctx, time.Unix(int64(genesisTime+offset), 0))
+		require.NoError(t, err)
+		require.Equal(t, expected, header.Number.Uint64(), "offset %d", offset)
+	}
+
+	_, err := fetcher.FirstBlockAtOrAfter(ctx, time.Unix(genesisTime+113, 0))
+	require.ErrorIs(t, err, ErrNoBlockAfter)
+}
+
+func TestBlockAtTime(t *testing.T) {
+	irregular := []uint64{genesisTime, genesisTime + 12, genesisTime + 24, genesisTime + 25, genesisTime + 26, genesisTime + 100, genesisTime + 112, genesisTime + 1000}
+	for name, timestamps := range map[string][]uint64{
+		"regular":   regularTimestamps(1000, 12),
+		"irregular": irregular,
+	} {
+		t.Run(name, func(t *testing.T) {
+			chain := newTestChain(timestamps)
+			_, fetcher := chain.serve(t)
+			ctx := context.Background()
+
+			for blockNo, timestamp := range timestamps {
+				for _, offset := range []uint64{0, 1} {
+					if blockNo+1 < len(timestamps) && timestamp+offset >= timestamps[blockNo+1] {
+						continue
+					}
+					header, err := fetcher.BlockAtTime(ctx, time.Unix(int64(timestamp+offset), 0))
+					require.NoError(t, err)
+					require.Equal(t, uint64(blockNo), header.Number.Uint64(), "time %d", timestamp+offset)
+				}
+			}
+
+			_, err := fetcher.BlockAtTime(ctx, time.Unix(genesisTime-1, 0))
+			require.ErrorIs(t, err, ErrBeforeGenesis)
+		})
+	}
+}
+
+func TestBlockAtTimeInterpolatesRegularBlocks(t *testing.T) {
+	chain := newTestChain(regularTimestamps(100000, 12))
+	server, fetcher := chain.serve(t)
+
+	header, err := fetcher.BlockAtTime(context.Background(), time.Unix(genesisTime+12*54321+5, 0))
+	require.NoError(t, err)
+	require.Equal(t, uint64(54321), header.Number.Uint64())
+	// Genesis, latest, the interpolated block and its successor
+	require.LessOrEqual(t, len(server.CallsTo("eth_getBlockByNumber")), 4)
+}
@@ -0,0 +1,68 @@
+package blocks
+
+import (
+	"container/list"
+	"sync"
+)
+
+// HeaderCache is a LRU cache of headers by block number
+type HeaderCache struct {
+	capacity int
+
+	mu    sync.Mutex
+	order *list.List
+	items map[uint64]*list.Element
+}
+
+func NewHeaderCache(capacity int) *HeaderCache {
+	return &HeaderCache{
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[uint64]*list.Element),
+	}
+}
+
+func (c *HeaderCache) Get(number uint64) (*Header, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, found := c.items[number]
+	if !found {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*Header), true
+}
+
+func (c *HeaderCache) Add(header *Header) {
+	if c.capacity <= 0 {
+		return
+	}
+	number := header.Number.Uint64()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, found := c.items[number]; found {
+		el.Value = header
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[number] = c.order.PushFront(header)
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*Header).Number.Uint64())
+	}
+}
+
+// Purge drops all the headers, e.g. after a reorg
+func (c *HeaderCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order.Init()
+	c.items = make(map[uint64]*list.Element)
+}
+
+func (c *HeaderCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
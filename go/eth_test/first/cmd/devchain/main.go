@@ -0,0 +1,232 @@
+// devchain starts a local dev node and scripts it from the command line with the
+// helpers of the test suite, e.g.
+//
+//	devchain up --blocks 10
+//	devchain fund 0x70997970C51812dc3A010C7d01b50e0d17dc79C8 100
+//	devchain snapshot
+//	devchain warp 24h
+//	devchain mine 5 --block-time 2s
+//	devchain revert 0x1
+//	devchain scenario run scenario.yaml
+//
+// Every command but up connects to the node started by up
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"first"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const usage = `usage: devchain [--node anvil|hardhat|geth] <command> [arguments]
+
+commands:
+  up [--hardfork name] [--blocks n] [--block-time d]   start a node, stop it with Ctrl-C
+  mine <n> [--block-time d]                            mine n blocks
+  warp <duration>                                      increase the chain time, e.g. 24h
+  snapshot                                             print the id of a new snapshot
+  revert <id>                                          revert to a snapshot
+  fund <address> <ether>                               set the balance of an account
+  scenario run <file.yaml>                             apply the steps of a scenario
+`
+
+var errUsage = errors.New("invalid arguments")
+
+func main() {
+	flags := flag.NewFlagSet("devchain", flag.ExitOnError)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	nodeName := flags.String("node", "", "node profile, DEV_NODE or anvil by default")
+	flags.Parse(os.Args[1:])
+
+	profile := first.CurrentProfile()
+	if *nodeName != "" {
+		var err error
+		profile, err = first.ProfileByName(*nodeName)
+		exitOnError(err)
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	command, args := flags.Arg(0), flags.Args()[1:]
+	var err error
+	if command == "up" {
+		err = up(profile, args)
+	} else {
+		err = runCommand(profile, command, args)
+	}
+	if errors.Is(err, errUsage) {
+		fmt.Fprintf(os.Stderr, "%s\n\n%s", err, usage)
+		os.Exit(2)
+	}
+	exitOnError(err)
+}
+
+func exitOnError(err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "devchain: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func usageError(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", errUsage, fmt.Sprintf(format, args...))
+}
+
+func up(profile *first.NodeProfile, args []string) (err error) {
+	flags := flag.NewFlagSet("up", flag.ContinueOnError)
+	hardfork := flags.String("hardfork", "", "start at hardfork, e.g. cancun")
+	blocks := flags.Int("blocks", 0, "blocks to mine after the start")
+	blockTime := flags.Duration("block-time", first.DefaultBlockTime, "interval between the mined blocks")
+	err = flags.Parse(args)
+	if err != nil {
+		return usageError("up: %s", err)
+	}
+
+	var nodeArgs []string
+	if *hardfork != "" {
+		if !profile.Supports(first.CapHardfork) {
+			return &first.UnsupportedError{Profile: profile.Name, Capability: first.CapHardfork}
+		}
+		nodeArgs = profile.HardforkArgs(*hardfork)
+	}
+	anvil, err := first.Start(profile, nodeArgs...)
+	if err != nil {
+		return err
+	}
+	// Close would revert the state, the node is meant to be stopped as is
+	defer func() {
+		stopErr := anvil.Stop()
+		if err == nil {
+			err = stopErr
+		}
+	}()
+	if *blocks > 0 {
+		err = anvil.MineBlocks(*blocks, *blockTime)
+		if err != nil {
+			return err
+		}
+	}
+
+	addresses, err := anvil.AvailableAddresses()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s listening on %s\n", profile.Name, profile.URL)
+	for i, address := range addresses {
+		fmt.Printf("(%d) %s\n", i, address)
+	}
+
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt, syscall.SIGTERM)
+	<-interrupted
+	fmt.Printf("stopping %s\n", profile.Name)
+	return nil
+}
+
+func runCommand(profile *first.NodeProfile, command string, args []string) error {
+	ctx := context.Background()
+	switch command {
+	case "mine":
+		flags := flag.NewFlagSet("mine", flag.ContinueOnError)
+		blockTime := flags.Duration("block-time", first.DefaultBlockTime, "interval between the mined blocks")
+		// Accept the block count before the flags, i.e. `mine 10 --block-time 2s`
+		if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+			args = append(args[1:], args[0])
+		}
+		err := flags.Parse(args)
+		if err != nil || flags.NArg() != 1 {
+			return usageError("mine <n> [--block-time d]")
+		}
+		blocks, err := strconv.Atoi(flags.Arg(0))
+		if err != nil || blocks <= 0 {
+			return usageError("invalid block count %q", flags.Arg(0))
+		}
+		return withNode(profile, func(anvil *first.Anvil) error {
+			return anvil.MineBlocks(blocks, *blockTime)
+		})
+	case "warp":
+		if len(args) != 1 {
+			return usageError("warp <duration>")
+		}
+		duration, err := time.ParseDuration(args[0])
+		if err != nil {
+			return usageError("invalid duration %q", args[0])
+		}
+		return withNode(profile, func(anvil *first.Anvil) error {
+			_, err := anvil.IncreaseTime(duration)
+			return err
+		})
+	case "snapshot":
+		if len(args) != 0 {
+			return usageError("snapshot")
+		}
+		return withNode(profile, func(anvil *first.Anvil) error {
+			snapshotId, err := anvil.TakeSnapshot()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("0x%x\n", snapshotId)
+			return nil
+		})
+	case "revert":
+		if len(args) != 1 {
+			return usageError("revert <id>")
+		}
+		snapshotId, err := strconv.ParseInt(args[0], 0, 64)
+		if err != nil {
+			return usageError("invalid snapshot id %q", args[0])
+		}
+		return withNode(profile, func(anvil *first.Anvil) error {
+			return anvil.RevertSnapshot(int(snapshotId))
+		})
+	case "fund":
+		if len(args) != 2 {
+			return usageError("fund <address> <ether>")
+		}
+		if !common.IsHexAddress(args[0]) {
+			return usageError("invalid address %q", args[0])
+		}
+		amount, err := first.ParseEther(args[1])
+		if err != nil {
+			return usageError("%s", err)
+		}
+		return withNode(profile, func(anvil *first.Anvil) error {
+			return anvil.SetBalance(ctx, common.HexToAddress(args[0]), amount)
+		})
+	case "scenario":
+		if len(args) != 2 || args[0] != "run" {
+			return usageError("scenario run <file.yaml>")
+		}
+		scenario, err := first.LoadScenario(args[1])
+		if err != nil {
+			return err
+		}
+		return withNode(profile, func(anvil *first.Anvil) error {
+			return scenario.Run(ctx, anvil, os.Stdout)
+		})
+	}
+	return usageError("unknown command %q", command)
+}
+
+// withNode runs f connected to the node started by `devchain up`
+func withNode(profile *first.NodeProfile, f func(anvil *first.Anvil) error) error {
+	anvil, err := first.Connect(profile)
+	if err != nil {
+		return fmt.Errorf("%w; is `devchain up` running?", err)
+	}
+	defer anvil.Close()
+	return f(anvil)
+}
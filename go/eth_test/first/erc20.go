@@ -0,0 +1,144 @@
+package first
+
+import (
+	"context"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+const testTokenABIJSON = `[
+	{"type":"function","name":"totalSupply","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"decimals","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint8"}]},
+	{"type":"function","name":"balanceOf","stateMutability":"view","inputs":[{"name":"owner","type":"address"}],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"allowance","stateMutability":"view","inputs":[{"name":"owner","type":"address"},{"name":"spender","type":"address"}],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"transfer","stateMutability":"nonpayable","inputs":[{"name":"to","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]},
+	{"type":"function","name":"transferFrom","stateMutability":"nonpayable","inputs":[{"name":"from","type":"address"},{"name":"to","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]},
+	{"type":"function","name":"approve","stateMutability":"nonpayable","inputs":[{"name":"spender","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]},
+	{"type":"function","name":"mint","stateMutability":"nonpayable","inputs":[{"name":"to","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]},
+	{"type":"event","name":"Transfer","anonymous":false,"inputs":[{"name":"from","type":"address","indexed":true},{"name":"to","type":"address","indexed":true},{"name":"value","type":"uint256","indexed":false}]},
+	{"type":"event","name":"Approval","anonymous":false,"inputs":[{"name":"owner","type":"address","indexed":true},{"name":"spender","type":"address","indexed":true},{"name":"value","type":"uint256","indexed":false}]}
+]`
+
+// TestTokenABI is the ABI of the bundled ERC-20 test token
+var TestTokenABI = mustParseABI(testTokenABIJSON)
+
+// TransferEventTopic is the topic of the ERC-20 Transfer event
+var TransferEventTopic = crypto.Keccak256Hash([]byte(transferEventSignature))
+
+func mustParseABI(definition string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(definition))
+	panicOnError(err)
+	return parsed
+}
+
+// Token wraps an ERC-20 contract with typed helpers. The write helpers only send the
+// transaction; the caller mines it and waits for the receipt
+type Token struct {
+	Address common.Address
+
+	client *ethclient.Client
+}
+
+func NewToken(client *ethclient.Client, address common.Address) *Token {
+	return &Token{Address: address, client: client}
+}
+
+// DeployTestToken sends the creation transaction of the bundled ERC-20 test token.
+// The token is usable once the transaction is mined
+func DeployTestToken(ctx context.Context, sender *TxSender) (*Token, *types.Transaction, error) {
+	tx, err := sender.Send(ctx, nil, nil, testTokenBytecode())
+	if err != nil {
+		return nil, nil, err
+	}
+	return NewToken(sender.client, crypto.CreateAddress(sender.From(), tx.Nonce())), tx, nil
+}
+
+func (t *Token) send(ctx context.Context, sender *TxSender, method string, args ...interface{}) (*types.Transaction, error) {
+	data, err := TestTokenABI.Pack(method, args...)
+	if err != nil {
+		return nil, err
+	}
+	return sender.Send(ctx, &t.Address, nil, data)
+}
+
+// Mint creates amount tokens for to; anyone can mint the test token
+func (t *Token) Mint(ctx context.Context, sender *TxSender, to common.Address, amount *big.Int) (*types.Transaction, error) {
+	return t.send(ctx, sender, "mint", to, amount)
+}
+
+func (t *Token) Transfer(ctx context.Context, sender *TxSender, to common.Address, amount *big.Int) (*types.Transaction, error) {
+	return t.send(ctx, sender, "transfer", to, amount)
+}
+
+func (t *Token) Approve(ctx context.Context, sender *TxSender, spender common.Address, amount *big.Int) (*types.Transaction, error) {
+	return t.send(ctx, sender, "approve", spender, amount)
+}
+
+// TransferFrom moves tokens of from using the allowance given to the sender
+func (t *Token) TransferFrom(ctx context.Context, sender *TxSender, from common.Address, to common.Address, amount *big.Int) (*types.Transaction, error) {
+	return t.send(ctx, sender, "transferFrom", from, to, amount)
+}
+
+func (t *Token) callUint(ctx context.Context, method string, args ...interface{}) (*big.Int, error) {
+	data, err := TestTokenABI.Pack(method, args...)
+	if err != nil {
+		return nil, err
+	}
+	output, err := t.client.CallContract(ctx, ethereum.CallMsg{To: &t.Address, Data: data}, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := TestTokenABI.Unpack(method, output)
+	if err != nil {
+		return nil, err
+	}
+	return res[0].(*big.Int), nil
+}
+
+func (t *Token) BalanceOf(ctx context.Context, owner common.Address) (*big.Int, error) {
+	return t.callUint(ctx, "balanceOf", owner)
+}
+
+func (t *Token) Allowance(ctx context.Context, owner common.Address, spender common.Address) (*big.Int, error) {
+	return t.callUint(ctx, "allowance", owner, spender)
+}
+
+func (t *Token) TotalSupply(ctx context.Context) (*big.Int, error) {
+	return t.callUint(ctx, "totalSupply")
+}
+
+// mappingStorageSlot is the storage slot of key in a solidity mapping declared at slot
+func mappingStorageSlot(key common.Hash, slot uint64) common.Hash {
+	return crypto.Keccak256Hash(key.Bytes(), common.BigToHash(new(big.Int).SetUint64(slot)).Bytes())
+}
+
+// TokenBalanceSlot is the storage slot of owner's balance for tokens declaring
+// `mapping(address => uint256) balanceOf` at balancesSlot, 0 for the test token
+func TokenBalanceSlot(owner common.Address, balancesSlot uint64) common.Hash {
+	return mappingStorageSlot(common.BytesToHash(owner.Bytes()), balancesSlot)
+}
+
+// SetTokenBalance overwrites owner's balance of the test token without a transaction.
+// The total supply is left unchanged
+func (g *Anvil) SetTokenBalance(ctx context.Context, token common.Address, owner common.Address, amount *big.Int) error {
+	return g.SetTokenBalanceAtSlot(ctx, token, testTokenBalancesSlot, owner, amount)
+}
+
+// SetTokenBalanceAtSlot is SetTokenBalance for tokens with a different storage layout
+func (g *Anvil) SetTokenBalanceAtSlot(ctx context.Context, token common.Address, balancesSlot uint64, owner common.Address, amount *big.Int) error {
+	err := g.Profile().require(CapSetStorage)
+	if err != nil {
+		return err
+	}
+	value := hexutil.Encode(common.BigToHash(amount).Bytes())
+	call := prepareCall(g.Profile().SetStorageMethod, []any{token, TokenBalanceSlot(owner, balancesSlot), value}, new(bool))
+	return g.c.CallContext(ctx, call.Result, call.Method, call.Args...)
+}
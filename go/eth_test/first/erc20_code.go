@@ -0,0 +1,198 @@
+package first
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// No solidity compiler is required to build the test token: its bytecode is assembled
+// from the listing in testTokenRuntime. The storage layout is the one solc generates for
+//
+//	mapping(address => uint256) balanceOf;                      // slot 0
+//	mapping(address => mapping(address => uint256)) allowance;  // slot 1
+//	uint256 totalSupply;                                        // slot 2
+//
+// so that balances can be patched with anvil_setStorageAt, see TokenBalanceSlot
+
+const (
+	testTokenBalancesSlot   = 0
+	testTokenAllowancesSlot = 1
+	testTokenSupplySlot     = 2
+	testTokenDecimals       = 18
+)
+
+const (
+	transferEventSignature = "Transfer(address,address,uint256)"
+	approvalEventSignature = "Approval(address,address,uint256)"
+)
+
+// assembler is a minimal EVM assembler supporting PUSH2 labels
+type assembler struct {
+	code   []byte
+	labels map[string]int
+	fixups map[int]string
+}
+
+func newAssembler() *assembler {
+	return &assembler{labels: make(map[string]int), fixups: make(map[int]string)}
+}
+
+func (a *assembler) op(ops ...vm.OpCode) *assembler {
+	for _, op := range ops {
+		a.code = append(a.code, byte(op))
+	}
+	return a
+}
+
+// push emits the shortest PUSH for value
+func (a *assembler) push(value []byte) *assembler {
+	value = common.TrimLeftZeroes(value)
+	if len(value) == 0 {
+		value = []byte{0}
+	}
+	a.code = append(a.code, byte(vm.PUSH1)+byte(len(value)-1))
+	a.code = append(a.code, value...)
+	return a
+}
+
+func (a *assembler) pushInt(value uint64) *assembler {
+	return a.push(new(big.Int).SetUint64(value).Bytes())
+}
+
+// pushLabel emits the jump destination of label, resolved by assemble
+func (a *assembler) pushLabel(label string) *assembler {
+	a.code = append(a.code, byte(vm.PUSH2))
+	a.fixups[len(a.code)] = label
+	a.code = append(a.code, 0, 0)
+	return a
+}
+
+func (a *assembler) label(label string) *assembler {
+	a.labels[label] = len(a.code)
+	return a.op(vm.JUMPDEST)
+}
+
+func (a *assembler) assemble() []byte {
+	for at, label := range a.fixups {
+		dest, found := a.labels[label]
+		if !found {
+			panic(fmt.Sprintf("undefined label %s", label))
+		}
+		a.code[at] = byte(dest >> 8)
+		a.code[at+1] = byte(dest)
+	}
+	return a.code
+}
+
+// arg loads the nth static calldata argument
+func (a *assembler) arg(n uint64) *assembler {
+	return a.pushInt(4 + 32*n).op(vm.CALLDATALOAD)
+}
+
+// mappingSlot replaces the key on top of the stack with keccak256(key . slot)
+func (a *assembler) mappingSlot(slot uint64) *assembler {
+	a.pushInt(0).op(vm.MSTORE)
+	a.pushInt(slot).pushInt(32).op(vm.MSTORE)
+	return a.pushInt(64).pushInt(0).op(vm.KECCAK256)
+}
+
+// allowanceSlot replaces [spender, owner] with the slot of allowance[owner][spender]
+func (a *assembler) allowanceSlot() *assembler {
+	a.mappingSlot(testTokenAllowancesSlot)
+	a.pushInt(32).op(vm.MSTORE)
+	a.pushInt(0).op(vm.MSTORE)
+	return a.pushInt(64).pushInt(0).op(vm.KECCAK256)
+}
+
+func (a *assembler) returnWord() *assembler {
+	a.pushInt(0).op(vm.MSTORE)
+	return a.pushInt(32).pushInt(0).op(vm.RETURN)
+}
+
+// log3 emits an event with topics [signature, topic1, topic2] and the word on top of the
+// stack as data; the stack is [topic2, topic1, data]
+func (a *assembler) log3(signature string) *assembler {
+	a.pushInt(0).op(vm.MSTORE)
+	a.push(crypto.Keccak256([]byte(signature)))
+	return a.pushInt(32).pushInt(0).op(vm.LOG3)
+}
+
+// testTokenRuntime is the deployed code of the test token. Anyone can mint. Failures
+// revert without a reason; there are no overflow checks on credit
+func testTokenRuntime() []byte {
+	a := newAssembler()
+
+	// Dispatcher
+	a.pushInt(0).op(vm.CALLDATALOAD).pushInt(0xe0).op(vm.SHR)
+	for _, method := range []string{
+		"totalSupply()",
+		"decimals()",
+		"balanceOf(address)",
+		"allowance(address,address)",
+		"transfer(address,uint256)",
+		"transferFrom(address,address,uint256)",
+		"approve(address,uint256)",
+		"mint(address,uint256)",
+	} {
+		a.op(vm.DUP1).push(MethodSelector(method)).op(vm.EQ).pushLabel(method).op(vm.JUMPI)
+	}
+	a.label("revert").pushInt(0).op(vm.DUP1, vm.REVERT)
+
+	a.label("totalSupply()").pushInt(testTokenSupplySlot).op(vm.SLOAD).returnWord()
+
+	a.label("decimals()").pushInt(testTokenDecimals).returnWord()
+
+	a.label("balanceOf(address)").arg(0).mappingSlot(testTokenBalancesSlot).op(vm.SLOAD).returnWord()
+
+	a.label("allowance(address,address)").arg(1).arg(0).allowanceSlot().op(vm.SLOAD).returnWord()
+
+	// [return, from, to, amount] -> [return]
+	a.label("_transfer")
+	a.op(vm.DUP3).mappingSlot(testTokenBalancesSlot) // [.., amount, fromSlot]
+	a.op(vm.DUP1, vm.SLOAD)                          // [.., amount, fromSlot, fromBalance]
+	a.op(vm.DUP3, vm.DUP2, vm.LT).pushLabel("revert").op(vm.JUMPI)
+	a.op(vm.DUP3, vm.SWAP1, vm.SUB, vm.SWAP1, vm.SSTORE) // [return, from, to, amount]
+	a.op(vm.DUP2).mappingSlot(testTokenBalancesSlot)     // [.., amount, toSlot]
+	a.op(vm.DUP1, vm.SLOAD, vm.DUP3, vm.ADD, vm.SWAP1, vm.SSTORE)
+	a.op(vm.SWAP1, vm.SWAP2, vm.SWAP1).log3(transferEventSignature) // [return, to, from, amount]
+	a.op(vm.JUMP)
+
+	a.label("transfer(address,uint256)")
+	a.pushLabel("returnTrue").op(vm.CALLER).arg(0).arg(1).pushLabel("_transfer").op(vm.JUMP)
+
+	a.label("transferFrom(address,address,uint256)")
+	a.arg(2).op(vm.CALLER).arg(0).allowanceSlot() // [amount, slot]
+	a.op(vm.DUP1, vm.SLOAD)                       // [amount, slot, allowed]
+	a.op(vm.DUP3, vm.DUP2, vm.LT).pushLabel("revert").op(vm.JUMPI)
+	a.op(vm.DUP3, vm.SWAP1, vm.SUB, vm.SWAP1, vm.SSTORE, vm.POP)
+	a.pushLabel("returnTrue").arg(0).arg(1).arg(2).pushLabel("_transfer").op(vm.JUMP)
+
+	a.label("approve(address,uint256)")
+	a.arg(1).arg(0).op(vm.CALLER).allowanceSlot().op(vm.SSTORE)
+	a.arg(0).op(vm.CALLER).arg(1).log3(approvalEventSignature)
+	a.pushLabel("returnTrue").op(vm.JUMP)
+
+	a.label("mint(address,uint256)")
+	a.arg(1).op(vm.DUP1).pushInt(testTokenSupplySlot).op(vm.SLOAD, vm.ADD).pushInt(testTokenSupplySlot).op(vm.SSTORE)
+	a.arg(0).mappingSlot(testTokenBalancesSlot)
+	a.op(vm.DUP1, vm.SLOAD, vm.DUP3, vm.ADD, vm.SWAP1, vm.SSTORE, vm.POP)
+	a.arg(0).pushInt(0).arg(1).log3(transferEventSignature)
+
+	a.label("returnTrue").pushInt(1).returnWord()
+
+	return a.assemble()
+}
+
+// testTokenBytecode is the creation code: it copies the runtime code to memory and returns it
+func testTokenBytecode() []byte {
+	runtime := testTokenRuntime()
+	init := newAssembler()
+	init.code = append(init.code, byte(vm.PUSH2), byte(len(runtime)>>8), byte(len(runtime)))
+	init.op(vm.DUP1).pushLabel("runtime").pushInt(0).op(vm.CODECOPY).pushInt(0).op(vm.RETURN)
+	init.labels["runtime"] = len(init.code)
+	return append(init.assemble(), runtime...)
+}
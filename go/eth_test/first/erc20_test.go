@@ -0,0 +1,188 @@
+package first
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm/runtime"
+	"github.com/stretchr/testify/require"
+)
+
+// evmToken runs the test token in an in-memory EVM
+type evmToken struct {
+	t       *testing.T
+	cfg     *runtime.Config
+	address common.Address
+}
+
+func deployEVMToken(t *testing.T) *evmToken {
+	cfg := &runtime.Config{}
+	code, address, _, err := runtime.Create(testTokenBytecode(), cfg)
+	require.NoError(t, err)
+	require.Equal(t, testTokenRuntime(), code)
+	return &evmToken{t: t, cfg: cfg, address: address}
+}
+
+func (e *evmToken) call(from common.Address, method string, args ...interface{}) ([]interface{}, error) {
+	data, err := TestTokenABI.Pack(method, args...)
+	require.NoError(e.t, err)
+	e.cfg.Origin = from
+	output, _, err := runtime.Call(e.address, data, e.cfg)
+	if err != nil {
+		return nil, err
+	}
+	return TestTokenABI.Unpack(method, output)
+}
+
+func (e *evmToken) mustCall(from common.Address, method string, args ...interface{}) interface{} {
+	res, err := e.call(from, method, args...)
+	require.NoError(e.t, err)
+	return res[0]
+}
+
+func (e *evmToken) balanceOf(owner common.Address) *big.Int {
+	return e.mustCall(common.Address{}, "balanceOf", owner).(*big.Int)
+}
+
+func TestTestTokenMintTransferAndAllowance(t *testing.T) {
+	token := deployEVMToken(t)
+	alice, bob, carol := common.Address{0xa}, common.Address{0xb}, common.Address{0xc}
+
+	require.Equal(t, uint8(18), token.mustCall(alice, "decimals"))
+	require.Equal(t, true, token.mustCall(alice, "mint", alice, big.NewInt(1000)))
+	require.Equal(t, big.NewInt(1000), token.mustCall(alice, "totalSupply"))
+
+	require.Equal(t, true, token.mustCall(alice, "transfer", bob, big.NewInt(300)))
+	require.Equal(t, big.NewInt(700), token.balanceOf(alice))
+	require.Equal(t, big.NewInt(300), token.balanceOf(bob))
+
+	_, err := token.call(bob, "transfer", carol, big.NewInt(301))
+	require.Error(t, err, "insufficient balance")
+
+	require.Equal(t, true, token.mustCall(alice, "approve", carol, big.NewInt(500)))
+	require.Equal(t, big.NewInt(500), token.mustCall(bob, "allowance", alice, carol))
+	require.Zero(t, token.mustCall(bob, "allowance", carol, alice).(*big.Int).Sign())
+
+	require.Equal(t, true, token.mustCall(carol, "transferFrom", alice, bob, big.NewInt(200)))
+	require.Equal(t, big.NewInt(300), token.mustCall(bob, "allowance", alice, carol))
+	require.Equal(t, big.NewInt(500), token.balanceOf(alice))
+	require.Equal(t, big.NewInt(500), token.balanceOf(bob))
+
+	_, err = token.call(carol, "transferFrom", alice, bob, big.NewInt(301))
+	require.Error(t, err, "insufficient allowance")
+	_, err = token.call(bob, "transferFrom", alice, bob, big.NewInt(1))
+	require.Error(t, err, "no allowance")
+	require.Equal(t, big.NewInt(1000), token.mustCall(alice, "totalSupply"))
+}
+
+func TestTestTokenEvents(t *testing.T) {
+	token := deployEVMToken(t)
+	alice, bob := common.Address{0xa}, common.Address{0xb}
+
+	token.mustCall(alice, "mint", alice, big.NewInt(10))
+	token.mustCall(alice, "transfer", bob, big.NewInt(4))
+	token.mustCall(alice, "approve", bob, big.NewInt(6))
+
+	logs := token.cfg.State.Logs()
+	require.Equal(t, 3, len(logs))
+	expected := []struct {
+		event string
+		from  common.Address
+		to    common.Address
+		value int64
+	}{
+		{"Transfer", common.Address{}, alice, 10},
+		{"Transfer", alice, bob, 4},
+		{"Approval", alice, bob, 6},
+	}
+	for _, exp := range expected {
+		found := false
+		for _, log := range logs {
+			event := TestTokenABI.Events[exp.event]
+			if log.Topics[0] != event.ID || log.Topics[1] != common.BytesToHash(exp.from.Bytes()) {
+				continue
+			}
+			found = true
+			require.Equal(t, common.BytesToHash(exp.to.Bytes()), log.Topics[2])
+			require.Equal(t, common.BigToHash(big.NewInt(exp.value)).Bytes(), log.Data)
+		}
+		require.True(t, found, "%s from %s", exp.event, exp.from)
+	}
+	require.Equal(t, TransferEventTopic, TestTokenABI.Events["Transfer"].ID)
+}
+
+func TestTokenBalanceSlotMatchesTestTokenStorage(t *testing.T) {
+	token := deployEVMToken(t)
+	alice := common.Address{0xa}
+	token.mustCall(alice, "mint", alice, big.NewInt(42))
+
+	stored := token.cfg.State.GetState(token.address, TokenBalanceSlot(alice, 0))
+	require.Equal(t, common.BigToHash(big.NewInt(42)), stored)
+
+	// Patching the slot is what SetTokenBalance does through anvil_setStorageAt
+	token.cfg.State.SetState(token.address, TokenBalanceSlot(alice, 0), common.BigToHash(big.NewInt(7)))
+	require.Equal(t, big.NewInt(7), token.balanceOf(alice))
+}
+
+func TestSetTokenBalanceWithoutNode(t *testing.T) {
+	server, anvil, tearDown := setupTestingWithMock(t)
+	defer tearDown()
+	server.Respond("anvil_setStorageAt", true)
+
+	token, owner := common.HexToAddress("0x5FbDB2315678afecb367f032d93F642f64180aa3"), common.Address{0xa}
+	require.NoError(t, anvil.SetTokenBalance(context.Background(), token, owner, big.NewInt(1000)))
+	server.ExpectCall(t, "anvil_setStorageAt",
+		token,
+		TokenBalanceSlot(owner, 0),
+		"0x00000000000000000000000000000000000000000000000000000000000003e8",
+	)
+}
+
+func TestTransferEventsOfTestToken(t *testing.T) {
+	client, testData, anvil, tearDown := testClient(t, CapMine, CapSetStorage)
+	defer tearDown()
+	ctx := context.Background()
+
+	sender, err := NewTxSender(client, testData.PrivateKeys[0], t.Name())
+	require.NoError(t, err)
+	receiver := common.HexToAddress(testData.Addresses[1])
+
+	mine := func(tx *types.Transaction) *types.Receipt {
+		require.NoError(t, anvil.MineBlocks(1, DefaultBlockTime))
+		receipt, err := sender.WaitForReceipt(ctx, tx)
+		require.NoError(t, err)
+		require.Equal(t, types.ReceiptStatusSuccessful, receipt.Status)
+		return receipt
+	}
+
+	token, tx, err := DeployTestToken(ctx, sender)
+	require.NoError(t, err)
+	require.Equal(t, token.Address, mine(tx).ContractAddress)
+
+	tx, err = token.Mint(ctx, sender, sender.From(), big.NewInt(1000))
+	require.NoError(t, err)
+	mine(tx)
+	tx, err = token.Transfer(ctx, sender, receiver, big.NewInt(250))
+	require.NoError(t, err)
+	receipt := mine(tx)
+
+	require.Equal(t, 1, len(receipt.Logs))
+	transfer := receipt.Logs[0]
+	require.Equal(t, TransferEventTopic, transfer.Topics[0])
+	require.Equal(t, common.BytesToHash(receiver.Bytes()), transfer.Topics[2])
+	values, err := TestTokenABI.Unpack("Transfer", transfer.Data)
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(250), values[0])
+
+	balance, err := token.BalanceOf(ctx, receiver)
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(250), balance)
+
+	require.NoError(t, anvil.SetTokenBalance(ctx, token.Address, receiver, big.NewInt(5)))
+	balance, err = token.BalanceOf(ctx, receiver)
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(5), balance)
+}
@@ -32,7 +32,7 @@ func TestConvertAddresses(t *testing.T) {
 }
 
 func TestGetBalanceLastBlock(t *testing.T) {
-	client, _, anvil, tearDown := testClientWithBlocks()
+	client, _, anvil, tearDown := testClientWithBlocks(t)
 	defer tearDown()
 
 	addresses, err := anvil.AvailableAddresses()
@@ -45,7 +45,7 @@ func TestGetBalanceLastBlock(t *testing.T) {
 }
 
 func TestGetBalanceFirstBlock(t *testing.T) {
-	client, _, anvil, tearDown := testClientWithBlocks()
+	client, _, anvil, tearDown := testClientWithBlocks(t)
 	defer tearDown()
 
 	addresses, err := anvil.AvailableAddresses()
@@ -56,8 +56,23 @@ func TestGetBalanceFirstBlock(t *testing.T) {
 	require.Equalf(t, 10000.0, balanceToEther(balance), "balance should be 10000 ETH")
 }
 
+func TestGetBalancesOfAllAccountsInOneBatch(t *testing.T) {
+	_, _, anvil, tearDown := testClientWithBlocks(t)
+	defer tearDown()
+
+	addresses, err := anvil.AvailableAddresses()
+	require.NoError(t, err)
+	balances, err := BalancesAt(context.Background(), anvil.Client(), addresses, nil)
+	require.NoError(t, err)
+
+	require.Equal(t, len(addresses), len(balances))
+	for _, address := range addresses {
+		require.Equal(t, 0, ethToWei(anvilDefaultEthBalance).Cmp(balances[address]), "balance should be 10000 ETH")
+	}
+}
+
 func TestHeaderByNumberLast(t *testing.T) {
-	client, _, _, tearDown := testClientWithBlocks()
+	client, _, _, tearDown := testClientWithBlocks(t)
 	defer tearDown()
 
 	lastHeader, err := client.HeaderByNumber(context.Background(), nil)
@@ -73,7 +88,7 @@ func TestHeaderByNumberLast(t *testing.T) {
 }
 
 func TestBlockByNumber(t *testing.T) {
-	client, _, _, tearDown := testClientWithBlocks()
+	client, _, _, tearDown := testClientWithBlocks(t)
 	defer tearDown()
 
 	lastBlock, err := client.BlockByNumber(context.Background(), nil)
@@ -94,7 +109,7 @@ func TestBlockByNumber(t *testing.T) {
 }
 
 func TestGenerateNewWallet(t *testing.T) {
-	_, testData, _, tearDown := testClient()
+	_, testData, _, tearDown := testClient(t)
 	defer tearDown()
 
 	// Generate a random private key
@@ -212,7 +227,7 @@ func TestAddressIsValid(t *testing.T) {
 }
 
 func TestAddressIsFromASmartContract(t *testing.T) {
-	client, _, anvil, tearDown := testClient()
+	client, _, anvil, tearDown := testClient(t)
 	defer tearDown()
 
 	addresses, err := anvil.AvailableAddresses()
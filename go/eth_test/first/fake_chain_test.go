@@ -0,0 +1,244 @@
+package first
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"sync"
+	"testing"
+
+	"first/rpcmock"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+const fakeChainGenesisTime = 1713900000
+
+type fakeBlock struct {
+	hash      common.Hash
+	timestamp uint64
+	txs       []common.Hash
+	logs      []*types.Log
+}
+
+// fakeChain emulates the mining, snapshot and log APIs of Anvil on top of rpcmock.
+// Every transaction included in a block emits a Transfer event from its recipient
+type fakeChain struct {
+	mu            sync.Mutex
+	blocks        []fakeBlock
+	pending       []*types.Transaction
+	snapshots     []int
+	nextTimestamp uint64
+}
+
+func newFakeChain() *fakeChain {
+	return &fakeChain{
+		blocks: []fakeBlock{{hash: crypto.Keccak256Hash([]byte("genesis")), timestamp: fakeChainGenesisTime}},
+	}
+}
+
+func setupTestingWithFakeChain(t *testing.T) (*fakeChain, *rpcmock.Server, *Anvil, func()) {
+	server, anvil, tearDown := setupTestingWithMock(t)
+	chain := newFakeChain()
+	chain.register(server)
+
+	var err error
+	anvil.initialSnapshotId, err = anvil.TakeSnapshot()
+	require.NoError(t, err)
+	return chain, server, anvil, tearDown
+}
+
+func decodeParam(params []json.RawMessage, i int, v interface{}) error {
+	if i >= len(params) {
+		return fmt.Errorf("missing param %d", i)
+	}
+	return json.Unmarshal(params[i], v)
+}
+
+func (c *fakeChain) register(server *rpcmock.Server) {
+	server.Handle("eth_blockNumber", func(params []json.RawMessage) (interface{}, error) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return hexutil.Uint64(len(c.blocks) - 1), nil
+	})
+	server.Handle("eth_getBlockByNumber", func(params []json.RawMessage) (interface{}, error) {
+		var number string
+		if err := decodeParam(params, 0, &number); err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		var blockNo uint64
+		if number == "latest" {
+			blockNo = uint64(len(c.blocks) - 1)
+		} else {
+			n, err := hexutil.DecodeUint64(number)
+			if err != nil {
+				return nil, err
+			}
+			blockNo = n
+		}
+		if blockNo >= uint64(len(c.blocks)) {
+			return nil, nil
+		}
+		return c.blockJSON(blockNo)
+	})
+	server.Handle("evm_snapshot", func(params []json.RawMessage) (interface{}, error) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.snapshots = append(c.snapshots, len(c.blocks))
+		return "0x" + strconv.FormatInt(int64(len(c.snapshots)-1), 16), nil
+	})
+	server.Handle("evm_revert", func(params []json.RawMessage) (interface{}, error) {
+		var id int
+		if err := decodeParam(params, 0, &id); err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if id >= len(c.snapshots) {
+			return false, nil
+		}
+		c.blocks = c.blocks[:c.snapshots[id]]
+		c.snapshots = c.snapshots[:id]
+		c.pending = nil
+		return true, nil
+	})
+	server.Handle("evm_setNextBlockTimestamp", func(params []json.RawMessage) (interface{}, error) {
+		var timestamp hexutil.Uint64
+		if err := decodeParam(params, 0, &timestamp); err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.nextTimestamp = uint64(timestamp)
+		return nil, nil
+	})
+	server.Handle("evm_mine", func(params []json.RawMessage) (interface{}, error) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.mine(1, standardBlockDuration.Seconds())
+		return "0x0", nil
+	})
+	server.Handle("anvil_mine", func(params []json.RawMessage) (interface{}, error) {
+		var count, interval float64
+		if err := decodeParam(params, 0, &count); err != nil {
+			return nil, err
+		}
+		if err := decodeParam(params, 1, &interval); err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.mine(int(count), interval)
+		return nil, nil
+	})
+	server.Handle("eth_sendRawTransaction", func(params []json.RawMessage) (interface{}, error) {
+		var raw hexutil.Bytes
+		if err := decodeParam(params, 0, &raw); err != nil {
+			return nil, err
+		}
+		tx := new(types.Transaction)
+		if err := tx.UnmarshalBinary(raw); err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.pending = append(c.pending, tx)
+		return tx.Hash(), nil
+	})
+	server.Handle("eth_getLogs", func(params []json.RawMessage) (interface{}, error) {
+		var query struct {
+			BlockHash *common.Hash `json:"blockHash"`
+		}
+		if err := decodeParam(params, 0, &query); err != nil {
+			return nil, err
+		}
+		if query.BlockHash == nil {
+			return nil, errors.New("only blockHash queries are supported")
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for _, block := range c.blocks {
+			if block.hash == *query.BlockHash {
+				return append([]*types.Log{}, block.logs...), nil
+			}
+		}
+		return []*types.Log{}, nil
+	})
+}
+
+func (c *fakeChain) mine(count int, interval float64) {
+	for i := 0; i < count; i++ {
+		parent := c.blocks[len(c.blocks)-1]
+		timestamp := parent.timestamp + uint64(interval)
+		if c.nextTimestamp != 0 {
+			timestamp = c.nextTimestamp
+			c.nextTimestamp = 0
+		}
+		number := uint64(len(c.blocks))
+		block := fakeBlock{timestamp: timestamp}
+		seed := append(parent.hash.Bytes(), new(big.Int).SetUint64(timestamp).Bytes()...)
+		for _, tx := range c.pending {
+			seed = append(seed, tx.Hash().Bytes()...)
+		}
+		block.hash = crypto.Keccak256Hash(seed)
+		for txIndex, tx := range c.pending {
+			block.txs = append(block.txs, tx.Hash())
+			block.logs = append(block.logs, &types.Log{
+				Address:     *tx.To(),
+				Topics:      []common.Hash{TransferEventTopic},
+				Data:        common.BigToHash(tx.Value()).Bytes(),
+				BlockNumber: number,
+				TxHash:      tx.Hash(),
+				TxIndex:     uint(txIndex),
+				BlockHash:   block.hash,
+				Index:       uint(txIndex),
+			})
+		}
+		c.pending = nil
+		c.blocks = append(c.blocks, block)
+	}
+}
+
+// blockJSON returns a full header with the fake block and parent hashes
+func (c *fakeChain) blockJSON(number uint64) (map[string]interface{}, error) {
+	block := c.blocks[number]
+	header := &types.Header{
+		Number:     new(big.Int).SetUint64(number),
+		Difficulty: new(big.Int),
+		GasLimit:   30000000,
+		Time:       block.timestamp,
+	}
+	if number > 0 {
+		header.ParentHash = c.blocks[number-1].hash
+	}
+	encoded, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	var res map[string]interface{}
+	err = json.Unmarshal(encoded, &res)
+	if err != nil {
+		return nil, err
+	}
+	res["hash"] = block.hash
+	res["transactions"] = block.txs
+	return res, nil
+}
+
+func (c *fakeChain) hashes() []common.Hash {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	res := make([]common.Hash, 0, len(c.blocks))
+	for _, block := range c.blocks {
+		res = append(res, block.hash)
+	}
+	return res
+}
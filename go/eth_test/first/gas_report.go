@@ -0,0 +1,226 @@
+package first
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	gasMethodTransfer = "transfer (ETH)"
+	gasMethodDeploy   = "deploy"
+	gasContractNone   = "-"
+)
+
+type gasUsage struct {
+	test     string
+	contract string
+	method   string
+	gasUsed  uint64
+}
+
+// GasReporter aggregates the gas used by the transactions seen by the receipt hooks
+type GasReporter struct {
+	mu          sync.Mutex
+	usages      []gasUsage
+	methodNames map[string]string
+}
+
+func NewGasReporter() *GasReporter {
+	return &GasReporter{methodNames: make(map[string]string)}
+}
+
+// RegisterMethods names selectors in the report, e.g. "transfer(address,uint256)"
+func (r *GasReporter) RegisterMethods(signatures ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, signature := range signatures {
+		r.methodNames[hexutil.Encode(MethodSelector(signature))] = signature
+	}
+}
+
+// Record is a ReceiptHook
+func (r *GasReporter) Record(test string, tx *types.Transaction, receipt *types.Receipt) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	usage := gasUsage{test: test, gasUsed: receipt.GasUsed}
+	switch {
+	case tx.To() == nil:
+		usage.contract = receipt.ContractAddress.Hex()
+		usage.method = gasMethodDeploy
+	case len(tx.Data()) < 4:
+		usage.contract = gasContractNone
+		usage.method = gasMethodTransfer
+	default:
+		usage.contract = tx.To().Hex()
+		selector := hexutil.Encode(tx.Data()[:4])
+		usage.method = selector
+		if name, found := r.methodNames[selector]; found {
+			usage.method = name
+		}
+	}
+	r.usages = append(r.usages, usage)
+}
+
+type MethodGas struct {
+	Contract string `json:"contract"`
+	Method   string `json:"method"`
+	Calls    int    `json:"calls"`
+	Min      uint64 `json:"min"`
+	Max      uint64 `json:"max"`
+	Avg      uint64 `json:"avg"`
+	Total    uint64 `json:"total"`
+}
+
+func (m *MethodGas) key() string {
+	return m.Contract + " " + m.Method
+}
+
+type TestGas struct {
+	Test  string `json:"test"`
+	Calls int    `json:"calls"`
+	Total uint64 `json:"total"`
+}
+
+type GasReport struct {
+	Methods []MethodGas `json:"methods"`
+	Tests   []TestGas   `json:"tests"`
+}
+
+// Report aggregates the recorded usage per contract method and per test
+func (r *GasReporter) Report() *GasReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	methods := make(map[string]*MethodGas)
+	tests := make(map[string]*TestGas)
+	for _, usage := range r.usages {
+		method := &MethodGas{Contract: usage.contract, Method: usage.method}
+		if existing, found := methods[method.key()]; found {
+			method = existing
+		} else {
+			method.Min = usage.gasUsed
+			methods[method.key()] = method
+		}
+		method.Calls++
+		method.Total += usage.gasUsed
+		if usage.gasUsed < method.Min {
+			method.Min = usage.gasUsed
+		}
+		if usage.gasUsed > method.Max {
+			method.Max = usage.gasUsed
+		}
+
+		test, found := tests[usage.test]
+		if !found {
+			test = &TestGas{Test: usage.test}
+			tests[usage.test] = test
+		}
+		test.Calls++
+		test.Total += usage.gasUsed
+	}
+
+	report := &GasReport{Methods: make([]MethodGas, 0, len(methods)), Tests: make([]TestGas, 0, len(tests))}
+	for _, method := range methods {
+		method.Avg = method.Total / uint64(method.Calls)
+		report.Methods = append(report.Methods, *method)
+	}
+	for _, test := range tests {
+		report.Tests = append(report.Tests, *test)
+	}
+	sort.Slice(report.Methods, func(i, j int) bool { return report.Methods[i].key() < report.Methods[j].key() })
+	sort.Slice(report.Tests, func(i, j int) bool { return report.Tests[i].Test < report.Tests[j].Test })
+	return report
+}
+
+func LoadGasReport(path string) (*GasReport, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var report GasReport
+	err = json.Unmarshal(content, &report)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gas report %s: %w", path, err)
+	}
+	return &report, nil
+}
+
+func (rep *GasReport) WriteJSON(path string) error {
+	content, err := json.MarshalIndent(rep, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(content, '\n'), 0644)
+}
+
+func (rep *GasReport) WriteMarkdown(w io.Writer) error {
+	var buf strings.Builder
+	buf.WriteString("## Gas usage per method\n\n")
+	buf.WriteString("| Contract | Method | Calls | Min | Max | Avg | Total |\n")
+	buf.WriteString("|---|---|---:|---:|---:|---:|---:|\n")
+	for _, m := range rep.Methods {
+		fmt.Fprintf(&buf, "| %s | %s | %d | %d | %d | %d | %d |\n", m.Contract, m.Method, m.Calls, m.Min, m.Max, m.Avg, m.Total)
+	}
+	buf.WriteString("\n## Gas usage per test\n\n")
+	buf.WriteString("| Test | Transactions | Total |\n")
+	buf.WriteString("|---|---:|---:|\n")
+	for _, test := range rep.Tests {
+		fmt.Fprintf(&buf, "| %s | %d | %d |\n", test.Test, test.Calls, test.Total)
+	}
+	_, err := io.WriteString(w, buf.String())
+	return err
+}
+
+type GasRegression struct {
+	// Name is the method, as "<contract> <method>", or the test that regressed
+	Name     string
+	Baseline uint64
+	Current  uint64
+}
+
+func (g GasRegression) String() string {
+	return fmt.Sprintf("%s: %d -> %d (+%.2f%%)", g.Name, g.Baseline, g.Current, exceededBy(g.Baseline, g.Current)*100)
+}
+
+func exceededBy(baseline uint64, current uint64) float64 {
+	if baseline == 0 {
+		return 0
+	}
+	return float64(current)/float64(baseline) - 1
+}
+
+// CompareGasReports returns the methods, by average gas, and the tests, by
+// total gas, that use more than threshold (0.05 is 5%) over the baseline.
+// Entries missing from either report are ignored
+func CompareGasReports(baseline *GasReport, current *GasReport, threshold float64) []GasRegression {
+	res := make([]GasRegression, 0)
+	baselineMethods := make(map[string]MethodGas, len(baseline.Methods))
+	for _, m := range baseline.Methods {
+		baselineMethods[m.key()] = m
+	}
+	for _, m := range current.Methods {
+		if base, found := baselineMethods[m.key()]; found && exceededBy(base.Avg, m.Avg) > threshold {
+			res = append(res, GasRegression{Name: m.key(), Baseline: base.Avg, Current: m.Avg})
+		}
+	}
+
+	baselineTests := make(map[string]TestGas, len(baseline.Tests))
+	for _, test := range baseline.Tests {
+		baselineTests[test.Test] = test
+	}
+	for _, test := range current.Tests {
+		if base, found := baselineTests[test.Test]; found && exceededBy(base.Total, test.Total) > threshold {
+			res = append(res, GasRegression{Name: test.Test, Baseline: base.Total, Current: test.Total})
+		}
+	}
+	return res
+}
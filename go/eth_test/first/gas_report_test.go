@@ -0,0 +1,139 @@
+package first
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+)
+
+func testCallTx(to common.Address, data []byte) *types.Transaction {
+	return types.NewTx(&types.DynamicFeeTx{To: &to, Data: data, Value: new(big.Int)})
+}
+
+func TestGasReporterAggregatesPerMethodAndTest(t *testing.T) {
+	reporter := NewGasReporter()
+	reporter.RegisterMethods("transfer(address,uint256)")
+	token := common.HexToAddress("0x5FbDB2315678afecb367f032d93F642f64180aa3")
+
+	transfer := MethodSelector("transfer(address,uint256)")
+	approve := MethodSelector("approve(address,uint256)")
+	reporter.Record("TestA", testCallTx(token, transfer), &types.Receipt{GasUsed: 50000})
+	reporter.Record("TestA", testCallTx(token, transfer), &types.Receipt{GasUsed: 30000})
+	reporter.Record("TestB", testCallTx(token, approve), &types.Receipt{GasUsed: 46000})
+	reporter.Record("TestB", testCallTx(common.Address{1}, nil), &types.Receipt{GasUsed: 21000})
+	reporter.Record("TestB", types.NewTx(&types.DynamicFeeTx{Data: []byte{0x60}}), &types.Receipt{GasUsed: 120000, ContractAddress: token})
+
+	report := reporter.Report()
+	require.Equal(t, []MethodGas{
+		{Contract: "-", Method: "transfer (ETH)", Calls: 1, Min: 21000, Max: 21000, Avg: 21000, Total: 21000},
+		{Contract: token.Hex(), Method: "0x095ea7b3", Calls: 1, Min: 46000, Max: 46000, Avg: 46000, Total: 46000},
+		{Contract: token.Hex(), Method: "deploy", Calls: 1, Min: 120000, Max: 120000, Avg: 120000, Total: 120000},
+		{Contract: token.Hex(), Method: "transfer(address,uint256)", Calls: 2, Min: 30000, Max: 50000, Avg: 40000, Total: 80000},
+	}, report.Methods)
+	require.Equal(t, []TestGas{
+		{Test: "TestA", Calls: 2, Total: 80000},
+		{Test: "TestB", Calls: 3, Total: 187000},
+	}, report.Tests)
+
+	var markdown strings.Builder
+	require.NoError(t, report.WriteMarkdown(&markdown))
+	require.Contains(t, markdown.String(), "| "+token.Hex()+" | transfer(address,uint256) | 2 | 30000 | 50000 | 40000 | 80000 |")
+	require.Contains(t, markdown.String(), "| TestB | 3 | 187000 |")
+
+	path := filepath.Join(t.TempDir(), "gas.json")
+	require.NoError(t, report.WriteJSON(path))
+	loaded, err := LoadGasReport(path)
+	require.NoError(t, err)
+	require.Equal(t, report, loaded)
+}
+
+func TestCompareGasReports(t *testing.T) {
+	baseline := &GasReport{
+		Methods: []MethodGas{{Contract: "-", Method: "transfer (ETH)", Avg: 21000}, {Contract: "0x1", Method: "mint", Avg: 1000}},
+		Tests:   []TestGas{{Test: "TestA", Total: 10000}},
+	}
+	current := &GasReport{
+		Methods: []MethodGas{{Contract: "-", Method: "transfer (ETH)", Avg: 21000}, {Contract: "0x1", Method: "mint", Avg: 1100}, {Contract: "0x2", Method: "new", Avg: 5}},
+		Tests:   []TestGas{{Test: "TestA", Total: 10400}},
+	}
+
+	regressions := CompareGasReports(baseline, current, 0.05)
+	require.Equal(t, []GasRegression{{Name: "0x1 mint", Baseline: 1000, Current: 1100}}, regressions)
+	require.Equal(t, "0x1 mint: 1000 -> 1100 (+10.00%)", regressions[0].String())
+
+	require.Equal(t, 2, len(CompareGasReports(baseline, current, 0.01)))
+	require.Empty(t, CompareGasReports(baseline, current, 0.2))
+}
+
+func TestTxSenderNotifiesReceiptHooks(t *testing.T) {
+	server, anvil, tearDown := setupTestingWithMock(t)
+	defer tearDown()
+	server.Respond("eth_getTransactionCount", "0x3")
+	server.Respond("eth_maxPriorityFeePerGas", "0x3b9aca00")
+	server.Respond("eth_gasPrice", "0x77359400")
+	server.Respond("eth_estimateGas", "0x5208")
+
+	var sent *types.Transaction
+	server.Handle("eth_sendRawTransaction", func(params []json.RawMessage) (interface{}, error) {
+		var raw hexutil.Bytes
+		require.NoError(t, json.Unmarshal(params[0], &raw))
+		sent = new(types.Transaction)
+		require.NoError(t, sent.UnmarshalBinary(raw))
+		return sent.Hash(), nil
+	})
+	receiptRequests := 0
+	server.Handle("eth_getTransactionReceipt", func(params []json.RawMessage) (interface{}, error) {
+		receiptRequests++
+		// Not mined on the first poll
+		if receiptRequests == 1 {
+			return nil, nil
+		}
+		return &types.Receipt{Status: types.ReceiptStatusSuccessful, GasUsed: 21000, CumulativeGasUsed: 21000, Logs: []*types.Log{}, TxHash: sent.Hash()}, nil
+	})
+
+	reporter := NewGasReporter()
+	t.Cleanup(AddReceiptHook(reporter.Record))
+
+	sender, err := NewTxSender(anvil.EthClient(), "0xac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80", t.Name())
+	require.NoError(t, err)
+	require.Equal(t, common.HexToAddress("0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266"), sender.From())
+	require.Equal(t, big.NewInt(31337), sender.ChainID())
+
+	to := common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8")
+	tx, err := sender.Send(context.Background(), &to, ethToWei(1), nil)
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), tx.Nonce())
+	require.Equal(t, uint64(21000), tx.Gas())
+	require.Equal(t, sent.Hash(), tx.Hash())
+
+	receipt, err := sender.WaitForReceipt(context.Background(), tx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(21000), receipt.GasUsed)
+	require.Equal(t, 2, receiptRequests)
+
+	require.Equal(t, []TestGas{{Test: t.Name(), Calls: 1, Total: 21000}}, reporter.Report().Tests)
+}
+
+func TestRemovedReceiptHookIsNotCalled(t *testing.T) {
+	var calls []string
+	record := func(label string, tx *types.Transaction, receipt *types.Receipt) {
+		calls = append(calls, label)
+	}
+	removeFirst := AddReceiptHook(record)
+	removeSecond := AddReceiptHook(record)
+	defer removeSecond()
+
+	notifyReceiptHooks("a", nil, nil)
+	removeFirst()
+	removeFirst()
+	notifyReceiptHooks("b", nil, nil)
+	require.Equal(t, []string{"a", "a", "b"}, calls)
+}
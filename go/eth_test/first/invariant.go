@@ -0,0 +1,282 @@
+package first
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	defaultInvariantRuns    = 20
+	defaultInvariantDepth   = 10
+	defaultInvariantShrinks = 200
+)
+
+// ErrSkipAction is returned by actions that don't apply to the current state, e.g. a
+// transfer from an account without funds. Skipped actions aren't failures
+var ErrSkipAction = errors.New("action skipped")
+
+// Action is a step of a generated sequence. Actions carry their parameters so that a
+// sequence can be replayed
+type Action interface {
+	Apply(ctx context.Context, run *InvariantRun) error
+	String() string
+}
+
+// ShrinkableAction proposes simpler variants of itself, e.g. smaller values
+type ShrinkableAction interface {
+	Action
+	Shrink() []Action
+}
+
+// ActionGenerator draws a random action
+type ActionGenerator func(rng *rand.Rand) Action
+
+// Invariant is checked before the first action and after each step
+type Invariant struct {
+	Name  string
+	Check func(ctx context.Context, run *InvariantRun) error
+}
+
+// InvariantRun is the state of the sequence being executed, shared by actions and invariants
+type InvariantRun struct {
+	// Step is the number of actions applied so far
+	Step int
+	// Receipts of the transactions sent by the actions of the sequence
+	Receipts []*types.Receipt
+}
+
+// AddReceipt records the receipt of a transaction sent by an action
+func (r *InvariantRun) AddReceipt(receipt *types.Receipt) {
+	r.Receipts = append(r.Receipts, receipt)
+}
+
+// GasFees is the total paid for the gas of the recorded transactions
+func (r *InvariantRun) GasFees() *big.Int {
+	res := new(big.Int)
+	for _, receipt := range r.Receipts {
+		res.Add(res, TransactionFee(receipt))
+	}
+	return res
+}
+
+// Snapshotter restores the chain between sequences. The Anvil dev API drops a snapshot
+// when reverting to it, hence the runner takes a new one after each revert
+type Snapshotter interface {
+	TakeSnapshot() (snapshotId int, err error)
+	RevertSnapshot(snapshotId int) error
+}
+
+// InvariantFailure is a failing sequence, shrunk to a minimal reproduction
+type InvariantFailure struct {
+	Seed int64
+	// Sequence is the shrunk sequence, it fails at its last action
+	Sequence []Action
+	// OriginalLength is the number of actions applied before the failure, before shrinking
+	OriginalLength int
+	// Invariant is the name of the broken invariant; empty if an action failed
+	Invariant string
+	Err       error
+}
+
+func (f *InvariantFailure) Error() string {
+	var sb strings.Builder
+	if f.Invariant != "" {
+		fmt.Fprintf(&sb, "invariant %q broken: %s\n", f.Invariant, f.Err)
+	} else {
+		fmt.Fprintf(&sb, "action failed: %s\n", f.Err)
+	}
+	fmt.Fprintf(&sb, "seed %d, shrunk from %d to %d actions:", f.Seed, f.OriginalLength, len(f.Sequence))
+	for i, action := range f.Sequence {
+		fmt.Fprintf(&sb, "\n  %d. %s", i+1, action)
+	}
+	return sb.String()
+}
+
+func (f *InvariantFailure) Unwrap() error {
+	return f.Err
+}
+
+// InvariantRunner applies random sequences of actions from a snapshot of the chain and
+// checks the invariants after each step. Failing sequences are shrunk
+type InvariantRunner struct {
+	// Runs is the number of sequences to try
+	Runs int
+	// Depth is the number of actions of a sequence
+	Depth int
+	// Seed of the generator; a time based seed is used if 0
+	Seed int64
+	// MaxShrinks bounds the number of replays spent on shrinking
+	MaxShrinks int
+
+	chain      Snapshotter
+	generators []ActionGenerator
+	invariants []Invariant
+
+	snapshotId int
+}
+
+func NewInvariantRunner(chain Snapshotter, generators []ActionGenerator, invariants ...Invariant) *InvariantRunner {
+	return &InvariantRunner{
+		Runs:       defaultInvariantRuns,
+		Depth:      defaultInvariantDepth,
+		MaxShrinks: defaultInvariantShrinks,
+		chain:      chain,
+		generators: generators,
+		invariants: invariants,
+	}
+}
+
+// Run returns an *InvariantFailure for the first failing sequence. The chain is left
+// in the state it had before the call
+func (r *InvariantRunner) Run(ctx context.Context) (err error) {
+	seed := r.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	rng := rand.New(rand.NewSource(seed))
+
+	r.snapshotId, err = r.chain.TakeSnapshot()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		revertErr := r.chain.RevertSnapshot(r.snapshotId)
+		if err == nil {
+			err = revertErr
+		}
+	}()
+
+	for run := 0; run < r.Runs; run++ {
+		sequence := make([]Action, 0, r.Depth)
+		for i := 0; i < r.Depth; i++ {
+			sequence = append(sequence, r.generators[rng.Intn(len(r.generators))](rng))
+		}
+		failure, err := r.execute(ctx, sequence)
+		if err != nil {
+			return err
+		}
+		if failure != nil {
+			failure.Seed = seed
+			failure.OriginalLength = len(failure.Sequence)
+			return r.shrink(ctx, failure)
+		}
+	}
+	return nil
+}
+
+// reset reverts to the initial state and snapshots it again
+func (r *InvariantRunner) reset() (err error) {
+	err = r.chain.RevertSnapshot(r.snapshotId)
+	if err != nil {
+		return err
+	}
+	r.snapshotId, err = r.chain.TakeSnapshot()
+	return err
+}
+
+// execute replays sequence from the initial state. A failure holds the sequence up to the failing action
+func (r *InvariantRunner) execute(ctx context.Context, sequence []Action) (*InvariantFailure, error) {
+	err := r.reset()
+	if err != nil {
+		return nil, err
+	}
+	run := &InvariantRun{}
+	failure := r.check(ctx, run)
+	if failure != nil {
+		return nil, fmt.Errorf("invariant %q doesn't hold before any action: %w", failure.Invariant, failure.Err)
+	}
+	for i, action := range sequence {
+		err = action.Apply(ctx, run)
+		run.Step = i + 1
+		if errors.Is(err, ErrSkipAction) {
+			continue
+		}
+		if err != nil {
+			return &InvariantFailure{Sequence: sequence[:i+1], Err: err}, nil
+		}
+		failure = r.check(ctx, run)
+		if failure != nil {
+			failure.Sequence = sequence[:i+1]
+			return failure, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *InvariantRunner) check(ctx context.Context, run *InvariantRun) *InvariantFailure {
+	for _, invariant := range r.invariants {
+		err := invariant.Check(ctx, run)
+		if err != nil {
+			return &InvariantFailure{Invariant: invariant.Name, Err: err}
+		}
+	}
+	return nil
+}
+
+// shrink removes chunks of actions, then simplifies the remaining ones, as long as the
+// sequence still breaks the same invariant
+func (r *InvariantRunner) shrink(ctx context.Context, failure *InvariantFailure) error {
+	replays := 0
+	// try replaces failure if candidate fails the same way
+	try := func(candidate []Action) (bool, error) {
+		replays++
+		res, err := r.execute(ctx, candidate)
+		if err != nil || res == nil || res.Invariant != failure.Invariant {
+			return false, err
+		}
+		failure.Sequence, failure.Err = res.Sequence, res.Err
+		return true, nil
+	}
+
+	for progress := true; progress && replays < r.MaxShrinks; {
+		progress = false
+		for chunk := len(failure.Sequence) / 2; chunk >= 1 && replays < r.MaxShrinks; chunk /= 2 {
+			for start := 0; start+chunk <= len(failure.Sequence) && replays < r.MaxShrinks; {
+				sequence := failure.Sequence
+				candidate := append(append([]Action{}, sequence[:start]...), sequence[start+chunk:]...)
+				shrunk, err := try(candidate)
+				if err != nil {
+					return err
+				}
+				if shrunk {
+					progress = true
+				} else {
+					start++
+				}
+			}
+		}
+		for i := 0; i < len(failure.Sequence) && replays < r.MaxShrinks; i++ {
+			shrinkable, ok := failure.Sequence[i].(ShrinkableAction)
+			if !ok {
+				continue
+			}
+			for _, simpler := range shrinkable.Shrink() {
+				candidate := append([]Action{}, failure.Sequence...)
+				candidate[i] = simpler
+				shrunk, err := try(candidate)
+				if err != nil {
+					return err
+				}
+				if shrunk {
+					progress = true
+					break
+				}
+			}
+		}
+	}
+	return failure
+}
+
+// RequireInvariants runs runner and fails the test with the shrunk reproduction
+func RequireInvariants(t require.TestingT, runner *InvariantRunner) {
+	err := runner.Run(context.Background())
+	require.NoError(t, err)
+}
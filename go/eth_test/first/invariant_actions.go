@@ -0,0 +1,251 @@
+package first
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// randomAmount draws an amount in [1, max]
+func randomAmount(rng *rand.Rand, max *big.Int) *big.Int {
+	return new(big.Int).Add(new(big.Int).Rand(rng, max), big.NewInt(1))
+}
+
+// shrinkAmount proposes 1 and half of amount
+func shrinkAmount(amount *big.Int) []*big.Int {
+	var res []*big.Int
+	if amount.Cmp(big.NewInt(1)) > 0 {
+		res = append(res, big.NewInt(1))
+	}
+	if half := new(big.Int).Rsh(amount, 1); half.Cmp(big.NewInt(1)) > 0 {
+		res = append(res, half)
+	}
+	return res
+}
+
+// mineAndRecord mines the pending transaction and records its receipt
+func mineAndRecord(ctx context.Context, anvil *Anvil, sender *TxSender, tx *types.Transaction, run *InvariantRun) error {
+	err := anvil.MineBlocks(1, DefaultBlockTime)
+	if err != nil {
+		return err
+	}
+	receipt, err := sender.WaitForReceipt(ctx, tx)
+	if err != nil {
+		return err
+	}
+	run.AddReceipt(receipt)
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return fmt.Errorf("transaction %s failed", tx.Hash())
+	}
+	return nil
+}
+
+type transferAction struct {
+	anvil  *Anvil
+	sender *TxSender
+	to     common.Address
+	value  *big.Int
+}
+
+func (a *transferAction) Apply(ctx context.Context, run *InvariantRun) error {
+	tx, err := a.sender.Send(ctx, &a.to, a.value, nil)
+	if err != nil {
+		return err
+	}
+	return mineAndRecord(ctx, a.anvil, a.sender, tx, run)
+}
+
+func (a *transferAction) String() string {
+	return fmt.Sprintf("transfer %s wei from %s to %s", a.value, a.sender.From(), a.to)
+}
+
+func (a *transferAction) Shrink() []Action {
+	var res []Action
+	for _, value := range shrinkAmount(a.value) {
+		res = append(res, &transferAction{a.anvil, a.sender, a.to, value})
+	}
+	return res
+}
+
+// TransferActions sends up to maxValue wei between random senders and recipients, each in its own block
+func TransferActions(anvil *Anvil, senders []*TxSender, recipients []common.Address, maxValue *big.Int) ActionGenerator {
+	return func(rng *rand.Rand) Action {
+		return &transferAction{
+			anvil:  anvil,
+			sender: senders[rng.Intn(len(senders))],
+			to:     recipients[rng.Intn(len(recipients))],
+			value:  randomAmount(rng, maxValue),
+		}
+	}
+}
+
+type tokenTransferAction struct {
+	anvil  *Anvil
+	token  *Token
+	sender *TxSender
+	to     common.Address
+	amount *big.Int
+}
+
+func (a *tokenTransferAction) Apply(ctx context.Context, run *InvariantRun) error {
+	balance, err := a.token.BalanceOf(ctx, a.sender.From())
+	if err != nil {
+		return err
+	}
+	if balance.Cmp(a.amount) < 0 {
+		return ErrSkipAction
+	}
+	tx, err := a.token.Transfer(ctx, a.sender, a.to, a.amount)
+	if err != nil {
+		return err
+	}
+	return mineAndRecord(ctx, a.anvil, a.sender, tx, run)
+}
+
+func (a *tokenTransferAction) String() string {
+	return fmt.Sprintf("transfer %s of token %s from %s to %s", a.amount, a.token.Address, a.sender.From(), a.to)
+}
+
+func (a *tokenTransferAction) Shrink() []Action {
+	var res []Action
+	for _, amount := range shrinkAmount(a.amount) {
+		res = append(res, &tokenTransferAction{a.anvil, a.token, a.sender, a.to, amount})
+	}
+	return res
+}
+
+// TokenTransferActions calls transfer on token with up to maxAmount. Transfers exceeding the
+// sender's balance are skipped
+func TokenTransferActions(anvil *Anvil, token *Token, senders []*TxSender, recipients []common.Address, maxAmount *big.Int) ActionGenerator {
+	return func(rng *rand.Rand) Action {
+		return &tokenTransferAction{
+			anvil:  anvil,
+			token:  token,
+			sender: senders[rng.Intn(len(senders))],
+			to:     recipients[rng.Intn(len(recipients))],
+			amount: randomAmount(rng, maxAmount),
+		}
+	}
+}
+
+type warpAction struct {
+	anvil    *Anvil
+	duration time.Duration
+}
+
+func (a *warpAction) Apply(ctx context.Context, run *InvariantRun) error {
+	_, err := a.anvil.IncreaseTime(a.duration)
+	return err
+}
+
+func (a *warpAction) String() string {
+	return fmt.Sprintf("warp %s", a.duration)
+}
+
+func (a *warpAction) Shrink() []Action {
+	if a.duration <= time.Second {
+		return nil
+	}
+	return []Action{&warpAction{a.anvil, time.Second}, &warpAction{a.anvil, (a.duration / 2).Truncate(time.Second)}}
+}
+
+// WarpActions moves the time of the next block forward by up to maxWarp, in seconds
+func WarpActions(anvil *Anvil, maxWarp time.Duration) ActionGenerator {
+	return func(rng *rand.Rand) Action {
+		return &warpAction{anvil, time.Duration(rng.Int63n(int64(maxWarp/time.Second))+1) * time.Second}
+	}
+}
+
+type mineAction struct {
+	anvil  *Anvil
+	blocks int
+}
+
+func (a *mineAction) Apply(ctx context.Context, run *InvariantRun) error {
+	return a.anvil.MineBlocks(a.blocks, DefaultBlockTime)
+}
+
+func (a *mineAction) String() string {
+	return fmt.Sprintf("mine %d blocks", a.blocks)
+}
+
+func (a *mineAction) Shrink() []Action {
+	if a.blocks <= 1 {
+		return nil
+	}
+	return []Action{&mineAction{a.anvil, 1}}
+}
+
+// MineActions mines up to maxBlocks empty blocks
+func MineActions(anvil *Anvil, maxBlocks int) ActionGenerator {
+	return func(rng *rand.Rand) Action {
+		return &mineAction{anvil, rng.Intn(maxBlocks) + 1}
+	}
+}
+
+func totalBalance(ctx context.Context, client *ethclient.Client, accounts []common.Address) (*big.Int, error) {
+	total := new(big.Int)
+	for _, account := range accounts {
+		balance, err := client.BalanceAt(ctx, account, nil)
+		if err != nil {
+			return nil, err
+		}
+		total.Add(total, balance)
+	}
+	return total, nil
+}
+
+// BalanceConserved checks that the total balance of accounts only decreases by the gas
+// fees of the sequence. Transfers must stay between accounts
+func BalanceConserved(client *ethclient.Client, accounts []common.Address) Invariant {
+	var initial *big.Int
+	return Invariant{
+		Name: "balance conserved minus gas",
+		Check: func(ctx context.Context, run *InvariantRun) error {
+			total, err := totalBalance(ctx, client, accounts)
+			if err != nil {
+				return err
+			}
+			if run.Step == 0 {
+				initial = total
+				return nil
+			}
+			expected := new(big.Int).Sub(initial, run.GasFees())
+			if total.Cmp(expected) != 0 {
+				return fmt.Errorf("total balance is %s, expected %s", total, expected)
+			}
+			return nil
+		},
+	}
+}
+
+// TokenSupplyConserved checks that the balances of holders add up to the total supply of token
+func TokenSupplyConserved(token *Token, holders []common.Address) Invariant {
+	return Invariant{
+		Name: "token supply held by the holders",
+		Check: func(ctx context.Context, run *InvariantRun) error {
+			supply, err := token.TotalSupply(ctx)
+			if err != nil {
+				return err
+			}
+			total := new(big.Int)
+			for _, holder := range holders {
+				balance, err := token.BalanceOf(ctx, holder)
+				if err != nil {
+					return err
+				}
+				total.Add(total, balance)
+			}
+			if total.Cmp(supply) != 0 {
+				return fmt.Errorf("holders have %s tokens, the total supply is %s", total, supply)
+			}
+			return nil
+		},
+	}
+}
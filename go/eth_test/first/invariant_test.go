@@ -0,0 +1,193 @@
+package first
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryChain is a counter with the snapshot semantics of the Anvil dev API
+type memoryChain struct {
+	counter   int
+	snapshots map[int]int
+	nextId    int
+}
+
+func newMemoryChain() *memoryChain {
+	return &memoryChain{snapshots: make(map[int]int)}
+}
+
+func (c *memoryChain) TakeSnapshot() (int, error) {
+	c.nextId++
+	c.snapshots[c.nextId] = c.counter
+	return c.nextId, nil
+}
+
+func (c *memoryChain) RevertSnapshot(snapshotId int) error {
+	counter, found := c.snapshots[snapshotId]
+	if !found {
+		return errors.New("unknown snapshot")
+	}
+	delete(c.snapshots, snapshotId)
+	c.counter = counter
+	return nil
+}
+
+type addAction struct {
+	chain *memoryChain
+	value int
+}
+
+func (a *addAction) Apply(ctx context.Context, run *InvariantRun) error {
+	if a.value < 0 {
+		return errors.New("negative value")
+	}
+	a.chain.counter += a.value
+	return nil
+}
+
+func (a *addAction) String() string {
+	return fmt.Sprintf("add %d", a.value)
+}
+
+func (a *addAction) Shrink() []Action {
+	if a.value <= 1 {
+		return nil
+	}
+	return []Action{&addAction{a.chain, 1}, &addAction{a.chain, a.value / 2}}
+}
+
+type noopAction struct{}
+
+func (noopAction) Apply(ctx context.Context, run *InvariantRun) error { return ErrSkipAction }
+
+func (noopAction) String() string { return "noop" }
+
+func counterBelow(chain *memoryChain, limit int) Invariant {
+	return Invariant{
+		Name: fmt.Sprintf("counter below %d", limit),
+		Check: func(ctx context.Context, run *InvariantRun) error {
+			if chain.counter >= limit {
+				return fmt.Errorf("counter is %d", chain.counter)
+			}
+			return nil
+		},
+	}
+}
+
+func counterGenerators(chain *memoryChain, maxValue int) []ActionGenerator {
+	return []ActionGenerator{
+		func(rng *rand.Rand) Action { return &addAction{chain, rng.Intn(maxValue) + 1} },
+		func(rng *rand.Rand) Action { return noopAction{} },
+	}
+}
+
+func TestInvariantRunnerPassesAndRestoresChain(t *testing.T) {
+	chain := newMemoryChain()
+	chain.counter = 5
+	runner := NewInvariantRunner(chain, counterGenerators(chain, 3), counterBelow(chain, 1000))
+	runner.Seed = 1
+
+	require.NoError(t, runner.Run(context.Background()))
+	require.Equal(t, 5, chain.counter)
+	require.Empty(t, chain.snapshots)
+}
+
+func TestInvariantRunnerShrinksFailingSequence(t *testing.T) {
+	chain := newMemoryChain()
+	runner := NewInvariantRunner(chain, counterGenerators(chain, 50), counterBelow(chain, 100))
+	runner.Seed = 42
+	runner.Depth = 30
+
+	err := runner.Run(context.Background())
+	var failure *InvariantFailure
+	require.ErrorAs(t, err, &failure)
+	require.Equal(t, "counter below 100", failure.Invariant)
+	require.Equal(t, int64(42), failure.Seed)
+	require.Less(t, len(failure.Sequence), failure.OriginalLength)
+
+	total := 0
+	for _, action := range failure.Sequence {
+		add, ok := action.(*addAction)
+		require.True(t, ok, "noops are removed, got %s", action)
+		total += add.value
+	}
+	require.GreaterOrEqual(t, total, 100)
+	// Every action is needed: without the smallest one the counter stays below the limit
+	require.LessOrEqual(t, len(failure.Sequence), 4)
+	require.Equal(t, 0, chain.counter)
+
+	// Same seed, same reproduction
+	chain.counter = 0
+	require.Equal(t, err.Error(), runner.Run(context.Background()).Error())
+}
+
+func TestInvariantRunnerReportsFailingActions(t *testing.T) {
+	chain := newMemoryChain()
+	generators := []ActionGenerator{
+		func(rng *rand.Rand) Action { return &addAction{chain, rng.Intn(10) - 2} },
+	}
+	runner := NewInvariantRunner(chain, generators, counterBelow(chain, 1000))
+	runner.Seed = 7
+
+	err := runner.Run(context.Background())
+	var failure *InvariantFailure
+	require.ErrorAs(t, err, &failure)
+	require.Empty(t, failure.Invariant)
+	require.EqualError(t, failure.Err, "negative value")
+	require.Equal(t, 1, len(failure.Sequence))
+	require.Contains(t, err.Error(), "action failed: negative value")
+}
+
+func TestInvariantRunnerRequiresInitialInvariants(t *testing.T) {
+	chain := newMemoryChain()
+	chain.counter = 200
+	runner := NewInvariantRunner(chain, counterGenerators(chain, 1), counterBelow(chain, 100))
+
+	err := runner.Run(context.Background())
+	require.ErrorContains(t, err, "doesn't hold before any action")
+	require.Equal(t, 200, chain.counter)
+}
+
+func TestInvariantTransfersConserveBalance(t *testing.T) {
+	client, testData, anvil, tearDown := testClient(t, CapMine, CapSnapshot, CapIncreaseTime)
+	defer tearDown()
+	ctx := context.Background()
+
+	accounts := make([]common.Address, 0, len(testData.PrivateKeys))
+	senders := make([]*TxSender, 0, len(testData.PrivateKeys))
+	for _, key := range testData.PrivateKeys {
+		sender, err := NewTxSender(client, key, t.Name())
+		require.NoError(t, err)
+		senders = append(senders, sender)
+		accounts = append(accounts, sender.From())
+	}
+
+	token, tx, err := DeployTestToken(ctx, senders[0])
+	require.NoError(t, err)
+	require.NoError(t, mineAndRecord(ctx, anvil, senders[0], tx, &InvariantRun{}))
+	tx, err = token.Mint(ctx, senders[0], accounts[0], big.NewInt(1000))
+	require.NoError(t, err)
+	require.NoError(t, mineAndRecord(ctx, anvil, senders[0], tx, &InvariantRun{}))
+
+	runner := NewInvariantRunner(anvil,
+		[]ActionGenerator{
+			TransferActions(anvil, senders, accounts, ethToWei(10)),
+			TokenTransferActions(anvil, token, senders, accounts, big.NewInt(500)),
+			WarpActions(anvil, time.Hour),
+			MineActions(anvil, 5),
+		},
+		BalanceConserved(client, accounts),
+		TokenSupplyConserved(token, accounts),
+	)
+	runner.Runs = 3
+	runner.Depth = 8
+	RequireInvariants(t, runner)
+}
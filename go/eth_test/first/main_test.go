@@ -0,0 +1,84 @@
+package first
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"testing"
+)
+
+const (
+	defaultGasBaseline         = "testdata/gas_baseline.json"
+	defaultGasRegressionMargin = 5.0
+)
+
+// TestMain writes a gas report when GAS_REPORT is set to an output path without extension.
+// The report is compared with GAS_BASELINE (testdata/gas_baseline.json by default) and
+// the run fails if any method or test uses more than GAS_THRESHOLD percent (5 by default) over it
+func TestMain(m *testing.M) {
+	reportPath := os.Getenv("GAS_REPORT")
+	if reportPath == "" {
+		os.Exit(m.Run())
+	}
+
+	reporter := NewGasReporter()
+	reporter.RegisterMethods(
+		"transfer(address,uint256)",
+		"approve(address,uint256)",
+		"transferFrom(address,address,uint256)",
+		"mint(address,uint256)",
+	)
+	removeHook := AddReceiptHook(reporter.Record)
+
+	code := m.Run()
+	removeHook()
+	err := writeGasReport(reporter.Report(), reportPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gas report: %s\n", err)
+		code = 1
+	}
+	os.Exit(code)
+}
+
+func writeGasReport(report *GasReport, reportPath string) error {
+	err := report.WriteJSON(reportPath + ".json")
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(reportPath + ".md")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	err = report.WriteMarkdown(f)
+	if err != nil {
+		return err
+	}
+
+	baselinePath := os.Getenv("GAS_BASELINE")
+	if baselinePath == "" {
+		baselinePath = defaultGasBaseline
+	}
+	baseline, err := LoadGasReport(baselinePath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	margin := defaultGasRegressionMargin
+	if threshold := os.Getenv("GAS_THRESHOLD"); threshold != "" {
+		margin, err = strconv.ParseFloat(threshold, 64)
+		if err != nil {
+			return fmt.Errorf("invalid GAS_THRESHOLD: %w", err)
+		}
+	}
+	regressions := CompareGasReports(baseline, report, margin/100)
+	if len(regressions) > 0 {
+		for _, regression := range regressions {
+			fmt.Fprintf(os.Stderr, "gas regression %s\n", regression)
+		}
+		return fmt.Errorf("%d gas regressions over %.2f%% compared to %s", len(regressions), margin, baselinePath)
+	}
+	return nil
+}
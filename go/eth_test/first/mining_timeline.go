@@ -0,0 +1,81 @@
+package first
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"first/blocks"
+)
+
+// MiningTimeline is the expected timestamps of the blocks mined by MineBlocks for a scenario.
+// Each block is mined `blockDuration` after its parent
+type MiningTimeline struct {
+	// durations[i] is the duration of block i+1
+	durations []time.Duration
+}
+
+// NewMiningTimeline evaluates the scenario the same way MineBlocks does
+func NewMiningTimeline(getBlockInfo getBlockInfoCallback) *MiningTimeline {
+	timeline := &MiningTimeline{}
+	for blockNo := 1; ; blockNo++ {
+		blockInfo, stop := getBlockInfo(blockNo)
+		if stop {
+			break
+		}
+		timeline.durations = append(timeline.durations, blockInfo.blockDuration)
+	}
+	return timeline
+}
+
+// BlockCount is the number of blocks mined by the scenario, genesis excluded
+func (m *MiningTimeline) BlockCount() int {
+	return len(m.durations)
+}
+
+// Offset returns the expected time between genesis and blockNo
+func (m *MiningTimeline) Offset(blockNo int) time.Duration {
+	var res time.Duration
+	for i := 0; i < blockNo && i < len(m.durations); i++ {
+		res += m.durations[i]
+	}
+	return res
+}
+
+// ExpectedTimestamp returns the timestamp of blockNo for a chain with the given genesis timestamp
+func (m *MiningTimeline) ExpectedTimestamp(genesisTime uint64, blockNo int) uint64 {
+	return genesisTime + uint64(m.Offset(blockNo).Seconds())
+}
+
+// BlockAt returns the block current at t according to the model; -1 before genesis
+func (m *MiningTimeline) BlockAt(genesisTime uint64, t time.Time) int {
+	target := t.Unix()
+	if target < int64(genesisTime) {
+		return -1
+	}
+	// The first block after t, minus one
+	return sort.Search(len(m.durations)+1, func(blockNo int) bool {
+		return int64(m.ExpectedTimestamp(genesisTime, blockNo)) > target
+	}) - 1
+}
+
+// Verify checks the mined chain against the model. Only the first block is allowed to be
+// later than expected, by the wall-clock time elapsed between starting Anvil and mining
+func (m *MiningTimeline) Verify(ctx context.Context, fetcher *blocks.Fetcher) error {
+	if len(m.durations) == 0 {
+		return nil
+	}
+	headers, err := fetcher.Headers(ctx, 0, uint64(len(m.durations)))
+	if err != nil {
+		return err
+	}
+	for blockNo := 1; blockNo < len(headers); blockNo++ {
+		expected := uint64(m.durations[blockNo-1].Seconds())
+		actual := headers[blockNo].Time - headers[blockNo-1].Time
+		if actual != expected && (blockNo > 1 || actual < expected) {
+			return fmt.Errorf("block %d was mined %ds after its parent, expected %ds", blockNo, actual, expected)
+		}
+	}
+	return nil
+}
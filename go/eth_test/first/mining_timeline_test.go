@@ -0,0 +1,56 @@
+package first
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"first/blocks"
+
+	"github.com/stretchr/testify/require"
+)
+
+// irregularScenario mines 3 standard blocks, 4 blocks of 2s and a standard one
+func irregularScenario() getBlockInfoCallback {
+	short := 2 * time.Second
+	return blocksWithDurations(standardBlockDuration, standardBlockDuration, standardBlockDuration, short, short, short, short, standardBlockDuration)
+}
+
+func TestMiningTimelineModel(t *testing.T) {
+	timeline := NewMiningTimeline(irregularScenario())
+	require.Equal(t, 8, timeline.BlockCount())
+
+	genesis := uint64(fakeChainGenesisTime)
+	require.Equal(t, genesis, timeline.ExpectedTimestamp(genesis, 0))
+	require.Equal(t, genesis+36, timeline.ExpectedTimestamp(genesis, 3))
+	require.Equal(t, genesis+44, timeline.ExpectedTimestamp(genesis, 7))
+	require.Equal(t, genesis+56, timeline.ExpectedTimestamp(genesis, 8))
+
+	require.Equal(t, -1, timeline.BlockAt(genesis, time.Unix(int64(genesis)-1, 0)))
+	require.Equal(t, 0, timeline.BlockAt(genesis, time.Unix(int64(genesis)+11, 0)))
+	require.Equal(t, 3, timeline.BlockAt(genesis, time.Unix(int64(genesis)+37, 0)))
+	require.Equal(t, 8, timeline.BlockAt(genesis, time.Unix(int64(genesis)+1000, 0)))
+}
+
+func TestMiningTimelineMatchesMinedChain(t *testing.T) {
+	_, _, anvil, tearDown := setupTestingWithFakeChain(t)
+	defer tearDown()
+	ctx := context.Background()
+
+	require.NoError(t, MineBlocks(anvil, irregularScenario()))
+
+	timeline := NewMiningTimeline(irregularScenario())
+	fetcher := blocks.NewFetcher(anvil.Client(), blocks.DefaultCacheSize)
+	require.NoError(t, timeline.Verify(ctx, fetcher))
+
+	genesis := uint64(fakeChainGenesisTime)
+	for offset := int64(0); offset < 70; offset++ {
+		at := time.Unix(int64(genesis)+offset, 0)
+		header, err := fetcher.BlockAtTime(ctx, at)
+		require.NoError(t, err)
+		require.Equal(t, uint64(timeline.BlockAt(genesis, at)), header.Number.Uint64(), "offset %d", offset)
+	}
+
+	// A scenario with other durations doesn't match
+	require.Error(t, NewMiningTimeline(blocksWithDurations(standardBlockDuration, standardBlockDuration, standardBlockDuration, time.Second)).Verify(ctx, fetcher))
+}
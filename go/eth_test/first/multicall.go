@@ -0,0 +1,151 @@
+package first
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Result of a single call in a Multicall; Err is set if the node rejected the call
+type Result[T any] struct {
+	Value T
+	Err   error
+}
+
+type multicallEntry struct {
+	elem   rpc.BatchElem
+	finish func(err error)
+}
+
+// Multicall queues read requests and sends them as JSON-RPC batches on Execute
+type Multicall struct {
+	// MaxBatchSize splits the requests in batches of at most this size; 0 sends a single batch
+	MaxBatchSize int
+
+	client      *rpc.Client
+	blockNumber *big.Int
+	entries     []multicallEntry
+}
+
+// NewMulticall queries state at blockNumber, nil for the latest block
+func NewMulticall(client *rpc.Client, blockNumber *big.Int) *Multicall {
+	return &Multicall{
+		client:      client,
+		blockNumber: blockNumber,
+	}
+}
+
+func queue[T any, R any](m *Multicall, method string, args []any, convert func(raw *R) T) *Result[T] {
+	res := &Result[T]{}
+	raw := new(R)
+	m.entries = append(m.entries, multicallEntry{
+		elem: prepareCall(method, args, raw),
+		finish: func(err error) {
+			res.Err = err
+			if err == nil {
+				res.Value = convert(raw)
+			}
+		},
+	})
+	return res
+}
+
+// Balance queues eth_getBalance; the result is filled by Execute
+func (m *Multicall) Balance(address common.Address) *Result[*big.Int] {
+	return queue(m, "eth_getBalance", []any{address, toBlockNumArg(m.blockNumber)}, func(raw *hexutil.Big) *big.Int {
+		return raw.ToInt()
+	})
+}
+
+// Nonce queues eth_getTransactionCount; the result is filled by Execute
+func (m *Multicall) Nonce(address common.Address) *Result[uint64] {
+	return queue(m, "eth_getTransactionCount", []any{address, toBlockNumArg(m.blockNumber)}, func(raw *hexutil.Uint64) uint64 {
+		return uint64(*raw)
+	})
+}
+
+// Code queues eth_getCode; the result is filled by Execute
+func (m *Multicall) Code(address common.Address) *Result[[]byte] {
+	return queue(m, "eth_getCode", []any{address, toBlockNumArg(m.blockNumber)}, func(raw *hexutil.Bytes) []byte {
+		return *raw
+	})
+}
+
+// StorageAt queues eth_getStorageAt; the result is filled by Execute
+func (m *Multicall) StorageAt(address common.Address, slot common.Hash) *Result[common.Hash] {
+	return queue(m, "eth_getStorageAt", []any{address, slot, toBlockNumArg(m.blockNumber)}, func(raw *hexutil.Bytes) common.Hash {
+		return common.BytesToHash(*raw)
+	})
+}
+
+// Call queues eth_call; the result is filled by Execute
+func (m *Multicall) Call(msg ethereum.CallMsg) *Result[[]byte] {
+	return queue(m, "eth_call", []any{toCallArg(msg), toBlockNumArg(m.blockNumber)}, func(raw *hexutil.Bytes) []byte {
+		return *raw
+	})
+}
+
+// Len returns the number of queued requests
+func (m *Multicall) Len() int {
+	return len(m.entries)
+}
+
+// Execute sends the queued requests and fills their results. The returned
+// error is only for transport failures, call errors are reported per result. On a
+// transport failure the results of the failed and unsent batches get its error
+func (m *Multicall) Execute(ctx context.Context) error {
+	entries := m.entries
+	m.entries = nil
+
+	batchSize := m.MaxBatchSize
+	if batchSize <= 0 {
+		batchSize = len(entries)
+	}
+	for start := 0; start < len(entries); start += batchSize {
+		end := start + batchSize
+		if end > len(entries) {
+			end = len(entries)
+		}
+		batch := entries[start:end]
+		calls := make([]rpc.BatchElem, 0, len(batch))
+		for _, entry := range batch {
+			calls = append(calls, entry.elem)
+		}
+		err := m.client.BatchCallContext(ctx, calls)
+		if err != nil {
+			for _, entry := range entries[start:] {
+				entry.finish(err)
+			}
+			return err
+		}
+		for i, call := range calls {
+			batch[i].finish(call.Error)
+		}
+	}
+	return nil
+}
+
+// BalancesAt fetches the balances of all addresses in a single round trip
+func BalancesAt(ctx context.Context, client *rpc.Client, addresses []common.Address, blockNumber *big.Int) (map[common.Address]*big.Int, error) {
+	multicall := NewMulticall(client, blockNumber)
+	results := make([]*Result[*big.Int], 0, len(addresses))
+	for _, address := range addresses {
+		results = append(results, multicall.Balance(address))
+	}
+	err := multicall.Execute(ctx)
+	if err != nil {
+		return nil, err
+	}
+	balances := make(map[common.Address]*big.Int, len(addresses))
+	for i, res := range results {
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		balances[addresses[i]] = res.Value
+	}
+	return balances, nil
+}
@@ -0,0 +1,138 @@
+package first
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"first/rpcmock"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBalancesAtIsASingleRoundTrip(t *testing.T) {
+	server, anvil, tearDown := setupTestingWithMock(t)
+	defer tearDown()
+	// The balance of each account is its address as a number
+	server.Handle("eth_getBalance", func(params []json.RawMessage) (interface{}, error) {
+		var address common.Address
+		require.NoError(t, json.Unmarshal(params[0], &address))
+		return (*hexutil.Big)(new(big.Int).SetBytes(address.Bytes())), nil
+	})
+
+	addresses := make([]common.Address, 0, 1000)
+	for i := 1; i <= 1000; i++ {
+		addresses = append(addresses, common.BigToAddress(big.NewInt(int64(i))))
+	}
+	balances, err := BalancesAt(context.Background(), anvil.Client(), addresses, big.NewInt(5))
+	require.NoError(t, err)
+	require.Equal(t, 1000, len(balances))
+	require.Equal(t, big.NewInt(1000), balances[addresses[999]])
+
+	calls := server.CallsTo("eth_getBalance")
+	require.Equal(t, 1000, len(calls))
+	for _, call := range calls {
+		require.Equal(t, 0, call.Batch)
+	}
+	server.ExpectCall(t, "eth_getBalance", addresses[0], "0x5")
+}
+
+func TestMulticallReportsErrorsPerCall(t *testing.T) {
+	server, anvil, tearDown := setupTestingWithMock(t)
+	defer tearDown()
+	server.Respond("eth_getCode", "0x6080")
+	server.Respond("eth_getTransactionCount", "0x7")
+	server.Respond("eth_getStorageAt", "0x01")
+	server.RespondError("eth_call", 3, "execution reverted")
+
+	account := common.HexToAddress("0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266")
+	multicall := NewMulticall(anvil.Client(), nil)
+	code := multicall.Code(account)
+	nonce := multicall.Nonce(account)
+	slot := multicall.StorageAt(account, common.Hash{})
+	call := multicall.Call(ethereum.CallMsg{From: account, To: &account})
+	require.Equal(t, 4, multicall.Len())
+
+	require.NoError(t, multicall.Execute(context.Background()))
+	require.Equal(t, 0, multicall.Len())
+
+	require.NoError(t, code.Err)
+	require.Equal(t, []byte{0x60, 0x80}, code.Value)
+	require.NoError(t, nonce.Err)
+	require.Equal(t, uint64(7), nonce.Value)
+	require.NoError(t, slot.Err)
+	require.Equal(t, common.BigToHash(big.NewInt(1)), slot.Value)
+	require.EqualError(t, call.Err, "execution reverted")
+	require.Nil(t, call.Value)
+	server.ExpectCall(t, "eth_getCode", account, "latest")
+}
+
+func TestMulticallMaxBatchSize(t *testing.T) {
+	server, anvil, tearDown := setupTestingWithMock(t)
+	defer tearDown()
+	server.Respond("eth_getBalance", "0x1")
+
+	multicall := NewMulticall(anvil.Client(), nil)
+	multicall.MaxBatchSize = 4
+	results := make([]*Result[*big.Int], 0)
+	for i := 0; i < 10; i++ {
+		results = append(results, multicall.Balance(common.BigToAddress(big.NewInt(int64(i)))))
+	}
+	require.NoError(t, multicall.Execute(context.Background()))
+	for _, res := range results {
+		require.NoError(t, res.Err)
+		require.Equal(t, big.NewInt(1), res.Value)
+	}
+
+	batches := make(map[int]int)
+	for _, call := range server.CallsTo("eth_getBalance") {
+		batches[call.Batch]++
+	}
+	require.Equal(t, map[int]int{0: 4, 1: 4, 2: 2}, batches)
+}
+
+func TestMulticallTransportErrorFailsRemainingResults(t *testing.T) {
+	server := rpcmock.New()
+	defer server.Close()
+	server.Respond("eth_getBalance", "0x1")
+	// The second batch doesn't get a JSON-RPC response
+	requests := 0
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 2 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		server.ServeHTTP(w, r)
+	}))
+	defer failing.Close()
+	client, err := rpc.DialHTTP(failing.URL)
+	require.NoError(t, err)
+	defer client.Close()
+
+	multicall := NewMulticall(client, nil)
+	multicall.MaxBatchSize = 2
+	results := make([]*Result[*big.Int], 0)
+	for i := 0; i < 5; i++ {
+		results = append(results, multicall.Balance(common.BigToAddress(big.NewInt(int64(i)))))
+	}
+	err = multicall.Execute(context.Background())
+	require.ErrorContains(t, err, "503")
+	require.Equal(t, 2, requests, "the third batch isn't sent")
+
+	for _, res := range results[:2] {
+		require.NoError(t, res.Err)
+		require.Equal(t, big.NewInt(1), res.Value)
+	}
+	for _, res := range results[2:] {
+		require.Equal(t, err, res.Err)
+		require.Nil(t, res.Value)
+	}
+}
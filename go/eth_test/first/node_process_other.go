@@ -0,0 +1,22 @@
+//go:build !unix
+
+package first
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+func startInProcessGroup(cmd *exec.Cmd) error {
+	return cmd.Start()
+}
+
+// killProcessGroup only kills the node process, the processes it spawned keep running
+func killProcessGroup(cmd *exec.Cmd) error {
+	err := cmd.Process.Kill()
+	if errors.Is(err, os.ErrProcessDone) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,25 @@
+//go:build unix
+
+package first
+
+import (
+	"errors"
+	"os/exec"
+	"syscall"
+)
+
+// startInProcessGroup runs the node in its own process group, so that the processes it
+// spawns, e.g. the node started by `npx hardhat node`, stop with it
+func startInProcessGroup(cmd *exec.Cmd) error {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	return cmd.Start()
+}
+
+// killProcessGroup kills the process group of a node started by startInProcessGroup
+func killProcessGroup(cmd *exec.Cmd) error {
+	err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	if errors.Is(err, syscall.ESRCH) {
+		return nil
+	}
+	return err
+}
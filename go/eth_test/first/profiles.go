@@ -0,0 +1,225 @@
+package first
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Capability is a dev API feature that not every node implements
+type Capability string
+
+const (
+	// CapMine mines a number of blocks on demand with a given block interval
+	CapMine Capability = "mine"
+	// CapIncreaseTime moves the chain clock forward, i.e. evm_increaseTime
+	CapIncreaseTime Capability = "increase-time"
+	// CapSetNextBlockTimestamp fixes the timestamp of the next block, i.e. evm_setNextBlockTimestamp
+	CapSetNextBlockTimestamp Capability = "set-next-block-timestamp"
+	// CapSnapshot takes and reverts chain snapshots, i.e. evm_snapshot and evm_revert
+	CapSnapshot Capability = "snapshot"
+	// CapSetStorage overwrites a storage slot of any account
+	CapSetStorage Capability = "set-storage"
+	// CapSetBalance overwrites the balance of any account
+	CapSetBalance Capability = "set-balance"
+	// CapTracing runs the built-in callTracer and prestateTracer
+	CapTracing Capability = "tracing"
+	// CapWebSocket serves subscriptions over WebSocket
+	CapWebSocket Capability = "websocket"
+	// CapHardfork starts the node at a given hardfork
+	CapHardfork Capability = "hardfork"
+	// CapStandardAccounts prefunds the accounts of the "test test ... junk" mnemonic
+	CapStandardAccounts Capability = "standard-accounts"
+)
+
+// ErrUnsupported is wrapped by the errors of operations the node profile doesn't support
+var ErrUnsupported = errors.New("unsupported by the node")
+
+// UnsupportedError is returned when the node lacks the capability an operation needs
+type UnsupportedError struct {
+	Profile    string
+	Capability Capability
+}
+
+func (e *UnsupportedError) Error() string {
+	return fmt.Sprintf("%s: %s doesn't support %s", ErrUnsupported, e.Profile, e.Capability)
+}
+
+func (e *UnsupportedError) Unwrap() error {
+	return ErrUnsupported
+}
+
+// NodeCall is a JSON-RPC call without result
+type NodeCall struct {
+	Method string
+	Args   []any
+}
+
+// NodeProfile describes how to run a local dev node and maps the harness operations to its API
+type NodeProfile struct {
+	Name string
+	// Command and Args start the node, mining only on demand when the node supports it
+	Command string
+	Args    []string
+	// ProcessName is killed to stop instances left running; empty if it can't be told apart
+	ProcessName  string
+	URL          string
+	WSURL        string
+	StartTimeout time.Duration
+	// Accounts is the number of accounts returned by eth_accounts once the node is up
+	Accounts     int
+	Capabilities []Capability
+	// HardforkArgs are appended to Args to start the node at hardfork
+	HardforkArgs func(hardfork string) []string
+	// InitCalls are sent once after the node is up
+	InitCalls        []NodeCall
+	MineMethod       string
+	MineArgs         func(blockCount int, blockLength time.Duration) []any
+	SetStorageMethod string
+	SetBalanceMethod string
+	// RevertArgs encodes the snapshot id taken by evm_snapshot for evm_revert
+	RevertArgs func(snapshotId int) []any
+	// ParseTimeOffset decodes the result of evm_increaseTime, the total offset in seconds
+	ParseTimeOffset func(result json.RawMessage) (int64, error)
+}
+
+// Supports is true if the profile has all of caps
+func (p *NodeProfile) Supports(caps ...Capability) bool {
+	return len(p.Missing(caps...)) == 0
+}
+
+// Missing returns the capabilities of caps the profile doesn't have
+func (p *NodeProfile) Missing(caps ...Capability) []Capability {
+	var res []Capability
+	for _, c := range caps {
+		found := false
+		for _, supported := range p.Capabilities {
+			if supported == c {
+				found = true
+				break
+			}
+		}
+		if !found {
+			res = append(res, c)
+		}
+	}
+	return res
+}
+
+func (p *NodeProfile) require(c Capability) error {
+	if !p.Supports(c) {
+		return &UnsupportedError{Profile: p.Name, Capability: c}
+	}
+	return nil
+}
+
+var AnvilProfile = &NodeProfile{
+	Name:         "anvil",
+	Command:      "anvil",
+	Args:         []string{"--port", strconv.Itoa(anvilPort), "--timestamp", "1713900000", "--no-mining", "--silent"},
+	ProcessName:  "anvil",
+	URL:          fmt.Sprintf("http://localhost:%d", anvilPort),
+	WSURL:        fmt.Sprintf("ws://localhost:%d", anvilPort),
+	StartTimeout: 1 * time.Second,
+	Accounts:     10,
+	Capabilities: []Capability{CapMine, CapIncreaseTime, CapSetNextBlockTimestamp, CapSnapshot, CapSetStorage, CapSetBalance, CapTracing, CapWebSocket, CapHardfork, CapStandardAccounts},
+	HardforkArgs: func(hardfork string) []string {
+		return []string{"--hardfork", hardfork}
+	},
+	MineMethod: "anvil_mine",
+	MineArgs: func(blockCount int, blockLength time.Duration) []any {
+		return []any{big.NewInt(int64(blockCount)), big.NewInt(int64(blockLength.Seconds()))}
+	},
+	SetStorageMethod: "anvil_setStorageAt",
+	SetBalanceMethod: "anvil_setBalance",
+	RevertArgs: func(snapshotId int) []any {
+		return []any{snapshotId}
+	},
+	ParseTimeOffset: func(result json.RawMessage) (int64, error) {
+		var offset int64
+		err := json.Unmarshal(result, &offset)
+		return offset, err
+	},
+}
+
+// HardhatProfile runs `npx hardhat node`, so the working directory must be a Hardhat project.
+// Its tracer only returns struct logs and the hardfork comes from hardhat.config
+var HardhatProfile = &NodeProfile{
+	Name:    "hardhat",
+	Command: "npx",
+	Args:    []string{"hardhat", "node", "--port", strconv.Itoa(anvilPort)},
+	// The node runs as `node`, killing it by name could stop unrelated processes
+	ProcessName:  "",
+	URL:          fmt.Sprintf("http://localhost:%d", anvilPort),
+	WSURL:        fmt.Sprintf("ws://localhost:%d", anvilPort),
+	StartTimeout: 10 * time.Second,
+	Accounts:     20,
+	Capabilities: []Capability{CapMine, CapIncreaseTime, CapSetNextBlockTimestamp, CapSnapshot, CapSetStorage, CapSetBalance, CapWebSocket, CapStandardAccounts},
+	InitCalls:    []NodeCall{{Method: "evm_setAutomine", Args: []any{false}}},
+	MineMethod:   "hardhat_mine",
+	MineArgs: func(blockCount int, blockLength time.Duration) []any {
+		return []any{hexutil.Uint64(blockCount), hexutil.Uint64(blockLength.Seconds())}
+	},
+	SetStorageMethod: "hardhat_setStorageAt",
+	SetBalanceMethod: "hardhat_setBalance",
+	RevertArgs: func(snapshotId int) []any {
+		return []any{hexutil.Uint64(snapshotId)}
+	},
+	// The offset is a decimal string
+	ParseTimeOffset: func(result json.RawMessage) (int64, error) {
+		var offset string
+		err := json.Unmarshal(result, &offset)
+		if err != nil {
+			return 0, err
+		}
+		return strconv.ParseInt(offset, 10, 64)
+	},
+}
+
+// GethDevProfile runs `geth --dev`, which mines a block per transaction and has a single
+// prefunded account with a random key. It has no dev API to control mining or the clock
+var GethDevProfile = &NodeProfile{
+	Name:    "geth",
+	Command: "geth",
+	Args: []string{
+		"--dev", "--verbosity", "1",
+		"--http", "--http.port", strconv.Itoa(anvilPort), "--http.api", "eth,net,web3,debug",
+		"--ws", "--ws.port", strconv.Itoa(anvilPort + 1), "--ws.api", "eth,net,web3",
+	},
+	ProcessName:  "geth",
+	URL:          fmt.Sprintf("http://localhost:%d", anvilPort),
+	WSURL:        fmt.Sprintf("ws://localhost:%d", anvilPort+1),
+	StartTimeout: 5 * time.Second,
+	Accounts:     1,
+	Capabilities: []Capability{CapTracing, CapWebSocket},
+}
+
+var profiles = []*NodeProfile{AnvilProfile, HardhatProfile, GethDevProfile}
+
+// ProfileByName returns one of anvil, hardhat and geth
+func ProfileByName(name string) (*NodeProfile, error) {
+	for _, profile := range profiles {
+		if profile.Name == strings.ToLower(name) {
+			return profile, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown node profile %q", name)
+}
+
+// CurrentProfile is the profile selected with the DEV_NODE environment variable, Anvil by default
+func CurrentProfile() *NodeProfile {
+	name := os.Getenv("DEV_NODE")
+	if name == "" {
+		return AnvilProfile
+	}
+	profile, err := ProfileByName(name)
+	panicOnError(err)
+	return profile
+}
@@ -0,0 +1,111 @@
+package first
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+// requireCapabilities skips the test if the node selected with DEV_NODE lacks any of caps
+func requireCapabilities(t *testing.T, caps ...Capability) {
+	t.Helper()
+	profile := CurrentProfile()
+	if missing := profile.Missing(caps...); len(missing) > 0 {
+		t.Skipf("%s doesn't support %v", profile.Name, missing)
+	}
+}
+
+func TestProfileByName(t *testing.T) {
+	for _, name := range []string{"anvil", "Hardhat", "geth"} {
+		profile, err := ProfileByName(name)
+		require.NoError(t, err)
+		require.Equal(t, strings.ToLower(name), profile.Name)
+	}
+	_, err := ProfileByName("ganache")
+	require.ErrorContains(t, err, `unknown node profile "ganache"`)
+
+	t.Setenv("DEV_NODE", "hardhat")
+	require.Equal(t, HardhatProfile, CurrentProfile())
+	t.Setenv("DEV_NODE", "")
+	require.Equal(t, AnvilProfile, CurrentProfile())
+}
+
+func TestProfileMissingCapabilities(t *testing.T) {
+	require.True(t, AnvilProfile.Supports(CapMine, CapSnapshot, CapTracing))
+	require.Equal(t, []Capability{CapTracing, CapHardfork}, HardhatProfile.Missing(CapMine, CapTracing, CapHardfork))
+	require.False(t, GethDevProfile.Supports(CapMine))
+}
+
+func TestHardhatProfileUsesHardhatMethodsWithoutNode(t *testing.T) {
+	server, anvil, tearDown := setupTestingWithMock(t)
+	defer tearDown()
+	anvil.profile = HardhatProfile
+	server.Respond("hardhat_mine", nil)
+	server.Respond("hardhat_setStorageAt", true)
+
+	err := MineBlocks(anvil, blocksWithDurations(standardBlockDuration, standardBlockDuration))
+	require.NoError(t, err)
+	// Hardhat only accepts hex quantities
+	calls := server.CallsTo("hardhat_mine")
+	require.Equal(t, 1, len(calls))
+	require.Equal(t, []json.RawMessage{json.RawMessage(`"0x2"`), json.RawMessage(`"0xc"`)}, calls[0].Params)
+
+	require.NoError(t, anvil.SetTokenBalance(context.Background(), common.Address{1}, common.Address{2}, big.NewInt(5)))
+	require.Equal(t, 1, len(server.CallsTo("hardhat_setStorageAt")))
+	require.Empty(t, server.CallsTo("anvil_setStorageAt"))
+}
+
+func TestHardhatProfileEncodesSnapshotsAndTimeOffsetsWithoutNode(t *testing.T) {
+	server, anvil, tearDown := setupTestingWithMock(t)
+	defer tearDown()
+	anvil.profile = HardhatProfile
+	server.Respond("evm_snapshot", "0xa")
+	server.Respond("evm_increaseTime", "3612")
+
+	snapshotId, err := anvil.TakeSnapshot()
+	require.NoError(t, err)
+	require.Equal(t, 10, snapshotId)
+	require.NoError(t, anvil.RevertSnapshot(snapshotId))
+	calls := server.CallsTo("evm_revert")
+	require.Equal(t, 1, len(calls))
+	require.Equal(t, []json.RawMessage{json.RawMessage(`"0xa"`)}, calls[0].Params)
+
+	offset, err := anvil.IncreaseTime(time.Hour)
+	require.NoError(t, err)
+	require.Equal(t, 3612*time.Second, offset)
+}
+
+func TestAnvilProfileDecodesNumericTimeOffsetWithoutNode(t *testing.T) {
+	server, anvil, tearDown := setupTestingWithMock(t)
+	defer tearDown()
+	server.Respond("evm_increaseTime", 3612)
+
+	offset, err := anvil.IncreaseTime(time.Hour)
+	require.NoError(t, err)
+	require.Equal(t, 3612*time.Second, offset)
+}
+
+func TestUnsupportedOperationsDontCallNode(t *testing.T) {
+	server, anvil, tearDown := setupTestingWithMock(t)
+	defer tearDown()
+	anvil.profile = GethDevProfile
+
+	err := anvil.MineBlocks(1, DefaultBlockTime)
+	require.ErrorIs(t, err, ErrUnsupported)
+	var unsupported *UnsupportedError
+	require.ErrorAs(t, err, &unsupported)
+	require.Equal(t, CapMine, unsupported.Capability)
+
+	_, err = anvil.TakeSnapshot()
+	require.ErrorIs(t, err, ErrUnsupported)
+	_, err = anvil.IncreaseTime(DefaultBlockTime)
+	require.ErrorIs(t, err, ErrUnsupported)
+	require.Empty(t, server.CallsTo("evm_snapshot"))
+	require.Empty(t, server.CallsTo("evm_increaseTime"))
+}
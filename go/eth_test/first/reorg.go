@@ -0,0 +1,183 @@
+package first
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// reorgBlockDuration differs from the standard block duration so that even
+// empty blocks of a new branch get different hashes than the orphaned ones
+const reorgBlockDuration = standardBlockDuration + time.Second
+
+// BranchBlock describes a block of the branch replacing the reorganized blocks
+type BranchBlock struct {
+	// Transactions are signed transactions included in the block
+	Transactions []*types.Transaction
+	// BlockDuration is the time since the parent block; reorgBlockDuration if 0
+	BlockDuration time.Duration
+}
+
+type ReorgResult struct {
+	// ForkBlock is the common ancestor of the orphaned and the new blocks
+	ForkBlock uint64
+	Orphaned  []common.Hash
+	New       []common.Hash
+	// RemovedLogs are the logs of the orphaned blocks marked as removed, as log subscribers receive them
+	RemovedLogs []types.Log
+}
+
+type blockRef struct {
+	Hash      common.Hash    `json:"hash"`
+	Number    hexutil.Uint64 `json:"number"`
+	Timestamp hexutil.Uint64 `json:"timestamp"`
+}
+
+// checkpoint takes a snapshot of the head before mining on top of it, so that Reorg can
+// fork from any block mined by the harness. Attached nodes are left without snapshots
+func (g *Anvil) checkpoint(ctx context.Context) error {
+	if g.attached || !g.Profile().Supports(CapSnapshot) {
+		return nil
+	}
+	blockNumber, err := g.eth.BlockNumber(ctx)
+	if err != nil {
+		return err
+	}
+	if _, found := g.checkpoints[blockNumber]; found {
+		return nil
+	}
+	snapshotId, err := g.TakeSnapshot()
+	if err != nil {
+		return err
+	}
+	if g.checkpoints == nil {
+		g.checkpoints = make(map[uint64]int)
+	}
+	g.checkpoints[blockNumber] = snapshotId
+	return nil
+}
+
+// Reorg replaces the last depth blocks with newBranch. MineBlocks and Reorg take a snapshot
+// of the head before mining, so the fork block must be the head of one of those calls
+func (g *Anvil) Reorg(depth int, newBranch []BranchBlock) (*ReorgResult, error) {
+	err := g.Profile().require(CapSnapshot)
+	if err != nil {
+		return nil, err
+	}
+	err = g.Profile().require(CapSetNextBlockTimestamp)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	head, err := g.eth.BlockNumber(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if depth <= 0 || uint64(depth) > head {
+		return nil, fmt.Errorf("invalid reorg depth %d for head %d", depth, head)
+	}
+	forkBlock := head - uint64(depth)
+	snapshotId, found := g.checkpoints[forkBlock]
+	if !found {
+		return nil, fmt.Errorf("no snapshot at block %d; only the blocks mined with MineBlocks or Reorg can be reorganized", forkBlock)
+	}
+
+	orphaned, err := g.blockRefs(ctx, forkBlock+1, head)
+	if err != nil {
+		return nil, err
+	}
+	res := &ReorgResult{ForkBlock: forkBlock}
+	for _, block := range orphaned {
+		res.Orphaned = append(res.Orphaned, block.Hash)
+		blockHash := block.Hash
+		logs, err := g.eth.FilterLogs(ctx, ethereum.FilterQuery{BlockHash: &blockHash})
+		if err != nil {
+			return nil, err
+		}
+		for _, log := range logs {
+			log.Removed = true
+			res.RemovedLogs = append(res.RemovedLogs, log)
+		}
+	}
+
+	err = g.RevertSnapshot(snapshotId)
+	if err != nil {
+		return nil, err
+	}
+	// Reverting discarded the snapshot of the fork block
+	err = g.checkpoint(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	parent, err := g.blockRefs(ctx, forkBlock, forkBlock)
+	if err != nil {
+		return nil, err
+	}
+	timestamp := uint64(parent[0].Timestamp)
+	for _, block := range newBranch {
+		for _, tx := range block.Transactions {
+			err = g.eth.SendTransaction(ctx, tx)
+			if err != nil {
+				return nil, err
+			}
+		}
+		duration := block.BlockDuration
+		if duration == 0 {
+			duration = reorgBlockDuration
+		}
+		timestamp += uint64(duration.Seconds())
+		err = g.checkpoint(ctx)
+		if err != nil {
+			return nil, err
+		}
+		err = g.c.Call(nil, "evm_setNextBlockTimestamp", hexutil.Uint64(timestamp))
+		if err != nil {
+			return nil, err
+		}
+		err = g.c.Call(nil, "evm_mine")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(newBranch) > 0 {
+		mined, err := g.blockRefs(ctx, forkBlock+1, forkBlock+uint64(len(newBranch)))
+		if err != nil {
+			return nil, err
+		}
+		for _, block := range mined {
+			res.New = append(res.New, block.Hash)
+		}
+	}
+	return res, nil
+}
+
+// blockRefs fetches the hashes and timestamps of blocks [from, to] in a single batch
+func (g *Anvil) blockRefs(ctx context.Context, from uint64, to uint64) ([]blockRef, error) {
+	refs := make([]blockRef, to-from+1)
+	calls := make([]rpc.BatchElem, 0, len(refs))
+	for i := range refs {
+		calls = append(calls, prepareCall("eth_getBlockByNumber", []any{hexutil.EncodeBig(new(big.Int).SetUint64(from + uint64(i))), false}, &refs[i]))
+	}
+	err := g.c.BatchCallContext(ctx, calls)
+	if err != nil {
+		return nil, err
+	}
+	for i, call := range calls {
+		if call.Error != nil {
+			return nil, fmt.Errorf("error fetching block %d: %w", from+uint64(i), call.Error)
+		}
+		if refs[i].Hash == (common.Hash{}) {
+			return nil, fmt.Errorf("block %d not found", from+uint64(i))
+		}
+	}
+	return refs, nil
+}
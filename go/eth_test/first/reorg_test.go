@@ -0,0 +1,163 @@
+package first
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/require"
+)
+
+func signedTestTransfer(t *testing.T, nonce uint64, to common.Address, value *big.Int) *types.Transaction {
+	privateKey, err := crypto.HexToECDSA("ac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80")
+	require.NoError(t, err)
+	chainID := big.NewInt(31337)
+	tx := types.NewTx(&types.DynamicFeeTx{
+		ChainID:   chainID,
+		Nonce:     nonce,
+		GasTipCap: big.NewInt(params.GWei),
+		GasFeeCap: big.NewInt(2 * params.GWei),
+		Gas:       21000,
+		To:        &to,
+		Value:     value,
+	})
+	signedTx, err := types.SignTx(tx, types.NewLondonSigner(chainID), privateKey)
+	require.NoError(t, err)
+	return signedTx
+}
+
+func TestReorgReplacesLastBlocks(t *testing.T) {
+	chain, _, anvil, tearDown := setupTestingWithFakeChain(t)
+	defer tearDown()
+	ctx := context.Background()
+	recipient := common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8")
+
+	require.NoError(t, anvil.MineBlocks(2, standardBlockDuration))
+	require.NoError(t, anvil.EthClient().SendTransaction(ctx, signedTestTransfer(t, 0, recipient, big.NewInt(100))))
+	require.NoError(t, anvil.MineBlocks(3, standardBlockDuration))
+	before := chain.hashes()
+	require.Equal(t, 6, len(before))
+
+	res, err := anvil.Reorg(3, []BranchBlock{
+		{Transactions: []*types.Transaction{signedTestTransfer(t, 0, recipient, big.NewInt(200))}},
+		{},
+		{},
+		{BlockDuration: time.Second},
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, uint64(2), res.ForkBlock)
+	require.Equal(t, before[3:], res.Orphaned)
+	after := chain.hashes()
+	require.Equal(t, 7, len(after))
+	require.Equal(t, before[:3], after[:3], "blocks up to the fork are kept")
+	require.Equal(t, after[3:], res.New)
+	for _, hash := range res.New {
+		require.NotContains(t, res.Orphaned, hash)
+	}
+
+	require.Equal(t, 1, len(res.RemovedLogs))
+	require.True(t, res.RemovedLogs[0].Removed)
+	require.Equal(t, before[3], res.RemovedLogs[0].BlockHash)
+
+	// The fork block and the new branch can be reorganized again
+	res, err = anvil.Reorg(4, []BranchBlock{{}})
+	require.NoError(t, err)
+	require.Equal(t, after[3:], res.Orphaned)
+	require.Equal(t, 1, len(res.RemovedLogs), "only the logs of the replaced branch are removed")
+	require.Equal(t, after[3], res.RemovedLogs[0].BlockHash)
+	require.Equal(t, 4, len(chain.hashes()))
+
+	_, err = anvil.Reorg(1, nil)
+	require.NoError(t, err)
+	require.Equal(t, 3, len(chain.hashes()))
+	require.NoError(t, anvil.MineBlocks(1, standardBlockDuration))
+	_, err = anvil.Reorg(1, []BranchBlock{{}})
+	require.NoError(t, err, "the fork block keeps its snapshot after an empty branch")
+}
+
+func TestReorgNewBranchTimestamps(t *testing.T) {
+	chain, _, anvil, tearDown := setupTestingWithFakeChain(t)
+	defer tearDown()
+
+	require.NoError(t, anvil.MineBlocks(2, standardBlockDuration))
+	_, err := anvil.Reorg(2, []BranchBlock{{}, {BlockDuration: 5 * time.Second}})
+	require.NoError(t, err)
+
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+	require.Equal(t, uint64(fakeChainGenesisTime)+uint64(reorgBlockDuration.Seconds()), chain.blocks[1].timestamp)
+	require.Equal(t, chain.blocks[1].timestamp+5, chain.blocks[2].timestamp)
+}
+
+func TestReorgNeedsBlocksMinedByTheHarness(t *testing.T) {
+	_, _, anvil, tearDown := setupTestingWithFakeChain(t)
+	defer tearDown()
+
+	require.NoError(t, anvil.MineBlocks(3, standardBlockDuration))
+	_, err := anvil.Reorg(2, []BranchBlock{{}})
+	require.ErrorContains(t, err, "no snapshot at block 1")
+
+	_, err = anvil.Reorg(4, nil)
+	require.Error(t, err)
+}
+
+func TestReorgForgetsSnapshotsDiscardedByRevert(t *testing.T) {
+	_, _, anvil, tearDown := setupTestingWithFakeChain(t)
+	defer tearDown()
+
+	require.NoError(t, anvil.MineBlocks(1, standardBlockDuration))
+	require.NoError(t, anvil.MineBlocks(1, standardBlockDuration))
+	require.NoError(t, anvil.RevertSnapshot(anvil.initialSnapshotId))
+	require.NoError(t, anvil.MineBlocks(2, standardBlockDuration))
+
+	// The snapshot at block 1 was discarded with the first two blocks
+	_, err := anvil.Reorg(1, []BranchBlock{{}})
+	require.ErrorContains(t, err, "no snapshot at block 1")
+	_, err = anvil.Reorg(2, []BranchBlock{{}})
+	require.NoError(t, err)
+}
+
+func TestReorgEmitsRemovedLogsToSubscribers(t *testing.T) {
+	client, testData, anvil, tearDown := testClient(t, CapMine, CapSnapshot, CapSetNextBlockTimestamp, CapWebSocket)
+	defer tearDown()
+	ctx := context.Background()
+
+	sender, err := NewTxSender(client, testData.PrivateKeys[0], t.Name())
+	require.NoError(t, err)
+	mine := func(tx *types.Transaction) {
+		require.NoError(t, anvil.MineBlocks(1, DefaultBlockTime))
+		receipt, err := sender.WaitForReceipt(ctx, tx)
+		require.NoError(t, err)
+		require.Equal(t, types.ReceiptStatusSuccessful, receipt.Status)
+	}
+	token, tx, err := DeployTestToken(ctx, sender)
+	require.NoError(t, err)
+	mine(tx)
+
+	logs, err := CollectLogs(ctx, anvil.WSEthClient(), ethereum.FilterQuery{Addresses: []common.Address{token.Address}}, 10)
+	require.NoError(t, err)
+	defer logs.Close()
+	tx, err = token.Mint(ctx, sender, sender.From(), big.NewInt(1000))
+	require.NoError(t, err)
+	mine(tx)
+	minted, err := logs.Expect(1, 5*time.Second)
+	require.NoError(t, err)
+	require.False(t, minted[0].Removed)
+
+	res, err := anvil.Reorg(1, []BranchBlock{{}, {}})
+	require.NoError(t, err)
+	removed, err := logs.Expect(1, 5*time.Second)
+	require.NoError(t, err)
+	require.True(t, removed[0].Removed)
+	require.Equal(t, minted[0].TxHash, removed[0].TxHash)
+	require.Equal(t, res.Orphaned[0], removed[0].BlockHash)
+	require.Equal(t, res.RemovedLogs, removed)
+	require.NoError(t, logs.ExpectNone(100*time.Millisecond))
+}
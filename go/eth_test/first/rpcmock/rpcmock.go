@@ -0,0 +1,344 @@
+package rpcmock
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	jsonrpcVersion = "2.0"
+
+	errCodeParse          = -32700
+	errCodeInvalidRequest = -32600
+	errCodeMethodNotFound = -32601
+	errCodeInternal       = -32603
+)
+
+// Handler is called for every request of the method it is registered for.
+// A returned *Error is forwarded to the client as is, any other error is
+// reported as an internal error
+type Handler func(params []json.RawMessage) (result interface{}, err error)
+
+// Error is a JSON-RPC error object
+type Error struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) ErrorCode() int {
+	return e.Code
+}
+
+// Call is a request received by the server
+type Call struct {
+	Method string
+	Params []json.RawMessage
+	// Batch is the index of the batch the call was part of or -1 for single calls
+	Batch int
+}
+
+type request struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Server is an in-process JSON-RPC server to be used by unit tests instead of a real node
+type Server struct {
+	srv *httptest.Server
+
+	mu         sync.Mutex
+	handlers   map[string]Handler
+	calls      []Call
+	batchCount int
+}
+
+func New() *Server {
+	s := &Server{
+		handlers: make(map[string]Handler),
+	}
+	s.srv = httptest.NewServer(s)
+	return s
+}
+
+// URL of the HTTP endpoint
+func (s *Server) URL() string {
+	return s.srv.URL
+}
+
+// Client dials a new rpc.Client connected to the server
+func (s *Server) Client() (*rpc.Client, error) {
+	return rpc.DialHTTP(s.srv.URL)
+}
+
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// Handle registers handler for method replacing the previous one
+func (s *Server) Handle(method string, handler Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[method] = handler
+}
+
+// Respond registers a canned result for method
+func (s *Server) Respond(method string, result interface{}) {
+	s.Handle(method, func([]json.RawMessage) (interface{}, error) {
+		return result, nil
+	})
+}
+
+// RespondError registers a canned error for method
+func (s *Server) RespondError(method string, code int, message string) {
+	s.Handle(method, func([]json.RawMessage) (interface{}, error) {
+		return nil, &Error{Code: code, Message: message}
+	})
+}
+
+// LoadFixtures registers canned results from a JSON file containing an
+// object that maps method names to their results, e.g.
+//
+//	{"eth_chainId": "0x7a69", "eth_accounts": ["0xf39f..."]}
+func (s *Server) LoadFixtures(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var fixtures map[string]json.RawMessage
+	err = json.Unmarshal(content, &fixtures)
+	if err != nil {
+		return fmt.Errorf("invalid fixtures file %s: %w", path, err)
+	}
+	for method, result := range fixtures {
+		s.Respond(method, result)
+	}
+	return nil
+}
+
+// Calls returns all the requests received so far in order
+func (s *Server) Calls() []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Call(nil), s.calls...)
+}
+
+// CallsTo returns all the requests received so far for method
+func (s *Server) CallsTo(method string) []Call {
+	res := make([]Call, 0)
+	for _, call := range s.Calls() {
+		if call.Method == method {
+			res = append(res, call)
+		}
+	}
+	return res
+}
+
+// Reset forgets the recorded calls; handlers are kept
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = nil
+	s.batchCount = 0
+}
+
+// ExpectCall fails the test if there is no recorded call of method with
+// params matching args after both are JSON encoded
+func (s *Server) ExpectCall(t require.TestingT, method string, args ...interface{}) {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+	expected, err := encodeArgs(args)
+	require.NoError(t, err)
+
+	calls := s.CallsTo(method)
+	for _, call := range calls {
+		if paramsEqual(expected, call.Params) {
+			return
+		}
+	}
+	require.Failf(t, "expected call not found", "%s(%s); recorded calls: %s", method, joinParams(expected), formatCalls(calls))
+}
+
+// ExpectNoCall fails the test if method was called
+func (s *Server) ExpectNoCall(t require.TestingT, method string) {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+	calls := s.CallsTo(method)
+	require.Emptyf(t, calls, "unexpected calls to %s: %s", method, formatCalls(calls))
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var res interface{}
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var reqs []request
+		if err = json.Unmarshal(body, &reqs); err != nil {
+			res = parseErrorResponse(err)
+		} else {
+			res = s.serveBatch(reqs)
+		}
+	} else {
+		var req request
+		if err = json.Unmarshal(body, &req); err != nil {
+			res = parseErrorResponse(err)
+		} else {
+			res = s.serve(req, -1)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(res)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) serveBatch(reqs []request) []response {
+	s.mu.Lock()
+	batch := s.batchCount
+	s.batchCount++
+	s.mu.Unlock()
+
+	res := make([]response, 0, len(reqs))
+	for _, req := range reqs {
+		res = append(res, s.serve(req, batch))
+	}
+	return res
+}
+
+func (s *Server) serve(req request, batch int) response {
+	res := response{Version: jsonrpcVersion, ID: req.ID}
+	if req.Version != jsonrpcVersion || req.Method == "" {
+		res.Error = &Error{Code: errCodeInvalidRequest, Message: "invalid request"}
+		return res
+	}
+
+	var params []json.RawMessage
+	if len(req.Params) > 0 && string(req.Params) != "null" {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			res.Error = &Error{Code: errCodeInvalidRequest, Message: fmt.Sprintf("invalid params: %s", err)}
+			return res
+		}
+	}
+
+	s.mu.Lock()
+	s.calls = append(s.calls, Call{Method: req.Method, Params: params, Batch: batch})
+	handler, found := s.handlers[req.Method]
+	s.mu.Unlock()
+
+	if !found {
+		res.Error = &Error{Code: errCodeMethodNotFound, Message: fmt.Sprintf("the method %s does not exist/is not available", req.Method)}
+		return res
+	}
+
+	result, err := handler(params)
+	if err != nil {
+		rpcErr, ok := err.(*Error)
+		if !ok {
+			rpcErr = &Error{Code: errCodeInternal, Message: err.Error()}
+		}
+		res.Error = rpcErr
+		return res
+	}
+
+	res.Result, err = json.Marshal(result)
+	if err != nil {
+		res.Error = &Error{Code: errCodeInternal, Message: err.Error()}
+	}
+	return res
+}
+
+func parseErrorResponse(err error) response {
+	return response{
+		Version: jsonrpcVersion,
+		ID:      json.RawMessage("null"),
+		Error:   &Error{Code: errCodeParse, Message: err.Error()},
+	}
+}
+
+func encodeArgs(args []interface{}) ([]json.RawMessage, error) {
+	res := make([]json.RawMessage, 0, len(args))
+	for _, arg := range args {
+		encoded, err := json.Marshal(arg)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, encoded)
+	}
+	return res, nil
+}
+
+func paramsEqual(expected []json.RawMessage, actual []json.RawMessage) bool {
+	if len(expected) != len(actual) {
+		return false
+	}
+	for i := range expected {
+		var e, a interface{}
+		if json.Unmarshal(expected[i], &e) != nil || json.Unmarshal(actual[i], &a) != nil {
+			return false
+		}
+		if !reflect.DeepEqual(e, a) {
+			return false
+		}
+	}
+	return true
+}
+
+func joinParams(params []json.RawMessage) string {
+	var buf bytes.Buffer
+	for i, param := range params {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.Write(param)
+	}
+	return buf.String()
+}
+
+func formatCalls(calls []Call) string {
+	var buf bytes.Buffer
+	buf.WriteString("[")
+	for i, call := range calls {
+		if i > 0 {
+			buf.WriteString("; ")
+		}
+		fmt.Fprintf(&buf, "%s(%s)", call.Method, joinParams(call.Params))
+	}
+	buf.WriteString("]")
+	return buf.String()
+}
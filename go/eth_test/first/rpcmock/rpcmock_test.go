@@ -0,0 +1,100 @@
+package rpcmock
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCallIsRecordedAndAnswered(t *testing.T) {
+	server := New()
+	defer server.Close()
+	server.Respond("eth_chainId", "0x7a69")
+
+	client, err := server.Client()
+	require.NoError(t, err)
+	defer client.Close()
+
+	var chainID string
+	err = client.Call(&chainID, "eth_chainId")
+	require.NoError(t, err)
+	require.Equal(t, "0x7a69", chainID)
+
+	calls := server.Calls()
+	require.Equal(t, 1, len(calls))
+	require.Equal(t, -1, calls[0].Batch)
+	server.ExpectCall(t, "eth_chainId")
+}
+
+func TestHandlerReceivesParams(t *testing.T) {
+	server := New()
+	defer server.Close()
+	server.Handle("test_add", func(params []json.RawMessage) (interface{}, error) {
+		var a, b int
+		require.NoError(t, json.Unmarshal(params[0], &a))
+		require.NoError(t, json.Unmarshal(params[1], &b))
+		return a + b, nil
+	})
+
+	client, err := server.Client()
+	require.NoError(t, err)
+	defer client.Close()
+
+	var sum int
+	err = client.Call(&sum, "test_add", 2, 3)
+	require.NoError(t, err)
+	require.Equal(t, 5, sum)
+	server.ExpectCall(t, "test_add", 2, 3)
+}
+
+func TestBatchCall(t *testing.T) {
+	server := New()
+	defer server.Close()
+	server.Respond("anvil_mine", nil)
+	server.RespondError("evm_revert", 3, "revert failed")
+
+	client, err := server.Client()
+	require.NoError(t, err)
+	defer client.Close()
+
+	calls := []rpc.BatchElem{
+		{Method: "anvil_mine", Args: []any{1, 12}, Result: new(interface{})},
+		{Method: "evm_revert", Args: []any{"0x1"}, Result: new(bool)},
+		{Method: "unknown_method", Result: new(interface{})},
+	}
+	err = client.BatchCall(calls)
+	require.NoError(t, err)
+
+	require.NoError(t, calls[0].Error)
+	require.EqualError(t, calls[1].Error, "revert failed")
+	require.Equal(t, 3, calls[1].Error.(rpc.Error).ErrorCode())
+	require.Error(t, calls[2].Error)
+	require.Equal(t, errCodeMethodNotFound, calls[2].Error.(rpc.Error).ErrorCode())
+
+	recorded := server.Calls()
+	require.Equal(t, 3, len(recorded))
+	for _, call := range recorded {
+		require.Equal(t, 0, call.Batch)
+	}
+	server.ExpectCall(t, "anvil_mine", 1, 12)
+	server.ExpectCall(t, "evm_revert", "0x1")
+}
+
+func TestResetClearsCalls(t *testing.T) {
+	server := New()
+	defer server.Close()
+	server.Respond("eth_blockNumber", "0x1")
+
+	client, err := server.Client()
+	require.NoError(t, err)
+	defer client.Close()
+
+	require.NoError(t, client.Call(nil, "eth_blockNumber"))
+	require.Equal(t, 1, len(server.Calls()))
+
+	server.Reset()
+	require.Empty(t, server.Calls())
+	server.ExpectNoCall(t, "eth_blockNumber")
+}
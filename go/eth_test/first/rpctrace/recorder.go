@@ -0,0 +1,214 @@
+package rpctrace
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Entry is one HTTP round trip to the node. Request and Response hold either
+// a single JSON-RPC message or an array of messages for batches
+type Entry struct {
+	Time     time.Time       `json:"time"`
+	Duration time.Duration   `json:"duration"`
+	Request  json.RawMessage `json:"request"`
+	Response json.RawMessage `json:"response,omitempty"`
+	// Error is set when the round trip failed at the transport level
+	Error string `json:"error,omitempty"`
+}
+
+// Recorder is a http.RoundTripper recording JSON-RPC traffic.
+// Entries are kept in memory and, if an output is given, written as JSONL
+type Recorder struct {
+	// Transport used for the actual requests; http.DefaultTransport if nil
+	Transport http.RoundTripper
+
+	mu      sync.Mutex
+	out     io.Writer
+	entries []Entry
+	outErr  error
+}
+
+// NewRecorder creates a recorder that writes every entry as a JSON line to out.
+// out can be nil to keep the trace in memory only
+func NewRecorder(out io.Writer) *Recorder {
+	return &Recorder{out: out}
+}
+
+// Dial connects to an HTTP JSON-RPC endpoint recording all the traffic
+func (r *Recorder) Dial(url string) (*rpc.Client, error) {
+	return rpc.DialHTTPWithClient(url, &http.Client{Transport: r})
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	entry := Entry{Time: time.Now()}
+	if req.Body != nil {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		entry.Request = json.RawMessage(bytes.TrimSpace(body))
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		entry.Duration = time.Since(entry.Time)
+		entry.Error = err.Error()
+		r.add(entry)
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	entry.Duration = time.Since(entry.Time)
+	if err != nil {
+		entry.Error = err.Error()
+		r.add(entry)
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	body = bytes.TrimSpace(body)
+	if json.Valid(body) {
+		entry.Response = json.RawMessage(body)
+	} else {
+		entry.Error = fmt.Sprintf("%s: %s", resp.Status, body)
+	}
+	r.add(entry)
+	return resp, nil
+}
+
+func (r *Recorder) add(entry Entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, entry)
+	if r.out != nil && r.outErr == nil {
+		var line []byte
+		line, r.outErr = json.Marshal(entry)
+		if r.outErr == nil {
+			_, r.outErr = r.out.Write(append(line, '\n'))
+		}
+	}
+}
+
+// Entries returns the round trips recorded so far
+func (r *Recorder) Entries() []Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Entry(nil), r.entries...)
+}
+
+// Err returns the first error encountered while writing the trace
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.outErr
+}
+
+// Transcript returns a human readable form of the recorded traffic
+func (r *Recorder) Transcript() string {
+	return Transcript(r.Entries())
+}
+
+// TestingT is the subset of testing.TB used by PrintOnFailure
+type TestingT interface {
+	Cleanup(func())
+	Failed() bool
+	Logf(format string, args ...any)
+}
+
+// PrintOnFailure logs the transcript at the end of the test if it failed
+func (r *Recorder) PrintOnFailure(t TestingT) {
+	t.Cleanup(func() {
+		if t.Failed() {
+			t.Logf("JSON-RPC transcript:\n%s", r.Transcript())
+		}
+	})
+}
+
+type message struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method,omitempty"`
+	Params json.RawMessage `json:"params,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// decodeMessages returns the messages of a single or batch payload
+func decodeMessages(raw json.RawMessage) (msgs []message, batch bool, err error) {
+	if len(raw) > 0 && raw[0] == '[' {
+		err = json.Unmarshal(raw, &msgs)
+		return msgs, true, err
+	}
+	var msg message
+	if len(raw) > 0 {
+		err = json.Unmarshal(raw, &msg)
+	}
+	return []message{msg}, false, err
+}
+
+// Transcript formats entries as one line per call, e.g.
+//
+//	[+0s 1.2ms] eth_chainId() -> "0x7a69"
+func Transcript(entries []Entry) string {
+	if len(entries) == 0 {
+		return "(no JSON-RPC traffic)\n"
+	}
+	var buf strings.Builder
+	start := entries[0].Time
+	for _, entry := range entries {
+		fmt.Fprintf(&buf, "[+%s %s] ", entry.Time.Sub(start).Round(time.Millisecond), entry.Duration.Round(10*time.Microsecond))
+
+		requests, batch, err := decodeMessages(entry.Request)
+		if err != nil {
+			fmt.Fprintf(&buf, "invalid request %s: %s\n", entry.Request, err)
+			continue
+		}
+		responses, _, err := decodeMessages(entry.Response)
+		if err != nil {
+			fmt.Fprintf(&buf, "invalid response %s: %s\n", entry.Response, err)
+			continue
+		}
+		byID := make(map[string]message, len(responses))
+		for _, resp := range responses {
+			byID[string(resp.ID)] = resp
+		}
+
+		indent := ""
+		if batch {
+			fmt.Fprintf(&buf, "batch of %d\n", len(requests))
+			indent = "    "
+		}
+		for _, req := range requests {
+			fmt.Fprintf(&buf, "%s%s(%s) -> ", indent, req.Method, strings.TrimSuffix(strings.TrimPrefix(string(req.Params), "["), "]"))
+			resp, found := byID[string(req.ID)]
+			switch {
+			case entry.Error != "":
+				fmt.Fprintf(&buf, "transport error: %s\n", entry.Error)
+			case !found:
+				buf.WriteString("no response\n")
+			case resp.Error != nil:
+				fmt.Fprintf(&buf, "error %d: %s\n", resp.Error.Code, resp.Error.Message)
+			default:
+				fmt.Fprintf(&buf, "%s\n", resp.Result)
+			}
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,144 @@
+package rpctrace
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sync"
+
+	"first/rpcmock"
+)
+
+const errCodeNotRecorded = -32000
+
+// LoadTrace reads a JSONL trace written by a Recorder
+func LoadTrace(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadTrace(f)
+}
+
+func ReadTrace(r io.Reader) ([]Entry, error) {
+	entries := make([]Entry, 0)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("invalid trace entry at line %d: %w", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+type recordedCall struct {
+	params   json.RawMessage
+	response message
+	used     bool
+}
+
+// replayer answers calls with the recorded responses in the recorded order.
+// A call is matched to the first unused recording with the same method and params
+type replayer struct {
+	mu    sync.Mutex
+	calls map[string][]*recordedCall
+}
+
+func (r *replayer) handler(method string) rpcmock.Handler {
+	return func(params []json.RawMessage) (interface{}, error) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		for _, call := range r.calls[method] {
+			if call.used || !sameParams(call.params, params) {
+				continue
+			}
+			call.used = true
+			if call.response.Error != nil {
+				return nil, &rpcmock.Error{Code: call.response.Error.Code, Message: call.response.Error.Message}
+			}
+			if call.response.Result == nil {
+				return nil, nil
+			}
+			return call.response.Result, nil
+		}
+		return nil, &rpcmock.Error{Code: errCodeNotRecorded, Message: fmt.Sprintf("no recorded response left for %s", method)}
+	}
+}
+
+// Replay serves recorded entries as a fake node
+func Replay(entries []Entry) (*rpcmock.Server, error) {
+	r := &replayer{calls: make(map[string][]*recordedCall)}
+	for i, entry := range entries {
+		if entry.Error != "" {
+			continue
+		}
+		requests, _, err := decodeMessages(entry.Request)
+		if err != nil {
+			return nil, fmt.Errorf("invalid request in entry %d: %w", i, err)
+		}
+		responses, _, err := decodeMessages(entry.Response)
+		if err != nil {
+			return nil, fmt.Errorf("invalid response in entry %d: %w", i, err)
+		}
+		byID := make(map[string]message, len(responses))
+		for _, resp := range responses {
+			byID[string(resp.ID)] = resp
+		}
+		for _, req := range requests {
+			resp, found := byID[string(req.ID)]
+			if !found {
+				continue
+			}
+			r.calls[req.Method] = append(r.calls[req.Method], &recordedCall{params: req.Params, response: resp})
+		}
+	}
+
+	server := rpcmock.New()
+	for method := range r.calls {
+		server.Handle(method, r.handler(method))
+	}
+	return server, nil
+}
+
+// ReplayFile serves the trace at path as a fake node
+func ReplayFile(path string) (*rpcmock.Server, error) {
+	entries, err := LoadTrace(path)
+	if err != nil {
+		return nil, err
+	}
+	return Replay(entries)
+}
+
+func sameParams(recorded json.RawMessage, params []json.RawMessage) bool {
+	var expected []interface{}
+	if len(recorded) > 0 && string(recorded) != "null" {
+		if json.Unmarshal(recorded, &expected) != nil {
+			return false
+		}
+	}
+	if len(expected) != len(params) {
+		return false
+	}
+	for i, param := range params {
+		var actual interface{}
+		if json.Unmarshal(param, &actual) != nil {
+			return false
+		}
+		if !reflect.DeepEqual(expected[i], actual) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,97 @@
+package rpctrace
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"first/rpcmock"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+func recordSession(t *testing.T, out io.Writer) *Recorder {
+	node := rpcmock.New()
+	defer node.Close()
+	node.Respond("eth_chainId", "0x7a69")
+	node.Respond("anvil_mine", nil)
+	node.RespondError("evm_revert", 3, "unknown snapshot")
+
+	recorder := NewRecorder(out)
+	client, err := recorder.Dial(node.URL())
+	require.NoError(t, err)
+	defer client.Close()
+
+	var chainID string
+	require.NoError(t, client.Call(&chainID, "eth_chainId"))
+
+	calls := []rpc.BatchElem{
+		{Method: "anvil_mine", Args: []any{2, 12}, Result: new(interface{})},
+		{Method: "evm_revert", Args: []any{5}, Result: new(bool)},
+	}
+	require.NoError(t, client.BatchCall(calls))
+	require.Error(t, calls[1].Error)
+	return recorder
+}
+
+func TestRecorderWritesJSONL(t *testing.T) {
+	var out bytes.Buffer
+	recorder := recordSession(t, &out)
+	require.NoError(t, recorder.Err())
+
+	entries := recorder.Entries()
+	require.Equal(t, 2, len(entries))
+	require.Equal(t, 2, bytes.Count(out.Bytes(), []byte("\n")))
+
+	loaded, err := ReadTrace(&out)
+	require.NoError(t, err)
+	require.Equal(t, len(entries), len(loaded))
+	require.JSONEq(t, string(entries[1].Request), string(loaded[1].Request))
+	require.Equal(t, entries[1].Duration, loaded[1].Duration)
+}
+
+func TestTranscript(t *testing.T) {
+	recorder := recordSession(t, nil)
+
+	transcript := recorder.Transcript()
+	require.Contains(t, transcript, `eth_chainId() -> "0x7a69"`)
+	require.Contains(t, transcript, "batch of 2")
+	require.Contains(t, transcript, "    anvil_mine(2,12) -> null")
+	require.Contains(t, transcript, "    evm_revert(5) -> error 3: unknown snapshot")
+}
+
+func TestReplayServesRecordedResponses(t *testing.T) {
+	var out bytes.Buffer
+	recordSession(t, &out)
+	entries, err := ReadTrace(&out)
+	require.NoError(t, err)
+
+	node, err := Replay(entries)
+	require.NoError(t, err)
+	defer node.Close()
+
+	client, err := node.Client()
+	require.NoError(t, err)
+	defer client.Close()
+
+	var chainID string
+	require.NoError(t, client.Call(&chainID, "eth_chainId"))
+	require.Equal(t, "0x7a69", chainID)
+
+	calls := []rpc.BatchElem{
+		{Method: "anvil_mine", Args: []any{2, 12}, Result: new(interface{})},
+		{Method: "evm_revert", Args: []any{5}, Result: new(bool)},
+	}
+	require.NoError(t, client.BatchCall(calls))
+	require.NoError(t, calls[0].Error)
+	require.EqualError(t, calls[1].Error, "unknown snapshot")
+
+	// Every recorded response is served only once
+	err = client.Call(&chainID, "eth_chainId")
+	require.Error(t, err)
+
+	// Params have to match the recording
+	err = client.Call(nil, "anvil_mine", 3, 12)
+	require.Error(t, err)
+}
@@ -0,0 +1,180 @@
+package first
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario is a script of dev API steps, e.g.
+//
+//	steps:
+//	  - mine: {blocks: 10, block_time: 12s}
+//	  - fund: {address: "0x70997970C51812dc3A010C7d01b50e0d17dc79C8", amount: "1.5"}
+//	  - snapshot: funded
+//	  - warp: 24h
+//	  - revert: funded
+type Scenario struct {
+	Steps []ScenarioStep `yaml:"steps"`
+}
+
+// ScenarioStep has exactly one of its fields set
+type ScenarioStep struct {
+	Mine *MineStep     `yaml:"mine"`
+	Warp time.Duration `yaml:"warp"`
+	Fund *FundStep     `yaml:"fund"`
+	// Snapshot names the snapshot for a later Revert step
+	Snapshot string `yaml:"snapshot"`
+	Revert   string `yaml:"revert"`
+}
+
+type MineStep struct {
+	Blocks int `yaml:"blocks"`
+	// BlockTime is DefaultBlockTime if not set
+	BlockTime time.Duration `yaml:"block_time"`
+}
+
+type FundStep struct {
+	Address common.Address `yaml:"address"`
+	// Amount is in ether, see ParseEther
+	Amount string `yaml:"amount"`
+}
+
+func (s *ScenarioStep) kind() (string, error) {
+	var kinds []string
+	if s.Mine != nil {
+		kinds = append(kinds, "mine")
+	}
+	if s.Warp != 0 {
+		kinds = append(kinds, "warp")
+	}
+	if s.Fund != nil {
+		kinds = append(kinds, "fund")
+	}
+	if s.Snapshot != "" {
+		kinds = append(kinds, "snapshot")
+	}
+	if s.Revert != "" {
+		kinds = append(kinds, "revert")
+	}
+	if len(kinds) != 1 {
+		return "", fmt.Errorf("a step needs exactly one of mine, warp, fund, snapshot and revert, got %v", kinds)
+	}
+	return kinds[0], nil
+}
+
+// ParseScenario decodes a YAML scenario and checks its steps
+func ParseScenario(r io.Reader) (*Scenario, error) {
+	decoder := yaml.NewDecoder(r)
+	decoder.KnownFields(true)
+	var scenario Scenario
+	err := decoder.Decode(&scenario)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	snapshots := make(map[string]bool)
+	for i, step := range scenario.Steps {
+		kind, err := step.kind()
+		if err != nil {
+			return nil, fmt.Errorf("step %d: %w", i+1, err)
+		}
+		switch kind {
+		case "mine":
+			if step.Mine.Blocks <= 0 {
+				return nil, fmt.Errorf("step %d: mine needs a positive number of blocks", i+1)
+			}
+		case "fund":
+			_, err = ParseEther(step.Fund.Amount)
+			if err != nil {
+				return nil, fmt.Errorf("step %d: %w", i+1, err)
+			}
+		case "snapshot":
+			snapshots[step.Snapshot] = true
+		case "revert":
+			if !snapshots[step.Revert] {
+				return nil, fmt.Errorf("step %d: no snapshot %q before the revert", i+1, step.Revert)
+			}
+		}
+	}
+	return &scenario, nil
+}
+
+// LoadScenario reads a YAML scenario file
+func LoadScenario(path string) (*Scenario, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseScenario(f)
+}
+
+// Run applies the steps in order. log, if not nil, gets a line per step
+func (s *Scenario) Run(ctx context.Context, anvil *Anvil, log io.Writer) error {
+	if log == nil {
+		log = io.Discard
+	}
+	snapshots := make(map[string]int)
+	for i, step := range s.Steps {
+		kind, err := step.kind()
+		if err != nil {
+			return fmt.Errorf("step %d: %w", i+1, err)
+		}
+		var message string
+		switch kind {
+		case "mine":
+			blockTime := step.Mine.BlockTime
+			if blockTime == 0 {
+				blockTime = DefaultBlockTime
+			}
+			err = anvil.MineBlocks(step.Mine.Blocks, blockTime)
+			message = fmt.Sprintf("mined %d blocks, %s apart\n", step.Mine.Blocks, blockTime)
+		case "warp":
+			_, err = anvil.IncreaseTime(step.Warp)
+			message = fmt.Sprintf("warped %s\n", step.Warp)
+		case "fund":
+			var amount *big.Int
+			amount, err = ParseEther(step.Fund.Amount)
+			if err == nil {
+				err = anvil.SetBalance(ctx, step.Fund.Address, amount)
+			}
+			message = fmt.Sprintf("funded %s with %s ether\n", step.Fund.Address, step.Fund.Amount)
+		case "snapshot":
+			snapshots[step.Snapshot], err = anvil.TakeSnapshot()
+			message = fmt.Sprintf("snapshot %s\n", step.Snapshot)
+		case "revert":
+			snapshotId, found := snapshots[step.Revert]
+			if !found {
+				return fmt.Errorf("step %d: no snapshot %q", i+1, step.Revert)
+			}
+			err = anvil.RevertSnapshot(snapshotId)
+			message = fmt.Sprintf("reverted to %s\n", step.Revert)
+		}
+		if err != nil {
+			return fmt.Errorf("step %d (%s): %w", i+1, kind, err)
+		}
+		fmt.Fprint(log, message)
+	}
+	return nil
+}
+
+// ParseEther converts a decimal amount of ether, e.g. "1.5", to wei
+func ParseEther(amount string) (*big.Int, error) {
+	value, ok := new(big.Rat).SetString(strings.TrimSpace(amount))
+	if !ok || value.Sign() < 0 {
+		return nil, fmt.Errorf("invalid ether amount %q", amount)
+	}
+	value.Mul(value, new(big.Rat).SetInt(weiInEth()))
+	if !value.IsInt() {
+		return nil, fmt.Errorf("ether amount %q has more than 18 decimals", amount)
+	}
+	return new(big.Int).Set(value.Num()), nil
+}
@@ -0,0 +1,86 @@
+package first
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+// testScenario is testdata/scenario.yaml
+const testScenario = `
+steps:
+  - mine: {blocks: 10}
+  - fund: {address: "0x70997970C51812dc3A010C7d01b50e0d17dc79C8", amount: "1.5"}
+  - snapshot: funded
+  - warp: 24h
+  - mine: {blocks: 2, block_time: 2s}
+  - revert: funded
+`
+
+func TestScenarioRunAppliesStepsInOrder(t *testing.T) {
+	server, anvil, tearDown := setupTestingWithMock(t)
+	defer tearDown()
+	server.Respond("anvil_setBalance", nil)
+	server.Respond("evm_snapshot", "0x3")
+	server.Respond("evm_increaseTime", 86400)
+
+	scenario, err := LoadScenario("testdata/scenario.yaml")
+	require.NoError(t, err)
+	var log bytes.Buffer
+	require.NoError(t, scenario.Run(context.Background(), anvil, &log))
+
+	server.ExpectCall(t, "anvil_mine", 10, 12)
+	server.ExpectCall(t, "anvil_mine", 2, 2)
+	server.ExpectCall(t, "anvil_setBalance", common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8"), "0x14d1120d7b160000")
+	server.ExpectCall(t, "evm_increaseTime", "0x15180")
+	// The revert of the scenario, then the one of tearDown
+	server.ExpectCall(t, "evm_revert", 3)
+	require.Equal(t, 6, strings.Count(log.String(), "\n"))
+	require.Contains(t, log.String(), "reverted to funded")
+}
+
+func TestScenarioRunStopsAtFailingStep(t *testing.T) {
+	server, anvil, tearDown := setupTestingWithMock(t)
+	defer tearDown()
+	server.RespondError("anvil_setBalance", -32601, "method not found")
+
+	scenario, err := ParseScenario(strings.NewReader(testScenario))
+	require.NoError(t, err)
+	err = scenario.Run(context.Background(), anvil, nil)
+	require.ErrorContains(t, err, "step 2 (fund)")
+	server.ExpectNoCall(t, "evm_increaseTime")
+}
+
+func TestParseScenarioRejectsInvalidSteps(t *testing.T) {
+	for scenario, message := range map[string]string{
+		"steps:\n  - {mine: {blocks: 1}, warp: 1h}":    "exactly one of",
+		"steps:\n  - mine: {blocks: 0}":                "positive number of blocks",
+		"steps:\n  - fund: {amount: abc}":              `invalid ether amount "abc"`,
+		"steps:\n  - revert: missing":                  `no snapshot "missing"`,
+		"steps:\n  - sleep: 1h":                        "field sleep not found",
+		"steps:\n  - fund: {address: 0x12, amount: 1}": "hex string has length 2",
+	} {
+		_, err := ParseScenario(strings.NewReader(scenario))
+		require.ErrorContains(t, err, message, scenario)
+	}
+}
+
+func TestParseEther(t *testing.T) {
+	amount, err := ParseEther("1.5")
+	require.NoError(t, err)
+	require.Equal(t, new(big.Int).Div(ethToWei(3), big.NewInt(2)), amount)
+
+	amount, err = ParseEther("0.000000000000000001")
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(1), amount)
+
+	_, err = ParseEther("0.0000000000000000001")
+	require.ErrorContains(t, err, "more than 18 decimals")
+	_, err = ParseEther("-1")
+	require.Error(t, err)
+}
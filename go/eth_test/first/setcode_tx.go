@@ -0,0 +1,75 @@
+package first
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/holiman/uint256"
+)
+
+// SignAuthorization signs an EIP-7702 authorization delegating the sender's account to
+// delegate. nonce is the account nonce when the authorization is applied: if the sender
+// also sends the set-code transaction, that's the transaction nonce + 1
+func (s *TxSender) SignAuthorization(delegate common.Address, nonce uint64) (types.SetCodeAuthorization, error) {
+	return types.SignSetCode(s.privateKey, types.SetCodeAuthorization{
+		ChainID: *uint256.MustFromBig(s.chainID),
+		Address: delegate,
+		Nonce:   nonce,
+	})
+}
+
+// SendSetCode signs and broadcasts a set-code transaction applying authorizations. The
+// node has to run Prague or later
+func (s *TxSender) SendSetCode(ctx context.Context, to common.Address, authorizations []types.SetCodeAuthorization, value *big.Int, data []byte) (*types.Transaction, error) {
+	if value == nil {
+		value = new(big.Int)
+	}
+	fees, err := s.prepare(ctx, ethereum.CallMsg{
+		To:                &to,
+		Value:             value,
+		Data:              data,
+		AuthorizationList: authorizations,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tx := types.NewTx(&types.SetCodeTx{
+		ChainID:   uint256.MustFromBig(s.chainID),
+		Nonce:     fees.nonce,
+		GasTipCap: uint256.MustFromBig(fees.tipCap),
+		GasFeeCap: uint256.MustFromBig(fees.feeCap),
+		Gas:       fees.gasLimit,
+		To:        to,
+		Value:     uint256.MustFromBig(value),
+		Data:      data,
+		AuthList:  authorizations,
+	})
+	return s.SendTx(ctx, tx)
+}
+
+// DelegateSelf delegates the sender's own account to delegate with a single transaction
+func (s *TxSender) DelegateSelf(ctx context.Context, delegate common.Address) (*types.Transaction, error) {
+	nonce, err := s.client.PendingNonceAt(ctx, s.from)
+	if err != nil {
+		return nil, err
+	}
+	authorization, err := s.SignAuthorization(delegate, nonce+1)
+	if err != nil {
+		return nil, err
+	}
+	return s.SendSetCode(ctx, s.from, []types.SetCodeAuthorization{authorization}, nil, nil)
+}
+
+// DelegationOf returns the address account delegates to, if its code is an EIP-7702 delegation
+func DelegationOf(ctx context.Context, client ethereum.ChainStateReader, account common.Address, blockNumber *big.Int) (common.Address, bool, error) {
+	code, err := client.CodeAt(ctx, account, blockNumber)
+	if err != nil {
+		return common.Address{}, false, err
+	}
+	delegate, ok := types.ParseDelegation(code)
+	return delegate, ok, nil
+}
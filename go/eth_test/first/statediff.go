@@ -0,0 +1,335 @@
+package first
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+// AccountWatch is an account compared by StateDiff, with the storage slots to compare
+type AccountWatch struct {
+	Address common.Address
+	Slots   []common.Hash
+}
+
+// WatchAccounts compares balance, nonce and code of addresses, no storage
+func WatchAccounts(addresses ...common.Address) []AccountWatch {
+	res := make([]AccountWatch, 0, len(addresses))
+	for _, address := range addresses {
+		res = append(res, AccountWatch{Address: address})
+	}
+	return res
+}
+
+type accountState struct {
+	balance *Result[*big.Int]
+	nonce   *Result[uint64]
+	code    *Result[[]byte]
+	storage []*Result[common.Hash]
+}
+
+// AccountDiff is the state of an account at both blocks of a StateDiff
+type AccountDiff struct {
+	Address       common.Address
+	BalanceBefore *big.Int
+	BalanceAfter  *big.Int
+	NonceBefore   uint64
+	NonceAfter    uint64
+	CodeBefore    []byte
+	CodeAfter     []byte
+	// Storage has the watched slots that changed, in the order they were given
+	Storage []StorageChange
+	// GasPaid is the fee of the transactions sent by the account in (fromBlock, toBlock]
+	GasPaid *big.Int
+}
+
+// BalanceDelta is positive if the account gained ether
+func (d *AccountDiff) BalanceDelta() *big.Int {
+	return new(big.Int).Sub(d.BalanceAfter, d.BalanceBefore)
+}
+
+func (d *AccountDiff) CodeChanged() bool {
+	return !bytes.Equal(d.CodeBefore, d.CodeAfter)
+}
+
+func (d *AccountDiff) Changed() bool {
+	return d.BalanceBefore.Cmp(d.BalanceAfter) != 0 || d.NonceBefore != d.NonceAfter || d.CodeChanged() || len(d.Storage) > 0
+}
+
+// BlockStateDiff is the result of StateDiff
+type BlockStateDiff struct {
+	FromBlock *big.Int
+	ToBlock   *big.Int
+	Accounts  map[common.Address]*AccountDiff
+}
+
+// StateDiff compares accounts between fromBlock and toBlock; nil toBlock is the latest
+// block, fromBlock is required. The state of each block is fetched in a single batch
+func StateDiff(ctx context.Context, client *rpc.Client, accounts []AccountWatch, fromBlock *big.Int, toBlock *big.Int) (*BlockStateDiff, error) {
+	if fromBlock == nil {
+		return nil, errors.New("state diff needs a from block")
+	}
+	var err error
+	if toBlock == nil {
+		var latest hexutil.Big
+		err = client.CallContext(ctx, &latest, "eth_blockNumber")
+		if err != nil {
+			return nil, err
+		}
+		toBlock = latest.ToInt()
+	}
+
+	before, err := fetchAccountStates(ctx, client, accounts, fromBlock)
+	if err != nil {
+		return nil, err
+	}
+	after, err := fetchAccountStates(ctx, client, accounts, toBlock)
+	if err != nil {
+		return nil, err
+	}
+	gasPaid, err := gasPaidBetween(ctx, client, fromBlock.Uint64(), toBlock.Uint64())
+	if err != nil {
+		return nil, err
+	}
+
+	res := &BlockStateDiff{FromBlock: fromBlock, ToBlock: toBlock, Accounts: make(map[common.Address]*AccountDiff, len(accounts))}
+	for i, account := range accounts {
+		diff := &AccountDiff{
+			Address:       account.Address,
+			BalanceBefore: before[i].balance.Value,
+			BalanceAfter:  after[i].balance.Value,
+			NonceBefore:   before[i].nonce.Value,
+			NonceAfter:    after[i].nonce.Value,
+			CodeBefore:    before[i].code.Value,
+			CodeAfter:     after[i].code.Value,
+			GasPaid:       new(big.Int),
+		}
+		if paid, found := gasPaid[account.Address]; found {
+			diff.GasPaid = paid
+		}
+		for j, slot := range account.Slots {
+			if before[i].storage[j].Value != after[i].storage[j].Value {
+				diff.Storage = append(diff.Storage, StorageChange{Slot: slot, Before: before[i].storage[j].Value, After: after[i].storage[j].Value})
+			}
+		}
+		res.Accounts[account.Address] = diff
+	}
+	return res, nil
+}
+
+func fetchAccountStates(ctx context.Context, client *rpc.Client, accounts []AccountWatch, blockNumber *big.Int) ([]accountState, error) {
+	multicall := NewMulticall(client, blockNumber)
+	states := make([]accountState, 0, len(accounts))
+	for _, account := range accounts {
+		state := accountState{
+			balance: multicall.Balance(account.Address),
+			nonce:   multicall.Nonce(account.Address),
+			code:    multicall.Code(account.Address),
+		}
+		for _, slot := range account.Slots {
+			state.storage = append(state.storage, multicall.StorageAt(account.Address, slot))
+		}
+		states = append(states, state)
+	}
+	err := multicall.Execute(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, state := range states {
+		for _, err := range []error{state.balance.Err, state.nonce.Err, state.code.Err} {
+			if err != nil {
+				return nil, err
+			}
+		}
+		for _, slot := range state.storage {
+			if slot.Err != nil {
+				return nil, slot.Err
+			}
+		}
+	}
+	return states, nil
+}
+
+type feeTransaction struct {
+	Hash     common.Hash    `json:"hash"`
+	From     common.Address `json:"from"`
+	GasPrice *hexutil.Big   `json:"gasPrice"`
+}
+
+type feeReceipt struct {
+	GasUsed           hexutil.Uint64 `json:"gasUsed"`
+	EffectiveGasPrice *hexutil.Big   `json:"effectiveGasPrice"`
+	BlobGasUsed       hexutil.Uint64 `json:"blobGasUsed"`
+	BlobGasPrice      *hexutil.Big   `json:"blobGasPrice"`
+}
+
+// gasPaidBetween sums the fees per sender of the transactions in (fromBlock, toBlock], blob
+// gas included. The price is the receipt's effectiveGasPrice, the transaction's gasPrice if missing
+func gasPaidBetween(ctx context.Context, client *rpc.Client, fromBlock uint64, toBlock uint64) (map[common.Address]*big.Int, error) {
+	res := make(map[common.Address]*big.Int)
+	if toBlock <= fromBlock {
+		return res, nil
+	}
+
+	blocks := make([]struct {
+		Transactions []feeTransaction `json:"transactions"`
+	}, toBlock-fromBlock)
+	calls := make([]rpc.BatchElem, 0, len(blocks))
+	for i := range blocks {
+		calls = append(calls, prepareCall("eth_getBlockByNumber", []any{hexutil.EncodeUint64(fromBlock + 1 + uint64(i)), true}, &blocks[i]))
+	}
+	err := batchCall(ctx, client, calls)
+	if err != nil {
+		return nil, err
+	}
+
+	var transactions []feeTransaction
+	for _, block := range blocks {
+		transactions = append(transactions, block.Transactions...)
+	}
+	if len(transactions) == 0 {
+		return res, nil
+	}
+	receipts := make([]feeReceipt, len(transactions))
+	calls = make([]rpc.BatchElem, 0, len(transactions))
+	for i, tx := range transactions {
+		calls = append(calls, prepareCall("eth_getTransactionReceipt", []any{tx.Hash}, &receipts[i]))
+	}
+	err = batchCall(ctx, client, calls)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, tx := range transactions {
+		price := receipts[i].EffectiveGasPrice
+		if price == nil {
+			price = tx.GasPrice
+		}
+		if price == nil {
+			return nil, fmt.Errorf("no gas price for transaction %s", tx.Hash)
+		}
+		fee := new(big.Int).Mul(price.ToInt(), new(big.Int).SetUint64(uint64(receipts[i].GasUsed)))
+		if receipts[i].BlobGasPrice != nil {
+			fee.Add(fee, new(big.Int).Mul(receipts[i].BlobGasPrice.ToInt(), new(big.Int).SetUint64(uint64(receipts[i].BlobGasUsed))))
+		}
+		if _, found := res[tx.From]; !found {
+			res[tx.From] = new(big.Int)
+		}
+		res[tx.From].Add(res[tx.From], fee)
+	}
+	return res, nil
+}
+
+func batchCall(ctx context.Context, client *rpc.Client, calls []rpc.BatchElem) error {
+	err := client.BatchCallContext(ctx, calls)
+	if err != nil {
+		return err
+	}
+	for _, call := range calls {
+		if call.Error != nil {
+			return call.Error
+		}
+	}
+	return nil
+}
+
+// AccountExpectation asserts the changes of an account, e.g.
+//
+//	diff.Expect(t, addresses[0]).LostPlusGas(ethToWei(1)).NonceIncreasedBy(1)
+//	diff.Expect(t, addresses[1]).Gained(ethToWei(1))
+type AccountExpectation struct {
+	t    require.TestingT
+	diff *AccountDiff
+}
+
+type tHelper interface {
+	Helper()
+}
+
+// Expect starts the assertions for address, which must be one of the compared accounts
+func (d *BlockStateDiff) Expect(t require.TestingT, address common.Address) *AccountExpectation {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+	diff, found := d.Accounts[address]
+	require.True(t, found, "account %s isn't part of the state diff", address)
+	return &AccountExpectation{t: t, diff: diff}
+}
+
+func (e *AccountExpectation) requireBalanceDelta(expected *big.Int, description string) *AccountExpectation {
+	if h, ok := e.t.(tHelper); ok {
+		h.Helper()
+	}
+	delta := e.diff.BalanceDelta()
+	require.Zero(e.t, expected.Cmp(delta), "account %s: expected balance change %s (%s), got %s", e.diff.Address, expected, description, delta)
+	return e
+}
+
+// Gained requires the balance to increase by exactly amount
+func (e *AccountExpectation) Gained(amount *big.Int) *AccountExpectation {
+	return e.requireBalanceDelta(amount, fmt.Sprintf("gained %s", amount))
+}
+
+// Lost requires the balance to decrease by exactly amount; see LostPlusGas for senders
+func (e *AccountExpectation) Lost(amount *big.Int) *AccountExpectation {
+	return e.requireBalanceDelta(new(big.Int).Neg(amount), fmt.Sprintf("lost %s", amount))
+}
+
+// LostPlusGas requires the balance to decrease by amount plus the fees paid by the account
+func (e *AccountExpectation) LostPlusGas(amount *big.Int) *AccountExpectation {
+	lost := new(big.Int).Add(amount, e.diff.GasPaid)
+	return e.requireBalanceDelta(new(big.Int).Neg(lost), fmt.Sprintf("lost %s + %s gas", amount, e.diff.GasPaid))
+}
+
+// PaidOnlyGas requires the balance to decrease by the fees paid by the account
+func (e *AccountExpectation) PaidOnlyGas() *AccountExpectation {
+	return e.LostPlusGas(new(big.Int))
+}
+
+func (e *AccountExpectation) NonceIncreasedBy(n uint64) *AccountExpectation {
+	if h, ok := e.t.(tHelper); ok {
+		h.Helper()
+	}
+	require.Equal(e.t, n, e.diff.NonceAfter-e.diff.NonceBefore, "account %s: nonce went from %d to %d", e.diff.Address, e.diff.NonceBefore, e.diff.NonceAfter)
+	return e
+}
+
+// HasCode requires code to be deployed at the account by the end of the range
+func (e *AccountExpectation) HasCode() *AccountExpectation {
+	if h, ok := e.t.(tHelper); ok {
+		h.Helper()
+	}
+	require.NotEmpty(e.t, e.diff.CodeAfter, "account %s has no code", e.diff.Address)
+	return e
+}
+
+// StorageChangedTo requires a watched slot to change to value
+func (e *AccountExpectation) StorageChangedTo(slot common.Hash, value common.Hash) *AccountExpectation {
+	if h, ok := e.t.(tHelper); ok {
+		h.Helper()
+	}
+	for _, change := range e.diff.Storage {
+		if change.Slot == slot {
+			require.Equal(e.t, value, change.After, "account %s: slot %s", e.diff.Address, slot)
+			return e
+		}
+	}
+	require.Fail(e.t, fmt.Sprintf("account %s: slot %s didn't change", e.diff.Address, slot))
+	return e
+}
+
+// Unchanged requires balance, nonce, code and watched storage to be the same at both blocks
+func (e *AccountExpectation) Unchanged() *AccountExpectation {
+	if h, ok := e.t.(tHelper); ok {
+		h.Helper()
+	}
+	require.False(e.t, e.diff.Changed(), "account %s changed: balance %s, nonce %d -> %d, %d slots", e.diff.Address, e.diff.BalanceDelta(), e.diff.NonceBefore, e.diff.NonceAfter, len(e.diff.Storage))
+	return e
+}
@@ -0,0 +1,186 @@
+package first
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/require"
+)
+
+// diffChainState is the state served for one block
+type diffChainState struct {
+	balances map[common.Address]int64
+	nonces   map[common.Address]uint64
+	code     map[common.Address]string
+	storage  map[common.Hash]common.Hash
+}
+
+func blockParam(t *testing.T, raw json.RawMessage) uint64 {
+	var block hexutil.Uint64
+	require.NoError(t, json.Unmarshal(raw, &block))
+	return uint64(block)
+}
+
+func addressParam(t *testing.T, raw json.RawMessage) common.Address {
+	var address common.Address
+	require.NoError(t, json.Unmarshal(raw, &address))
+	return address
+}
+
+var (
+	diffSender   = common.HexToAddress("0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266")
+	diffReceiver = common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8")
+	diffContract = common.HexToAddress("0x5FbDB2315678afecb367f032d93F642f64180aa3")
+	diffTxHash   = common.HexToHash("0x01")
+)
+
+// serveTransferDiff serves block 5 and block 7, where block 6 has a transfer of 1000 wei
+// from diffSender to diffReceiver paying 21000 gas at 2 wei, and a contract deployment
+func serveTransferDiff(t *testing.T) *Anvil {
+	server, anvil, tearDown := setupTestingWithMock(t)
+	t.Cleanup(tearDown)
+
+	states := map[uint64]*diffChainState{
+		5: {
+			balances: map[common.Address]int64{diffSender: 100000, diffReceiver: 10},
+			nonces:   map[common.Address]uint64{diffSender: 3},
+			code:     map[common.Address]string{},
+			storage:  map[common.Hash]common.Hash{},
+		},
+		7: {
+			balances: map[common.Address]int64{diffSender: 100000 - 1000 - 21000*2, diffReceiver: 1010},
+			nonces:   map[common.Address]uint64{diffSender: 4, diffContract: 1},
+			code:     map[common.Address]string{diffContract: "0x6080"},
+			storage:  map[common.Hash]common.Hash{{2}: {0x2a}},
+		},
+	}
+	state := func(params []json.RawMessage) *diffChainState {
+		res, found := states[blockParam(t, params[len(params)-1])]
+		require.True(t, found)
+		return res
+	}
+
+	server.Handle("eth_blockNumber", func(params []json.RawMessage) (interface{}, error) {
+		return "0x7", nil
+	})
+	server.Handle("eth_getBalance", func(params []json.RawMessage) (interface{}, error) {
+		return (*hexutil.Big)(big.NewInt(state(params).balances[addressParam(t, params[0])])), nil
+	})
+	server.Handle("eth_getTransactionCount", func(params []json.RawMessage) (interface{}, error) {
+		return hexutil.Uint64(state(params).nonces[addressParam(t, params[0])]), nil
+	})
+	server.Handle("eth_getCode", func(params []json.RawMessage) (interface{}, error) {
+		code := state(params).code[addressParam(t, params[0])]
+		if code == "" {
+			code = "0x"
+		}
+		return code, nil
+	})
+	server.Handle("eth_getStorageAt", func(params []json.RawMessage) (interface{}, error) {
+		var slot common.Hash
+		require.NoError(t, json.Unmarshal(params[1], &slot))
+		return state(params).storage[slot], nil
+	})
+	server.Handle("eth_getBlockByNumber", func(params []json.RawMessage) (interface{}, error) {
+		var transactions []interface{}
+		if blockParam(t, params[0]) == 6 {
+			transactions = append(transactions, map[string]interface{}{
+				"hash":     diffTxHash,
+				"from":     diffSender,
+				"gasPrice": "0x3",
+			})
+		}
+		return map[string]interface{}{"transactions": transactions}, nil
+	})
+	server.Handle("eth_getTransactionReceipt", func(params []json.RawMessage) (interface{}, error) {
+		return map[string]interface{}{"gasUsed": "0x5208", "effectiveGasPrice": "0x2"}, nil
+	})
+	return anvil
+}
+
+func TestStateDiffBetweenBlocks(t *testing.T) {
+	anvil := serveTransferDiff(t)
+	accounts := append(WatchAccounts(diffSender, diffReceiver), AccountWatch{Address: diffContract, Slots: []common.Hash{{1}, {2}}})
+
+	diff, err := StateDiff(context.Background(), anvil.Client(), accounts, big.NewInt(5), nil)
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(7), diff.ToBlock)
+
+	sender := diff.Accounts[diffSender]
+	require.Equal(t, big.NewInt(-43000), sender.BalanceDelta())
+	require.Equal(t, big.NewInt(42000), sender.GasPaid)
+	require.Equal(t, uint64(3), sender.NonceBefore)
+	require.Equal(t, uint64(4), sender.NonceAfter)
+	require.False(t, sender.CodeChanged())
+
+	contract := diff.Accounts[diffContract]
+	require.True(t, contract.CodeChanged())
+	require.Equal(t, []StorageChange{{Slot: common.Hash{2}, Before: common.Hash{}, After: common.Hash{0x2a}}}, contract.Storage)
+	require.Zero(t, diff.Accounts[diffReceiver].GasPaid.Sign())
+
+	diff.Expect(t, diffSender).LostPlusGas(big.NewInt(1000)).NonceIncreasedBy(1)
+	diff.Expect(t, diffReceiver).Gained(big.NewInt(1000)).NonceIncreasedBy(0)
+	diff.Expect(t, diffContract).HasCode().StorageChangedTo(common.Hash{2}, common.Hash{0x2a})
+}
+
+// recordingT collects the failures of the assertions
+type recordingT struct {
+	failures []string
+}
+
+type failNow struct{}
+
+func (r *recordingT) Errorf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func (r *recordingT) FailNow() {
+	panic(failNow{})
+}
+
+func expectFailure(t *testing.T, assert func(rt require.TestingT)) string {
+	rt := &recordingT{}
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				require.Equal(t, failNow{}, r)
+			}
+		}()
+		assert(rt)
+	}()
+	require.Equal(t, 1, len(rt.failures))
+	return rt.failures[0]
+}
+
+func TestStateDiffExpectationsReportMismatches(t *testing.T) {
+	anvil := serveTransferDiff(t)
+	diff, err := StateDiff(context.Background(), anvil.Client(), WatchAccounts(diffSender, diffReceiver), big.NewInt(5), big.NewInt(7))
+	require.NoError(t, err)
+
+	// Without gas the sender lost more than expected
+	message := expectFailure(t, func(rt require.TestingT) { diff.Expect(rt, diffSender).Lost(big.NewInt(1000)) })
+	require.Contains(t, message, "expected balance change -1000 (lost 1000), got -43000")
+
+	message = expectFailure(t, func(rt require.TestingT) { diff.Expect(rt, diffReceiver).Gained(big.NewInt(999)) })
+	require.Contains(t, message, "got 1000")
+
+	message = expectFailure(t, func(rt require.TestingT) { diff.Expect(rt, diffReceiver).Unchanged() })
+	require.Contains(t, message, diffReceiver.Hex())
+
+	message = expectFailure(t, func(rt require.TestingT) { diff.Expect(rt, diffContract) })
+	require.Contains(t, message, "isn't part of the state diff")
+}
+
+func TestStateDiffNeedsFromBlock(t *testing.T) {
+	server, anvil, tearDown := setupTestingWithMock(t)
+	defer tearDown()
+
+	_, err := StateDiff(context.Background(), anvil.Client(), WatchAccounts(diffSender), nil, big.NewInt(7))
+	require.ErrorContains(t, err, "needs a from block")
+	require.Empty(t, server.Calls())
+}
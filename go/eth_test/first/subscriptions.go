@@ -0,0 +1,125 @@
+package first
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+const (
+	defaultEventsBuffer    = 128
+	maxResubscribeInterval = 2 * time.Second
+)
+
+// subscribeFunc establishes a subscription delivering events to ch, e.g. ethclient.Client.SubscribeNewHead
+type subscribeFunc[T any] func(ctx context.Context, ch chan<- T) (ethereum.Subscription, error)
+
+// EventCollector buffers the events of a subscription and resubscribes when the connection drops
+type EventCollector[T any] struct {
+	events chan T
+	sub    event.Subscription
+
+	mu           sync.Mutex
+	resubscribed int
+	lastErr      error
+}
+
+func newEventCollector[T any](ctx context.Context, buffer int, subscribe subscribeFunc[T]) (*EventCollector[T], error) {
+	if buffer <= 0 {
+		buffer = defaultEventsBuffer
+	}
+	c := &EventCollector[T]{events: make(chan T, buffer)}
+
+	// Subscribe once synchronously to report setup errors to the caller
+	first, err := subscribe(ctx, c.events)
+	if err != nil {
+		return nil, err
+	}
+	c.sub = event.ResubscribeErr(maxResubscribeInterval, func(ctx context.Context, lastErr error) (event.Subscription, error) {
+		if first != nil {
+			sub := first
+			first = nil
+			return sub, nil
+		}
+		c.mu.Lock()
+		c.lastErr = lastErr
+		c.mu.Unlock()
+
+		sub, err := subscribe(ctx, c.events)
+		if err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		c.resubscribed++
+		c.mu.Unlock()
+		return sub, nil
+	})
+	return c, nil
+}
+
+// CollectNewHeads subscribes to new block headers; client must be connected over WebSocket
+func CollectNewHeads(ctx context.Context, client *ethclient.Client, buffer int) (*EventCollector[*types.Header], error) {
+	return newEventCollector(ctx, buffer, client.SubscribeNewHead)
+}
+
+// CollectLogs subscribes to the logs matching query; client must be connected over WebSocket
+func CollectLogs(ctx context.Context, client *ethclient.Client, query ethereum.FilterQuery, buffer int) (*EventCollector[types.Log], error) {
+	return newEventCollector(ctx, buffer, func(ctx context.Context, ch chan<- types.Log) (ethereum.Subscription, error) {
+		return client.SubscribeFilterLogs(ctx, query, ch)
+	})
+}
+
+// Events gives direct access to the buffered events
+func (c *EventCollector[T]) Events() <-chan T {
+	return c.events
+}
+
+// Expect waits for the next n events. On timeout it returns the events received so far and an error
+func (c *EventCollector[T]) Expect(n int, timeout time.Duration) ([]T, error) {
+	res := make([]T, 0, n)
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for len(res) < n {
+		select {
+		case ev := <-c.events:
+			res = append(res, ev)
+		case <-timer.C:
+			return res, fmt.Errorf("received %d of %d expected events in %s", len(res), n, timeout)
+		}
+	}
+	return res, nil
+}
+
+// ExpectNone fails if any event arrives within d
+func (c *EventCollector[T]) ExpectNone(d time.Duration) error {
+	select {
+	case ev := <-c.events:
+		return fmt.Errorf("unexpected event %v", ev)
+	case <-time.After(d):
+		return nil
+	}
+}
+
+// Resubscribed returns how many times the subscription was reestablished after a failure
+func (c *EventCollector[T]) Resubscribed() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.resubscribed
+}
+
+// LastError returns the error that ended the previous subscription
+func (c *EventCollector[T]) LastError() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastErr
+}
+
+func (c *EventCollector[T]) Close() {
+	c.sub.Unsubscribe()
+}
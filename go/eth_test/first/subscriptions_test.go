@@ -0,0 +1,136 @@
+package first
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeHeadsService serves `eth_subscribe("newHeads")` publishing the headers sent to feed
+type fakeHeadsService struct {
+	feed event.Feed
+}
+
+func (s *fakeHeadsService) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+	sub := notifier.CreateSubscription()
+	headers := make(chan *types.Header)
+	feedSub := s.feed.Subscribe(headers)
+	go func() {
+		defer feedSub.Unsubscribe()
+		for {
+			select {
+			case header := <-headers:
+				notifier.Notify(sub.ID, header)
+			case <-sub.Err():
+				return
+			}
+		}
+	}()
+	return sub, nil
+}
+
+func testHeader(number int64) *types.Header {
+	return &types.Header{
+		Number:     big.NewInt(number),
+		Difficulty: big.NewInt(0),
+		Time:       uint64(fakeChainGenesisTime + number*12),
+	}
+}
+
+func TestCollectNewHeadsOverWebSocket(t *testing.T) {
+	service := &fakeHeadsService{}
+	server := rpc.NewServer()
+	defer server.Stop()
+	require.NoError(t, server.RegisterName("eth", service))
+	httpServer := httptest.NewServer(server.WebsocketHandler([]string{"*"}))
+	defer httpServer.Close()
+
+	client, err := rpc.DialWebsocket(context.Background(), "ws"+strings.TrimPrefix(httpServer.URL, "http"), "")
+	require.NoError(t, err)
+	defer client.Close()
+
+	heads, err := CollectNewHeads(context.Background(), ethclient.NewClient(client), 0)
+	require.NoError(t, err)
+	defer heads.Close()
+
+	for i := int64(1); i <= 3; i++ {
+		require.Equal(t, 1, service.feed.Send(testHeader(i)))
+	}
+	headers, err := heads.Expect(3, time.Second)
+	require.NoError(t, err)
+	for i, header := range headers {
+		require.Equal(t, int64(i+1), header.Number.Int64())
+	}
+	require.NoError(t, heads.ExpectNone(10*time.Millisecond))
+}
+
+func TestEventCollectorExpectTimesOut(t *testing.T) {
+	var feed event.Feed
+	collector, err := newEventCollector(context.Background(), 10, func(ctx context.Context, ch chan<- int) (ethereum.Subscription, error) {
+		return feed.Subscribe(ch), nil
+	})
+	require.NoError(t, err)
+	defer collector.Close()
+
+	feed.Send(1)
+	events, err := collector.Expect(2, 20*time.Millisecond)
+	require.Error(t, err)
+	require.Equal(t, []int{1}, events)
+}
+
+func TestEventCollectorReportsSubscribeError(t *testing.T) {
+	_, err := newEventCollector(context.Background(), 10, func(ctx context.Context, ch chan<- int) (ethereum.Subscription, error) {
+		return nil, rpc.ErrNotificationsUnsupported
+	})
+	require.ErrorIs(t, err, rpc.ErrNotificationsUnsupported)
+}
+
+func TestEventCollectorResubscribesAfterDisconnect(t *testing.T) {
+	var feed event.Feed
+	disconnect := make(chan struct{})
+	errDisconnected := errors.New("disconnected")
+	subscriptions := 0
+	collector, err := newEventCollector(context.Background(), 10, func(ctx context.Context, ch chan<- int) (ethereum.Subscription, error) {
+		subscriptions++
+		if subscriptions == 1 {
+			return event.NewSubscription(func(quit <-chan struct{}) error {
+				select {
+				case <-disconnect:
+					return errDisconnected
+				case <-quit:
+					return nil
+				}
+			}), nil
+		}
+		return feed.Subscribe(ch), nil
+	})
+	require.NoError(t, err)
+	defer collector.Close()
+	require.Equal(t, 0, collector.Resubscribed())
+
+	close(disconnect)
+	require.Eventually(t, func() bool {
+		return collector.Resubscribed() == 1
+	}, time.Second, time.Millisecond)
+	require.ErrorIs(t, collector.LastError(), errDisconnected)
+
+	feed.Send(42)
+	events, err := collector.Expect(1, time.Second)
+	require.NoError(t, err)
+	require.Equal(t, []int{42}, events)
+}
@@ -0,0 +1,44 @@
+package first
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// testClient starts the node selected with DEV_NODE, skipping the test if it lacks any of
+// caps or the prefunded accounts of testData
+func testClient(t *testing.T, caps ...Capability) (client *ethclient.Client, testData *ethTestData, anvil *Anvil, tearDown func()) {
+	t.Helper()
+	requireCapabilities(t, append([]Capability{CapStandardAccounts}, caps...)...)
+	anvil = StartAndConnect()
+	addresses, err := anvil.AvailableAddresses()
+	if err != nil {
+		panic(err)
+	}
+	strAddresses := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		strAddresses = append(strAddresses, address.Hex())
+	}
+	return anvil.EthClient(), &ethTestData{
+			Addresses: strAddresses,
+			PrivateKeys: []string{
+				"0xac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80",
+				"0x59c6995e998f97a5a0044966f0945389dc9e86dae88c7a8412f4603b6b78690d",
+				"0x5de4111afa1a4b94908f83103eb1f1706367c2e68ca870fc3fb9a804cdab365a",
+			},
+		},
+		anvil, func() {
+			anvil.Close()
+		}
+}
+
+// testClientWithBlocks is testClient with 10 mined blocks
+func testClientWithBlocks(t *testing.T, caps ...Capability) (client *ethclient.Client, testData *ethTestData, anvil *Anvil, tearDown func()) {
+	t.Helper()
+	requireCapabilities(t, append([]Capability{CapMine, CapStandardAccounts}, caps...)...)
+	anvil = NewAnvilWithStandardBlocks(10)
+	return anvil.EthClient(), testData, anvil, func() {
+		anvil.Close()
+	}
+}
@@ -0,0 +1,336 @@
+package first
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	callTracer     = "callTracer"
+	prestateTracer = "prestateTracer"
+)
+
+// CallLog is an event emitted by a call frame, collected with the callTracer `withLog` option
+type CallLog struct {
+	Address common.Address `json:"address"`
+	Topics  []common.Hash  `json:"topics"`
+	Data    hexutil.Bytes  `json:"data"`
+}
+
+// CallFrame is a node of the call tree returned by the callTracer
+type CallFrame struct {
+	Type         string          `json:"type"`
+	From         common.Address  `json:"from"`
+	To           *common.Address `json:"to,omitempty"`
+	Value        *hexutil.Big    `json:"value,omitempty"`
+	Gas          hexutil.Uint64  `json:"gas"`
+	GasUsed      hexutil.Uint64  `json:"gasUsed"`
+	Input        hexutil.Bytes   `json:"input"`
+	Output       hexutil.Bytes   `json:"output,omitempty"`
+	Error        string          `json:"error,omitempty"`
+	RevertReason string          `json:"revertReason,omitempty"`
+	Calls        []CallFrame     `json:"calls,omitempty"`
+	Logs         []CallLog       `json:"logs,omitempty"`
+}
+
+// Failed is true if the frame reverted or ran into an error
+func (f *CallFrame) Failed() bool {
+	return f.Error != ""
+}
+
+// Reason returns the revert reason reported by the node or decoded from the `Error(string)` output
+func (f *CallFrame) Reason() string {
+	if f.RevertReason != "" {
+		return f.RevertReason
+	}
+	if reason, err := abi.UnpackRevert(f.Output); err == nil {
+		return reason
+	}
+	return ""
+}
+
+// Selector returns the 4 bytes method id of the input, nil for plain transfers
+func (f *CallFrame) Selector() []byte {
+	if len(f.Input) < 4 {
+		return nil
+	}
+	return f.Input[:4]
+}
+
+// Walk visits frames depth-first; the callback returns false to stop descending into a frame's sub-calls
+func (f *CallFrame) Walk(visit func(frame *CallFrame, depth int) bool) {
+	f.walk(visit, 0)
+}
+
+func (f *CallFrame) walk(visit func(frame *CallFrame, depth int) bool, depth int) {
+	if !visit(f, depth) {
+		return
+	}
+	for i := range f.Calls {
+		f.Calls[i].walk(visit, depth+1)
+	}
+}
+
+// String formats the call tree, one frame per line indented by depth
+func (f *CallFrame) String() string {
+	var buf strings.Builder
+	f.Walk(func(frame *CallFrame, depth int) bool {
+		buf.WriteString(strings.Repeat("  ", depth))
+		to := "<none>"
+		if frame.To != nil {
+			to = frame.To.Hex()
+		}
+		fmt.Fprintf(&buf, "%s %s -> %s", frame.Type, frame.From.Hex(), to)
+		if selector := frame.Selector(); selector != nil {
+			fmt.Fprintf(&buf, " %s", hexutil.Encode(selector))
+		}
+		if frame.Value != nil && frame.Value.ToInt().Sign() != 0 {
+			fmt.Fprintf(&buf, " value=%s", frame.Value.ToInt())
+		}
+		fmt.Fprintf(&buf, " gas=%d/%d", uint64(frame.GasUsed), uint64(frame.Gas))
+		if frame.Failed() {
+			fmt.Fprintf(&buf, " error=%q", frame.Error)
+			if reason := frame.Reason(); reason != "" {
+				fmt.Fprintf(&buf, " reason=%q", reason)
+			}
+		}
+		buf.WriteString("\n")
+		return true
+	})
+	return buf.String()
+}
+
+// MethodSelector returns the 4 bytes method id of a signature like "transfer(address,uint256)"
+func MethodSelector(signature string) []byte {
+	return crypto.Keccak256([]byte(signature))[:4]
+}
+
+// FindCalls returns all the frames calling signature on contract
+func FindCalls(trace *CallFrame, contract common.Address, signature string) []*CallFrame {
+	selector := MethodSelector(signature)
+	res := make([]*CallFrame, 0)
+	trace.Walk(func(frame *CallFrame, depth int) bool {
+		if frame.To != nil && *frame.To == contract && bytes.Equal(frame.Selector(), selector) {
+			res = append(res, frame)
+		}
+		return true
+	})
+	return res
+}
+
+// ExpectCalled returns an error with the call tree if signature wasn't called on contract
+// anywhere in it
+func ExpectCalled(trace *CallFrame, contract common.Address, signature string) error {
+	if len(FindCalls(trace, contract, signature)) == 0 {
+		return fmt.Errorf("%s was not called on %s; call tree:\n%s", signature, contract.Hex(), trace)
+	}
+	return nil
+}
+
+// RequireCalled fails the test if signature wasn't called on contract anywhere in the call tree
+func RequireCalled(t require.TestingT, trace *CallFrame, contract common.Address, signature string) {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+	require.NoError(t, ExpectCalled(trace, contract, signature))
+}
+
+// AccountState is the state of an account as reported by the prestateTracer
+type AccountState struct {
+	Balance *hexutil.Big                `json:"balance,omitempty"`
+	Nonce   uint64                      `json:"nonce,omitempty"`
+	Code    hexutil.Bytes               `json:"code,omitempty"`
+	Storage map[common.Hash]common.Hash `json:"storage,omitempty"`
+}
+
+// Prestate is the state of the accounts touched by a transaction before its execution
+type Prestate map[common.Address]*AccountState
+
+// PrestateDiff is the prestateTracer result in diff mode; only the changed fields are present
+type PrestateDiff struct {
+	Pre  Prestate `json:"pre"`
+	Post Prestate `json:"post"`
+}
+
+type StorageChange struct {
+	Slot   common.Hash
+	Before common.Hash
+	After  common.Hash
+}
+
+// StorageChanges returns the modified slots per account ordered by slot
+func (d *PrestateDiff) StorageChanges() map[common.Address][]StorageChange {
+	res := make(map[common.Address][]StorageChange)
+	addresses := make(map[common.Address]bool)
+	for address := range d.Pre {
+		addresses[address] = true
+	}
+	for address := range d.Post {
+		addresses[address] = true
+	}
+	for address := range addresses {
+		pre, post := d.Pre[address], d.Post[address]
+		slots := make(map[common.Hash]bool)
+		if pre != nil {
+			for slot := range pre.Storage {
+				slots[slot] = true
+			}
+		}
+		if post != nil {
+			for slot := range post.Storage {
+				slots[slot] = true
+			}
+		}
+		changes := make([]StorageChange, 0, len(slots))
+		for slot := range slots {
+			change := StorageChange{Slot: slot}
+			if pre != nil {
+				change.Before = pre.Storage[slot]
+			}
+			// Slots missing from post in diff mode were cleared
+			if post != nil {
+				change.After = post.Storage[slot]
+			}
+			if change.Before != change.After {
+				changes = append(changes, change)
+			}
+		}
+		if len(changes) > 0 {
+			sort.Slice(changes, func(i, j int) bool {
+				return bytes.Compare(changes[i].Slot[:], changes[j].Slot[:]) < 0
+			})
+			res[address] = changes
+		}
+	}
+	return res
+}
+
+// BalanceChange returns the balance difference of address; zero if unchanged
+func (d *PrestateDiff) BalanceChange(address common.Address) *big.Int {
+	pre, post := d.Pre[address], d.Post[address]
+	if post == nil || post.Balance == nil {
+		return new(big.Int)
+	}
+	before := new(big.Int)
+	if pre != nil && pre.Balance != nil {
+		before = pre.Balance.ToInt()
+	}
+	return new(big.Int).Sub(post.Balance.ToInt(), before)
+}
+
+type tracerConfig struct {
+	Tracer       string                 `json:"tracer"`
+	TracerConfig map[string]interface{} `json:"tracerConfig,omitempty"`
+}
+
+func (g *Anvil) traceTransaction(ctx context.Context, txHash common.Hash, config tracerConfig, result interface{}) error {
+	err := g.Profile().require(CapTracing)
+	if err != nil {
+		return err
+	}
+	return g.c.CallContext(ctx, result, "debug_traceTransaction", txHash, config)
+}
+
+func (g *Anvil) traceCall(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int, config tracerConfig, result interface{}) error {
+	err := g.Profile().require(CapTracing)
+	if err != nil {
+		return err
+	}
+	return g.c.CallContext(ctx, result, "debug_traceCall", toCallArg(msg), toBlockNumArg(blockNumber), config)
+}
+
+// TraceTransaction returns the call tree of a mined transaction
+func (g *Anvil) TraceTransaction(ctx context.Context, txHash common.Hash) (*CallFrame, error) {
+	var res CallFrame
+	err := g.traceTransaction(ctx, txHash, tracerConfig{Tracer: callTracer, TracerConfig: map[string]interface{}{"withLog": true}}, &res)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// TraceCall executes msg on top of blockNumber, nil for latest, and returns the call tree
+func (g *Anvil) TraceCall(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) (*CallFrame, error) {
+	var res CallFrame
+	err := g.traceCall(ctx, msg, blockNumber, tracerConfig{Tracer: callTracer, TracerConfig: map[string]interface{}{"withLog": true}}, &res)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// TracePrestate returns the state of the accounts touched by the transaction before its execution
+func (g *Anvil) TracePrestate(ctx context.Context, txHash common.Hash) (Prestate, error) {
+	res := make(Prestate)
+	err := g.traceTransaction(ctx, txHash, tracerConfig{Tracer: prestateTracer}, &res)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// TraceStateDiff returns the state changed by the transaction
+func (g *Anvil) TraceStateDiff(ctx context.Context, txHash common.Hash) (*PrestateDiff, error) {
+	var res PrestateDiff
+	err := g.traceTransaction(ctx, txHash, tracerConfig{Tracer: prestateTracer, TracerConfig: map[string]interface{}{"diffMode": true}}, &res)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// TraceCallPrestate returns the state of the accounts touched by msg executed on top of blockNumber
+func (g *Anvil) TraceCallPrestate(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) (Prestate, error) {
+	res := make(Prestate)
+	err := g.traceCall(ctx, msg, blockNumber, tracerConfig{Tracer: prestateTracer}, &res)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func toCallArg(msg ethereum.CallMsg) interface{} {
+	arg := map[string]interface{}{
+		"from": msg.From,
+	}
+	if msg.To != nil {
+		arg["to"] = msg.To
+	}
+	if len(msg.Data) > 0 {
+		arg["data"] = hexutil.Bytes(msg.Data)
+	}
+	if msg.Value != nil {
+		arg["value"] = (*hexutil.Big)(msg.Value)
+	}
+	if msg.Gas != 0 {
+		arg["gas"] = hexutil.Uint64(msg.Gas)
+	}
+	if msg.GasPrice != nil {
+		arg["gasPrice"] = (*hexutil.Big)(msg.GasPrice)
+	}
+	if msg.GasFeeCap != nil {
+		arg["maxFeePerGas"] = (*hexutil.Big)(msg.GasFeeCap)
+	}
+	if msg.GasTipCap != nil {
+		arg["maxPriorityFeePerGas"] = (*hexutil.Big)(msg.GasTipCap)
+	}
+	return arg
+}
+
+func toBlockNumArg(number *big.Int) string {
+	if number == nil {
+		return "latest"
+	}
+	return hexutil.EncodeBig(number)
+}
@@ -0,0 +1,96 @@
+package first
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testTokenAddress   = common.HexToAddress("0x5FbDB2315678afecb367f032d93F642f64180aa3")
+	testBalancesHolder = common.HexToAddress("0xe7f1725E7734CE288F8367e1Bb143E90bb3F0512")
+)
+
+func TestTraceTransactionCallTree(t *testing.T) {
+	server, anvil, tearDown := setupTestingWithMock(t)
+	defer tearDown()
+	respondWithFixture(t, server, "debug_traceTransaction", "testdata/call_trace.json")
+
+	txHash := common.HexToHash("0x01")
+	trace, err := anvil.TraceTransaction(context.Background(), txHash)
+	require.NoError(t, err)
+	server.ExpectCall(t, "debug_traceTransaction", txHash, map[string]interface{}{
+		"tracer":       "callTracer",
+		"tracerConfig": map[string]interface{}{"withLog": true},
+	})
+
+	require.Equal(t, "CALL", trace.Type)
+	require.Equal(t, uint64(0x8c35), uint64(trace.GasUsed))
+	require.True(t, trace.Failed())
+	require.Equal(t, "insufficient balance", trace.Reason())
+	require.Equal(t, 1, len(trace.Calls))
+	require.False(t, trace.Calls[0].Failed())
+
+	RequireCalled(t, trace, testTokenAddress, "transfer(address,uint256)")
+	RequireCalled(t, trace, testBalancesHolder, "balanceOf(address)")
+	require.Empty(t, FindCalls(trace, testTokenAddress, "approve(address,uint256)"))
+	require.ErrorContains(t, ExpectCalled(trace, testTokenAddress, "approve(address,uint256)"), "approve(address,uint256) was not called on 0x5FbDB2315678afecb367f032d93F642f64180aa3")
+
+	printed := trace.String()
+	require.Contains(t, printed, "CALL 0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266 -> 0x5FbDB2315678afecb367f032d93F642f64180aa3 0xa9059cbb gas=35893/500000")
+	require.Contains(t, printed, `reason="insufficient balance"`)
+	require.Contains(t, printed, "\n  STATICCALL ")
+}
+
+func TestTraceCallSendsCallArgs(t *testing.T) {
+	server, anvil, tearDown := setupTestingWithMock(t)
+	defer tearDown()
+	respondWithFixture(t, server, "debug_traceCall", "testdata/call_trace.json")
+
+	from := common.HexToAddress("0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266")
+	_, err := anvil.TraceCall(context.Background(), ethereum.CallMsg{
+		From:  from,
+		To:    &testTokenAddress,
+		Value: big.NewInt(16),
+		Data:  []byte{0xa9, 0x05, 0x9c, 0xbb},
+	}, big.NewInt(2))
+	require.NoError(t, err)
+	server.ExpectCall(t, "debug_traceCall",
+		map[string]interface{}{
+			"from":  from,
+			"to":    testTokenAddress,
+			"value": "0x10",
+			"data":  "0xa9059cbb",
+		},
+		"0x2",
+		map[string]interface{}{
+			"tracer":       "callTracer",
+			"tracerConfig": map[string]interface{}{"withLog": true},
+		})
+}
+
+func TestTraceStateDiff(t *testing.T) {
+	server, anvil, tearDown := setupTestingWithMock(t)
+	defer tearDown()
+	respondWithFixture(t, server, "debug_traceTransaction", "testdata/prestate_diff.json")
+
+	diff, err := anvil.TraceStateDiff(context.Background(), common.HexToHash("0x01"))
+	require.NoError(t, err)
+
+	sender := common.HexToAddress("0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266")
+	require.Equal(t, big.NewInt(-100), diff.BalanceChange(sender))
+	require.Equal(t, uint64(2), diff.Post[sender].Nonce)
+	require.Equal(t, 0, diff.BalanceChange(testTokenAddress).Sign())
+
+	changes := diff.StorageChanges()
+	require.Equal(t, 1, len(changes))
+	require.Equal(t, []StorageChange{
+		{Slot: common.BigToHash(big.NewInt(1)), Before: common.BigToHash(big.NewInt(100)), After: common.Hash{}},
+		{Slot: common.BigToHash(big.NewInt(2)), Before: common.BigToHash(big.NewInt(5)), After: common.Hash{}},
+		{Slot: common.BigToHash(big.NewInt(3)), Before: common.Hash{}, After: common.BigToHash(big.NewInt(100))},
+	}, changes[testTokenAddress])
+}
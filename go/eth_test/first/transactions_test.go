@@ -2,7 +2,6 @@ package first
 
 import (
 	"context"
-	"crypto/ecdsa"
 	"fmt"
 	"math/big"
 	"testing"
@@ -10,7 +9,6 @@ import (
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/stretchr/testify/require"
 )
@@ -18,7 +16,7 @@ import (
 func TestTransactionQueryAllInBlock(t *testing.T) {
 	t.Skip("TODO: implement generateTransactions")
 
-	client, _, anvil, tearDown := testClient()
+	client, _, anvil, tearDown := testClient(t, CapMine)
 	defer tearDown()
 
 	addresses, err := anvil.AvailableAddresses()
@@ -63,11 +61,11 @@ func TestTransactionQueryAllInBlock(t *testing.T) {
 		chainID, err := client.NetworkID(context.Background())
 		require.NoError(t, err)
 		fmt.Println("@dd chainID", chainID)
-		// The AsMessage method requires the EIP155 signer, which we derive the chain ID from the client.
-		msg, err := tx.AsMessage(types.NewEIP155Signer(chainID), nil)
+		// Recovering the sender requires a signer for the chain ID, which we derive from the client.
+		from, err := types.Sender(types.LatestSignerForChainID(chainID), tx)
 		require.NoError(t, err)
 		// TODO: check sender address
-		fmt.Println("@dd from", msg.From().Hex()) // 0x4Bf2eDd5b99F84fe8406830DC0f8dDb09958C439
+		fmt.Println("@dd from", from.Hex()) // 0x4Bf2eDd5b99F84fe8406830DC0f8dDb09958C439
 
 		// Each transaction has a receipt which contains the result of the execution of the transaction, such as any return values and logs, as well as the status which will be 1 (success) or 0 (fail).
 		receipt, err := client.TransactionReceipt(context.Background(), tx.Hash())
@@ -80,7 +78,7 @@ func TestTransactionQueryAllInBlock(t *testing.T) {
 
 func TestTransactionByHash(t *testing.T) {
 	t.Skip("TODO: implement generateTransactions")
-	client, _, anvil, tearDown := testClient()
+	client, _, anvil, tearDown := testClient(t, CapMine)
 	defer tearDown()
 
 	addresses, err := anvil.AvailableAddresses()
@@ -109,7 +107,7 @@ func TestTransactionByHash(t *testing.T) {
 
 // See https://geth.ethereum.org/docs/developers/dapp-developer/native
 func TestTransactionCreate(t *testing.T) {
-	client, testData, _, tearDown := testClient()
+	client, testData, anvil, tearDown := testClient(t, CapMine)
 	defer tearDown()
 
 	ctx := context.Background()
@@ -132,16 +130,12 @@ func TestTransactionCreate(t *testing.T) {
 	eventsCount := getEventsCount()
 	require.Equal(t, 0, eventsCount)
 
-	// load private key.
-	privateKey, err := crypto.HexToECDSA(testData.PrivateKeys[0][2:])
+	// load private key, the sender signs and reports the gas of the test
+	sender, err := NewTxSender(client, testData.PrivateKeys[0], t.Name())
 	require.NoError(t, err)
-	senderPublicKey := privateKey.Public()
-	senderPublicKeyECDSA, ok := senderPublicKey.(*ecdsa.PublicKey)
-	require.True(t, ok)
-	fromAddress := crypto.PubkeyToAddress(*senderPublicKeyECDSA)
 
 	// get the account nonce
-	nonce, err := client.PendingNonceAt(ctx, fromAddress)
+	nonce, err := client.PendingNonceAt(ctx, sender.From())
 	require.NoError(t, err)
 
 	gasLimit := uint64(21000)
@@ -155,11 +149,9 @@ func TestTransactionCreate(t *testing.T) {
 	// create transaction
 	value := new(big.Int).Mul(big.NewInt(1), big.NewInt(params.Ether))
 	toAddress := common.HexToAddress(testData.Addresses[1])
-	chainID, err := client.ChainID(ctx)
-	require.NoError(t, err)
 
 	tx := types.NewTx(&types.DynamicFeeTx{
-		ChainID:   chainID,
+		ChainID:   sender.ChainID(),
 		Nonce:     nonce,
 		GasTipCap: tipCap,
 		GasFeeCap: feeCap,
@@ -168,13 +160,48 @@ func TestTransactionCreate(t *testing.T) {
 		Value:     value,
 		Data:      nil,
 	})
-	signedTx, err := types.SignTx(tx, types.NewLondonSigner(chainID), privateKey)
+
+	// sign and broadcast transaction
+	signedTx, err := sender.SendTx(ctx, tx)
 	require.NoError(t, err)
 
-	// broadcast transaction
-	err = client.SendTransaction(ctx, signedTx)
+	require.NoError(t, anvil.MineBlocks(1, DefaultBlockTime))
+	receipt, err := sender.WaitForReceipt(ctx, signedTx)
 	require.NoError(t, err)
+	require.Equal(t, types.ReceiptStatusSuccessful, receipt.Status)
 
+	// a plain transfer emits no Transfer event
 	eventsCount = getEventsCount()
 	require.Equal(t, 0, eventsCount)
 }
+
+func TestTransactionSendAndWaitForReceipt(t *testing.T) {
+	client, testData, anvil, tearDown := testClient(t, CapMine)
+	defer tearDown()
+
+	ctx := context.Background()
+	sender, err := NewTxSender(client, testData.PrivateKeys[0], t.Name())
+	require.NoError(t, err)
+
+	toAddress := common.HexToAddress(testData.Addresses[1])
+	fromBlock, err := client.BlockNumber(ctx)
+	require.NoError(t, err)
+	tx, err := sender.Send(ctx, &toAddress, ethToWei(1), nil)
+	require.NoError(t, err)
+
+	// Anvil doesn't mine on its own
+	err = anvil.MineBlocks(1, DefaultBlockTime)
+	require.NoError(t, err)
+
+	receipt, err := sender.WaitForReceipt(ctx, tx)
+	require.NoError(t, err)
+	require.Equal(t, types.ReceiptStatusSuccessful, receipt.Status)
+	require.Equal(t, uint64(21000), receipt.GasUsed)
+
+	bystander := common.HexToAddress(testData.Addresses[2])
+	diff, err := StateDiff(ctx, anvil.Client(), WatchAccounts(sender.From(), toAddress, bystander), new(big.Int).SetUint64(fromBlock), nil)
+	require.NoError(t, err)
+	diff.Expect(t, sender.From()).LostPlusGas(ethToWei(1)).NonceIncreasedBy(1)
+	diff.Expect(t, toAddress).Gained(ethToWei(1))
+	diff.Expect(t, bystander).Unchanged()
+}
@@ -0,0 +1,196 @@
+package first
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+const receiptPollInterval = 10 * time.Millisecond
+
+// ReceiptHook is called for every receipt obtained by TxSender.WaitForReceipt.
+// label identifies the sender, usually the test name
+type ReceiptHook func(label string, tx *types.Transaction, receipt *types.Receipt)
+
+var (
+	receiptHooksMu sync.Mutex
+	// receiptHooks holds pointers so that a hook can be told apart from the same func added twice
+	receiptHooks []*ReceiptHook
+)
+
+// AddReceiptHook registers hook for all the senders until the returned function is called
+func AddReceiptHook(hook ReceiptHook) (remove func()) {
+	receiptHooksMu.Lock()
+	defer receiptHooksMu.Unlock()
+	registered := &hook
+	receiptHooks = append(receiptHooks, registered)
+	return func() {
+		receiptHooksMu.Lock()
+		defer receiptHooksMu.Unlock()
+		for i, h := range receiptHooks {
+			if h == registered {
+				receiptHooks = append(receiptHooks[:i:i], receiptHooks[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+func notifyReceiptHooks(label string, tx *types.Transaction, receipt *types.Receipt) {
+	receiptHooksMu.Lock()
+	hooks := append([]*ReceiptHook(nil), receiptHooks...)
+	receiptHooksMu.Unlock()
+	for _, hook := range hooks {
+		(*hook)(label, tx, receipt)
+	}
+}
+
+// TxSender signs and sends EIP-1559 transactions from a single account
+type TxSender struct {
+	// Label is passed to the receipt hooks
+	Label string
+
+	client     *ethclient.Client
+	privateKey *ecdsa.PrivateKey
+	from       common.Address
+	chainID    *big.Int
+}
+
+// NewTxSender loads a hex private key, with or without 0x prefix. label identifies the
+// sender in the receipt hooks, e.g. t.Name() to report the gas used by each test
+func NewTxSender(client *ethclient.Client, privateKeyHex string, label string) (*TxSender, error) {
+	privateKey, err := crypto.HexToECDSA(strings.TrimPrefix(privateKeyHex, "0x"))
+	if err != nil {
+		return nil, err
+	}
+	chainID, err := client.ChainID(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return &TxSender{
+		Label:      label,
+		client:     client,
+		privateKey: privateKey,
+		from:       crypto.PubkeyToAddress(privateKey.PublicKey),
+		chainID:    chainID,
+	}, nil
+}
+
+func (s *TxSender) From() common.Address {
+	return s.from
+}
+
+func (s *TxSender) ChainID() *big.Int {
+	return s.chainID
+}
+
+// txFees are the common fields of the EIP-1559 style transactions
+type txFees struct {
+	nonce    uint64
+	tipCap   *big.Int
+	feeCap   *big.Int
+	gasLimit uint64
+}
+
+// prepare fetches the nonce and fees and estimates the gas of msg
+func (s *TxSender) prepare(ctx context.Context, msg ethereum.CallMsg) (*txFees, error) {
+	nonce, err := s.client.PendingNonceAt(ctx, s.from)
+	if err != nil {
+		return nil, err
+	}
+	tipCap, err := s.client.SuggestGasTipCap(ctx)
+	if err != nil {
+		return nil, err
+	}
+	feeCap, err := s.client.SuggestGasPrice(ctx)
+	if err != nil {
+		return nil, err
+	}
+	msg.From = s.from
+	gasLimit, err := s.client.EstimateGas(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+	return &txFees{nonce: nonce, tipCap: tipCap, feeCap: feeCap, gasLimit: gasLimit}, nil
+}
+
+// Send signs and broadcasts a transaction; to is nil for contract creation. Gas is estimated
+func (s *TxSender) Send(ctx context.Context, to *common.Address, value *big.Int, data []byte) (*types.Transaction, error) {
+	if value == nil {
+		value = new(big.Int)
+	}
+	fees, err := s.prepare(ctx, ethereum.CallMsg{
+		To:    to,
+		Value: value,
+		Data:  data,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tx := types.NewTx(&types.DynamicFeeTx{
+		ChainID:   s.chainID,
+		Nonce:     fees.nonce,
+		GasTipCap: fees.tipCap,
+		GasFeeCap: fees.feeCap,
+		Gas:       fees.gasLimit,
+		To:        to,
+		Value:     value,
+		Data:      data,
+	})
+	return s.SendTx(ctx, tx)
+}
+
+// SendTx signs and broadcasts an already built transaction
+func (s *TxSender) SendTx(ctx context.Context, tx *types.Transaction) (*types.Transaction, error) {
+	signedTx, err := types.SignTx(tx, types.LatestSignerForChainID(s.chainID), s.privateKey)
+	if err != nil {
+		return nil, err
+	}
+	err = s.client.SendTransaction(ctx, signedTx)
+	if err != nil {
+		return nil, err
+	}
+	return signedTx, nil
+}
+
+// WaitForReceipt polls until tx is mined or ctx is done. Anvil runs with
+// `--no-mining` so blocks have to be mined for the receipt to be available
+func (s *TxSender) WaitForReceipt(ctx context.Context, tx *types.Transaction) (*types.Receipt, error) {
+	receipt, err := WaitForReceipt(ctx, s.client, tx.Hash())
+	if err != nil {
+		return nil, err
+	}
+	notifyReceiptHooks(s.Label, tx, receipt)
+	return receipt, nil
+}
+
+// WaitForReceipt polls for the receipt of txHash until it is available or ctx is done
+func WaitForReceipt(ctx context.Context, client *ethclient.Client, txHash common.Hash) (*types.Receipt, error) {
+	ticker := time.NewTicker(receiptPollInterval)
+	defer ticker.Stop()
+	for {
+		receipt, err := client.TransactionReceipt(ctx, txHash)
+		if err == nil {
+			return receipt, nil
+		}
+		if !errors.Is(err, ethereum.NotFound) {
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}